@@ -9,8 +9,10 @@ import (
 	"os"
 	"os/signal"
 	"profile/internal/app"
+	"profile/internal/clients/events"
 	"profile/internal/config"
-	"profile/internal/lib/logger/sl"
+	"sso/pkg/logger/sl"
+	"sso/pkg/tracing"
 	"syscall"
 	"time"
 )
@@ -30,15 +32,51 @@ func main() {
 
 	// Initialize logger
 	log := setupLogger(cfg.Env)
+	// the package-level slog calls are redacted too
+	slog.SetDefault(log)
+
+	// spans are exported in the background, the rest are flushed on shutdown
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	}, "profile")
+	if err != nil {
+		panic(err)
+	}
 
 	// Initialize app
-	application := app.New(log, cfg.GRPC.Port, cfg.HTTPServer.Port, cfg.DSN, cfg.JWT.TokenTTL)
+	application := app.New(log, cfg.GRPC.Port, cfg.HTTPServer.Port, cfg.DSN, cfg.JWT.TokenTTL, cfg.JWT.Audience, cfg.PolicyPath, cfg.Authz.Rules, cfg.SSO.Addr, cfg.SSO.APIKey, cfg.SSO.Timeout, app.EventsConfig{
+		Enabled: cfg.Events.Enabled,
+		Consumer: events.Config{
+			URL:           cfg.Events.NATSURL,
+			SubjectPrefix: cfg.Events.SubjectPrefix,
+			Queue:         cfg.Events.Queue,
+			JetStream:     cfg.Events.JetStream,
+			Stream:        cfg.Events.Stream,
+			Timeout:       cfg.Events.Timeout,
+		},
+		Retention: cfg.Events.Retention,
+	})
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	grp, grpCtx := errgroup.WithContext(ctx)
 
+	// method policy is reloaded on SIGHUP
+	go application.Policy.ReloadOnSIGHUP(grpCtx, log)
+
+	// profiles are provisioned from the sso user events
+	if application.Events != nil {
+		go application.Provisioning.Run(grpCtx, cfg.Events.CleanupInterval)
+
+		grp.Go(func() error {
+			return application.Events.Run(grpCtx)
+		})
+	}
+
 	// Launch gRPC
 	grp.Go(func() error {
 		log.Info("starting gRPC server", slog.Int("port", cfg.GRPC.Port))
@@ -96,29 +134,43 @@ func main() {
 		log.Error("server exited with error", sl.Err(err))
 	}
 
-	// initiate a graceful shutdown
+	// initiate a graceful shutdown, events in flight finish before the storage is closed
+	if err := application.CloseEvents(); err != nil {
+		log.Error("failed to close events connection", sl.Err(err))
+	}
 	err = application.CloseStorage()
 	if err != nil {
 		log.Error("failed to close storage", sl.Err(err))
 	}
+	if err := application.CloseSSO(); err != nil {
+		log.Error("failed to close sso connection", sl.Err(err))
+	}
+
+	tracingCtx, tracingCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer tracingCancel()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		log.Error("failed to flush spans", sl.Err(err))
+	}
+
 	log.Info("Gracefully stopped")
 }
 
+// setupLogger returns the logger of the environment, the personal data is visible only in local
 func setupLogger(env string) *slog.Logger {
 	var log *slog.Logger
 
 	switch env {
 	case envLocal:
 		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
+			sl.NewContextHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})),
 		)
 	case envDev:
 		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
+			sl.NewContextHandler(sl.NewRedactHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))),
 		)
 	case envProd:
 		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
+			sl.NewContextHandler(sl.NewRedactHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))),
 		)
 	}
 
@@ -7,9 +7,15 @@ import (
 	"os"
 	"os/signal"
 	grpcapp "profile/internal/app/grpc"
-	"profile/internal/lib/logger/sl"
+	"profile/internal/clients/events"
+	"profile/internal/clients/sso"
+	"profile/internal/lib/authz"
+	"profile/internal/lib/metrics"
 	"profile/internal/services/profile"
+	"profile/internal/services/provisioning"
 	"profile/internal/storage/postgres"
+	"sso/pkg/logger/sl"
+	"sso/pkg/policy"
 	"syscall"
 	"time"
 
@@ -20,34 +26,80 @@ type App struct {
 	GRPCServer *grpcapp.App
 	Storage    *postgres.Storage
 	HTTPServer *httpserver.Server
-	log        *slog.Logger
+	Policy     *policy.Store
+	SSO        *sso.Client
+	// Provisioning and Events are set when the sso events are consumed
+	Provisioning *provisioning.Service
+	Events       *events.Consumer
+	log          *slog.Logger
 }
 
+// EventsConfig is the subscription to the sso user events
+type EventsConfig struct {
+	Enabled   bool
+	Consumer  events.Config
+	Retention time.Duration
+}
+
+// policySection is the section of the shared method policy file for profile
+const policySection = "profile"
+
 func New(
 	log *slog.Logger,
 	grpcPort int,
 	httpPort int,
 	dsn string,
 	tokenTTL time.Duration,
+	audience string,
+	policyPath string,
+	authzRules map[string]string,
+	ssoAddr string,
+	ssoAPIKey string,
+	ssoTimeout time.Duration,
+	eventsConfig EventsConfig,
 ) *App {
+	policies := policy.MustLoad(policyPath, policySection)
+
 	storage, err := postgres.New(dsn, log)
 	if err != nil {
 		panic(err)
 	}
+	metrics.Registry.MustRegister(metrics.NewPoolCollector(storage))
+
+	authorizer := authz.MustNew(log, authzRules)
+	log.Info("authorization rules loaded", slog.Any("actions", authorizer.Actions()))
+
+	profileService := profile.New(log, storage, authorizer)
 
-	profileService := profile.New(log, storage)
+	ssoClient, err := sso.New(log, ssoAddr, ssoAPIKey, ssoTimeout)
+	if err != nil {
+		panic(err)
+	}
 
-	grpcApp := grpcapp.New(log, profileService, grpcPort)
+	grpcApp := grpcapp.New(log, profileService, policies, ssoClient, audience, grpcPort)
 
 	grpcAddr := fmt.Sprintf("localhost:%d", grpcPort)
 	httpServer := httpserver.NewServer(grpcAddr, httpPort, log)
 
-	return &App{
+	application := &App{
 		GRPCServer: grpcApp,
 		HTTPServer: httpServer,
 		Storage:    storage,
+		Policy:     policies,
+		SSO:        ssoClient,
 		log:        log,
 	}
+
+	if eventsConfig.Enabled {
+		application.Provisioning = provisioning.New(log, storage, eventsConfig.Retention)
+
+		application.Events, err = events.New(log, eventsConfig.Consumer, application.Provisioning.Handle)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return application
 }
 
 func (a *App) CloseStorage() error {
@@ -61,6 +113,22 @@ func (a *App) CloseStorage() error {
 	return nil
 }
 
+// CloseSSO closes the connection to sso
+func (a *App) CloseSSO() error {
+	if a.SSO != nil {
+		return a.SSO.Close()
+	}
+	return nil
+}
+
+// CloseEvents closes the connection to the events broker
+func (a *App) CloseEvents() error {
+	if a.Events != nil {
+		return a.Events.Close()
+	}
+	return nil
+}
+
 func (a *App) Stop() {
 	const op = "app.Stop"
 
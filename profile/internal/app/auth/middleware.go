@@ -8,6 +8,10 @@ import (
 	"strings"
 	"time"
 
+	"profile/internal/clients/sso"
+	"sso/pkg/policy"
+	"sso/pkg/requestid"
+
 	"github.com/golang-jwt/jwt/v5"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -21,24 +25,52 @@ type Claims struct {
 	Name        string          `json:"name"`
 	AppID       int32           `json:"app_id"`
 	Permissions json.RawMessage `json:"permissions"` // Use RawMessage to handle different formats
+	Actor       *Actor          `json:"act,omitempty"` // staff member impersonating the user
 	jwt.RegisteredClaims
 }
 
+// Actor is the party acting on behalf of the user (RFC 8693 "act" claim)
+type Actor struct {
+	Subject string `json:"sub"`
+	UserID  int64  `json:"user_id"`
+}
+
 type Permission struct {
 	ID   int64  `json:"id"`
 	Name string `json:"name"`
 }
 
+// SSOClient resolves the identity of x-api-key callers and the permissions of users
+// whose tokens are issued without them (app embed_permissions is false)
+type SSOClient interface {
+	ValidateAPIKey(ctx context.Context, key string) (sso.APIKey, error)
+	UserPermissions(ctx context.Context, userID int64) ([]string, error)
+}
+
+const apiKeyMetadataKey = "x-api-key"
+
 type JWTValidator struct {
 	secretKey []byte
+	audience  string
+	ssoClient SSOClient
 }
 
-func NewJWTValidator(secretKey string) *JWTValidator {
+// NewJWTValidator creates validator accepting only tokens issued for the audience
+// callers without a token may use an api key checked by sso
+func NewJWTValidator(secretKey string, audience string, ssoClient SSOClient) *JWTValidator {
 	return &JWTValidator{
 		secretKey: []byte(secretKey),
+		audience:  audience,
+		ssoClient: ssoClient,
 	}
 }
 
+// HasPermissionsClaim reports whether the token carries the permissions, an empty list included,
+// sso leaves the claim out only for the apps with embed_permissions off
+func (c *Claims) HasPermissionsClaim() bool {
+	return len(c.Permissions) > 0 && string(c.Permissions) != "null"
+}
+
 // ParsePermissions парсит permissions из разных форматов
 func (c *Claims) ParsePermissions() []Permission {
 	if len(c.Permissions) == 0 {
@@ -82,7 +114,7 @@ func (v *JWTValidator) ValidateToken(tokenString string) (*Claims, error) {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return v.secretKey, nil
-	})
+	}, jwt.WithAudience(v.audience))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -101,8 +133,13 @@ func (v *JWTValidator) ValidateToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
-// AuthInterceptor - gRPC middleware для проверки JWT
-func (v *JWTValidator) AuthInterceptor() grpc.UnaryServerInterceptor {
+// RuleProvider returns permission rule of the full gRPC method name
+type RuleProvider interface {
+	Rule(method string) policy.Rule
+}
+
+// AuthInterceptor - gRPC middleware для проверки JWT и правил политики методов
+func (v *JWTValidator) AuthInterceptor(rules RuleProvider) grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
 		req interface{},
@@ -111,7 +148,33 @@ func (v *JWTValidator) AuthInterceptor() grpc.UnaryServerInterceptor {
 	) (interface{}, error) {
 
 		// Пропускаем публичные эндпоинты
-		if v.isPublicEndpoint(info.FullMethod) {
+		rule := rules.Rule(info.FullMethod)
+		if !rule.Protected() {
+			return handler(ctx, req)
+		}
+
+		// API ключ вместо токена проверяется в sso
+		if key := v.extractAPIKey(ctx); key != "" {
+			apiKey, err := v.ssoClient.ValidateAPIKey(ctx, key)
+			if err != nil {
+				if errors.Is(err, sso.ErrInvalidAPIKey) {
+					return nil, status.Error(codes.Unauthenticated, "invalid api key")
+				}
+				return nil, status.Error(codes.Unavailable, "failed to validate api key")
+			}
+
+			permissions, err := json.Marshal(apiKey.Permissions)
+			if err != nil {
+				return nil, status.Error(codes.Internal, "failed to read api key permissions")
+			}
+
+			ctx = v.enrichContext(ctx, &Claims{UserID: apiKey.UserID, AppID: apiKey.AppID, Permissions: permissions})
+			ctx = context.WithValue(ctx, "api_key_id", apiKey.ID)
+
+			if err := checkRule(ctx, rule); err != nil {
+				return nil, err
+			}
+
 			return handler(ctx, req)
 		}
 
@@ -127,19 +190,41 @@ func (v *JWTValidator) AuthInterceptor() grpc.UnaryServerInterceptor {
 			return nil, status.Error(codes.Unauthenticated, "invalid token: "+err.Error())
 		}
 
+		// токены приложений без embed_permissions не содержат permissions, их отдает sso
+		if !claims.HasPermissionsClaim() && v.ssoClient != nil {
+			permissions, err := v.ssoClient.UserPermissions(ctx, claims.UserID)
+			if err != nil {
+				return nil, status.Error(codes.Unavailable, "failed to get user permissions")
+			}
+
+			claims.Permissions, err = json.Marshal(permissions)
+			if err != nil {
+				return nil, status.Error(codes.Internal, "failed to read user permissions")
+			}
+		}
+
 		// Добавляем claims в контекст
 		ctx = v.enrichContext(ctx, claims)
 
+		if err := checkRule(ctx, rule); err != nil {
+			return nil, err
+		}
+
 		return handler(ctx, req)
 	}
 }
 
 // enrichContext добавляет данные пользователя в контекст
 func (v *JWTValidator) enrichContext(ctx context.Context, claims *Claims) context.Context {
+	requestid.SetUserID(ctx, claims.UserID)
+
 	ctx = context.WithValue(ctx, "user_id", claims.UserID)
 	ctx = context.WithValue(ctx, "user_email", claims.Email)
 	ctx = context.WithValue(ctx, "user_name", claims.Name)
 	ctx = context.WithValue(ctx, "app_id", claims.AppID)
+	if claims.Actor != nil {
+		ctx = context.WithValue(ctx, "actor_id", claims.Actor.UserID)
+	}
 
 	// Парсим permissions в правильном формате
 	permissions := claims.ParsePermissions()
@@ -148,6 +233,25 @@ func (v *JWTValidator) enrichContext(ctx context.Context, claims *Claims) contex
 	return ctx
 }
 
+// extractAPIKey возвращает API ключ, если вызывающий не передал токен
+func (v *JWTValidator) extractAPIKey(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || v.ssoClient == nil {
+		return ""
+	}
+
+	if len(md.Get("authorization")) > 0 {
+		return ""
+	}
+
+	keys := md.Get(apiKeyMetadataKey)
+	if len(keys) == 0 {
+		return ""
+	}
+
+	return keys[0]
+}
+
 // extractToken извлекает JWT из gRPC metadata
 func (v *JWTValidator) extractToken(ctx context.Context) (string, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
@@ -169,19 +273,23 @@ func (v *JWTValidator) extractToken(ctx context.Context) (string, error) {
 	return parts[1], nil
 }
 
-// isPublicEndpoint проверяет публичные эндпоинты
-func (v *JWTValidator) isPublicEndpoint(method string) bool {
-	publicEndpoints := []string{
-		// public endpoins
+// checkRule проверяет разрешения из токена по правилу политики
+func checkRule(ctx context.Context, rule policy.Rule) error {
+	for _, required := range rule.Required {
+		if !HasPermission(ctx, required) {
+			return status.Error(codes.PermissionDenied, fmt.Sprintf("missing required permission: %s", required))
+		}
+	}
 
-		//"/grpc.health.v1.Health/Check",
-		//"/metrics",
+	if len(rule.OneOf) == 0 {
+		return nil
 	}
 
-	for _, endpoint := range publicEndpoints {
-		if strings.Contains(method, endpoint) {
-			return true
+	for _, permission := range rule.OneOf {
+		if HasPermission(ctx, permission) {
+			return nil
 		}
 	}
-	return false
+
+	return status.Error(codes.PermissionDenied, fmt.Sprintf("missing one of required permissions: %s", rule.OneOf))
 }
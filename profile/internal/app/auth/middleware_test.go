@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"profile/internal/clients/sso"
+	"sso/pkg/policy"
+)
+
+const (
+	testSecret   = "test-secret"
+	testAudience = "profile"
+)
+
+type fakeSSO struct {
+	permissions []string
+	calls       int
+}
+
+func (f *fakeSSO) ValidateAPIKey(context.Context, string) (sso.APIKey, error) {
+	return sso.APIKey{}, sso.ErrInvalidAPIKey
+}
+
+func (f *fakeSSO) UserPermissions(context.Context, int64) ([]string, error) {
+	f.calls++
+	return f.permissions, nil
+}
+
+type staticRules struct {
+	rule policy.Rule
+}
+
+func (r staticRules) Rule(string) policy.Rule {
+	return r.rule
+}
+
+// call runs the interceptor with the token built from the claims
+func call(t *testing.T, ssoClient SSOClient, rule policy.Rule, claims jwt.MapClaims) error {
+	t.Helper()
+
+	claims["user_id"] = 7
+	claims["aud"] = testAudience
+	claims["exp"] = time.Now().Add(time.Minute).Unix()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	require.NoError(t, err)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	interceptor := NewJWTValidator(testSecret, testAudience, ssoClient).AuthInterceptor(staticRules{rule: rule})
+
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/profile.Profile/GetProfile"},
+		func(context.Context, any) (any, error) { return nil, nil })
+	return err
+}
+
+func TestAuthInterceptor_EmbeddedPermissions(t *testing.T) {
+	ssoClient := &fakeSSO{permissions: []string{"profile:read:any"}}
+	rule := policy.Rule{OneOf: []string{"profile:read:any"}}
+
+	require.NoError(t, call(t, ssoClient, rule, jwt.MapClaims{"permissions": []string{"profile:read:any"}}))
+	assert.Zero(t, ssoClient.calls)
+
+	// a user without permissions gets an empty list, sso is not asked
+	err := call(t, ssoClient, rule, jwt.MapClaims{"permissions": []string{}})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Zero(t, ssoClient.calls)
+}
+
+func TestAuthInterceptor_PermissionsFromSSO(t *testing.T) {
+	ssoClient := &fakeSSO{permissions: []string{"profile:read:any"}}
+	rule := policy.Rule{OneOf: []string{"profile:read:any"}}
+
+	// the apps with embed_permissions off issue tokens without the claim
+	require.NoError(t, call(t, ssoClient, rule, jwt.MapClaims{}))
+	assert.Equal(t, 1, ssoClient.calls)
+
+	require.NoError(t, call(t, ssoClient, rule, jwt.MapClaims{"permissions": nil}))
+	assert.Equal(t, 2, ssoClient.calls)
+}
@@ -97,6 +97,7 @@ func GetUserFromContext(ctx context.Context) (UserContext, error) {
 	name, _ := ctx.Value("name").(string)
 	appID, _ := ctx.Value("app_id").(int32)
 	permissions, _ := ctx.Value("permissions").([]Permission)
+	actorID, _ := ActorFromContext(ctx)
 
 	return UserContext{
 		UserID:      userID,
@@ -104,9 +105,22 @@ func GetUserFromContext(ctx context.Context) (UserContext, error) {
 		Name:        name,
 		AppID:       appID,
 		Permissions: permissions,
+		ActorID:     actorID,
 	}, nil
 }
 
+// ActorFromContext возвращает ID сотрудника, действующего от имени пользователя
+func ActorFromContext(ctx context.Context) (int64, bool) {
+	actorID, ok := ctx.Value("actor_id").(int64)
+	return actorID, ok
+}
+
+// IsImpersonated сообщает, что запрос выполняется сотрудником от имени пользователя
+func IsImpersonated(ctx context.Context) bool {
+	_, ok := ActorFromContext(ctx)
+	return ok
+}
+
 func DebugUserContext(ctx context.Context) {
 	userCtx, err := GetUserFromContext(ctx)
 	if err != nil {
@@ -133,4 +147,10 @@ type UserContext struct {
 	Name        string
 	AppID       int32
 	Permissions []Permission
+	ActorID     int64 // 0 если токен не получен через имперсонацию
+}
+
+// Impersonated сообщает, что пользователь действует не сам
+func (u UserContext) Impersonated() bool {
+	return u.ActorID != 0
 }
@@ -1,10 +1,15 @@
 package grpcapp
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"profile/internal/app/auth"
 	"profile/internal/grpc/profile"
+	"profile/internal/lib/metrics"
+	"sso/pkg/policy"
+	"sso/pkg/requestid"
+	"sso/pkg/tracing"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
 	"google.golang.org/grpc"
@@ -24,12 +29,15 @@ type App struct {
 func New(
 	log *slog.Logger,
 	profileService *profileService.Service,
+	policies *policy.Store,
+	ssoClient auth.SSOClient,
+	audience string,
 	port int,
 ) *App {
 	// gRPCServer and connect interceptors
 	recoveryOpts := []recovery.Option{
-		recovery.WithRecoveryHandler(func(p interface{}) (err error) {
-			log.Error("Recovered from panic", slog.Any("panic", p))
+		recovery.WithRecoveryHandlerContext(func(ctx context.Context, p interface{}) (err error) {
+			log.ErrorContext(ctx, "Recovered from panic", slog.Any("panic", p))
 			return status.Errorf(codes.Internal, "Internal error")
 		}),
 	}
@@ -41,16 +49,23 @@ func New(
 
 	log.Info("Initializing JWT validator", slog.String("secret_length", fmt.Sprintf("%d chars", len(jwtSecret))))
 
-	jwtValidator := auth.NewJWTValidator(jwtSecret)
+	jwtValidator := auth.NewJWTValidator(jwtSecret, audience, ssoClient)
 
-	gRPCServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
+	gRPCServer := grpc.NewServer(tracing.ServerOption(), grpc.ChainUnaryInterceptor(
+		metrics.UnaryServerInterceptor(),
+		requestid.UnaryServerInterceptor(),
 		recovery.UnaryServerInterceptor(recoveryOpts...),
-		jwtValidator.AuthInterceptor(),
+		jwtValidator.AuthInterceptor(policies),
 	))
 
 	// register the service
 	profile.Register(gRPCServer, profileService, log)
 
+	// typos in the policy must fail on start, not silently change the method access
+	if err := policies.Bind(gRPCServer.GetServiceInfo()); err != nil {
+		panic(fmt.Errorf("invalid method policy: %w", err))
+	}
+
 	// return App object with necessary fields
 	return &App{
 		log:        log,
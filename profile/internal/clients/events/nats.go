@@ -0,0 +1,169 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/pkg/logger/sl"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	ssoevents "sso/api/events"
+)
+
+// Handler handles an sso event, an error asks for redelivery
+type Handler func(ctx context.Context, event ssoevents.Event) error
+
+// Config of the subscription to the sso events
+type Config struct {
+	URL           string
+	SubjectPrefix string        // the events.subject_prefix of sso
+	Queue         string        // replicas of profile share the events of the queue group
+	JetStream     bool          // durable consumer of the stream holding the subjects, otherwise core NATS
+	Stream        string        // the JetStream stream, created on the subjects if missing
+	Timeout       time.Duration // handling time of a single event
+}
+
+// Consumer receives the user events published by sso to NATS
+type Consumer struct {
+	log     *slog.Logger
+	cfg     Config
+	conn    *nats.Conn
+	handler Handler
+}
+
+func New(log *slog.Logger, cfg Config, handler Handler) (*Consumer, error) {
+	const op = "clients.events.New"
+
+	conn, err := nats.Connect(cfg.URL, nats.Name("profile-provisioning"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Consumer{
+		log:     log,
+		cfg:     cfg,
+		conn:    conn,
+		handler: handler,
+	}, nil
+}
+
+// Run handles the events until the context is done
+func (c *Consumer) Run(ctx context.Context) error {
+	const op = "clients.events.Run"
+
+	log := c.log.With(slog.String("op", op))
+
+	subject := "user.*"
+	if c.cfg.SubjectPrefix != "" {
+		subject = c.cfg.SubjectPrefix + "." + subject
+	}
+
+	var (
+		sub *nats.Subscription
+		err error
+	)
+	if c.cfg.JetStream {
+		js, jsErr := c.conn.JetStream()
+		if jsErr != nil {
+			return fmt.Errorf("%s: %w", op, jsErr)
+		}
+		if err := ensureStream(js, c.cfg.Stream, subject); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		sub, err = js.QueueSubscribe(subject, c.cfg.Queue, func(msg *nats.Msg) {
+			c.handle(ctx, msg, true)
+		}, nats.BindStream(c.cfg.Stream), nats.ManualAck(), nats.AckExplicit(), nats.DeliverAll())
+	} else {
+		// core NATS delivers at most once, events published while profile is down are lost
+		sub, err = c.conn.QueueSubscribe(subject, c.cfg.Queue, func(msg *nats.Msg) {
+			c.handle(ctx, msg, false)
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.InfoContext(ctx, "consuming sso events", slog.String("subject", subject), slog.Bool("jetstream", c.cfg.JetStream))
+
+	<-ctx.Done()
+
+	if err := sub.Drain(); err != nil {
+		log.ErrorContext(ctx, "failed to drain subscription", sl.Err(err))
+	}
+
+	return nil
+}
+
+// ensureStream creates the stream on the subject unless it exists, the stream keeps the events
+// published while profile is down and drops the duplicates by the Nats-Msg-Id sso sends
+func ensureStream(js nats.JetStreamContext, name, subject string) error {
+	_, err := js.StreamInfo(name)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, nats.ErrStreamNotFound) {
+		return err
+	}
+
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:       name,
+		Subjects:   []string{subject},
+		Storage:    nats.FileStorage,
+		Duplicates: 10 * time.Minute,
+	})
+	if errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+		// another replica created it first
+		return nil
+	}
+	return err
+}
+
+func (c *Consumer) handle(ctx context.Context, msg *nats.Msg, ack bool) {
+	const op = "clients.events.handle"
+
+	log := c.log.With(
+		slog.String("op", op),
+		slog.String("subject", msg.Subject),
+	)
+
+	var event ssoevents.Event
+	if err := json.Unmarshal(msg.Data, &event); err != nil {
+		log.WarnContext(ctx, "dropped malformed message", sl.Err(err))
+		if ack {
+			_ = msg.Term()
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	if err := c.handler(ctx, event); err != nil {
+		log.ErrorContext(ctx, "failed to handle event", slog.Int64("event_id", event.ID), sl.Err(err))
+		if ack {
+			_ = msg.Nak()
+		}
+		return
+	}
+
+	if ack {
+		if err := msg.Ack(); err != nil {
+			log.ErrorContext(ctx, "failed to ack event", slog.Int64("event_id", event.ID), sl.Err(err))
+		}
+	}
+}
+
+// Close drains the connection and waits for the events in flight, drain is bounded by the nats drain timeout
+func (c *Consumer) Close() error {
+	if err := c.conn.Drain(); err != nil {
+		return err
+	}
+	for !c.conn.IsClosed() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	ssov1 "github.com/m4rk1sov/protos/gen/go/sso"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	ssoapiv1 "sso/api/gen/go/sso"
+	"sso/pkg/requestid"
+	"sso/pkg/tracing"
+)
+
+var ErrInvalidAPIKey = errors.New("api key is invalid")
+
+const apiKeyMetadataKey = "x-api-key"
+
+// APIKey is the identity of the api key caller
+type APIKey struct {
+	ID          int64
+	UserID      int64
+	AppID       int32
+	Permissions []string
+}
+
+// Client talks to sso over gRPC
+type Client struct {
+	log         *slog.Logger
+	conn        *grpc.ClientConn
+	apiKeys     ssoapiv1.ApiKeysClient
+	permissions ssov1.PermissionClient
+	apiKey      string
+	timeout     time.Duration
+}
+
+// New connects to sso, apiKey is the own key of profile sent with every call
+func New(log *slog.Logger, addr string, apiKey string, timeout time.Duration) (*Client, error) {
+	const op = "clients.sso.New"
+
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		tracing.DialOption(),
+		grpc.WithUnaryInterceptor(requestid.UnaryClientInterceptor()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Client{
+		log:         log,
+		conn:        conn,
+		apiKeys:     ssoapiv1.NewApiKeysClient(conn),
+		permissions: ssov1.NewPermissionClient(conn),
+		apiKey:      apiKey,
+		timeout:     timeout,
+	}, nil
+}
+
+// ValidateAPIKey asks sso for the identity of the api key
+func (c *Client) ValidateAPIKey(ctx context.Context, key string) (APIKey, error) {
+	const op = "clients.sso.ValidateAPIKey"
+
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	resp, err := c.apiKeys.ValidateApiKey(ctx, &ssoapiv1.ValidateApiKeyRequest{Key: key})
+	if err != nil {
+		if status.Code(err) == codes.Unauthenticated {
+			return APIKey{}, fmt.Errorf("%s: %w", op, ErrInvalidAPIKey)
+		}
+		c.log.ErrorContext(ctx, "failed to validate api key", slog.String("op", op), slog.String("error", err.Error()))
+		return APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return APIKey{
+		ID:          resp.GetKeyId(),
+		UserID:      resp.GetUserId(),
+		AppID:       resp.GetAppId(),
+		Permissions: resp.GetPermissions(),
+	}, nil
+}
+
+// UserPermissions returns the permissions the user has for every app
+func (c *Client) UserPermissions(ctx context.Context, userID int64) ([]string, error) {
+	const op = "clients.sso.UserPermissions"
+
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	resp, err := c.permissions.GetUserPermissions(ctx, &ssov1.GetUserPermissionsRequest{UserId: userID})
+	if err != nil {
+		c.log.ErrorContext(ctx, "failed to get user permissions", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return resp.GetPermissions(), nil
+}
+
+// callContext limits the call with the timeout and authenticates profile with its api key
+func (c *Client) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = metadata.AppendToOutgoingContext(ctx, apiKeyMetadataKey, c.apiKey)
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -8,16 +8,56 @@ import (
 )
 
 type Config struct {
-	Env            string     `json:"env" yaml:"env" env-default:"local"`
-	DSN            string     `env:"DSN_STRING"`
-	JWT            JWTConfig  `yaml:"jwt"`
-	GRPC           GRPCConfig `yaml:"grpc"`
-	MigrationsPath string     `env:"MIGRATE_PATH"`
-	HTTPServer     HTTPServer `yaml:"http_server"`
+	Env            string        `json:"env" yaml:"env" env-default:"local"`
+	DSN            string        `env:"DSN_STRING"`
+	JWT            JWTConfig     `yaml:"jwt"`
+	GRPC           GRPCConfig    `yaml:"grpc"`
+	MigrationsPath string        `env:"MIGRATE_PATH"`
+	HTTPServer     HTTPServer    `yaml:"http_server"`
+	PolicyPath     string        `yaml:"policy_path" env:"POLICY_PATH" env-default:"../policy/methods.yaml"`
+	Authz          AuthzConfig   `yaml:"authz"`
+	SSO            SSOConfig     `yaml:"sso"`
+	Events         EventsConfig  `yaml:"events"`
+	Tracing        TracingConfig `yaml:"tracing"`
+}
+
+// TracingConfig is the export of the OpenTelemetry spans
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`           // none, stdout or otlp
+	Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT" env-default:"localhost:4317"` // OTLP gRPC collector
+	Insecure    bool    `yaml:"insecure" env-default:"true"`
+	SampleRatio float64 `yaml:"sample_ratio" env-default:"1"`
+}
+
+// EventsConfig is the subscription to the sso user events, profiles are provisioned from them when enabled
+type EventsConfig struct {
+	Enabled         bool          `yaml:"enabled" env:"EVENTS_ENABLED" env-default:"false"`
+	NATSURL         string        `yaml:"nats_url" env:"NATS_URL" env-default:"nats://localhost:4222"`
+	SubjectPrefix   string        `yaml:"subject_prefix" env-default:"sso"`
+	Queue           string        `yaml:"queue" env-default:"profile"`
+	JetStream       bool          `yaml:"jetstream" env-default:"true"`
+	Stream          string        `yaml:"stream" env-default:"SSO_USERS"`
+	Timeout         time.Duration `yaml:"timeout" env-default:"10s"`
+	Retention       time.Duration `yaml:"retention" env-default:"720h"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env-default:"1h"`
+}
+
+// SSOConfig is the sso gRPC address used to validate api keys
+// profile calls sso with its own api key, the owner needs the sso "service" role
+type SSOConfig struct {
+	Addr    string        `yaml:"addr" env:"SSO_ADDR" env-default:"localhost:44044"`
+	APIKey  string        `yaml:"api_key" env:"SSO_API_KEY"`
+	Timeout time.Duration `yaml:"timeout" env-default:"5s"`
+}
+
+// AuthzConfig holds CEL rules per action, actions not listed use the built-in rules
+type AuthzConfig struct {
+	Rules map[string]string `yaml:"rules"`
 }
 
 type JWTConfig struct {
 	TokenTTL time.Duration `yaml:"token_ttl" env-default:"1h"`
+	Audience string        `yaml:"audience" env-default:"profile"` // tokens must be issued for this audience
 }
 
 type HTTPServer struct {
@@ -30,3 +30,15 @@ type ListProfilesFilter struct {
 	SortBy    string `json:"sort_by"`
 	SortOrder string `json:"sort_order"`
 }
+
+// UserEvent is a change of the sso user the profile is provisioned from
+type UserEvent struct {
+	ID      int64 // sso event id, events are applied once
+	Type    string
+	UserID  int64
+	Deleted bool
+	Name    string
+	Phone   string
+	Address string
+	Email   string
+}
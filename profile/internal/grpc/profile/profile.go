@@ -6,6 +6,7 @@ import (
 	"log/slog"
 
 	"profile/internal/domain"
+	"profile/internal/lib/authz"
 	"profile/internal/services/profile"
 	"profile/internal/storage"
 
@@ -97,7 +98,18 @@ func (s *serverAPI) CreateProfile(ctx context.Context, req *profilev1.CreateProf
 			}, nil
 		}
 
-		s.log.Error("Failed to create profile", slog.String("error", err.Error()))
+		if errors.Is(err, authz.ErrAccessDenied) {
+			return &profilev1.ProfileResponse{
+				Result: &profilev1.ProfileResponse_Error{
+					Error: &rpc.Status{
+						Code:    int32(codes.PermissionDenied),
+						Message: "access denied",
+					},
+				},
+			}, nil
+		}
+
+		s.log.ErrorContext(ctx, "Failed to create profile", slog.String("error", err.Error()))
 		return &profilev1.ProfileResponse{
 			Result: &profilev1.ProfileResponse_Error{
 				Error: &rpc.Status{
@@ -147,7 +159,18 @@ func (s *serverAPI) GetProfile(ctx context.Context, req *profilev1.GetProfileReq
 			}, nil
 		}
 
-		s.log.Error("Failed to get profile", slog.String("error", err.Error()))
+		if errors.Is(err, authz.ErrAccessDenied) {
+			return &profilev1.ProfileResponse{
+				Result: &profilev1.ProfileResponse_Error{
+					Error: &rpc.Status{
+						Code:    int32(codes.PermissionDenied),
+						Message: "access denied",
+					},
+				},
+			}, nil
+		}
+
+		s.log.ErrorContext(ctx, "Failed to get profile", slog.String("error", err.Error()))
 		return &profilev1.ProfileResponse{
 			Result: &profilev1.ProfileResponse_Error{
 				Error: &rpc.Status{
@@ -197,7 +220,18 @@ func (s *serverAPI) GetProfileByUserID(ctx context.Context, req *profilev1.GetPr
 			}, nil
 		}
 
-		s.log.Error("Failed to get profile by user ID", slog.String("error", err.Error()))
+		if errors.Is(err, authz.ErrAccessDenied) {
+			return &profilev1.ProfileResponse{
+				Result: &profilev1.ProfileResponse_Error{
+					Error: &rpc.Status{
+						Code:    int32(codes.PermissionDenied),
+						Message: "access denied",
+					},
+				},
+			}, nil
+		}
+
+		s.log.ErrorContext(ctx, "Failed to get profile by user ID", slog.String("error", err.Error()))
 		return &profilev1.ProfileResponse{
 			Result: &profilev1.ProfileResponse_Error{
 				Error: &rpc.Status{
@@ -261,7 +295,18 @@ func (s *serverAPI) UpdateProfile(ctx context.Context, req *profilev1.UpdateProf
 			}, nil
 		}
 
-		s.log.Error("Failed to update profile", slog.String("error", err.Error()))
+		if errors.Is(err, authz.ErrAccessDenied) {
+			return &profilev1.ProfileResponse{
+				Result: &profilev1.ProfileResponse_Error{
+					Error: &rpc.Status{
+						Code:    int32(codes.PermissionDenied),
+						Message: "access denied",
+					},
+				},
+			}, nil
+		}
+
+		s.log.ErrorContext(ctx, "Failed to update profile", slog.String("error", err.Error()))
 		return &profilev1.ProfileResponse{
 			Result: &profilev1.ProfileResponse_Error{
 				Error: &rpc.Status{
@@ -303,7 +348,14 @@ func (s *serverAPI) DeleteProfile(ctx context.Context, req *profilev1.DeleteProf
 			}, status.Error(codes.NotFound, "profile not found")
 		}
 
-		s.log.Error("Failed to delete profile", slog.String("error", err.Error()))
+		if errors.Is(err, authz.ErrAccessDenied) {
+			return &profilev1.DeleteProfileResponse{
+				Success: false,
+				Message: "access denied",
+			}, status.Error(codes.PermissionDenied, "access denied")
+		}
+
+		s.log.ErrorContext(ctx, "Failed to delete profile", slog.String("error", err.Error()))
 		return &profilev1.DeleteProfileResponse{
 			Success: false,
 			Message: "internal server error",
@@ -326,7 +378,7 @@ func (s *serverAPI) ListProfiles(ctx context.Context, req *profilev1.ListProfile
 
 	profiles, total, err := s.profileService.ListProfiles(ctx, filter)
 	if err != nil {
-		s.log.Error("Failed to list profiles", slog.String("error", err.Error()))
+		s.log.ErrorContext(ctx, "Failed to list profiles", slog.String("error", err.Error()))
 		return nil, status.Error(codes.Internal, "internal server error")
 	}
 
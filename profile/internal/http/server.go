@@ -14,6 +14,10 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 	"log/slog"
 	"net/http"
+	"profile/internal/lib/metrics"
+	"sso/pkg/requestid"
+	"sso/pkg/tracing"
+	"strings"
 	"time"
 )
 
@@ -41,9 +45,17 @@ func (s *Server) Start() error {
 	)
 
 	// gRPC-Gateway mux for API endpoints
-	gwMux := runtime.NewServeMux()
+	gwMux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(headerMatcher),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaderMatcher),
+		runtime.WithMiddlewares(metrics.GatewayMiddleware, tracing.GatewayMiddleware),
+	)
 
-	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		// the loopback calls continue the trace of the HTTP request
+		tracing.DialOption(),
+	}
 
 	err := profilev1.RegisterProfileServiceHandlerFromEndpoint(context.Background(), gwMux, s.grpcAddr, opts)
 	if err != nil {
@@ -59,7 +71,10 @@ func (s *Server) Start() error {
 	// API endpoints
 	mainMux.Handle("/v1/", gwMux)
 
-	handler := corsMiddleware(mainMux)
+	// Prometheus scrape endpoint
+	mainMux.Handle("/metrics", metrics.Handler())
+
+	handler := tracing.Handler(corsMiddleware(mainMux))
 
 	s.httpServer = &http.Server{
 		Addr:         fmt.Sprintf("localhost:%d", s.port),
@@ -85,12 +100,31 @@ func (s *Server) Stop(ctx context.Context) error {
 	return s.httpServer.Shutdown(ctx)
 }
 
+// headerMatcher forwards the api key to gRPC metadata
+func headerMatcher(key string) (string, bool) {
+	if strings.EqualFold(key, "X-Api-Key") {
+		return "x-api-key", true
+	}
+	if strings.EqualFold(key, "X-Request-Id") {
+		return requestid.MetadataKey, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// outgoingHeaderMatcher passes X-Request-Id as is, other metadata keeps the default Grpc-Metadata- prefix
+func outgoingHeaderMatcher(key string) (string, bool) {
+	if strings.EqualFold(key, requestid.MetadataKey) {
+		return "X-Request-Id", true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-		w.Header().Set("Access-Control-Expose-Headers", "Content-Length")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Api-Key, X-Request-Id")
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Length, X-Request-Id")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		if r.Method == "OPTIONS" {
@@ -0,0 +1,179 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/google/cel-go/cel"
+)
+
+var (
+	ErrAccessDenied  = errors.New("access denied")
+	ErrUnknownAction = errors.New("no rule for action")
+)
+
+// Actions checked by the profile service
+const (
+	ActionProfileCreate = "profile.create"
+	ActionProfileRead   = "profile.read"
+	ActionProfileUpdate = "profile.update"
+	ActionProfileDelete = "profile.delete"
+)
+
+// DefaultRules are used for the actions without a rule in config
+var DefaultRules = map[string]string{
+	ActionProfileCreate: `subject.user_id == resource.user_id`,
+	ActionProfileRead:   `subject.user_id == resource.user_id || 'admin' in subject.permissions || 'profile:read:any' in subject.permissions`,
+	ActionProfileUpdate: `subject.user_id == resource.user_id || 'admin' in subject.permissions || 'profile:write:any' in subject.permissions`,
+	ActionProfileDelete: `subject.user_id == resource.user_id || 'admin' in subject.permissions || 'profile:write:any' in subject.permissions`,
+}
+
+// Subject is the authenticated caller
+type Subject struct {
+	UserID      int64
+	AppID       int32
+	Permissions []string
+	ActorID     int64 // staff member impersonating the user, 0 otherwise
+}
+
+// Resource is the object the action is performed on
+type Resource struct {
+	ID     string
+	UserID int64 // owner
+}
+
+type rule struct {
+	expr    string
+	program cel.Program
+}
+
+// Engine evaluates CEL rules declared per action,
+// rules see the "subject" and "resource" maps with the fields of Subject and Resource in snake case
+type Engine struct {
+	log   *slog.Logger
+	rules map[string]rule
+}
+
+// New compiles the rules, DefaultRules are used for the actions missing in rules
+func New(log *slog.Logger, rules map[string]string) (*Engine, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("subject", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("resource", cel.MapType(cel.StringType, cel.DynType)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rules environment: %w", err)
+	}
+
+	merged := make(map[string]string, len(DefaultRules))
+	for action, expr := range DefaultRules {
+		merged[action] = expr
+	}
+	for action, expr := range rules {
+		merged[action] = expr
+	}
+
+	engine := &Engine{log: log, rules: make(map[string]rule, len(merged))}
+
+	for action, expr := range merged {
+		ast, issues := env.Compile(expr)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("rule %q: %w", action, issues.Err())
+		}
+
+		// map fields are dynamic, so such rules are checked for bool on evaluation
+		if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+			return nil, fmt.Errorf("rule %q: must evaluate to bool, got %s", action, ast.OutputType())
+		}
+
+		program, err := env.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", action, err)
+		}
+
+		engine.rules[action] = rule{expr: expr, program: program}
+	}
+
+	return engine, nil
+}
+
+// MustNew compiles the rules, panics on error
+func MustNew(log *slog.Logger, rules map[string]string) *Engine {
+	engine, err := New(log, rules)
+	if err != nil {
+		panic(err)
+	}
+	return engine
+}
+
+// Actions returns the actions having a rule
+func (e *Engine) Actions() []string {
+	actions := make([]string, 0, len(e.rules))
+	for action := range e.rules {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	return actions
+}
+
+// Authorize evaluates the rule of the action, returns ErrAccessDenied if it does not hold
+// every decision is logged with the rule and its inputs so it can be explained later
+func (e *Engine) Authorize(ctx context.Context, action string, subject Subject, resource Resource) error {
+	const op = "authz.Authorize"
+
+	log := e.log.With(
+		slog.String("op", op),
+		slog.String("action", action),
+	)
+
+	r, ok := e.rules[action]
+	if !ok {
+		log.Error("no rule for action")
+		return fmt.Errorf("%s: %w: %s", op, ErrUnknownAction, action)
+	}
+
+	input := map[string]any{
+		"subject": map[string]any{
+			"user_id":     subject.UserID,
+			"app_id":      int64(subject.AppID),
+			"permissions": subject.Permissions,
+			"actor_id":    subject.ActorID,
+		},
+		"resource": map[string]any{
+			"id":      resource.ID,
+			"user_id": resource.UserID,
+		},
+	}
+
+	out, _, err := r.program.ContextEval(ctx, input)
+	if err != nil {
+		log.Error("failed to evaluate rule", slog.String("rule", r.expr), slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	allowed, ok := out.Value().(bool)
+	if !ok {
+		log.Error("rule must evaluate to bool", slog.String("rule", r.expr))
+		return fmt.Errorf("%s: rule of %s is not a bool", op, action)
+	}
+
+	explain := []any{
+		slog.Bool("allowed", allowed),
+		slog.String("rule", r.expr),
+		slog.Int64("subject_user_id", subject.UserID),
+		slog.Any("subject_permissions", subject.Permissions),
+		slog.Int64("subject_actor_id", subject.ActorID),
+		slog.String("resource_id", resource.ID),
+		slog.Int64("resource_user_id", resource.UserID),
+	}
+
+	if !allowed {
+		log.Warn("access denied", explain...)
+		return ErrAccessDenied
+	}
+
+	log.Debug("access granted", explain...)
+	return nil
+}
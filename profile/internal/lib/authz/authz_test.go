@@ -0,0 +1,89 @@
+package authz
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEngine(t *testing.T, rules map[string]string) *Engine {
+	t.Helper()
+
+	engine, err := New(slog.New(slog.NewTextHandler(io.Discard, nil)), rules)
+	require.NoError(t, err)
+
+	return engine
+}
+
+func TestAuthorize_DefaultRules(t *testing.T) {
+	engine := newTestEngine(t, nil)
+
+	own := Resource{ID: "p1", UserID: 1}
+
+	tests := []struct {
+		name    string
+		action  string
+		subject Subject
+		allowed bool
+	}{
+		{"owner reads", ActionProfileRead, Subject{UserID: 1}, true},
+		{"stranger reads", ActionProfileRead, Subject{UserID: 2}, false},
+		{"admin reads", ActionProfileRead, Subject{UserID: 2, Permissions: []string{"admin"}}, true},
+		{"read any reads", ActionProfileRead, Subject{UserID: 2, Permissions: []string{"profile:read:any"}}, true},
+		{"read any can't update", ActionProfileUpdate, Subject{UserID: 2, Permissions: []string{"profile:read:any"}}, false},
+		{"write any deletes", ActionProfileDelete, Subject{UserID: 2, Permissions: []string{"profile:write:any"}}, true},
+		{"admin can't create for others", ActionProfileCreate, Subject{UserID: 2, Permissions: []string{"admin"}}, false},
+		{"owner creates", ActionProfileCreate, Subject{UserID: 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := engine.Authorize(context.Background(), tt.action, tt.subject, own)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrAccessDenied)
+			}
+		})
+	}
+}
+
+func TestAuthorize_ConfiguredRuleReplacesDefault(t *testing.T) {
+	// impersonating staff can only read
+	engine := newTestEngine(t, map[string]string{
+		ActionProfileUpdate: `subject.actor_id == 0 && subject.user_id == resource.user_id`,
+	})
+
+	own := Resource{ID: "p1", UserID: 1}
+
+	assert.NoError(t, engine.Authorize(context.Background(), ActionProfileUpdate, Subject{UserID: 1}, own))
+	assert.ErrorIs(t, engine.Authorize(context.Background(), ActionProfileUpdate, Subject{UserID: 1, ActorID: 7}, own), ErrAccessDenied)
+	assert.NoError(t, engine.Authorize(context.Background(), ActionProfileRead, Subject{UserID: 1, ActorID: 7}, own))
+}
+
+func TestAuthorize_UnknownAction(t *testing.T) {
+	engine := newTestEngine(t, nil)
+
+	err := engine.Authorize(context.Background(), "order.refund", Subject{UserID: 1}, Resource{UserID: 1})
+	assert.ErrorIs(t, err, ErrUnknownAction)
+}
+
+func TestNew_InvalidRules(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err := New(log, map[string]string{ActionProfileRead: `subject.user_id ==`})
+	assert.Error(t, err)
+
+	_, err = New(log, map[string]string{ActionProfileRead: `"yes"`})
+	assert.ErrorContains(t, err, "must evaluate to bool")
+}
+
+func TestActions(t *testing.T) {
+	engine := newTestEngine(t, map[string]string{"order.refund": `'orders:refund' in subject.permissions`})
+
+	assert.Equal(t, []string{"order.refund", ActionProfileCreate, ActionProfileDelete, ActionProfileRead, ActionProfileUpdate}, engine.Actions())
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+
+	pkgmetrics "sso/pkg/metrics"
+)
+
+const namespace = "profile"
+
+// Registry holds the metrics of profile, Handler serves them
+var Registry = prometheus.NewRegistry()
+
+// server is the metrics of the gRPC and gateway requests
+var server = pkgmetrics.NewServer(namespace, Registry)
+
+// domain events
+var (
+	ProfilesCreated = counterVec("profiles_created_total", "Created profiles by source: api or provisioning.", "source")
+)
+
+// sources of the created profiles
+const (
+	SourceAPI          = "api"
+	SourceProvisioning = "provisioning"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// UnaryServerInterceptor counts and times the gRPC requests
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return server.UnaryServerInterceptor()
+}
+
+// GatewayMiddleware counts and times the gateway requests by their route template
+func GatewayMiddleware(next runtime.HandlerFunc) runtime.HandlerFunc {
+	return server.GatewayMiddleware(next)
+}
+
+// NewPoolCollector exports the stats of the database pool, register it in Registry
+func NewPoolCollector(pool pkgmetrics.PoolStater) prometheus.Collector {
+	return pkgmetrics.NewPoolCollector(namespace, pool)
+}
+
+func counterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: namespace, Name: name, Help: help}, labels)
+	Registry.MustRegister(c)
+	return c
+}
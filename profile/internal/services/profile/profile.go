@@ -6,20 +6,52 @@ import (
 	"fmt"
 	"log/slog"
 	"profile/internal/app/auth"
+	"profile/internal/lib/authz"
+	"profile/internal/lib/metrics"
 	
 	"profile/internal/domain"
 	"profile/internal/storage"
 )
 
+// Authorizer decides whether the subject may perform the action on the resource
+type Authorizer interface {
+	Authorize(ctx context.Context, action string, subject authz.Subject, resource authz.Resource) error
+}
+
 type Service struct {
 	log     *slog.Logger
 	storage storage.ProfileStorage
+	authz   Authorizer
 }
 
-func New(log *slog.Logger, storage storage.ProfileStorage) *Service {
+func New(log *slog.Logger, storage storage.ProfileStorage, authorizer Authorizer) *Service {
 	return &Service{
 		log:     log,
 		storage: storage,
+		authz:   authorizer,
+	}
+}
+
+// subject builds the authorization subject from the caller token
+func subject(userCtx auth.UserContext) authz.Subject {
+	permissions := make([]string, len(userCtx.Permissions))
+	for i, permission := range userCtx.Permissions {
+		permissions[i] = permission.Name
+	}
+	
+	return authz.Subject{
+		UserID:      userCtx.UserID,
+		AppID:       userCtx.AppID,
+		Permissions: permissions,
+		ActorID:     userCtx.ActorID,
+	}
+}
+
+// flagImpersonated marks writes made by staff on behalf of the user,
+// the authz rules decide whether such writes are allowed at all
+func flagImpersonated(log *slog.Logger, userCtx auth.UserContext) {
+	if userCtx.Impersonated() {
+		log.Warn("write under impersonation", slog.Int64("actor_id", userCtx.ActorID))
 	}
 }
 
@@ -35,30 +67,30 @@ func (s *Service) CreateProfile(ctx context.Context, req domain.CreateProfileReq
 	
 	userCtx, err := auth.GetUserFromContext(ctx)
 	if err != nil {
-		log.Error("failed to get user from context", slog.String("error", err.Error()))
+		log.ErrorContext(ctx, "failed to get user from context", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	
-	if req.UserID != userCtx.UserID {
-		log.Warn("user trying to create profile for another user",
-			slog.Int64("jwt_user_id", userCtx.UserID),
-			slog.Int64("requested_user_id", req.UserID))
-		return nil, fmt.Errorf("%s: can only create own profile", op)
+	err = s.authz.Authorize(ctx, authz.ActionProfileCreate, subject(userCtx), authz.Resource{UserID: req.UserID})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	
+	flagImpersonated(log, userCtx)
+	
 	// Validate input
 	if req.UserID <= 0 {
-		log.Warn("Invalid user ID")
+		log.WarnContext(ctx, "Invalid user ID")
 		return nil, fmt.Errorf("%s: invalid user ID", op)
 	}
 	
 	if req.Name == "" {
-		log.Warn("Name is required")
+		log.WarnContext(ctx, "Name is required")
 		return nil, fmt.Errorf("%s: name is required", op)
 	}
 	
 	if req.Email == "" {
-		log.Warn("Email is required")
+		log.WarnContext(ctx, "Email is required")
 		return nil, fmt.Errorf("%s: email is required", op)
 	}
 	
@@ -72,14 +104,16 @@ func (s *Service) CreateProfile(ctx context.Context, req domain.CreateProfileReq
 	profile, err := s.storage.CreateProfile(ctx, req)
 	if err != nil {
 		if errors.Is(err, storage.ErrProfileAlreadyExists) {
-			log.Warn("Profile already exists")
+			log.WarnContext(ctx, "Profile already exists")
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		log.Error("Failed to create profile", slog.String("error", err.Error()))
+		log.ErrorContext(ctx, "Failed to create profile", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	
-	log.Info("Profile created successfully",
+	metrics.ProfilesCreated.WithLabelValues(metrics.SourceAPI).Inc()
+	
+	log.InfoContext(ctx, "Profile created successfully",
 		slog.String("profile_id", profile.ID),
 		slog.Int64("jwt_user_id", userCtx.UserID))
 	
@@ -98,33 +132,31 @@ func (s *Service) GetProfile(ctx context.Context, id string) (*domain.Profile, e
 	
 	userCtx, err := auth.GetUserFromContext(ctx)
 	if err != nil {
-		log.Error("failed to get user from context", slog.String("error", err.Error()))
+		log.ErrorContext(ctx, "failed to get user from context", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	
 	if id == "" {
-		log.Warn("Profile ID is required")
+		log.WarnContext(ctx, "Profile ID is required")
 		return nil, fmt.Errorf("%s: profile ID is required", op)
 	}
 	
 	profile, err := s.storage.GetProfile(ctx, id)
 	if err != nil {
 		if errors.Is(err, storage.ErrProfileNotFound) {
-			log.Warn("Profile not found")
+			log.WarnContext(ctx, "Profile not found")
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		log.Error("Failed to get profile", slog.String("error", err.Error()))
+		log.ErrorContext(ctx, "Failed to get profile", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	
-	if profile.UserID != userCtx.UserID && !auth.HasPermission(ctx, "admin") {
-		log.Warn("user trying to access another user's profile",
-			slog.Int64("jwt_user_id", userCtx.UserID),
-			slog.Int64("profile_user_id", profile.UserID))
-		return nil, fmt.Errorf("%s: access denied", op)
+	err = s.authz.Authorize(ctx, authz.ActionProfileRead, subject(userCtx), authz.Resource{ID: profile.ID, UserID: profile.UserID})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	
-	log.Info("Profile retrieved successfully",
+	log.InfoContext(ctx, "Profile retrieved successfully",
 		slog.String("profile_id", profile.ID),
 		slog.Int64("jwt_user_id", userCtx.UserID))
 	
@@ -143,33 +175,31 @@ func (s *Service) GetProfileByUserID(ctx context.Context, userID int64) (*domain
 	
 	userCtx, err := auth.GetUserFromContext(ctx)
 	if err != nil {
-		log.Error("failed to get user from context", slog.String("error", err.Error()))
+		log.ErrorContext(ctx, "failed to get user from context", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	
 	if userID <= 0 {
-		log.Warn("Invalid user ID")
+		log.WarnContext(ctx, "Invalid user ID")
 		return nil, fmt.Errorf("%s: invalid user ID", op)
 	}
 	
-	if userID != userCtx.UserID && !auth.HasPermission(ctx, "admin") {
-		log.Warn("user trying to access another user's profile",
-			slog.Int64("jwt_user_id", userCtx.UserID),
-			slog.Int64("requested_user_id", userID))
-		return nil, fmt.Errorf("%s: access denied", op)
+	err = s.authz.Authorize(ctx, authz.ActionProfileRead, subject(userCtx), authz.Resource{UserID: userID})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	
 	profile, err := s.storage.GetProfileByUserID(ctx, userID)
 	if err != nil {
 		if errors.Is(err, storage.ErrProfileNotFound) {
-			log.Warn("Profile not found")
+			log.WarnContext(ctx, "Profile not found")
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		log.Error("Failed to get profile", slog.String("error", err.Error()))
+		log.ErrorContext(ctx, "Failed to get profile", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	
-	log.Info("Profile retrieved successfully",
+	log.InfoContext(ctx, "Profile retrieved successfully",
 		slog.Int64("user_id", profile.UserID),
 		slog.Int64("jwt_user_id", userCtx.UserID))
 	
@@ -188,43 +218,43 @@ func (s *Service) UpdateProfile(ctx context.Context, req domain.UpdateProfileReq
 	
 	userCtx, err := auth.GetUserFromContext(ctx)
 	if err != nil {
-		log.Error("failed to get user from context", slog.String("error", err.Error()))
+		log.ErrorContext(ctx, "failed to get user from context", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	
 	if req.ID == "" {
-		log.Warn("Profile ID is required")
+		log.WarnContext(ctx, "Profile ID is required")
 		return nil, fmt.Errorf("%s: profile ID is required", op)
 	}
 	
 	existingProfile, err := s.storage.GetProfile(ctx, req.ID)
 	if err != nil {
 		if errors.Is(err, storage.ErrProfileNotFound) {
-			log.Warn("Profile not found")
+			log.WarnContext(ctx, "Profile not found")
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		log.Error("Failed to get existing profile", slog.String("error", err.Error()))
+		log.ErrorContext(ctx, "Failed to get existing profile", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	
-	if existingProfile.UserID != userCtx.UserID && !auth.HasPermission(ctx, "admin") {
-		log.Warn("user trying to update another user's profile",
-			slog.Int64("jwt_user_id", userCtx.UserID),
-			slog.Int64("profile_user_id", existingProfile.UserID))
-		return nil, fmt.Errorf("%s: access denied", op)
+	err = s.authz.Authorize(ctx, authz.ActionProfileUpdate, subject(userCtx), authz.Resource{ID: existingProfile.ID, UserID: existingProfile.UserID})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	
+	flagImpersonated(log, userCtx)
+	
 	profile, err := s.storage.UpdateProfile(ctx, req)
 	if err != nil {
 		if errors.Is(err, storage.ErrProfileNotFound) {
-			log.Warn("Profile not found")
+			log.WarnContext(ctx, "Profile not found")
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		log.Error("Failed to update profile", slog.String("error", err.Error()))
+		log.ErrorContext(ctx, "Failed to update profile", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	
-	log.Info("Profile updated successfully",
+	log.InfoContext(ctx, "Profile updated successfully",
 		slog.String("profile_id", profile.ID),
 		slog.Int64("jwt_user_id", userCtx.UserID))
 	
@@ -243,43 +273,43 @@ func (s *Service) DeleteProfile(ctx context.Context, id string) error {
 	
 	userCtx, err := auth.GetUserFromContext(ctx)
 	if err != nil {
-		log.Error("failed to get user from context", slog.String("error", err.Error()))
+		log.ErrorContext(ctx, "failed to get user from context", slog.String("error", err.Error()))
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	
 	if id == "" {
-		log.Warn("Profile ID is required")
+		log.WarnContext(ctx, "Profile ID is required")
 		return fmt.Errorf("%s: profile ID is required", op)
 	}
 	
 	existingProfile, err := s.storage.GetProfile(ctx, id)
 	if err != nil {
 		if errors.Is(err, storage.ErrProfileNotFound) {
-			log.Warn("Profile not found")
+			log.WarnContext(ctx, "Profile not found")
 			return fmt.Errorf("%s: %w", op, err)
 		}
-		log.Error("Failed to get existing profile", slog.String("error", err.Error()))
+		log.ErrorContext(ctx, "Failed to get existing profile", slog.String("error", err.Error()))
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	
-	if existingProfile.UserID != userCtx.UserID && !auth.HasPermission(ctx, "admin") {
-		log.Warn("user trying to delete another user's profile",
-			slog.Int64("jwt_user_id", userCtx.UserID),
-			slog.Int64("profile_user_id", existingProfile.UserID))
-		return fmt.Errorf("%s: access denied", op)
+	err = s.authz.Authorize(ctx, authz.ActionProfileDelete, subject(userCtx), authz.Resource{ID: existingProfile.ID, UserID: existingProfile.UserID})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 	
+	flagImpersonated(log, userCtx)
+	
 	err = s.storage.DeleteProfile(ctx, id)
 	if err != nil {
 		if errors.Is(err, storage.ErrProfileNotFound) {
-			log.Warn("Profile not found")
+			log.WarnContext(ctx, "Profile not found")
 			return fmt.Errorf("%s: %w", op, err)
 		}
-		log.Error("Failed to delete profile", slog.String("error", err.Error()))
+		log.ErrorContext(ctx, "Failed to delete profile", slog.String("error", err.Error()))
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	
-	log.Info("Profile deleted successfully",
+	log.InfoContext(ctx, "Profile deleted successfully",
 		slog.String("profile_id", id),
 		slog.Int64("jwt_user_id", userCtx.UserID))
 	
@@ -299,23 +329,18 @@ func (s *Service) ListProfiles(ctx context.Context, filter domain.ListProfilesFi
 	
 	userCtx, err := auth.GetUserFromContext(ctx)
 	if err != nil {
-		log.Error("failed to get user from context", slog.String("error", err.Error()))
+		log.ErrorContext(ctx, "failed to get user from context", slog.String("error", err.Error()))
 		return nil, 0, fmt.Errorf("%s: %w", op, err)
 	}
 	
-	if !auth.HasPermission(ctx, "admin") {
-		log.Warn("user trying to list all profiles without admin permission",
-			slog.Int64("jwt_user_id", userCtx.UserID))
-		return nil, 0, fmt.Errorf("%s: access denied", op)
-	}
-	
+	// access to the whole list is checked by the method policy
 	profiles, total, err := s.storage.ListProfiles(ctx, filter)
 	if err != nil {
-		log.Error("Failed to list profiles", slog.String("error", err.Error()))
+		log.ErrorContext(ctx, "Failed to list profiles", slog.String("error", err.Error()))
 		return nil, 0, fmt.Errorf("%s: %w", op, err)
 	}
 	
-	log.Info("Profiles listed successfully",
+	log.InfoContext(ctx, "Profiles listed successfully",
 		slog.Int("count", len(profiles)),
 		slog.Int64("total", total),
 		slog.Int64("jwt_user_id", userCtx.UserID))
@@ -331,11 +356,11 @@ func (s *Service) GetMyProfile(ctx context.Context) (*domain.Profile, error) {
 	// Получаем пользователя из JWT контекста
 	userCtx, err := auth.GetUserFromContext(ctx)
 	if err != nil {
-		log.Error("failed to get user from context", slog.String("error", err.Error()))
+		log.ErrorContext(ctx, "failed to get user from context", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	
-	log.Info("getting current user profile", slog.Int64("user_id", userCtx.UserID))
+	log.InfoContext(ctx, "getting current user profile", slog.Int64("user_id", userCtx.UserID))
 	
 	return s.GetProfileByUserID(ctx, userCtx.UserID)
 }
@@ -0,0 +1,121 @@
+package provisioning
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"profile/internal/domain"
+	"profile/internal/lib/metrics"
+	"sso/pkg/logger/sl"
+	"time"
+
+	"sso/api/events"
+)
+
+// Storage applies the user events to the profiles
+type Storage interface {
+	ApplyUserEvent(ctx context.Context, event domain.UserEvent) (bool, error)
+	DeleteProcessedEvents(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Service keeps the profiles in sync with the sso users,
+// so clients don't have to call CreateProfile after registration
+type Service struct {
+	log       *slog.Logger
+	storage   Storage
+	retention time.Duration
+}
+
+func New(log *slog.Logger, storage Storage, retention time.Duration) *Service {
+	return &Service{
+		log:       log,
+		storage:   storage,
+		retention: retention,
+	}
+}
+
+// Handle applies the event, malformed and unknown events are dropped,
+// an error means the event must be redelivered
+func (s *Service) Handle(ctx context.Context, event events.Event) error {
+	const op = "services.provisioning.Handle"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("event_id", event.ID),
+		slog.String("type", event.Type),
+		slog.Int64("user_id", event.UserID),
+	)
+
+	if event.ID <= 0 || event.UserID <= 0 {
+		log.WarnContext(ctx, "dropped malformed event")
+		return nil
+	}
+
+	userEvent := domain.UserEvent{
+		ID:     event.ID,
+		Type:   event.Type,
+		UserID: event.UserID,
+	}
+
+	switch event.Type {
+	case events.TypeUserRegistered, events.TypeUserVerified, events.TypeUserUpdated:
+		user, err := event.User()
+		if err != nil || user.UserID != event.UserID || user.Email == "" {
+			log.WarnContext(ctx, "dropped malformed event")
+			return nil
+		}
+		userEvent.Name = user.Name
+		userEvent.Phone = user.Phone
+		userEvent.Address = user.Address
+		userEvent.Email = user.Email
+	case events.TypeUserDeleted:
+		userEvent.Deleted = true
+	default:
+		log.DebugContext(ctx, "skipped event")
+		return nil
+	}
+
+	applied, err := s.storage.ApplyUserEvent(ctx, userEvent)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to apply event", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !applied {
+		log.DebugContext(ctx, "event already applied")
+		return nil
+	}
+
+	if event.Type == events.TypeUserRegistered {
+		metrics.ProfilesCreated.WithLabelValues(metrics.SourceProvisioning).Inc()
+	}
+
+	log.InfoContext(ctx, "profile provisioned")
+	return nil
+}
+
+// Run forgets the handled events older than the retention every interval until the context is done
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	const op = "services.provisioning.Run"
+
+	log := s.log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.storage.DeleteProcessedEvents(ctx, time.Now().Add(-s.retention))
+			if err != nil {
+				log.ErrorContext(ctx, "failed to delete processed events", sl.Err(err))
+				continue
+			}
+			if deleted > 0 {
+				log.InfoContext(ctx, "deleted processed events", slog.Int64("count", deleted))
+			}
+		}
+	}
+}
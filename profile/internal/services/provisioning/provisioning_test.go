@@ -0,0 +1,175 @@
+package provisioning
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"profile/internal/domain"
+
+	"sso/api/events"
+)
+
+type memoryStorage struct {
+	applied []domain.UserEvent
+	seen    map[int64]bool
+	err     error
+}
+
+func (s *memoryStorage) ApplyUserEvent(_ context.Context, event domain.UserEvent) (bool, error) {
+	if s.err != nil {
+		return false, s.err
+	}
+	if s.seen == nil {
+		s.seen = make(map[int64]bool)
+	}
+	if s.seen[event.ID] {
+		return false, nil
+	}
+	s.seen[event.ID] = true
+	s.applied = append(s.applied, event)
+	return true, nil
+}
+
+func (s *memoryStorage) DeleteProcessedEvents(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func newService(storage Storage) *Service {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), storage, time.Hour)
+}
+
+func userEvent(t *testing.T, id int64, eventType string, payload events.UserPayload) events.Event {
+	t.Helper()
+
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	return events.Event{ID: id, Type: eventType, UserID: payload.UserID, Payload: data}
+}
+
+func TestHandle_Registered(t *testing.T) {
+	storage := &memoryStorage{}
+
+	err := newService(storage).Handle(context.Background(), userEvent(t, 1, events.TypeUserRegistered, events.UserPayload{
+		UserID:  7,
+		Email:   "user@example.com",
+		Name:    "User",
+		Phone:   "+100",
+		Address: "Street 1",
+	}))
+	require.NoError(t, err)
+
+	require.Len(t, storage.applied, 1)
+	assert.Equal(t, domain.UserEvent{
+		ID:      1,
+		Type:    events.TypeUserRegistered,
+		UserID:  7,
+		Name:    "User",
+		Phone:   "+100",
+		Address: "Street 1",
+		Email:   "user@example.com",
+	}, storage.applied[0])
+}
+
+func TestHandle_Updated(t *testing.T) {
+	storage := &memoryStorage{}
+
+	err := newService(storage).Handle(context.Background(), userEvent(t, 2, events.TypeUserUpdated, events.UserPayload{
+		UserID:   7,
+		Email:    "new@example.com",
+		Name:     "New Name",
+		Disabled: true,
+	}))
+	require.NoError(t, err)
+
+	require.Len(t, storage.applied, 1)
+	assert.Equal(t, "new@example.com", storage.applied[0].Email)
+	assert.Equal(t, "New Name", storage.applied[0].Name)
+	assert.False(t, storage.applied[0].Deleted)
+}
+
+func TestHandle_Deleted(t *testing.T) {
+	storage := &memoryStorage{}
+
+	err := newService(storage).Handle(context.Background(), events.Event{
+		ID:      3,
+		Type:    events.TypeUserDeleted,
+		UserID:  7,
+		Payload: json.RawMessage(`{"user_id":7}`),
+	})
+	require.NoError(t, err)
+
+	require.Len(t, storage.applied, 1)
+	assert.True(t, storage.applied[0].Deleted)
+	assert.Equal(t, int64(7), storage.applied[0].UserID)
+}
+
+func TestHandle_Redelivered(t *testing.T) {
+	storage := &memoryStorage{}
+	service := newService(storage)
+	event := userEvent(t, 1, events.TypeUserRegistered, events.UserPayload{UserID: 7, Email: "user@example.com"})
+
+	require.NoError(t, service.Handle(context.Background(), event))
+	require.NoError(t, service.Handle(context.Background(), event))
+
+	assert.Len(t, storage.applied, 1)
+}
+
+func TestHandle_DropsMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		event events.Event
+	}{
+		{
+			name:  "no id",
+			event: events.Event{Type: events.TypeUserRegistered, UserID: 7, Payload: json.RawMessage(`{"user_id":7,"email":"a@b.c"}`)},
+		},
+		{
+			name:  "no user",
+			event: events.Event{ID: 1, Type: events.TypeUserRegistered, Payload: json.RawMessage(`{"email":"a@b.c"}`)},
+		},
+		{
+			name:  "invalid payload",
+			event: events.Event{ID: 1, Type: events.TypeUserRegistered, UserID: 7, Payload: json.RawMessage(`"user"`)},
+		},
+		{
+			name:  "payload of another user",
+			event: events.Event{ID: 1, Type: events.TypeUserRegistered, UserID: 7, Payload: json.RawMessage(`{"user_id":8,"email":"a@b.c"}`)},
+		},
+		{
+			name:  "no email",
+			event: events.Event{ID: 1, Type: events.TypeUserVerified, UserID: 7, Payload: json.RawMessage(`{"user_id":7}`)},
+		},
+		{
+			name:  "unknown type",
+			event: events.Event{ID: 1, Type: "user.renamed", UserID: 7, Payload: json.RawMessage(`{"user_id":7,"email":"a@b.c"}`)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &memoryStorage{}
+
+			require.NoError(t, newService(storage).Handle(context.Background(), tt.event))
+			assert.Empty(t, storage.applied)
+		})
+	}
+}
+
+func TestHandle_StorageErrorRedelivers(t *testing.T) {
+	storage := &memoryStorage{err: errors.New("connection refused")}
+
+	err := newService(storage).Handle(context.Background(), userEvent(t, 1, events.TypeUserRegistered, events.UserPayload{
+		UserID: 7,
+		Email:  "user@example.com",
+	}))
+	require.Error(t, err)
+}
@@ -10,6 +10,7 @@ import (
 
 	"profile/internal/domain"
 	"profile/internal/storage"
+	"sso/pkg/tracing"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -42,19 +43,11 @@ func Config(dsn string) *pgxpool.Config {
 	dbConfig.MaxConnIdleTime = defaultMaxConnIdleTime
 	dbConfig.HealthCheckPeriod = defaultHealthCheckPeriod
 	dbConfig.ConnConfig.ConnectTimeout = defaultConnectTimeout
+	dbConfig.ConnConfig.Tracer = tracing.NewQueryTracer()
 
-	dbConfig.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
-		slog.Info("Before acquiring the connection pool to the database")
-		return true
-	}
-
-	dbConfig.AfterRelease = func(conn *pgx.Conn) bool {
-		slog.Info("After releasing the connection pool to the database")
-		return true
-	}
-
+	// acquires and releases are counted by the pool metrics instead of logged
 	dbConfig.BeforeClose = func(conn *pgx.Conn) {
-		slog.Info("Closed the connection pool to the database")
+		slog.Debug("Closed the connection pool to the database")
 	}
 
 	return dbConfig
@@ -81,6 +74,11 @@ func (s *Storage) Close() error {
 	return nil
 }
 
+// Stat returns the stats of the connection pool for the metrics
+func (s *Storage) Stat() *pgxpool.Stat {
+	return s.db.Stat()
+}
+
 func (s *Storage) CreateProfile(ctx context.Context, req domain.CreateProfileRequest) (*domain.Profile, error) {
 	const op = "storage.postgres.CreateProfile"
 
@@ -186,26 +184,37 @@ func (s *Storage) UpdateProfile(ctx context.Context, req domain.UpdateProfileReq
 	var args []interface{}
 	argIndex := 1
 
+	// edited fields stop following the sso events
+	var edited []string
+
 	if req.Name != nil {
 		setParts = append(setParts, fmt.Sprintf("name = $%d", argIndex))
 		args = append(args, *req.Name)
 		argIndex++
+		edited = append(edited, "name")
 	}
 	if req.Phone != nil {
 		setParts = append(setParts, fmt.Sprintf("phone = $%d", argIndex))
 		args = append(args, *req.Phone)
 		argIndex++
+		edited = append(edited, "phone")
 	}
 	if req.Address != nil {
 		setParts = append(setParts, fmt.Sprintf("address = $%d", argIndex))
 		args = append(args, *req.Address)
 		argIndex++
+		edited = append(edited, "address")
 	}
 
 	if len(setParts) == 0 {
 		return s.GetProfile(ctx, req.ID)
 	}
 
+	setParts = append(setParts, fmt.Sprintf(
+		"edited_fields = ARRAY(SELECT DISTINCT unnest(edited_fields || $%d::text[]))", argIndex))
+	args = append(args, edited)
+	argIndex++
+
 	query := fmt.Sprintf(`
 		UPDATE profiles
 		SET %s
@@ -334,3 +343,73 @@ func (s *Storage) ListProfiles(ctx context.Context, filter domain.ListProfilesFi
 
 	return profiles, total, nil
 }
+
+// ApplyUserEvent creates, updates or deletes the profile of the event user in one transaction with the
+// record of the event, returns false if the event was already applied or is older than the profile state.
+// Email always follows sso, name, phone and address follow it until they are edited in profile
+func (s *Storage) ApplyUserEvent(ctx context.Context, event domain.UserEvent) (bool, error) {
+	const op = "storage.postgres.ApplyUserEvent"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO processed_events (event_id, user_id, type)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (event_id) DO NOTHING
+	`, event.ID, event.UserID, event.Type)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if event.Deleted {
+		tag, err = tx.Exec(ctx, `DELETE FROM profiles WHERE user_id = $1`, event.UserID)
+	} else {
+		// a later deletion of the user wins over the events redelivered after it
+		tag, err = tx.Exec(ctx, `
+			INSERT INTO profiles (id, user_id, name, phone, address, email, source_event_id)
+			SELECT $1, $2, $3, $4, $5, $6, $7
+			WHERE NOT EXISTS (
+				SELECT 1 FROM processed_events
+				WHERE user_id = $2 AND type = 'user.deleted' AND event_id > $7
+			)
+			ON CONFLICT (user_id) DO UPDATE
+			SET email = EXCLUDED.email,
+			    name = CASE WHEN 'name' = ANY(profiles.edited_fields) THEN profiles.name ELSE EXCLUDED.name END,
+			    phone = CASE WHEN 'phone' = ANY(profiles.edited_fields) THEN profiles.phone ELSE EXCLUDED.phone END,
+			    address = CASE WHEN 'address' = ANY(profiles.edited_fields) THEN profiles.address ELSE EXCLUDED.address END,
+			    source_event_id = EXCLUDED.source_event_id
+			WHERE profiles.source_event_id < EXCLUDED.source_event_id
+		`, uuid.New().String(), event.UserID, event.Name, event.Phone, event.Address, event.Email, event.ID)
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteProcessedEvents forgets the events handled before the time, deletions are kept as tombstones
+func (s *Storage) DeleteProcessedEvents(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.postgres.DeleteProcessedEvents"
+
+	tag, err := s.db.Exec(ctx, `
+		DELETE FROM processed_events
+		WHERE processed_at < $1 AND type <> 'user.deleted'
+	`, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
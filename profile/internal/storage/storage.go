@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"profile/internal/domain"
+	"time"
 )
 
 var (
@@ -20,3 +21,9 @@ type ProfileStorage interface {
 	ListProfiles(ctx context.Context, filter domain.ListProfilesFilter) ([]*domain.Profile, int64, error)
 	Close() error
 }
+
+// ProvisioningStorage applies the sso user events to the profiles
+type ProvisioningStorage interface {
+	ApplyUserEvent(ctx context.Context, event domain.UserEvent) (bool, error)
+	DeleteProcessedEvents(ctx context.Context, before time.Time) (int64, error)
+}
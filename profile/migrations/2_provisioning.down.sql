@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS processed_events;
+
+ALTER TABLE profiles DROP COLUMN IF EXISTS source_event_id;
@@ -0,0 +1,14 @@
+-- last sso event applied to the profile, older redelivered events don't overwrite it
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS source_event_id BIGINT NOT NULL DEFAULT 0;
+
+-- sso events already handled, delivery is at least once
+CREATE TABLE IF NOT EXISTS processed_events (
+    event_id BIGINT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    type VARCHAR(64) NOT NULL,
+    processed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- deletions are kept as tombstones, late events of the deleted user don't recreate the profile
+CREATE INDEX IF NOT EXISTS idx_processed_events_deleted ON processed_events(user_id) WHERE type = 'user.deleted';
+CREATE INDEX IF NOT EXISTS idx_processed_events_processed_at ON processed_events(processed_at);
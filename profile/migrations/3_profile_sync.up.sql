@@ -0,0 +1,2 @@
+-- fields edited in profile, the sso events don't overwrite them anymore
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS edited_fields TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,54 @@
+// Package events is the contract of the user lifecycle events published by sso,
+// other services decode the messages with these types
+package events
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Event types
+const (
+	TypeUserRegistered = "user.registered"
+	TypeUserVerified   = "user.verified"
+	TypeUserUpdated    = "user.updated"
+	TypeUserDeleted    = "user.deleted"
+)
+
+// Event is the envelope of every message, delivery is at least once,
+// so consumers drop the events with an ID they already handled
+type Event struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	UserID     int64           `json:"user_id"`
+	Payload    json.RawMessage `json:"payload"`
+	OccurredAt time.Time       `json:"occurred_at"`
+
+	// AppIDs are the apps of the user when the event happened, sso scopes the webhooks with them,
+	// they aren't sent to the broker
+	AppIDs []int32 `json:"-"`
+}
+
+// Key is the partition key, events of a user keep their order
+func (e Event) Key() string {
+	return strconv.FormatInt(e.UserID, 10)
+}
+
+// User decodes the payload of the user events
+func (e Event) User() (UserPayload, error) {
+	var payload UserPayload
+	err := json.Unmarshal(e.Payload, &payload)
+	return payload, err
+}
+
+// UserPayload is the state of the user after the change, user.deleted carries only the id
+type UserPayload struct {
+	UserID    int64  `json:"user_id"`
+	Email     string `json:"email,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Phone     string `json:"phone,omitempty"`
+	Address   string `json:"address,omitempty"`
+	Activated bool   `json:"activated"`
+	Disabled  bool   `json:"disabled"`
+}
@@ -0,0 +1,290 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: sso/email_queue.proto
+
+package apiv1
+
+import (
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetEmailQueueStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetEmailQueueStatsRequest) Reset() {
+	*x = GetEmailQueueStatsRequest{}
+	mi := &file_sso_email_queue_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetEmailQueueStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetEmailQueueStatsRequest) ProtoMessage() {}
+
+func (x *GetEmailQueueStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sso_email_queue_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetEmailQueueStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetEmailQueueStatsRequest) Descriptor() ([]byte, []int) {
+	return file_sso_email_queue_proto_rawDescGZIP(), []int{0}
+}
+
+type GetEmailQueueStatsResponse struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Pending           int64                  `protobuf:"varint,1,opt,name=pending,proto3" json:"pending,omitempty"`
+	Due               int64                  `protobuf:"varint,2,opt,name=due,proto3" json:"due,omitempty"`                                                        // pending and due for sending now
+	Dead              int64                  `protobuf:"varint,3,opt,name=dead,proto3" json:"dead,omitempty"`                                                      // rejected or out of attempts
+	OldestPendingUnix int64                  `protobuf:"varint,4,opt,name=oldest_pending_unix,json=oldestPendingUnix,proto3" json:"oldest_pending_unix,omitempty"` // zero if nothing is pending
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *GetEmailQueueStatsResponse) Reset() {
+	*x = GetEmailQueueStatsResponse{}
+	mi := &file_sso_email_queue_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetEmailQueueStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetEmailQueueStatsResponse) ProtoMessage() {}
+
+func (x *GetEmailQueueStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sso_email_queue_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetEmailQueueStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetEmailQueueStatsResponse) Descriptor() ([]byte, []int) {
+	return file_sso_email_queue_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetEmailQueueStatsResponse) GetPending() int64 {
+	if x != nil {
+		return x.Pending
+	}
+	return 0
+}
+
+func (x *GetEmailQueueStatsResponse) GetDue() int64 {
+	if x != nil {
+		return x.Due
+	}
+	return 0
+}
+
+func (x *GetEmailQueueStatsResponse) GetDead() int64 {
+	if x != nil {
+		return x.Dead
+	}
+	return 0
+}
+
+func (x *GetEmailQueueStatsResponse) GetOldestPendingUnix() int64 {
+	if x != nil {
+		return x.OldestPendingUnix
+	}
+	return 0
+}
+
+type RequeueDeadEmailsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ids           []int64                `protobuf:"varint,1,rep,packed,name=ids,proto3" json:"ids,omitempty"` // all dead emails if empty
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequeueDeadEmailsRequest) Reset() {
+	*x = RequeueDeadEmailsRequest{}
+	mi := &file_sso_email_queue_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequeueDeadEmailsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequeueDeadEmailsRequest) ProtoMessage() {}
+
+func (x *RequeueDeadEmailsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sso_email_queue_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequeueDeadEmailsRequest.ProtoReflect.Descriptor instead.
+func (*RequeueDeadEmailsRequest) Descriptor() ([]byte, []int) {
+	return file_sso_email_queue_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RequeueDeadEmailsRequest) GetIds() []int64 {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+type RequeueDeadEmailsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Requeued      int64                  `protobuf:"varint,1,opt,name=requeued,proto3" json:"requeued,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequeueDeadEmailsResponse) Reset() {
+	*x = RequeueDeadEmailsResponse{}
+	mi := &file_sso_email_queue_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequeueDeadEmailsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequeueDeadEmailsResponse) ProtoMessage() {}
+
+func (x *RequeueDeadEmailsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sso_email_queue_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequeueDeadEmailsResponse.ProtoReflect.Descriptor instead.
+func (*RequeueDeadEmailsResponse) Descriptor() ([]byte, []int) {
+	return file_sso_email_queue_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RequeueDeadEmailsResponse) GetRequeued() int64 {
+	if x != nil {
+		return x.Requeued
+	}
+	return 0
+}
+
+var File_sso_email_queue_proto protoreflect.FileDescriptor
+
+const file_sso_email_queue_proto_rawDesc = "" +
+	"\n" +
+	"\x15sso/email_queue.proto\x12\x04auth\x1a\x1cgoogle/api/annotations.proto\"\x1b\n" +
+	"\x19GetEmailQueueStatsRequest\"\x8c\x01\n" +
+	"\x1aGetEmailQueueStatsResponse\x12\x18\n" +
+	"\apending\x18\x01 \x01(\x03R\apending\x12\x10\n" +
+	"\x03due\x18\x02 \x01(\x03R\x03due\x12\x12\n" +
+	"\x04dead\x18\x03 \x01(\x03R\x04dead\x12.\n" +
+	"\x13oldest_pending_unix\x18\x04 \x01(\x03R\x11oldestPendingUnix\",\n" +
+	"\x18RequeueDeadEmailsRequest\x12\x10\n" +
+	"\x03ids\x18\x01 \x03(\x03R\x03ids\"7\n" +
+	"\x19RequeueDeadEmailsResponse\x12\x1a\n" +
+	"\brequeued\x18\x01 \x01(\x03R\brequeued2\x84\x02\n" +
+	"\n" +
+	"EmailQueue\x12v\n" +
+	"\x12GetEmailQueueStats\x12\x1f.auth.GetEmailQueueStatsRequest\x1a .auth.GetEmailQueueStatsResponse\"\x1d\x82\xd3\xe4\x93\x02\x17\x12\x15/v1/admin/email-queue\x12~\n" +
+	"\x11RequeueDeadEmails\x12\x1e.auth.RequeueDeadEmailsRequest\x1a\x1f.auth.RequeueDeadEmailsResponse\"(\x82\xd3\xe4\x93\x02\":\x01*\"\x1d/v1/admin/email-queue/requeueB\x1aZ\x18sso/api/gen/go/sso;apiv1b\x06proto3"
+
+var (
+	file_sso_email_queue_proto_rawDescOnce sync.Once
+	file_sso_email_queue_proto_rawDescData []byte
+)
+
+func file_sso_email_queue_proto_rawDescGZIP() []byte {
+	file_sso_email_queue_proto_rawDescOnce.Do(func() {
+		file_sso_email_queue_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_sso_email_queue_proto_rawDesc), len(file_sso_email_queue_proto_rawDesc)))
+	})
+	return file_sso_email_queue_proto_rawDescData
+}
+
+var file_sso_email_queue_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_sso_email_queue_proto_goTypes = []any{
+	(*GetEmailQueueStatsRequest)(nil),  // 0: auth.GetEmailQueueStatsRequest
+	(*GetEmailQueueStatsResponse)(nil), // 1: auth.GetEmailQueueStatsResponse
+	(*RequeueDeadEmailsRequest)(nil),   // 2: auth.RequeueDeadEmailsRequest
+	(*RequeueDeadEmailsResponse)(nil),  // 3: auth.RequeueDeadEmailsResponse
+}
+var file_sso_email_queue_proto_depIdxs = []int32{
+	0, // 0: auth.EmailQueue.GetEmailQueueStats:input_type -> auth.GetEmailQueueStatsRequest
+	2, // 1: auth.EmailQueue.RequeueDeadEmails:input_type -> auth.RequeueDeadEmailsRequest
+	1, // 2: auth.EmailQueue.GetEmailQueueStats:output_type -> auth.GetEmailQueueStatsResponse
+	3, // 3: auth.EmailQueue.RequeueDeadEmails:output_type -> auth.RequeueDeadEmailsResponse
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_sso_email_queue_proto_init() }
+func file_sso_email_queue_proto_init() {
+	if File_sso_email_queue_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_sso_email_queue_proto_rawDesc), len(file_sso_email_queue_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_sso_email_queue_proto_goTypes,
+		DependencyIndexes: file_sso_email_queue_proto_depIdxs,
+		MessageInfos:      file_sso_email_queue_proto_msgTypes,
+	}.Build()
+	File_sso_email_queue_proto = out.File
+	file_sso_email_queue_proto_goTypes = nil
+	file_sso_email_queue_proto_depIdxs = nil
+}
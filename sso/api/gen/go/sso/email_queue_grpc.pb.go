@@ -0,0 +1,165 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: sso/email_queue.proto
+
+package apiv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	EmailQueue_GetEmailQueueStats_FullMethodName = "/auth.EmailQueue/GetEmailQueueStats"
+	EmailQueue_RequeueDeadEmails_FullMethodName  = "/auth.EmailQueue/RequeueDeadEmails"
+)
+
+// EmailQueueClient is the client API for EmailQueue service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// EmailQueue exposes the state of the outgoing email queue and requeues the dead emails
+type EmailQueueClient interface {
+	GetEmailQueueStats(ctx context.Context, in *GetEmailQueueStatsRequest, opts ...grpc.CallOption) (*GetEmailQueueStatsResponse, error)
+	// RequeueDeadEmails sends the dead emails again with fresh attempts
+	RequeueDeadEmails(ctx context.Context, in *RequeueDeadEmailsRequest, opts ...grpc.CallOption) (*RequeueDeadEmailsResponse, error)
+}
+
+type emailQueueClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEmailQueueClient(cc grpc.ClientConnInterface) EmailQueueClient {
+	return &emailQueueClient{cc}
+}
+
+func (c *emailQueueClient) GetEmailQueueStats(ctx context.Context, in *GetEmailQueueStatsRequest, opts ...grpc.CallOption) (*GetEmailQueueStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetEmailQueueStatsResponse)
+	err := c.cc.Invoke(ctx, EmailQueue_GetEmailQueueStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *emailQueueClient) RequeueDeadEmails(ctx context.Context, in *RequeueDeadEmailsRequest, opts ...grpc.CallOption) (*RequeueDeadEmailsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RequeueDeadEmailsResponse)
+	err := c.cc.Invoke(ctx, EmailQueue_RequeueDeadEmails_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// EmailQueueServer is the server API for EmailQueue service.
+// All implementations must embed UnimplementedEmailQueueServer
+// for forward compatibility.
+//
+// EmailQueue exposes the state of the outgoing email queue and requeues the dead emails
+type EmailQueueServer interface {
+	GetEmailQueueStats(context.Context, *GetEmailQueueStatsRequest) (*GetEmailQueueStatsResponse, error)
+	// RequeueDeadEmails sends the dead emails again with fresh attempts
+	RequeueDeadEmails(context.Context, *RequeueDeadEmailsRequest) (*RequeueDeadEmailsResponse, error)
+	mustEmbedUnimplementedEmailQueueServer()
+}
+
+// UnimplementedEmailQueueServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedEmailQueueServer struct{}
+
+func (UnimplementedEmailQueueServer) GetEmailQueueStats(context.Context, *GetEmailQueueStatsRequest) (*GetEmailQueueStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEmailQueueStats not implemented")
+}
+func (UnimplementedEmailQueueServer) RequeueDeadEmails(context.Context, *RequeueDeadEmailsRequest) (*RequeueDeadEmailsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RequeueDeadEmails not implemented")
+}
+func (UnimplementedEmailQueueServer) mustEmbedUnimplementedEmailQueueServer() {}
+func (UnimplementedEmailQueueServer) testEmbeddedByValue()                    {}
+
+// UnsafeEmailQueueServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EmailQueueServer will
+// result in compilation errors.
+type UnsafeEmailQueueServer interface {
+	mustEmbedUnimplementedEmailQueueServer()
+}
+
+func RegisterEmailQueueServer(s grpc.ServiceRegistrar, srv EmailQueueServer) {
+	// If the following call pancis, it indicates UnimplementedEmailQueueServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&EmailQueue_ServiceDesc, srv)
+}
+
+func _EmailQueue_GetEmailQueueStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEmailQueueStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EmailQueueServer).GetEmailQueueStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EmailQueue_GetEmailQueueStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EmailQueueServer).GetEmailQueueStats(ctx, req.(*GetEmailQueueStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EmailQueue_RequeueDeadEmails_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequeueDeadEmailsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EmailQueueServer).RequeueDeadEmails(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EmailQueue_RequeueDeadEmails_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EmailQueueServer).RequeueDeadEmails(ctx, req.(*RequeueDeadEmailsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// EmailQueue_ServiceDesc is the grpc.ServiceDesc for EmailQueue service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EmailQueue_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "auth.EmailQueue",
+	HandlerType: (*EmailQueueServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetEmailQueueStats",
+			Handler:    _EmailQueue_GetEmailQueueStats_Handler,
+		},
+		{
+			MethodName: "RequeueDeadEmails",
+			Handler:    _EmailQueue_RequeueDeadEmails_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sso/email_queue.proto",
+}
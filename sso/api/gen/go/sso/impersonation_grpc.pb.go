@@ -0,0 +1,127 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: sso/impersonation.proto
+
+package apiv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Impersonation_Impersonate_FullMethodName = "/auth.Impersonation/Impersonate"
+)
+
+// ImpersonationClient is the client API for Impersonation service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Impersonation lets support staff act as a user
+type ImpersonationClient interface {
+	// Impersonate issues a short-lived access token of the user carrying the caller in the "act" claim
+	Impersonate(ctx context.Context, in *ImpersonateRequest, opts ...grpc.CallOption) (*ImpersonateResponse, error)
+}
+
+type impersonationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewImpersonationClient(cc grpc.ClientConnInterface) ImpersonationClient {
+	return &impersonationClient{cc}
+}
+
+func (c *impersonationClient) Impersonate(ctx context.Context, in *ImpersonateRequest, opts ...grpc.CallOption) (*ImpersonateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ImpersonateResponse)
+	err := c.cc.Invoke(ctx, Impersonation_Impersonate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ImpersonationServer is the server API for Impersonation service.
+// All implementations must embed UnimplementedImpersonationServer
+// for forward compatibility.
+//
+// Impersonation lets support staff act as a user
+type ImpersonationServer interface {
+	// Impersonate issues a short-lived access token of the user carrying the caller in the "act" claim
+	Impersonate(context.Context, *ImpersonateRequest) (*ImpersonateResponse, error)
+	mustEmbedUnimplementedImpersonationServer()
+}
+
+// UnimplementedImpersonationServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedImpersonationServer struct{}
+
+func (UnimplementedImpersonationServer) Impersonate(context.Context, *ImpersonateRequest) (*ImpersonateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Impersonate not implemented")
+}
+func (UnimplementedImpersonationServer) mustEmbedUnimplementedImpersonationServer() {}
+func (UnimplementedImpersonationServer) testEmbeddedByValue()                       {}
+
+// UnsafeImpersonationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ImpersonationServer will
+// result in compilation errors.
+type UnsafeImpersonationServer interface {
+	mustEmbedUnimplementedImpersonationServer()
+}
+
+func RegisterImpersonationServer(s grpc.ServiceRegistrar, srv ImpersonationServer) {
+	// If the following call pancis, it indicates UnimplementedImpersonationServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Impersonation_ServiceDesc, srv)
+}
+
+func _Impersonation_Impersonate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImpersonateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImpersonationServer).Impersonate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Impersonation_Impersonate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ImpersonationServer).Impersonate(ctx, req.(*ImpersonateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Impersonation_ServiceDesc is the grpc.ServiceDesc for Impersonation service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Impersonation_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "auth.Impersonation",
+	HandlerType: (*ImpersonationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Impersonate",
+			Handler:    _Impersonation_Impersonate_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sso/impersonation.proto",
+}
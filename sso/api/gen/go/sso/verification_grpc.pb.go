@@ -0,0 +1,125 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: sso/verification.proto
+
+package apiv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Verification_ResendVerification_FullMethodName = "/auth.Verification/ResendVerification"
+)
+
+// VerificationClient is the client API for Verification service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Verification resends the verification email to users who are not logged in
+type VerificationClient interface {
+	ResendVerification(ctx context.Context, in *ResendVerificationRequest, opts ...grpc.CallOption) (*ResendVerificationResponse, error)
+}
+
+type verificationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVerificationClient(cc grpc.ClientConnInterface) VerificationClient {
+	return &verificationClient{cc}
+}
+
+func (c *verificationClient) ResendVerification(ctx context.Context, in *ResendVerificationRequest, opts ...grpc.CallOption) (*ResendVerificationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResendVerificationResponse)
+	err := c.cc.Invoke(ctx, Verification_ResendVerification_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// VerificationServer is the server API for Verification service.
+// All implementations must embed UnimplementedVerificationServer
+// for forward compatibility.
+//
+// Verification resends the verification email to users who are not logged in
+type VerificationServer interface {
+	ResendVerification(context.Context, *ResendVerificationRequest) (*ResendVerificationResponse, error)
+	mustEmbedUnimplementedVerificationServer()
+}
+
+// UnimplementedVerificationServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedVerificationServer struct{}
+
+func (UnimplementedVerificationServer) ResendVerification(context.Context, *ResendVerificationRequest) (*ResendVerificationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResendVerification not implemented")
+}
+func (UnimplementedVerificationServer) mustEmbedUnimplementedVerificationServer() {}
+func (UnimplementedVerificationServer) testEmbeddedByValue()                      {}
+
+// UnsafeVerificationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VerificationServer will
+// result in compilation errors.
+type UnsafeVerificationServer interface {
+	mustEmbedUnimplementedVerificationServer()
+}
+
+func RegisterVerificationServer(s grpc.ServiceRegistrar, srv VerificationServer) {
+	// If the following call pancis, it indicates UnimplementedVerificationServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Verification_ServiceDesc, srv)
+}
+
+func _Verification_ResendVerification_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResendVerificationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VerificationServer).ResendVerification(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Verification_ResendVerification_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VerificationServer).ResendVerification(ctx, req.(*ResendVerificationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Verification_ServiceDesc is the grpc.ServiceDesc for Verification service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Verification_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "auth.Verification",
+	HandlerType: (*VerificationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ResendVerification",
+			Handler:    _Verification_ResendVerification_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sso/verification.proto",
+}
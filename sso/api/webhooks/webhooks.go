@@ -0,0 +1,63 @@
+// Package webhooks is the contract of the webhook requests sent by sso,
+// receivers verify the signature with the secret of their app
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of the webhook request, the body is the JSON envelope of sso/api/events
+const (
+	HeaderDeliveryID = "X-Sso-Delivery-Id"
+	HeaderEventType  = "X-Sso-Event"
+	HeaderTimestamp  = "X-Sso-Timestamp" // unix seconds, part of the signed message
+	HeaderSignature  = "X-Sso-Signature" // "sha256=<hex>"
+)
+
+const signaturePrefix = "sha256="
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrExpiredTimestamp = errors.New("webhook timestamp is outside the tolerance")
+)
+
+// Sign returns the signature header value, HMAC-SHA256 of "<timestamp>.<body>" keyed with the app secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and rejects timestamps older or newer than tolerance,
+// so captured requests can't be replayed later
+func Verify(secret string, signature string, timestamp string, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if tolerance > 0 {
+		age := time.Since(time.Unix(ts, 0))
+		if age > tolerance || age < -tolerance {
+			return ErrExpiredTimestamp
+		}
+	}
+
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sso/internal/lib/locale"
+	"sso/internal/lib/mailer"
+	"time"
+)
+
+// mailpreview renders the email templates with sample data, so the designers can check them in a browser
+// without sending anything:
+//
+//	go run ./cmd/mailpreview --templates ./templates/email --out ./preview
+//	go run ./cmd/mailpreview --template verification --locale kk --logo https://toys.example.com/logo.png --color "#ff6600"
+func main() {
+	var (
+		templatesDir string
+		name         string
+		loc          string
+		out          string
+		data         mailer.TemplateData
+	)
+
+	// the same directory as email.templates in config, empty renders the embedded templates
+	flag.StringVar(&templatesDir, "templates", "", "directory overriding the embedded templates")
+	flag.StringVar(&name, "template", "", "template to render, all of them if empty")
+	flag.StringVar(&loc, "locale", "", "locale to render, all of them if empty")
+	// without the output directory the single template is printed
+	flag.StringVar(&out, "out", "", "directory to write <template>.<locale>.html and .txt to")
+	// branding of the app, as stored in the apps table
+	flag.StringVar(&data.AppName, "app-name", "toys", "name of the app")
+	flag.StringVar(&data.LogoURL, "logo", "", "logo URL of the app")
+	flag.StringVar(&data.Color, "color", mailer.DefaultColor, "color of the buttons")
+	flag.Parse()
+
+	templates, err := mailer.LoadTemplates(templatesDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	names := templates.Names()
+	if name != "" {
+		if !slices.Contains(names, name) {
+			log.Fatalf("unknown template %q, one of %v", name, names)
+		}
+		names = []string{name}
+	}
+
+	locales := locale.Supported()
+	if loc != "" {
+		locales = []string{loc}
+	}
+
+	if out == "" && (len(names) != 1 || len(locales) != 1) {
+		log.Fatal("choose a --template and a --locale or set --out")
+	}
+
+	if out != "" {
+		if err := os.MkdirAll(out, 0o755); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	data.Name = "Aigerim"
+	data.URL = "https://sso.example.com/v1/auth/verification/confirm/sample-token"
+	data.Date = time.Now().Add(30 * 24 * time.Hour).UTC().Format(time.RFC1123)
+
+	for _, name := range names {
+		for _, loc := range locales {
+			subject, html, text, err := templates.Render(loc, name, data)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if out == "" {
+				fmt.Printf("Subject: %s\n\n%s\n%s", subject, text, html)
+				return
+			}
+
+			base := filepath.Join(out, name+"."+loc)
+			if err := os.WriteFile(base+".html", []byte(html), 0o644); err != nil {
+				log.Fatal(err)
+			}
+			if err := os.WriteFile(base+".txt", []byte("Subject: "+subject+"\n\n"+text), 0o644); err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("%s.html, %s.txt written", base, base)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// plugins are run as go tools declared in go.mod, so no protoc installation is needed
+var plugins = []string{
+	"protoc-gen-go",
+	"protoc-gen-go-grpc",
+	"protoc-gen-grpc-gateway",
+}
+
+// protogen compiles sso own proto contracts (api/proto/sso) and generates
+// the protobuf, gRPC and gateway code into api/gen/go
+//
+//	go run ./cmd/protogen --proto ./api/proto --out ./api/gen/go
+func main() {
+	var protoDir, outDir string
+
+	flag.StringVar(&protoDir, "proto", "./api/proto", "path to the proto sources")
+	flag.StringVar(&outDir, "out", "./api/gen/go", "path to the generated code")
+	flag.Parse()
+
+	targets, err := protoFiles(protoDir, "sso")
+	if err != nil {
+		panic(err)
+	}
+
+	request, err := codeGeneratorRequest(protoDir, targets)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, plugin := range plugins {
+		if err := runPlugin(plugin, request, outDir); err != nil {
+			panic(err)
+		}
+	}
+
+	fmt.Println("generated", len(targets), "proto files")
+}
+
+// protoFiles returns proto files of the package dir relative to the import path
+func protoFiles(protoDir string, pkgDir string) ([]string, error) {
+	var files []string
+
+	err := filepath.WalkDir(filepath.Join(protoDir, pkgDir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".proto" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(protoDir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list proto files: %w", err)
+	}
+
+	return files, nil
+}
+
+func codeGeneratorRequest(protoDir string, targets []string) (*pluginpb.CodeGeneratorRequest, error) {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			ImportPaths: []string{protoDir},
+		}),
+		SourceInfoMode: protocompile.SourceInfoStandard,
+	}
+
+	files, err := compiler.Compile(context.Background(), targets...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile proto files: %w", err)
+	}
+
+	// plugins expect every dependency before the files importing it
+	var protoFiles []*descriptorpb.FileDescriptorProto
+	seen := make(map[string]bool)
+
+	var add func(file protoreflect.FileDescriptor)
+	add = func(file protoreflect.FileDescriptor) {
+		if seen[file.Path()] {
+			return
+		}
+		seen[file.Path()] = true
+
+		imports := file.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+
+		protoFiles = append(protoFiles, protodesc.ToFileDescriptorProto(file))
+	}
+
+	for _, file := range files {
+		add(file)
+	}
+
+	return &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: targets,
+		Parameter:      proto.String("paths=source_relative"),
+		ProtoFile:      protoFiles,
+	}, nil
+}
+
+func runPlugin(plugin string, request *pluginpb.CodeGeneratorRequest, outDir string) error {
+	input, err := proto.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("%s: failed to marshal request: %w", plugin, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("go", "tool", plugin)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w: %s", plugin, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var response pluginpb.CodeGeneratorResponse
+	if err := proto.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return fmt.Errorf("%s: failed to unmarshal response: %w", plugin, err)
+	}
+
+	if response.Error != nil {
+		return fmt.Errorf("%s: %s", plugin, response.GetError())
+	}
+
+	for _, file := range response.File {
+		path := filepath.Join(outDir, file.GetName())
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("%s: %w", plugin, err)
+		}
+
+		if err := os.WriteFile(path, []byte(file.GetContent()), 0o644); err != nil {
+			return fmt.Errorf("%s: %w", plugin, err)
+		}
+	}
+
+	return nil
+}
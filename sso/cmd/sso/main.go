@@ -11,7 +11,11 @@ import (
 	"os/signal"
 	"sso/internal/app"
 	"sso/internal/config"
-	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/mailer"
+	"sso/internal/lib/publisher"
+	"sso/internal/services/auth"
+	"sso/pkg/logger/sl"
+	"sso/pkg/tracing"
 	"syscall"
 	"time"
 )
@@ -31,24 +35,109 @@ func main() {
 
 	// Initialize logger
 	log := setupLogger(cfg.Env)
+	// the package-level slog calls are redacted too
+	slog.SetDefault(log)
+
+	// spans are exported in the background, the rest are flushed on shutdown
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	}, "sso")
+	if err != nil {
+		panic(err)
+	}
+
+	emailConfig := app.EmailConfig{
+		Sender: mailer.Config{
+			Kind: cfg.Email.Sender,
+			SMTP: mailer.SMTPConfig{
+				Host:     os.Getenv("SMTP_HOST"),
+				Port:     os.Getenv("SMTP_PORT"),
+				Username: os.Getenv("SMTP_USERNAME"),
+				Password: os.Getenv("SMTP_PASSWORD"),
+				TLS:      cfg.Email.SMTPTLS,
+			},
+			MailtrapToken: cfg.Mailtrap.APIToken,
+			Dir:           cfg.Email.Dir,
+			Timeout:       cfg.Email.Timeout,
+			DKIM: mailer.DKIMConfig{
+				Domain:   cfg.Email.DKIM.Domain,
+				Selector: cfg.Email.DKIM.Selector,
+				KeyFile:  cfg.Email.DKIM.KeyFile,
+			},
+		},
+		From:          os.Getenv("SMTP_FROM"),
+		FromName:      os.Getenv("SMTP_FROM_NAME"),
+		Templates:     cfg.Email.Templates,
+		Workers:       cfg.Email.Workers,
+		MaxAttempts:   cfg.Email.MaxAttempts,
+		Retention:     cfg.Email.Retention,
+		DeadRetention: cfg.Email.DeadRetention,
+	}
+
+	profileConfig := app.ProfileConfig{
+		Addr:           cfg.Profile.Addr,
+		Timeout:        cfg.Profile.Timeout,
+		AppID:          cfg.Profile.AppID,
+		Audience:       cfg.Profile.Audience,
+		DeleteProfiles: cfg.Profile.DeleteProfiles,
+	}
+
+	eventsConfig := app.EventsConfig{
+		Publisher: publisher.Config{
+			Kind:          cfg.Events.Publisher,
+			NATSURL:       cfg.Events.NATSURL,
+			SubjectPrefix: cfg.Events.SubjectPrefix,
+			KafkaRESTURL:  cfg.Events.KafkaRESTURL,
+			KafkaTopic:    cfg.Events.KafkaTopic,
+			Timeout:       cfg.Events.Timeout,
+		},
+		BatchSize: cfg.Events.BatchSize,
+		Retention: cfg.Events.Retention,
+	}
 
-	smtpConfig := app.SMTPConfig{
-		Host:     os.Getenv("SMTP_HOST"),
-		Port:     os.Getenv("SMTP_PORT"),
-		Username: os.Getenv("SMTP_USERNAME"),
-		Password: os.Getenv("SMTP_PASSWORD"),
-		From:     os.Getenv("SMTP_FROM"),
-		FromName: os.Getenv("SMTP_FROM_NAME"),
+	webhooksConfig := app.WebhooksConfig{
+		Timeout:     cfg.Webhooks.Timeout,
+		MaxAttempts: cfg.Webhooks.MaxAttempts,
+		BatchSize:   cfg.Webhooks.BatchSize,
+		Retention:   cfg.Webhooks.Retention,
 	}
 
 	// Initialize app
-	application := app.New(log, cfg.GRPC.Port, cfg.HTTPServer.Port, cfg.DSN, smtpConfig, cfg.BaseURL, cfg.JWT.TokenTTL, cfg.JWT.RefreshTTL)
+	verificationConfig := auth.VerificationConfig{
+		TokenTTL:    cfg.Verification.TokenTTL,
+		MinValidity: cfg.Verification.MinValidity,
+		Cooldown:    cfg.Verification.Cooldown,
+		DailyLimit:  cfg.Verification.DailyLimit,
+	}
+
+	application := app.New(log, cfg.GRPC.Port, cfg.HTTPServer.Port, cfg.DSN, emailConfig, cfg.BaseURL, cfg.JWT.TokenTTL, cfg.JWT.RefreshTTL, cfg.JWT.Audience, cfg.JWT.ImpersonationTTL, cfg.PolicyPath, profileConfig, cfg.Deletion.GracePeriod, cfg.Export.TTL, eventsConfig, webhooksConfig, verificationConfig)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	grp, grpCtx := errgroup.WithContext(ctx)
 
+	// method policy is reloaded on SIGHUP
+	go application.Policy.ReloadOnSIGHUP(grpCtx, log)
+
+	// accounts are deleted when their grace period is over
+	go application.Account.Run(grpCtx, cfg.Deletion.PurgeInterval)
+
+	// requested data exports are built in the background
+	go application.Export.Run(grpCtx, cfg.Export.PollInterval)
+
+	// user lifecycle events are delivered from the outbox
+	go application.Relay.Run(grpCtx, cfg.Events.RelayInterval)
+
+	// queued emails are sent and retried in the background
+	go application.EmailQueue.Run(grpCtx, cfg.Email.QueueInterval)
+
+	// webhook deliveries are sent and retried in the background
+	go application.Webhooks.Run(grpCtx, cfg.Webhooks.Interval)
+
 	// Launch gRPC
 	grp.Go(func() error {
 		log.Info("starting gRPC server", slog.Int("port", cfg.GRPC.Port))
@@ -107,25 +196,35 @@ func main() {
 	}
 
 	// initiate a graceful shutdown
+	application.CloseProfile()
+	application.ClosePublisher()
 	application.CloseStorage()
+
+	tracingCtx, tracingCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer tracingCancel()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		log.Error("failed to flush spans", sl.Err(err))
+	}
+
 	log.Info("Gracefully stopped")
 }
 
+// setupLogger returns the logger of the environment, the personal data is visible only in local
 func setupLogger(env string) *slog.Logger {
 	var log *slog.Logger
 
 	switch env {
 	case envLocal:
 		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
+			sl.NewContextHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})),
 		)
 	case envDev:
 		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
+			sl.NewContextHandler(sl.NewRedactHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))),
 		)
 	case envProd:
 		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
+			sl.NewContextHandler(sl.NewRedactHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))),
 		)
 	}
 
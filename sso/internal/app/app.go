@@ -7,11 +7,25 @@ import (
 	"os"
 	"os/signal"
 	grpcapp "sso/internal/app/grpc"
-	"sso/internal/lib/logger/sl"
+	profileclient "sso/internal/clients/profile"
+	"sso/internal/lib/audit"
 	"sso/internal/lib/mailer"
+	"sso/internal/lib/metrics"
+	"sso/internal/lib/publisher"
+	"sso/internal/services/account"
+	"sso/internal/services/apikey"
+	auditservice "sso/internal/services/audit"
 	"sso/internal/services/auth"
+	"sso/internal/services/emailqueue"
+	"sso/internal/services/export"
+	"sso/internal/services/impersonation"
+	"sso/internal/services/outbox"
 	"sso/internal/services/permission"
+	"sso/internal/services/user"
+	"sso/internal/services/webhook"
 	"sso/internal/storage/postgres"
+	"sso/pkg/logger/sl"
+	"sso/pkg/policy"
 	"syscall"
 	"time"
 
@@ -22,16 +36,54 @@ type App struct {
 	GRPCServer *grpcapp.App
 	Storage    *postgres.Storage
 	HTTPServer *httpserver.Server
+	Policy     *policy.Store
+	Account    *account.Account
+	Export     *export.Export
+	Relay      *outbox.Relay
+	Webhooks   *webhook.Webhooks
+	EmailQueue *emailqueue.Queue
+	Publisher  publisher.Publisher
+	Profile    *profileclient.Client
 	log        *slog.Logger
 }
 
-type SMTPConfig struct {
-	Host     string
-	Port     string
-	Username string
-	Password string
-	From     string
-	FromName string
+// policySection is the section of the shared method policy file for sso
+const policySection = "sso"
+
+// EmailConfig is the sender of the emails, the address they are sent from and their queue
+type EmailConfig struct {
+	Sender        mailer.Config
+	From          string
+	FromName      string
+	Templates     string // directory overriding the embedded templates, optional
+	Workers       int
+	MaxAttempts   int32
+	Retention     time.Duration // sent emails are kept this long
+	DeadRetention time.Duration // dead emails are kept this long to be requeued
+}
+
+// EventsConfig is the publisher of the outbox events and its relay
+type EventsConfig struct {
+	Publisher publisher.Config
+	BatchSize int32
+	Retention time.Duration
+}
+
+// WebhooksConfig is the delivery of the events to the webhooks of the apps
+type WebhooksConfig struct {
+	Timeout     time.Duration
+	MaxAttempts int32
+	BatchSize   int32
+	Retention   time.Duration
+}
+
+// ProfileConfig is the profile service, account deletion and data export access it on behalf of the user
+type ProfileConfig struct {
+	Addr           string
+	Timeout        time.Duration
+	AppID          int32
+	Audience       string
+	DeleteProfiles bool // the relay deletes the profiles of the deleted users
 }
 
 func New(
@@ -39,27 +91,43 @@ func New(
 	grpcPort int,
 	httpPort int,
 	dsn string,
-	//mailtrapAPIToken string,
-	smtpConfig SMTPConfig,
+	emailConfig EmailConfig,
 	baseURL string,
 	tokenTTL time.Duration,
 	refreshTTL time.Duration,
+	audience string,
+	impersonationTTL time.Duration,
+	policyPath string,
+	profileConfig ProfileConfig,
+	deletionGracePeriod time.Duration,
+	exportTTL time.Duration,
+	eventsConfig EventsConfig,
+	webhooksConfig WebhooksConfig,
+	verificationConfig auth.VerificationConfig,
 ) *App {
+	policies := policy.MustLoad(policyPath, policySection)
+
 	storage, err := postgres.New(dsn)
 	if err != nil {
 		panic(err)
 	}
+	metrics.Registry.MustRegister(metrics.NewPoolCollector(storage))
 
-	//emailClient := mailer.NewMailtrapClient(mailtrapAPIToken)
-	emailClient := mailer.New(
-		smtpConfig.Host,
-		smtpConfig.Port,
-		smtpConfig.Username,
-		smtpConfig.Password,
-		smtpConfig.From,
-		smtpConfig.FromName,
-	)
-	permissionService := permission.New(log, storage, storage)
+	emailSender, err := mailer.NewSender(emailConfig.Sender)
+	if err != nil {
+		panic(err)
+	}
+	auditRecorder := audit.New(log, storage)
+	// requests only enqueue the emails, the queue workers send them
+	emailQueue := emailqueue.New(log, storage, emailSender, auditRecorder, emailConfig.Workers, emailConfig.MaxAttempts,
+		emailConfig.Retention, emailConfig.DeadRetention)
+	emailTemplates, err := mailer.LoadTemplates(emailConfig.Templates)
+	if err != nil {
+		panic(err)
+	}
+	emailClient := mailer.New(emailQueue, emailTemplates, emailConfig.From, emailConfig.FromName, emailConfig.Sender.DKIM.Domain)
+
+	permissionService := permission.New(log, storage, storage, storage, auditRecorder)
 	authService := auth.New(
 		log,
 		storage,           // UserSaver
@@ -67,13 +135,79 @@ func New(
 		storage,           // UserProvider
 		storage,           // AppProvider
 		permissionService, // PermProvider
+		auditRecorder,     // AuditSink
 		emailClient,
 		baseURL,
+		verificationConfig,
 		tokenTTL,
 		refreshTTL,
+		audience,
+	)
+
+	apiKeyService := apikey.New(log, storage, permissionService)
+
+	impersonationService := impersonation.New(log, storage, storage, permissionService, storage, impersonationTTL, audience)
+
+	auditService := auditservice.New(log, storage)
+
+	userService := user.New(log, storage, storage, permissionService, authService, auditRecorder)
+
+	eventPublisher, err := publisher.New(log, eventsConfig.Publisher)
+	if err != nil {
+		panic(err)
+	}
+
+	webhookService := webhook.New(
+		log,
+		storage, // Repository
+		storage, // AppProvider
+		auditRecorder,
+		webhooksConfig.Timeout,
+		webhooksConfig.MaxAttempts,
+		webhooksConfig.BatchSize,
+		webhooksConfig.Retention,
+	)
+
+	profileClient, err := profileclient.New(log, profileConfig.Addr, profileConfig.Timeout)
+	if err != nil {
+		panic(err)
+	}
+
+	accountService := account.New(
+		log,
+		storage,       // AccountRepository
+		storage,       // AppProvider
+		profileClient, // ProfileDeleter
+		emailClient,
+		auditRecorder,
+		deletionGracePeriod,
+		profileConfig.AppID,
+		profileConfig.Audience,
+	)
+
+	// the relay also enqueues the deliveries to the webhooks subscribed to the event
+	// and deletes the profiles of the deleted users
+	publishers := []publisher.Publisher{eventPublisher, webhookService}
+	if profileConfig.DeleteProfiles {
+		publishers = append(publishers, accountService)
+	}
+	relay := outbox.New(log, storage, publisher.NewMulti(publishers...), eventsConfig.BatchSize, eventsConfig.Retention)
+
+	exportService := export.New(
+		log,
+		storage,           // Repository
+		storage,           // AppProvider
+		permissionService, // PermProvider
+		profileClient,     // ProfileProvider
+		emailClient,
+		auditRecorder,
+		baseURL,
+		exportTTL,
+		profileConfig.AppID,
+		profileConfig.Audience,
 	)
 
-	grpcApp := grpcapp.New(log, authService, permissionService, storage, permissionService, grpcPort)
+	grpcApp := grpcapp.New(log, authService, permissionService, permissionService, storage, permissionService, apiKeyService, impersonationService, auditService, userService, accountService, exportService, webhookService, emailQueue, authService, policies, audience, grpcPort)
 
 	grpcAddr := fmt.Sprintf("localhost:%d", grpcPort)
 	httpServer := httpserver.NewServer(grpcAddr, httpPort)
@@ -82,6 +216,14 @@ func New(
 		GRPCServer: grpcApp,
 		HTTPServer: httpServer,
 		Storage:    storage,
+		Policy:     policies,
+		Account:    accountService,
+		Export:     exportService,
+		Relay:      relay,
+		Webhooks:   webhookService,
+		EmailQueue: emailQueue,
+		Publisher:  eventPublisher,
+		Profile:    profileClient,
 		log:        log,
 	}
 }
@@ -93,6 +235,26 @@ func (a *App) CloseStorage() {
 	}
 }
 
+func (a *App) CloseProfile() {
+	if a.Profile != nil {
+		if err := a.Profile.Close(); err != nil {
+			a.log.Error("failed to close profile connection", sl.Err(err))
+			return
+		}
+		a.log.Info("closed profile connection")
+	}
+}
+
+func (a *App) ClosePublisher() {
+	if a.Publisher != nil {
+		if err := a.Publisher.Close(); err != nil {
+			a.log.Error("failed to close event publisher", sl.Err(err))
+			return
+		}
+		a.log.Info("closed event publisher")
+	}
+}
+
 func (a *App) Stop() {
 	const op = "app.Stop"
 
@@ -13,9 +13,14 @@ import (
 	"google.golang.org/grpc/status"
 	"log/slog"
 	"net"
+	"sso/internal/domain/models"
 	authgrpc "sso/internal/grpc/auth"
 	"sso/internal/lib/jwt"
+	"sso/internal/lib/metrics"
 	"sso/internal/services/permission"
+	"sso/pkg/policy"
+	"sso/pkg/requestid"
+	"sso/pkg/tracing"
 	"strings"
 )
 
@@ -23,6 +28,12 @@ type AppProvider interface {
 	GetAppSecret(ctx context.Context, appID int32) (string, error)
 }
 
+type APIKeyValidator interface {
+	Validate(ctx context.Context, raw string) (models.APIKey, error)
+}
+
+const apiKeyMetadataKey = "x-api-key"
+
 type App struct {
 	log        *slog.Logger
 	gRPCServer *grpc.Server
@@ -30,26 +41,13 @@ type App struct {
 }
 
 var sensitiveMethods = map[string]bool{
-	"/sso.Auth/Login":    true,
-	"/sso.Auth/Register": true,
-}
-
-type MethodPermissions struct {
-	Required    []string // mandatory permissions
-	OneOf       []string // one of given permissions
-	RequireAuth bool     // require authentication without specific permissions
+	"/auth.Auth/Login":    true,
+	"/auth.Auth/Register": true,
 }
 
-var methodPermissions = map[string]MethodPermissions{
-	"/sso.Auth/GetUserInfo": {
-		RequireAuth: true,
-	},
-	"/sso.Permission/GetUserPermissions": {
-		OneOf: []string{"admin", "staff"},
-	},
-	"/sso.Auth/Logout": {
-		RequireAuth: true,
-	},
+// RuleProvider returns permission rule of the full gRPC method name
+type RuleProvider interface {
+	Rule(method string) policy.Rule
 }
 
 func InterceptorLogging(log *slog.Logger) grpc.UnaryServerInterceptor {
@@ -64,53 +62,97 @@ func InterceptorLogging(log *slog.Logger) grpc.UnaryServerInterceptor {
 			return handler(ctx, req)
 		}
 
-		log.Info("gRPC request", slog.String("method", info.FullMethod))
+		// the method and the request ID are added by the context handler
+		log.InfoContext(ctx, "gRPC request")
 		resp, err = handler(ctx, req)
 		if err != nil {
-			log.Error("gRPC error", slog.String("method", info.FullMethod), slog.String("error", err.Error()))
+			log.ErrorContext(ctx, "gRPC error", slog.String("error", err.Error()))
 		} else {
-			log.Info("gRPC response", slog.String("method", info.FullMethod))
+			log.InfoContext(ctx, "gRPC response")
 		}
 		return resp, err
 	}
 }
 
-func InterceptorPermission(appProvider AppProvider, pp permission.PermProvider) grpc.UnaryServerInterceptor {
+// InterceptorPermission authenticates the caller of methods protected by the policy, tokens must be issued for the audience
+// callers without a token can use an api key, then only the permissions of the key are granted
+func InterceptorPermission(appProvider AppProvider, keys APIKeyValidator, pp permission.PermProvider, rules RuleProvider, audience string) grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
 		req interface{},
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (interface{}, error) {
-		// check for sensitive methods
-		permConfig, requiresPermission := methodPermissions[info.FullMethod]
-		if !requiresPermission {
+		// check for protected methods
+		rule := rules.Rule(info.FullMethod)
+		if !rule.Protected() {
 			return handler(ctx, req)
 		}
 
-		token, err := extractTokenFromMetadata(ctx)
-		if err != nil {
-			return nil, status.Error(codes.Unauthenticated, "missing token")
-		}
+		var claims *jwt.TokenClaims
+		var apiKeyID int64
 
-		claims, err := validateTokenWithDynamicSecret(token, appProvider)
-		if err != nil {
-			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		if key := extractAPIKeyFromMetadata(ctx); key != "" {
+			apiKey, err := keys.Validate(ctx, key)
+			if err != nil {
+				return nil, status.Error(codes.Unauthenticated, "invalid api key")
+			}
+
+			apiKeyID = apiKey.ID
+			claims = &jwt.TokenClaims{UserID: apiKey.UserID, AppID: apiKey.AppID, Permissions: apiKey.Permissions}
+		} else {
+			token, err := extractTokenFromMetadata(ctx)
+			if err != nil {
+				return nil, status.Error(codes.Unauthenticated, "missing token")
+			}
+
+			claims, err = validateTokenWithDynamicSecret(token, appProvider, audience)
+			if err != nil {
+				return nil, status.Error(codes.Unauthenticated, "invalid token")
+			}
 		}
 
 		userID := claims.UserID
 
-		if err := checkPermissions(ctx, userID, permConfig, pp, claims); err != nil {
+		// api keys are limited to their own permissions, so DB is not consulted for them
+		if err := checkPermissions(ctx, userID, rule, pp, claims, apiKeyID == 0); err != nil {
 			return nil, err
 		}
 
+		requestid.SetUserID(ctx, userID)
+
 		ctx = context.WithValue(ctx, "user_claims", claims)
 		ctx = context.WithValue(ctx, "user_id", userID)
+		if apiKeyID != 0 {
+			ctx = context.WithValue(ctx, "api_key_id", apiKeyID)
+		}
+		if actorID := claims.ActorID(); actorID != 0 {
+			ctx = context.WithValue(ctx, "actor_id", actorID)
+		}
 
 		return handler(ctx, req)
 	}
 }
 
+// extractAPIKeyFromMetadata returns api key if the caller sent one instead of a token
+func extractAPIKeyFromMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if len(md.Get("authorization")) > 0 {
+		return ""
+	}
+
+	keys := md.Get(apiKeyMetadataKey)
+	if len(keys) == 0 {
+		return ""
+	}
+
+	return keys[0]
+}
+
 func extractTokenFromMetadata(ctx context.Context) (string, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
@@ -130,7 +172,7 @@ func extractTokenFromMetadata(ctx context.Context) (string, error) {
 	return token, nil
 }
 
-func validateTokenWithDynamicSecret(token string, appProvider AppProvider) (*jwt.TokenClaims, error) {
+func validateTokenWithDynamicSecret(token string, appProvider AppProvider, audience string) (*jwt.TokenClaims, error) {
 	claims, err := jwt.DecodeWithoutValidation(token)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode token: %w", err)
@@ -144,7 +186,7 @@ func validateTokenWithDynamicSecret(token string, appProvider AppProvider) (*jwt
 		return nil, fmt.Errorf("failed to get app secret: %w", err)
 	}
 
-	validClaims, err := jwt.ValidateToken(token, secret)
+	validClaims, err := jwt.ValidateTokenForAudience(token, secret, audience)
 	if err != nil {
 		return nil, fmt.Errorf("failed to validate token: %w", err)
 	}
@@ -155,24 +197,29 @@ func validateTokenWithDynamicSecret(token string, appProvider AppProvider) (*jwt
 func checkPermissions(
 	ctx context.Context,
 	userID int64,
-	permConfig MethodPermissions,
+	rule policy.Rule,
 	pp permission.PermProvider,
 	claims *jwt.TokenClaims,
+	lookupDB bool,
 ) error {
 	// only authentication
-	if permConfig.RequireAuth && len(permConfig.Required) == 0 && len(permConfig.OneOf) == 0 {
+	if rule.RequireAuth && len(rule.Required) == 0 && len(rule.OneOf) == 0 {
 		return nil
 	}
 
 	// check for required permissions
-	if len(permConfig.Required) > 0 {
-		for _, reqiredPerm := range permConfig.Required {
+	if len(rule.Required) > 0 {
+		for _, reqiredPerm := range rule.Required {
 			if claims.HasPermission(reqiredPerm) {
 				continue
 			}
 
+			if !lookupDB {
+				return status.Error(codes.PermissionDenied, fmt.Sprintf("missing required permission: %s", reqiredPerm))
+			}
+
 			// if not in token, check in DB
-			allowed, err := pp.HasUserPermission(ctx, userID, reqiredPerm)
+			allowed, err := pp.HasUserPermission(ctx, userID, claims.AppID, reqiredPerm)
 			if err != nil {
 				return status.Error(codes.Internal, "failed to check user permissions")
 			}
@@ -183,11 +230,11 @@ func checkPermissions(
 	}
 
 	// check for one of permissions
-	if len(permConfig.OneOf) > 0 {
+	if len(rule.OneOf) > 0 {
 		hasAny := false
 
 		// first in token
-		for _, perm := range permConfig.OneOf {
+		for _, perm := range rule.OneOf {
 			if claims.HasPermission(perm) {
 				hasAny = true
 				break
@@ -195,9 +242,9 @@ func checkPermissions(
 		}
 
 		// DB check
-		if !hasAny {
-			for _, perm := range permConfig.OneOf {
-				allowed, err := pp.HasUserPermission(ctx, userID, perm)
+		if !hasAny && lookupDB {
+			for _, perm := range rule.OneOf {
+				allowed, err := pp.HasUserPermission(ctx, userID, claims.AppID, perm)
 				if err != nil {
 					return status.Error(codes.Internal, "failed to check user permissions")
 				}
@@ -208,7 +255,7 @@ func checkPermissions(
 			}
 		}
 		if !hasAny {
-			return status.Error(codes.PermissionDenied, fmt.Sprintf("missing one of required permissions: %s", permConfig.OneOf))
+			return status.Error(codes.PermissionDenied, fmt.Sprintf("missing one of required permissions: %s", rule.OneOf))
 		}
 	}
 
@@ -219,26 +266,45 @@ func New(
 	log *slog.Logger,
 	authService authgrpc.Auth,
 	permissionService authgrpc.PermissionService,
+	permissionAdmin authgrpc.PermissionAdmin,
 	appProvider AppProvider,
 	permProvider permission.PermProvider,
+	apiKeys authgrpc.APIKeys,
+	impersonation authgrpc.Impersonation,
+	audit authgrpc.Audit,
+	users authgrpc.UserAdmin,
+	account authgrpc.Account,
+	exports authgrpc.DataExports,
+	webhooks authgrpc.Webhooks,
+	emailQueue authgrpc.EmailQueue,
+	verification authgrpc.Verification,
+	policies *policy.Store,
+	audience string,
 	port int,
 ) *App {
 	// gRPCServer and connect interceptors
 	recoveryOpts := []recovery.Option{
-		recovery.WithRecoveryHandler(func(p interface{}) (err error) {
-			log.Error("Recovered from panic", slog.Any("panic", p))
+		recovery.WithRecoveryHandlerContext(func(ctx context.Context, p interface{}) (err error) {
+			log.ErrorContext(ctx, "Recovered from panic", slog.Any("panic", p))
 			return status.Errorf(codes.Internal, "Internal error")
 		}),
 	}
 
-	gRPCServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
+	gRPCServer := grpc.NewServer(tracing.ServerOption(), grpc.ChainUnaryInterceptor(
+		metrics.UnaryServerInterceptor(),
+		requestid.UnaryServerInterceptor(),
 		recovery.UnaryServerInterceptor(recoveryOpts...),
 		InterceptorLogging(log),
-		InterceptorPermission(appProvider, permProvider),
+		InterceptorPermission(appProvider, apiKeys, permProvider, policies, audience),
 	))
 
 	// register the service Auth
-	authgrpc.Register(gRPCServer, authService, permissionService)
+	authgrpc.Register(gRPCServer, authService, permissionService, permissionAdmin, apiKeys, impersonation, audit, users, account, exports, webhooks, emailQueue, verification)
+
+	// typos in the policy must fail on start, not silently leave the method public
+	if err := policies.Bind(gRPCServer.GetServiceInfo()); err != nil {
+		panic(fmt.Errorf("invalid method policy: %w", err))
+	}
 
 	// return App object with necessary fields
 	return &App{
@@ -258,6 +324,12 @@ func GetUserIDFromContext(ctx context.Context) (int64, bool) {
 	return userID, ok
 }
 
+// GetActorIDFromContext returns the user impersonating the caller
+func GetActorIDFromContext(ctx context.Context) (int64, bool) {
+	actorID, ok := ctx.Value("actor_id").(int64)
+	return actorID, ok
+}
+
 func (a *App) MustRun() {
 	if err := a.Run(); err != nil {
 		panic(err)
@@ -0,0 +1,123 @@
+package profile
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/pkg/requestid"
+	"sso/pkg/tracing"
+	"time"
+
+	profilev1 "github.com/m4rk1sov/protos/gen/go/profile"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Client talks to the profile service over gRPC
+type Client struct {
+	log      *slog.Logger
+	conn     *grpc.ClientConn
+	profiles profilev1.ProfileServiceClient
+	timeout  time.Duration
+}
+
+func New(log *slog.Logger, addr string, timeout time.Duration) (*Client, error) {
+	const op = "clients.profile.New"
+
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		tracing.DialOption(),
+		grpc.WithUnaryInterceptor(requestid.UnaryClientInterceptor()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Client{
+		log:      log,
+		conn:     conn,
+		profiles: profilev1.NewProfileServiceClient(conn),
+		timeout:  timeout,
+	}, nil
+}
+
+// UserProfile returns the profile of the user on behalf of the user, nil if the user has no profile,
+// token is an access token of the user for the profile audience
+func (c *Client) UserProfile(ctx context.Context, token string, userID int64) (*profilev1.UserProfile, error) {
+	const op = "clients.profile.UserProfile"
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	profile, err := c.userProfile(withToken(ctx, token), userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return profile, nil
+}
+
+// DeleteUserProfile deletes the profile of the user on behalf of the user,
+// token is an access token of the user for the profile audience, a missing profile is not an error
+func (c *Client) DeleteUserProfile(ctx context.Context, token string, userID int64) error {
+	const op = "clients.profile.DeleteUserProfile"
+
+	log := c.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", userID),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	ctx = withToken(ctx, token)
+
+	profile, err := c.userProfile(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if profile == nil {
+		log.InfoContext(ctx, "user has no profile")
+		return nil
+	}
+
+	_, err = c.profiles.DeleteProfile(ctx, &profilev1.DeleteProfileRequest{Id: profile.GetId()})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.InfoContext(ctx, "profile deleted")
+
+	return nil
+}
+
+func (c *Client) userProfile(ctx context.Context, userID int64) (*profilev1.UserProfile, error) {
+	resp, err := c.profiles.GetProfileByUserID(ctx, &profilev1.GetProfileByUserIDRequest{UserId: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	// profile reports the errors of the lookup in the response
+	if respErr := resp.GetError(); respErr != nil {
+		if codes.Code(respErr.GetCode()) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, status.ErrorProto(respErr)
+	}
+
+	return resp.GetProfile(), nil
+}
+
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
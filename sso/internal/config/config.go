@@ -8,19 +8,31 @@ import (
 )
 
 type Config struct {
-	Env            string         `json:"env" yaml:"env" env-default:"local"`
-	DSN            string         `env:"DSN_STRING"`
-	JWT            JWTConfig      `yaml:"jwt"`
-	GRPC           GRPCConfig     `yaml:"grpc"`
-	MigrationsPath string         `env:"MIGRATE_PATH"`
-	HTTPServer     HTTPServer     `yaml:"http_server"`
-	Mailtrap       MailtrapConfig `yaml:"mailtrap"`
-	BaseURL        string         `yaml:"base_url"`
+	Env            string             `json:"env" yaml:"env" env-default:"local"`
+	DSN            string             `env:"DSN_STRING"`
+	JWT            JWTConfig          `yaml:"jwt"`
+	GRPC           GRPCConfig         `yaml:"grpc"`
+	MigrationsPath string             `env:"MIGRATE_PATH"`
+	HTTPServer     HTTPServer         `yaml:"http_server"`
+	Mailtrap       MailtrapConfig     `yaml:"mailtrap"`
+	BaseURL        string             `yaml:"base_url"`
+	PolicyPath     string             `yaml:"policy_path" env:"POLICY_PATH" env-default:"../policy/methods.yaml"`
+	Profile        ProfileConfig      `yaml:"profile"`
+	Deletion       DeletionConfig     `yaml:"account_deletion"`
+	Export         ExportConfig       `yaml:"data_export"`
+	Events         EventsConfig       `yaml:"events"`
+	Webhooks       WebhooksConfig     `yaml:"webhooks"`
+	Email          EmailConfig        `yaml:"email"`
+	Verification   VerificationConfig `yaml:"email_verification"`
+	Tracing        TracingConfig      `yaml:"tracing"`
 }
 
 type JWTConfig struct {
 	TokenTTL   time.Duration `yaml:"token_ttl" env-default:"1h"`
 	RefreshTTL time.Duration `yaml:"refresh_ttl" env-default:"240h"`
+	Audience   string        `yaml:"audience" env-default:"sso"` // audience of sso itself, added to every token
+	// ImpersonationTTL is the lifetime of the tokens issued to staff acting as a user
+	ImpersonationTTL time.Duration `yaml:"impersonation_ttl" env-default:"15m"`
 }
 
 type HTTPServer struct {
@@ -32,6 +44,92 @@ type GRPCConfig struct {
 	Timeout time.Duration `yaml:"timeout"`
 }
 
+// ProfileConfig is the profile service, sso deletes and exports the profiles on behalf of the users
+type ProfileConfig struct {
+	Addr           string        `yaml:"addr" env:"PROFILE_ADDR" env-default:"localhost:44045"`
+	Timeout        time.Duration `yaml:"timeout" env-default:"5s"`
+	AppID          int32         `yaml:"app_id" env:"PROFILE_APP_ID" env-default:"1"` // app whose secret profile validates tokens with
+	Audience       string        `yaml:"audience" env-default:"profile"`
+	DeleteProfiles bool          `yaml:"delete_profiles" env:"PROFILE_DELETE_PROFILES" env-default:"true"` // of the purged accounts, off only when profile consumes user.deleted
+}
+
+// DeletionConfig is the self-service account deletion
+type DeletionConfig struct {
+	GracePeriod   time.Duration `yaml:"grace_period" env-default:"720h"` // logging in during the period cancels the deletion
+	PurgeInterval time.Duration `yaml:"purge_interval" env-default:"1h"`
+}
+
+// VerificationConfig limits the verification emails sent to a user
+type VerificationConfig struct {
+	TokenTTL    time.Duration `yaml:"token_ttl" env-default:"24h"`
+	MinValidity time.Duration `yaml:"min_validity" env-default:"1h"` // a token expiring sooner is not sent again
+	Cooldown    time.Duration `yaml:"cooldown" env-default:"1m"`
+	DailyLimit  int32         `yaml:"daily_limit" env-default:"5"`
+}
+
+// ExportConfig is the personal data export
+type ExportConfig struct {
+	TTL          time.Duration `yaml:"ttl" env-default:"168h"` // the download link works this long
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"30s"`
+}
+
+// EventsConfig is the delivery of the user lifecycle events from the outbox
+type EventsConfig struct {
+	Publisher     string        `yaml:"publisher" env:"EVENTS_PUBLISHER" env-default:"nats"` // nats, kafka, inprocess or memory
+	NATSURL       string        `yaml:"nats_url" env:"NATS_URL" env-default:"nats://localhost:4222"`
+	SubjectPrefix string        `yaml:"subject_prefix" env-default:"sso"`
+	KafkaRESTURL  string        `yaml:"kafka_rest_url" env:"KAFKA_REST_URL" env-default:"http://localhost:8082"`
+	KafkaTopic    string        `yaml:"kafka_topic" env-default:"sso.users"`
+	Timeout       time.Duration `yaml:"timeout" env-default:"5s"`
+	RelayInterval time.Duration `yaml:"relay_interval" env-default:"1s"`
+	BatchSize     int32         `yaml:"batch_size" env-default:"100"`
+	Retention     time.Duration `yaml:"retention" env-default:"168h"` // published events are kept this long
+}
+
+// WebhooksConfig is the delivery of the user events to the webhooks of the apps
+type WebhooksConfig struct {
+	Timeout     time.Duration `yaml:"timeout" env-default:"10s"` // of a single request
+	MaxAttempts int32         `yaml:"max_attempts" env-default:"10"`
+	Interval    time.Duration `yaml:"interval" env-default:"5s"`
+	BatchSize   int32         `yaml:"batch_size" env-default:"20"`  // deliveries sent concurrently
+	Retention   time.Duration `yaml:"retention" env-default:"720h"` // delivered deliveries are kept this long
+}
+
+// EmailConfig is the transport of the emails, the SMTP server and the sender address come from env
+type EmailConfig struct {
+	Sender  string        `yaml:"sender" env:"EMAIL_SENDER" env-default:"smtp"` // smtp, mailtrap, file or memory
+	SMTPTLS string        `yaml:"smtp_tls" env:"SMTP_TLS" env-default:"auto"`   // auto, starttls, tls or none
+	Dir     string        `yaml:"dir" env-default:"./mail"`                     // maildir of the file sender
+	Timeout time.Duration `yaml:"timeout" env-default:"30s"`
+
+	// files in the templates directory replace the embedded ones with the same path
+	Templates string `yaml:"templates" env:"EMAIL_TEMPLATES"`
+
+	DKIM DKIMConfig `yaml:"dkim"`
+
+	// emails are queued in email_outbox and sent by the workers
+	Workers       int           `yaml:"workers" env-default:"4"`
+	MaxAttempts   int32         `yaml:"max_attempts" env-default:"8"` // then the email is dead-lettered
+	QueueInterval time.Duration `yaml:"queue_interval" env-default:"5s"`
+	Retention     time.Duration `yaml:"retention" env-default:"168h"`      // sent emails are kept this long
+	DeadRetention time.Duration `yaml:"dead_retention" env-default:"720h"` // dead emails can be requeued this long
+}
+
+// DKIMConfig signs the emails sent by the smtp and file senders, they are unsigned if the domain is empty
+type DKIMConfig struct {
+	Domain   string `yaml:"domain" env:"DKIM_DOMAIN"`
+	Selector string `yaml:"selector" env:"DKIM_SELECTOR"`
+	KeyFile  string `yaml:"key_file" env:"DKIM_KEY_FILE"` // PEM, RSA or Ed25519
+}
+
+// TracingConfig is the export of the OpenTelemetry spans
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`           // none, stdout or otlp
+	Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT" env-default:"localhost:4317"` // OTLP gRPC collector
+	Insecure    bool    `yaml:"insecure" env-default:"true"`
+	SampleRatio float64 `yaml:"sample_ratio" env-default:"1"`
+}
+
 type MailtrapConfig struct {
 	APIToken string `env:"MAILTRAP_API"`
 }
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// APIKey is a named key acting as its user with a subset of the user permissions
+// only the hash of the key is stored, zero times mean not set
+type APIKey struct {
+	ID          int64
+	UserID      int64
+	AppID       int32 // 0 for personal keys
+	Name        string
+	Prefix      string // first characters of the key to recognize it
+	Permissions []string
+	ExpiresAt   time.Time
+	LastUsedAt  time.Time
+	RevokedAt   time.Time
+	CreatedAt   time.Time
+}
+
+// Active reports whether the key can be used at the moment
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt.IsZero() && (k.ExpiresAt.IsZero() || k.ExpiresAt.After(now))
+}
@@ -1,7 +1,54 @@
 package models
 
+import "time"
+
 type App struct {
 	ID     int32  `json:"id"`
 	Name   string `json:"name"`
 	Secret string `json:"-"`
+
+	// per-app token settings, zero TTLs fall back to the global config
+	AccessTTL        time.Duration  `json:"access_ttl"`
+	RefreshTTL       time.Duration  `json:"refresh_ttl"`
+	EmbedPermissions bool           `json:"embed_permissions"`
+	ExtraClaims      map[string]any `json:"extra_claims,omitempty"`
+	Audience         string         `json:"audience,omitempty"`
+
+	Email AppEmail `json:"email"`
+}
+
+// AppEmail is the branding of the emails sent for the app, empty fields fall back to the config
+type AppEmail struct {
+	From     string `json:"from,omitempty"`
+	FromName string `json:"from_name,omitempty"`
+	LogoURL  string `json:"logo_url,omitempty"`
+	Color    string `json:"color,omitempty"` // of the buttons, e.g. #ff6600
+
+	// links to the app's pages, {token} is replaced with the token
+	VerificationURL  string `json:"verification_url,omitempty"`
+	ResetPasswordURL string `json:"reset_password_url,omitempty"`
+}
+
+// DefaultAudience returns the audience tokens of the app are issued for by default
+func (a App) DefaultAudience() string {
+	if a.Audience != "" {
+		return a.Audience
+	}
+	return a.Name
+}
+
+// AccessTokenTTL returns the app access token lifetime or the fallback if the app has none
+func (a App) AccessTokenTTL(fallback time.Duration) time.Duration {
+	if a.AccessTTL > 0 {
+		return a.AccessTTL
+	}
+	return fallback
+}
+
+// RefreshTokenTTL returns the app refresh token lifetime or the fallback if the app has none
+func (a App) RefreshTokenTTL(fallback time.Duration) time.Duration {
+	if a.RefreshTTL > 0 {
+		return a.RefreshTTL
+	}
+	return fallback
 }
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// AuditEvent is the durable record of a security relevant action
+type AuditEvent struct {
+	ID           int64          `json:"id"`
+	Type         string         `json:"type"`
+	ActorID      int64          `json:"actor_id,omitempty"` // 0 if the actor is unknown, e.g. failed login
+	TargetUserID int64          `json:"target_user_id,omitempty"`
+	AppID        int32          `json:"app_id,omitempty"`
+	IP           string         `json:"ip,omitempty"`
+	UserAgent    string         `json:"user_agent,omitempty"`
+	Outcome      string         `json:"outcome"`
+	Payload      map[string]any `json:"payload,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// AuditFilter selects audit events, zero fields are not applied
+type AuditFilter struct {
+	UserID int64 // actor or target
+	Types  []string
+	From   time.Time
+	To     time.Time
+	Limit  int32
+	Offset int32
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Statuses of the data export
+const (
+	DataExportPending = "pending"
+	DataExportRunning = "running"
+	DataExportReady   = "ready"
+	DataExportFailed  = "failed"
+)
+
+// DataExport is the archive of the personal data requested by the user
+type DataExport struct {
+	ID        int64
+	UserID    int64
+	Status    string
+	Archive   []byte // JSON document, loaded only for the download
+	Error     string
+	CreatedAt time.Time
+	ReadyAt   time.Time
+	ExpiresAt time.Time // the archive is deleted after it
+}
+
+// Session is a refresh token of the user, the token itself is never exposed
+type Session struct {
+	ID        int64     `json:"id"`
+	AppID     int32     `json:"app_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
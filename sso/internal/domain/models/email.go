@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Statuses of the queued email
+const (
+	EmailPending = "pending"
+	EmailSent    = "sent"
+	EmailDead    = "dead" // rejected or out of attempts, not retried anymore
+)
+
+// QueuedEmail is an email waiting in the outbox
+type QueuedEmail struct {
+	ID        int64
+	ToEmail   string
+	Subject   string
+	Message   []byte // JSON of the built message
+	Attempts  int32
+	CreatedAt time.Time
+}
+
+// EmailQueueStats is the depth of the email queue
+type EmailQueueStats struct {
+	Pending       int64
+	Due           int64 // pending and due for sending now
+	Dead          int64
+	OldestPending time.Time // zero if nothing is pending
+}
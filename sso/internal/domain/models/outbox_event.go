@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// OutboxEvent is an event waiting in the outbox to be published
+type OutboxEvent struct {
+	ID        int64
+	Type      string
+	UserID    int64
+	Payload   []byte  // JSON
+	AppIDs    []int32 // apps of the user when the event happened
+	CreatedAt time.Time
+	Attempts  int32
+}
@@ -1,11 +1,28 @@
 package models
 
 type Permission struct {
-	ID   int64  `json:"id"`
-	Code string `json:"code"`
+	ID          int64  `json:"id"`
+	Code        string `json:"code"`
+	Description string `json:"description,omitempty"`
 }
 
 type UserPermission struct {
 	UserID       int64 `json:"user_id"`
 	PermissionID int64 `json:"permission_id"`
 }
+
+// Role bundles permissions, users get permissions through assigned roles
+type Role struct {
+	ID          int64    `json:"id"`
+	Code        string   `json:"code"`
+	Description string   `json:"description,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// UserRole is a role assignment, zero AppID means the role applies to every app
+type UserRole struct {
+	UserID   int64  `json:"user_id"`
+	RoleID   int64  `json:"role_id"`
+	RoleCode string `json:"role_code"`
+	AppID    int32  `json:"app_id,omitempty"`
+}
@@ -1,11 +1,36 @@
 package models
 
+import "time"
+
 type User struct {
-	ID           int64  `json:"id"`
-	Email        string `json:"email"`
-	PasswordHash []byte `json:"-"`
-	Name         string `json:"name"`
-	Phone        string `json:"phone"`
-	Address      string `json:"address"`
-	Activated    bool   `json:"activated"`
+	ID           int64     `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash []byte    `json:"-"`
+	Name         string    `json:"name"`
+	Phone        string    `json:"phone"`
+	Address      string    `json:"address"`
+	Locale       string    `json:"locale"` // of the emails, empty if unknown
+	Activated    bool      `json:"activated"`
+	DisabledAt   time.Time `json:"disabled_at,omitempty"` // zero if the account is enabled
+	CreatedAt    time.Time `json:"created_at"`
+	DeleteAfter  time.Time `json:"delete_after,omitempty"` // zero unless the user asked to delete the account
+}
+
+// Disabled reports whether the account was disabled by an admin
+func (u User) Disabled() bool {
+	return !u.DisabledAt.IsZero()
+}
+
+// DeletionScheduled reports whether the account waits for deletion
+func (u User) DeletionScheduled() bool {
+	return !u.DeleteAfter.IsZero()
+}
+
+// UserFilter selects users for admins, zero fields are not applied
+type UserFilter struct {
+	EmailPrefix  string
+	Activated    *bool
+	PermissionID int64
+	Limit        int32
+	Offset       int32
 }
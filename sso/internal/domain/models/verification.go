@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// VerificationToken is the pending email verification of a user and the emails sent for it
+type VerificationToken struct {
+	Token           string
+	ExpiresAt       time.Time
+	LastSentAt      time.Time // zero if the email was never sent
+	SentCount       int32     // emails sent since WindowStartedAt
+	WindowStartedAt time.Time
+}
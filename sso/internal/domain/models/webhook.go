@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Statuses of the webhook delivery
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed" // attempts are exhausted, the delivery can be replayed
+)
+
+// Webhook is the subscription of an app to the user events
+type Webhook struct {
+	ID         int64
+	AppID      int32
+	URL        string
+	EventTypes []string
+	CreatedBy  int64
+	CreatedAt  time.Time
+}
+
+// WebhookDelivery is the delivery of an event to a webhook and the result of its last attempt
+type WebhookDelivery struct {
+	ID             int64
+	WebhookID      int64
+	AppID          int32
+	URL            string
+	EventID        int64
+	EventType      string
+	Payload        []byte // JSON envelope sent as the request body
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode int32
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    time.Time
+}
+
+// WebhookDeliveryFilter selects the deliveries of a webhook, empty status matches all of them
+type WebhookDeliveryFilter struct {
+	WebhookID int64
+	Status    string
+	Limit     int32
+	Offset    int32
+}
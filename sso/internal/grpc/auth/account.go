@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/lib/audit"
+	"sso/internal/services/account"
+	"sso/internal/services/export"
+	"time"
+
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	apiv1 "sso/api/gen/go/sso"
+)
+
+type accountServer struct {
+	apiv1.UnimplementedAccountServer
+	account Account
+	exports DataExports
+}
+
+type Account interface {
+	DeleteAccount(ctx context.Context, userID int64, password string) (deleteAfter time.Time, err error)
+}
+
+type DataExports interface {
+	RequestExport(ctx context.Context, userID int64) (models.DataExport, error)
+	DataExport(ctx context.Context, userID int64, exportID int64) (models.DataExport, error)
+	Download(ctx context.Context, token string) (models.DataExport, error)
+}
+
+func (s *accountServer) DeleteAccount(
+	ctx context.Context,
+	in *apiv1.DeleteAccountRequest,
+) (*apiv1.DeleteAccountResponse, error) {
+	if in.GetPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "password is required")
+	}
+
+	// only the owner signed in as themselves may delete the account
+	if _, ok := ctx.Value("actor_id").(int64); ok {
+		return nil, status.Error(codes.PermissionDenied, "impersonation tokens can't delete accounts")
+	}
+	if _, ok := ctx.Value("api_key_id").(int64); ok {
+		return nil, status.Error(codes.PermissionDenied, "api keys can't delete accounts")
+	}
+
+	userID := audit.ActorID(ctx)
+	if userID == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+
+	deleteAfter, err := s.account.DeleteAccount(ctx, userID, in.GetPassword())
+	if err != nil {
+		switch {
+		case errors.Is(err, account.ErrInvalidCredentials):
+			return nil, status.Error(codes.PermissionDenied, "invalid password")
+		case errors.Is(err, account.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		default:
+			return nil, status.Error(codes.Internal, "failed to delete account")
+		}
+	}
+
+	return &apiv1.DeleteAccountResponse{DeleteAfter: deleteAfter.Unix()}, nil
+}
+
+func (s *accountServer) ExportMyData(
+	ctx context.Context,
+	in *apiv1.ExportMyDataRequest,
+) (*apiv1.DataExport, error) {
+	// the archive is emailed to the owner, staff acting as the user must not trigger it
+	if _, ok := ctx.Value("actor_id").(int64); ok {
+		return nil, status.Error(codes.PermissionDenied, "impersonation tokens can't export data")
+	}
+	if _, ok := ctx.Value("api_key_id").(int64); ok {
+		return nil, status.Error(codes.PermissionDenied, "api keys can't export data")
+	}
+
+	userID := audit.ActorID(ctx)
+	if userID == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+
+	e, err := s.exports.RequestExport(ctx, userID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to export data")
+	}
+
+	return dataExportToProto(e), nil
+}
+
+func (s *accountServer) GetDataExport(
+	ctx context.Context,
+	in *apiv1.GetDataExportRequest,
+) (*apiv1.DataExport, error) {
+	if in.GetExportId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "export_id is required")
+	}
+
+	userID := audit.ActorID(ctx)
+	if userID == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+
+	e, err := s.exports.DataExport(ctx, userID, in.GetExportId())
+	if err != nil {
+		if errors.Is(err, export.ErrExportNotFound) {
+			return nil, status.Error(codes.NotFound, "data export not found")
+		}
+		return nil, status.Error(codes.Internal, "failed to get data export")
+	}
+
+	return dataExportToProto(e), nil
+}
+
+func (s *accountServer) DownloadDataExport(
+	ctx context.Context,
+	in *apiv1.DownloadDataExportRequest,
+) (*httpbody.HttpBody, error) {
+	if in.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	e, err := s.exports.Download(ctx, in.GetToken())
+	if err != nil {
+		if errors.Is(err, export.ErrExportNotFound) {
+			return nil, status.Error(codes.NotFound, "data export not found or expired")
+		}
+		return nil, status.Error(codes.Internal, "failed to download data export")
+	}
+
+	// the gateway turns it into the Content-Disposition header, so browsers save the file
+	disposition := fmt.Sprintf("attachment; filename=\"data-export-%d.json\"", e.ID)
+	_ = grpc.SetHeader(ctx, metadata.Pairs("content-disposition", disposition))
+
+	return &httpbody.HttpBody{ContentType: "application/json", Data: e.Archive}, nil
+}
+
+func dataExportToProto(e models.DataExport) *apiv1.DataExport {
+	resp := &apiv1.DataExport{
+		ExportId:  e.ID,
+		Status:    e.Status,
+		CreatedAt: e.CreatedAt.Unix(),
+	}
+	if !e.ReadyAt.IsZero() {
+		resp.ReadyAt = e.ReadyAt.Unix()
+	}
+	if !e.ExpiresAt.IsZero() {
+		resp.ExpiresAt = e.ExpiresAt.Unix()
+	}
+
+	return resp
+}
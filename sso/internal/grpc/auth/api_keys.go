@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/audit"
+	"sso/internal/services/apikey"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apiv1 "sso/api/gen/go/sso"
+)
+
+type apiKeysServer struct {
+	apiv1.UnimplementedApiKeysServer
+	keys APIKeys
+}
+
+type APIKeys interface {
+	Create(
+		ctx context.Context,
+		userID int64,
+		name string,
+		permissions []string,
+		appID int32,
+		expiresAt time.Time,
+	) (key models.APIKey, raw string, err error)
+	List(ctx context.Context, userID int64, appID int32) ([]models.APIKey, error)
+	Revoke(ctx context.Context, userID int64, keyID int64) (changed bool, err error)
+	Validate(ctx context.Context, raw string) (models.APIKey, error)
+}
+
+func (s *apiKeysServer) CreateApiKey(
+	ctx context.Context,
+	in *apiv1.CreateApiKeyRequest,
+) (*apiv1.CreateApiKeyResponse, error) {
+	if in.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	// a leaked key must not be able to mint new keys
+	if _, ok := ctx.Value("api_key_id").(int64); ok {
+		return nil, status.Error(codes.PermissionDenied, "api keys can't be created with an api key")
+	}
+	if _, ok := ctx.Value("actor_id").(int64); ok {
+		return nil, status.Error(codes.PermissionDenied, "api keys can't be created while impersonating")
+	}
+
+	var expiresAt time.Time
+	if in.GetExpiresAtUnix() != 0 {
+		expiresAt = time.Unix(in.GetExpiresAtUnix(), 0)
+	}
+
+	key, raw, err := s.keys.Create(ctx, audit.ActorID(ctx), in.GetName(), in.GetPermissions(), in.GetAppId(), expiresAt)
+	if err != nil {
+		return nil, apiKeyError(err, "failed to create api key")
+	}
+
+	return &apiv1.CreateApiKeyResponse{ApiKey: apiKeyToProto(key), Key: raw}, nil
+}
+
+func (s *apiKeysServer) ListApiKeys(
+	ctx context.Context,
+	in *apiv1.ListApiKeysRequest,
+) (*apiv1.ListApiKeysResponse, error) {
+	keys, err := s.keys.List(ctx, audit.ActorID(ctx), in.GetAppId())
+	if err != nil {
+		return nil, apiKeyError(err, "failed to list api keys")
+	}
+
+	resp := &apiv1.ListApiKeysResponse{ApiKeys: make([]*apiv1.ApiKey, len(keys))}
+	for i, key := range keys {
+		resp.ApiKeys[i] = apiKeyToProto(key)
+	}
+
+	return resp, nil
+}
+
+func (s *apiKeysServer) RevokeApiKey(
+	ctx context.Context,
+	in *apiv1.RevokeApiKeyRequest,
+) (*apiv1.RevokeApiKeyResponse, error) {
+	if in.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	changed, err := s.keys.Revoke(ctx, audit.ActorID(ctx), in.GetId())
+	if err != nil {
+		return nil, apiKeyError(err, "failed to revoke api key")
+	}
+
+	return &apiv1.RevokeApiKeyResponse{Changed: changed}, nil
+}
+
+func (s *apiKeysServer) ValidateApiKey(
+	ctx context.Context,
+	in *apiv1.ValidateApiKeyRequest,
+) (*apiv1.ValidateApiKeyResponse, error) {
+	if in.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key is required")
+	}
+
+	key, err := s.keys.Validate(ctx, in.GetKey())
+	if err != nil {
+		if errors.Is(err, apikey.ErrInvalidKey) {
+			return nil, status.Error(codes.Unauthenticated, "invalid api key")
+		}
+		return nil, status.Error(codes.Internal, "failed to validate api key")
+	}
+
+	return &apiv1.ValidateApiKeyResponse{
+		KeyId:       key.ID,
+		UserId:      key.UserID,
+		AppId:       key.AppID,
+		Permissions: key.Permissions,
+	}, nil
+}
+
+func apiKeyToProto(key models.APIKey) *apiv1.ApiKey {
+	return &apiv1.ApiKey{
+		Id:             key.ID,
+		Name:           key.Name,
+		Prefix:         key.Prefix,
+		UserId:         key.UserID,
+		AppId:          key.AppID,
+		Permissions:    key.Permissions,
+		ExpiresAtUnix:  unixOrZero(key.ExpiresAt),
+		LastUsedAtUnix: unixOrZero(key.LastUsedAt),
+		CreatedAtUnix:  unixOrZero(key.CreatedAt),
+		Revoked:        !key.RevokedAt.IsZero(),
+	}
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func apiKeyError(err error, msg string) error {
+	switch {
+	case errors.Is(err, apikey.ErrInvalidInput):
+		return status.Error(codes.InvalidArgument, "invalid request")
+	case errors.Is(err, apikey.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	case errors.Is(err, apikey.ErrKeyNotFound):
+		return status.Error(codes.NotFound, "api key not found")
+	default:
+		return status.Error(codes.Internal, msg)
+	}
+}
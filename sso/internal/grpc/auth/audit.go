@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/services/audit"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apiv1 "sso/api/gen/go/sso"
+)
+
+type auditServer struct {
+	apiv1.UnimplementedAuditServer
+	audit Audit
+}
+
+type Audit interface {
+	Events(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error)
+}
+
+func (s *auditServer) ListAuditEvents(
+	ctx context.Context,
+	in *apiv1.ListAuditEventsRequest,
+) (*apiv1.ListAuditEventsResponse, error) {
+	filter := models.AuditFilter{
+		UserID: in.GetUserId(),
+		Types:  in.GetTypes(),
+		Limit:  in.GetLimit(),
+		Offset: in.GetOffset(),
+	}
+	if in.GetFromUnix() != 0 {
+		filter.From = time.Unix(in.GetFromUnix(), 0)
+	}
+	if in.GetToUnix() != 0 {
+		filter.To = time.Unix(in.GetToUnix(), 0)
+	}
+
+	events, err := s.audit.Events(ctx, filter)
+	if err != nil {
+		if errors.Is(err, audit.ErrInvalidInput) {
+			return nil, status.Error(codes.InvalidArgument, "invalid filter")
+		}
+		return nil, status.Error(codes.Internal, "failed to list audit events")
+	}
+
+	resp := &apiv1.ListAuditEventsResponse{Events: make([]*apiv1.AuditEvent, len(events))}
+	for i, event := range events {
+		payload, err := json.Marshal(event.Payload)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to encode audit event")
+		}
+
+		resp.Events[i] = &apiv1.AuditEvent{
+			Id:            event.ID,
+			Type:          event.Type,
+			ActorId:       event.ActorID,
+			TargetUserId:  event.TargetUserID,
+			AppId:         event.AppID,
+			Ip:            event.IP,
+			UserAgent:     event.UserAgent,
+			Outcome:       event.Outcome,
+			PayloadJson:   string(payload),
+			CreatedAtUnix: unixOrZero(event.CreatedAt),
+		}
+	}
+
+	return resp, nil
+}
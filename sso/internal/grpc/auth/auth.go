@@ -3,9 +3,17 @@ package auth
 import (
 	"context"
 	"errors"
+	"math"
+	"sso/internal/lib/locale"
 	"sso/internal/services"
 	"sso/internal/services/auth"
 	"sso/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 	// codes for grpc clients to understand
 	"google.golang.org/grpc/codes"
 	// status of errors for understanding of grpc clients
@@ -14,6 +22,14 @@ import (
 	ssov1 "github.com/m4rk1sov/protos/gen/go/sso"
 )
 
+const audienceMetadataKey = "x-audience"
+
+// retryAfterKey is the metadata the gateway returns as the Retry-After header
+const retryAfterKey = "retry-after"
+
+// acceptLanguageKeys are the Accept-Language of gRPC clients and the one passed by the gateway
+var acceptLanguageKeys = []string{"accept-language", "grpcgateway-accept-language"}
+
 type authServer struct {
 	// to resolve compatibility issues, when adding new proto-generated files
 	// will return a not implemented error
@@ -27,6 +43,7 @@ type Auth interface {
 		email string,
 		password string,
 		appID int32,
+		audience []string,
 	) (token string, refresh string, exp int64, err error)
 	RegisterNewUser(
 		ctx context.Context,
@@ -35,6 +52,7 @@ type Auth interface {
 		address string,
 		email string,
 		password string,
+		locale string,
 	) (userId int64, resName string, resEmail string, activated bool, err error)
 	Logout(ctx context.Context,
 		refresh string,
@@ -46,6 +64,7 @@ type Auth interface {
 	RefreshToken(
 		ctx context.Context,
 		refresh string,
+		audience []string,
 	) (token string, refreshNew string, exp int64, err error)
 	ForgotPassword(
 		ctx context.Context,
@@ -61,7 +80,7 @@ type Auth interface {
 		ctx context.Context,
 		userId int64,
 		appID int32,
-	) (success bool, message string, exp int64, err error)
+	) (success bool, message string, exp int64, nextSendAt time.Time, err error)
 	EmailVerify(
 		ctx context.Context,
 		token string,
@@ -84,11 +103,17 @@ func (s *authServer) Login(
 		return nil, status.Error(codes.InvalidArgument, "app_id is required")
 	}
 
-	token, refresh, exp, err := s.auth.Login(ctx, in.GetEmail(), in.GetPassword(), in.GetAppId())
+	token, refresh, exp, err := s.auth.Login(ctx, in.GetEmail(), in.GetPassword(), in.GetAppId(), requestedAudience(ctx))
 	if err != nil {
 		if errors.Is(err, auth.ErrInvalidCredentials) {
 			return nil, status.Error(codes.InvalidArgument, "invalid email or password")
 		}
+		if errors.Is(err, auth.ErrUserDisabled) {
+			return nil, status.Error(codes.PermissionDenied, "account is disabled")
+		}
+		if errors.Is(err, auth.ErrInvalidAudience) {
+			return nil, status.Error(codes.InvalidArgument, "invalid audience")
+		}
 
 		return nil, status.Error(codes.Internal, "failed to login")
 	}
@@ -108,7 +133,7 @@ func (s *authServer) Register(
 		return nil, status.Error(codes.InvalidArgument, "password is required")
 	}
 
-	userId, name, email, activated, err := s.auth.RegisterNewUser(ctx, in.GetName(), in.GetPhone(), in.GetAddress(), in.GetEmail(), in.GetPassword())
+	userId, name, email, activated, err := s.auth.RegisterNewUser(ctx, in.GetName(), in.GetPhone(), in.GetAddress(), in.GetEmail(), in.GetPassword(), requestedLocale(ctx))
 	if err != nil {
 		if errors.Is(err, storage.ErrUserExists) {
 			return nil, status.Error(codes.AlreadyExists, "user already exists")
@@ -174,11 +199,20 @@ func (s *authServer) RefreshToken(
 		return nil, status.Error(codes.InvalidArgument, "refresh token is required or invalid")
 	}
 
-	token, refresh, exp, err := s.auth.RefreshToken(ctx, in.GetRefreshToken())
+	token, refresh, exp, err := s.auth.RefreshToken(ctx, in.GetRefreshToken(), requestedAudience(ctx))
 	if err != nil {
 		if errors.Is(err, storage.ErrTokenNotFound) {
 			return nil, status.Error(codes.NotFound, "refresh token is not found")
 		}
+		if errors.Is(err, auth.ErrUserDisabled) {
+			return nil, status.Error(codes.PermissionDenied, "account is disabled")
+		}
+		if errors.Is(err, auth.ErrDeletionScheduled) {
+			return nil, status.Error(codes.PermissionDenied, "account is scheduled for deletion, log in to cancel")
+		}
+		if errors.Is(err, auth.ErrInvalidAudience) {
+			return nil, status.Error(codes.InvalidArgument, "invalid audience")
+		}
 
 		return nil, status.Error(codes.Internal, "failed to retrieve refreshed tokens")
 	}
@@ -249,12 +283,16 @@ func (s *authServer) SendEmailVerification(
 		return nil, status.Error(codes.InvalidArgument, "app_id is required")
 	}
 
-	success, message, exp, err := s.auth.SendVerificationEmail(ctx, in.GetUserId(), in.GetAppId())
+	success, message, exp, nextSendAt, err := s.auth.SendVerificationEmail(ctx, in.GetUserId(), in.GetAppId())
+	setRetryAfter(ctx, nextSendAt)
 
 	if err != nil {
 		if errors.Is(err, auth.ErrInvalidCredentials) {
 			return nil, status.Error(codes.InvalidArgument, "invalid user id")
 		}
+		if errors.Is(err, auth.ErrVerificationCooldown) {
+			return nil, status.Error(codes.ResourceExhausted, "verification email was sent recently, retry later")
+		}
 
 		return nil, status.Error(codes.Internal, "failed to send email verification")
 	}
@@ -280,3 +318,55 @@ func (s *authServer) EmailVerify(
 	}
 	return &ssov1.EmailVerifyResponse{Success: success, Message: message, Activated: userActivated}, nil
 }
+
+// requestedAudience reads the resource services the client asks the token for
+// from the "x-audience" metadata (comma separated or repeated)
+func requestedAudience(ctx context.Context) []string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	var audience []string
+	for _, value := range md.Get(audienceMetadataKey) {
+		for _, aud := range strings.Split(value, ",") {
+			if aud = strings.TrimSpace(aud); aud != "" {
+				audience = append(audience, aud)
+			}
+		}
+	}
+
+	return audience
+}
+
+// requestedLocale picks the locale of the emails from the Accept-Language of the request,
+// empty if none of the languages is supported
+func requestedLocale(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	for _, key := range acceptLanguageKeys {
+		for _, value := range md.Get(key) {
+			if l := locale.Match(value); l != "" {
+				return l
+			}
+		}
+	}
+
+	return ""
+}
+
+// setRetryAfter tells the client in how many seconds the request may be repeated
+func setRetryAfter(ctx context.Context, at time.Time) {
+	if at.IsZero() {
+		return
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(retryAfterKey, strconv.FormatInt(retryAfterSeconds(at), 10)))
+}
+
+// retryAfterSeconds rounds up, so the client does not come back too early
+func retryAfterSeconds(at time.Time) int64 {
+	return max(int64(math.Ceil(time.Until(at).Seconds())), 0)
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/audit"
+	"sso/internal/services/emailqueue"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apiv1 "sso/api/gen/go/sso"
+)
+
+type emailQueueServer struct {
+	apiv1.UnimplementedEmailQueueServer
+	queue EmailQueue
+}
+
+type EmailQueue interface {
+	Stats(ctx context.Context) (models.EmailQueueStats, error)
+	Requeue(ctx context.Context, actorID int64, ids []int64) (int64, error)
+}
+
+func (s *emailQueueServer) GetEmailQueueStats(
+	ctx context.Context,
+	in *apiv1.GetEmailQueueStatsRequest,
+) (*apiv1.GetEmailQueueStatsResponse, error) {
+	stats, err := s.queue.Stats(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to get email queue stats")
+	}
+
+	return &apiv1.GetEmailQueueStatsResponse{
+		Pending:           stats.Pending,
+		Due:               stats.Due,
+		Dead:              stats.Dead,
+		OldestPendingUnix: unixOrZero(stats.OldestPending),
+	}, nil
+}
+
+func (s *emailQueueServer) RequeueDeadEmails(
+	ctx context.Context,
+	in *apiv1.RequeueDeadEmailsRequest,
+) (*apiv1.RequeueDeadEmailsResponse, error) {
+	requeued, err := s.queue.Requeue(ctx, audit.ActorID(ctx), in.GetIds())
+	if err != nil {
+		if errors.Is(err, emailqueue.ErrInvalidInput) {
+			return nil, status.Error(codes.InvalidArgument, "ids must be positive")
+		}
+		return nil, status.Error(codes.Internal, "failed to requeue dead emails")
+	}
+
+	return &apiv1.RequeueDeadEmailsResponse{Requeued: requeued}, nil
+}
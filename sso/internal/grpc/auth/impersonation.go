@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sso/internal/lib/audit"
+	"sso/internal/services/impersonation"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apiv1 "sso/api/gen/go/sso"
+)
+
+type impersonationServer struct {
+	apiv1.UnimplementedImpersonationServer
+	impersonation Impersonation
+}
+
+type Impersonation interface {
+	Impersonate(
+		ctx context.Context,
+		actorID int64,
+		userID int64,
+		appID int32,
+		reason string,
+	) (token string, expiresAt int64, err error)
+}
+
+func (s *impersonationServer) Impersonate(
+	ctx context.Context,
+	in *apiv1.ImpersonateRequest,
+) (*apiv1.ImpersonateResponse, error) {
+	if in.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if in.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+	if in.GetReason() == "" {
+		return nil, status.Error(codes.InvalidArgument, "reason is required")
+	}
+
+	// only a person signed in as themselves may impersonate, no chains and no api keys
+	if _, ok := ctx.Value("actor_id").(int64); ok {
+		return nil, status.Error(codes.PermissionDenied, "impersonation tokens can't impersonate")
+	}
+	if _, ok := ctx.Value("api_key_id").(int64); ok {
+		return nil, status.Error(codes.PermissionDenied, "api keys can't impersonate")
+	}
+
+	token, expiresAt, err := s.impersonation.Impersonate(ctx, audit.ActorID(ctx), in.GetUserId(), in.GetAppId(), in.GetReason())
+	if err != nil {
+		switch {
+		case errors.Is(err, impersonation.ErrInvalidInput):
+			return nil, status.Error(codes.InvalidArgument, "invalid request")
+		case errors.Is(err, impersonation.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		case errors.Is(err, impersonation.ErrPrivilegedUser):
+			return nil, status.Error(codes.PermissionDenied, "user can't be impersonated")
+		default:
+			return nil, status.Error(codes.Internal, "failed to impersonate user")
+		}
+	}
+
+	return &apiv1.ImpersonateResponse{AccessToken: token, ExpiresAt: expiresAt}, nil
+}
@@ -23,6 +23,7 @@ type PermissionService interface {
 	HasUserPermission(
 		ctx context.Context,
 		userId int64,
+		appID int32,
 		permission string,
 	) (allowed bool, err error)
 }
@@ -54,7 +55,7 @@ func (s *permissionServer) HasUserPermission(
 		return nil, status.Error(codes.InvalidArgument, "permission is required")
 	}
 
-	allowed, err := s.permission.HasUserPermission(ctx, in.GetUserId(), in.GetPermission())
+	allowed, err := s.permission.HasUserPermission(ctx, in.GetUserId(), 0, in.GetPermission())
 	if err != nil {
 		return nil, status.Error(codes.Internal, "failed to check user permission")
 	}
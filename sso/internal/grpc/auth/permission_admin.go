@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/audit"
+	"sso/internal/services/permission"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apiv1 "sso/api/gen/go/sso"
+)
+
+type permissionAdminServer struct {
+	apiv1.UnimplementedPermissionAdminServer
+	permission PermissionAdmin
+}
+
+type PermissionAdmin interface {
+	GrantUserPermission(
+		ctx context.Context,
+		actorID int64,
+		userID int64,
+		code string,
+	) (changed bool, err error)
+	RevokeUserPermission(
+		ctx context.Context,
+		actorID int64,
+		userID int64,
+		code string,
+	) (changed bool, err error)
+	ListPermissions(ctx context.Context) ([]models.Permission, error)
+	ListUsersWithPermission(
+		ctx context.Context,
+		code string,
+		limit int32,
+		offset int32,
+	) ([]models.User, error)
+	ListRoles(ctx context.Context) ([]models.Role, error)
+	GetUserRoles(ctx context.Context, userID int64) ([]models.UserRole, error)
+	AssignRole(ctx context.Context, userID int64, roleCode string, appID int32) error
+	RevokeRole(ctx context.Context, userID int64, roleCode string, appID int32) error
+}
+
+func (s *permissionAdminServer) GrantPermission(
+	ctx context.Context,
+	in *apiv1.GrantPermissionRequest,
+) (*apiv1.GrantPermissionResponse, error) {
+	if in.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	if in.GetPermission() == "" {
+		return nil, status.Error(codes.InvalidArgument, "permission is required")
+	}
+
+	changed, err := s.permission.GrantUserPermission(ctx, audit.ActorID(ctx), in.GetUserId(), in.GetPermission())
+	if err != nil {
+		return nil, permissionAdminError(err, "failed to grant permission")
+	}
+
+	return &apiv1.GrantPermissionResponse{Changed: changed}, nil
+}
+
+func (s *permissionAdminServer) RevokePermission(
+	ctx context.Context,
+	in *apiv1.RevokePermissionRequest,
+) (*apiv1.RevokePermissionResponse, error) {
+	if in.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	if in.GetPermission() == "" {
+		return nil, status.Error(codes.InvalidArgument, "permission is required")
+	}
+
+	changed, err := s.permission.RevokeUserPermission(ctx, audit.ActorID(ctx), in.GetUserId(), in.GetPermission())
+	if err != nil {
+		return nil, permissionAdminError(err, "failed to revoke permission")
+	}
+
+	return &apiv1.RevokePermissionResponse{Changed: changed}, nil
+}
+
+func (s *permissionAdminServer) ListPermissions(
+	ctx context.Context,
+	_ *apiv1.ListPermissionsRequest,
+) (*apiv1.ListPermissionsResponse, error) {
+	permissions, err := s.permission.ListPermissions(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to list permissions")
+	}
+
+	resp := &apiv1.ListPermissionsResponse{Permissions: make([]*apiv1.PermissionInfo, len(permissions))}
+	for i, p := range permissions {
+		resp.Permissions[i] = &apiv1.PermissionInfo{Id: p.ID, Code: p.Code, Description: p.Description}
+	}
+
+	return resp, nil
+}
+
+func (s *permissionAdminServer) ListUsersWithPermission(
+	ctx context.Context,
+	in *apiv1.ListUsersWithPermissionRequest,
+) (*apiv1.ListUsersWithPermissionResponse, error) {
+	if in.GetPermission() == "" {
+		return nil, status.Error(codes.InvalidArgument, "permission is required")
+	}
+
+	users, err := s.permission.ListUsersWithPermission(ctx, in.GetPermission(), in.GetLimit(), in.GetOffset())
+	if err != nil {
+		return nil, permissionAdminError(err, "failed to list users with permission")
+	}
+
+	resp := &apiv1.ListUsersWithPermissionResponse{Users: make([]*apiv1.UserSummary, len(users))}
+	for i, u := range users {
+		resp.Users[i] = &apiv1.UserSummary{UserId: u.ID, Email: u.Email, Name: u.Name}
+	}
+
+	return resp, nil
+}
+
+func (s *permissionAdminServer) ListRoles(
+	ctx context.Context,
+	_ *apiv1.ListRolesRequest,
+) (*apiv1.ListRolesResponse, error) {
+	roles, err := s.permission.ListRoles(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to list roles")
+	}
+
+	resp := &apiv1.ListRolesResponse{Roles: make([]*apiv1.RoleInfo, len(roles))}
+	for i, r := range roles {
+		resp.Roles[i] = &apiv1.RoleInfo{Id: r.ID, Code: r.Code, Description: r.Description, Permissions: r.Permissions}
+	}
+
+	return resp, nil
+}
+
+func (s *permissionAdminServer) GetUserRoles(
+	ctx context.Context,
+	in *apiv1.GetUserRolesRequest,
+) (*apiv1.GetUserRolesResponse, error) {
+	if in.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	roles, err := s.permission.GetUserRoles(ctx, in.GetUserId())
+	if err != nil {
+		return nil, permissionAdminError(err, "failed to get user roles")
+	}
+
+	resp := &apiv1.GetUserRolesResponse{Roles: make([]*apiv1.UserRoleInfo, len(roles))}
+	for i, r := range roles {
+		resp.Roles[i] = &apiv1.UserRoleInfo{Role: r.RoleCode, AppId: r.AppID}
+	}
+
+	return resp, nil
+}
+
+func (s *permissionAdminServer) AssignRole(
+	ctx context.Context,
+	in *apiv1.AssignRoleRequest,
+) (*apiv1.AssignRoleResponse, error) {
+	if in.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	if in.GetRole() == "" {
+		return nil, status.Error(codes.InvalidArgument, "role is required")
+	}
+
+	if err := s.permission.AssignRole(ctx, in.GetUserId(), in.GetRole(), in.GetAppId()); err != nil {
+		return nil, permissionAdminError(err, "failed to assign role")
+	}
+
+	return &apiv1.AssignRoleResponse{}, nil
+}
+
+func (s *permissionAdminServer) RevokeRole(
+	ctx context.Context,
+	in *apiv1.RevokeRoleRequest,
+) (*apiv1.RevokeRoleResponse, error) {
+	if in.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	if in.GetRole() == "" {
+		return nil, status.Error(codes.InvalidArgument, "role is required")
+	}
+
+	if err := s.permission.RevokeRole(ctx, in.GetUserId(), in.GetRole(), in.GetAppId()); err != nil {
+		return nil, permissionAdminError(err, "failed to revoke role")
+	}
+
+	return &apiv1.RevokeRoleResponse{}, nil
+}
+
+func permissionAdminError(err error, msg string) error {
+	switch {
+	case errors.Is(err, permission.ErrInvalidInput):
+		return status.Error(codes.InvalidArgument, "invalid request")
+	case errors.Is(err, permission.ErrInvalidCredentials):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, permission.ErrPermissionNotFound):
+		return status.Error(codes.NotFound, "permission not found")
+	case errors.Is(err, permission.ErrRoleNotFound):
+		return status.Error(codes.NotFound, "role not found")
+	case errors.Is(err, permission.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	default:
+		return status.Error(codes.Internal, msg)
+	}
+}
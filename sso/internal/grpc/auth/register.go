@@ -4,9 +4,19 @@ import (
 	"google.golang.org/grpc"
 
 	ssov1 "github.com/m4rk1sov/protos/gen/go/sso"
+	apiv1 "sso/api/gen/go/sso"
 )
 
-func Register(gRPCServer *grpc.Server, auth Auth, permission PermissionService) {
+func Register(gRPCServer *grpc.Server, auth Auth, permission PermissionService, permissionAdmin PermissionAdmin, apiKeys APIKeys, impersonation Impersonation, audit Audit, users UserAdmin, account Account, exports DataExports, webhooks Webhooks, emailQueue EmailQueue, verification Verification) {
 	ssov1.RegisterAuthServer(gRPCServer, &authServer{auth: auth})
 	ssov1.RegisterPermissionServer(gRPCServer, &permissionServer{permission: permission})
+	apiv1.RegisterPermissionAdminServer(gRPCServer, &permissionAdminServer{permission: permissionAdmin})
+	apiv1.RegisterApiKeysServer(gRPCServer, &apiKeysServer{keys: apiKeys})
+	apiv1.RegisterImpersonationServer(gRPCServer, &impersonationServer{impersonation: impersonation})
+	apiv1.RegisterAuditServer(gRPCServer, &auditServer{audit: audit})
+	apiv1.RegisterUserAdminServer(gRPCServer, &userAdminServer{users: users})
+	apiv1.RegisterAccountServer(gRPCServer, &accountServer{account: account, exports: exports})
+	apiv1.RegisterWebhooksServer(gRPCServer, &webhooksServer{webhooks: webhooks})
+	apiv1.RegisterEmailQueueServer(gRPCServer, &emailQueueServer{queue: emailQueue})
+	apiv1.RegisterVerificationServer(gRPCServer, &verificationServer{verification: verification})
 }
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/audit"
+	"sso/internal/services/user"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apiv1 "sso/api/gen/go/sso"
+)
+
+type userAdminServer struct {
+	apiv1.UnimplementedUserAdminServer
+	users UserAdmin
+}
+
+type UserAdmin interface {
+	ListUsers(ctx context.Context, filter models.UserFilter, permission string) ([]models.User, error)
+	UserDetails(ctx context.Context, userID int64) (models.User, []models.Permission, error)
+	SetDisabled(ctx context.Context, actorID int64, userID int64, disabled bool, reason string) (changed bool, err error)
+	ForcePasswordReset(ctx context.Context, actorID int64, userID int64) (resetExpiresAt time.Time, err error)
+	DeleteUser(ctx context.Context, actorID int64, userID int64) error
+}
+
+func (s *userAdminServer) ListUsers(
+	ctx context.Context,
+	in *apiv1.ListUsersRequest,
+) (*apiv1.ListUsersResponse, error) {
+	filter := models.UserFilter{
+		EmailPrefix: in.GetEmailPrefix(),
+		Activated:   in.Activated,
+		Limit:       in.GetLimit(),
+		Offset:      in.GetOffset(),
+	}
+
+	users, err := s.users.ListUsers(ctx, filter, in.GetPermission())
+	if err != nil {
+		return nil, userAdminError(err, "failed to list users")
+	}
+
+	resp := &apiv1.ListUsersResponse{Users: make([]*apiv1.UserDetails, len(users))}
+	for i, u := range users {
+		resp.Users[i] = userToProto(u)
+	}
+
+	return resp, nil
+}
+
+func (s *userAdminServer) GetUser(
+	ctx context.Context,
+	in *apiv1.GetUserRequest,
+) (*apiv1.GetUserResponse, error) {
+	if in.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	u, permissions, err := s.users.UserDetails(ctx, in.GetUserId())
+	if err != nil {
+		return nil, userAdminError(err, "failed to get user")
+	}
+
+	codesList := make([]string, len(permissions))
+	for i, permission := range permissions {
+		codesList[i] = permission.Code
+	}
+
+	return &apiv1.GetUserResponse{User: userToProto(u), Permissions: codesList}, nil
+}
+
+func (s *userAdminServer) DisableUser(
+	ctx context.Context,
+	in *apiv1.DisableUserRequest,
+) (*apiv1.DisableUserResponse, error) {
+	if in.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	changed, err := s.users.SetDisabled(ctx, audit.ActorID(ctx), in.GetUserId(), true, in.GetReason())
+	if err != nil {
+		return nil, userAdminError(err, "failed to disable user")
+	}
+
+	return &apiv1.DisableUserResponse{Changed: changed}, nil
+}
+
+func (s *userAdminServer) EnableUser(
+	ctx context.Context,
+	in *apiv1.EnableUserRequest,
+) (*apiv1.EnableUserResponse, error) {
+	if in.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	changed, err := s.users.SetDisabled(ctx, audit.ActorID(ctx), in.GetUserId(), false, in.GetReason())
+	if err != nil {
+		return nil, userAdminError(err, "failed to enable user")
+	}
+
+	return &apiv1.EnableUserResponse{Changed: changed}, nil
+}
+
+func (s *userAdminServer) ForcePasswordReset(
+	ctx context.Context,
+	in *apiv1.ForcePasswordResetRequest,
+) (*apiv1.ForcePasswordResetResponse, error) {
+	if in.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	expiresAt, err := s.users.ForcePasswordReset(ctx, audit.ActorID(ctx), in.GetUserId())
+	if err != nil {
+		return nil, userAdminError(err, "failed to force password reset")
+	}
+
+	return &apiv1.ForcePasswordResetResponse{ResetExpiresAt: expiresAt.Unix()}, nil
+}
+
+func (s *userAdminServer) DeleteUser(
+	ctx context.Context,
+	in *apiv1.DeleteUserRequest,
+) (*apiv1.DeleteUserResponse, error) {
+	if in.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	if err := s.users.DeleteUser(ctx, audit.ActorID(ctx), in.GetUserId()); err != nil {
+		return nil, userAdminError(err, "failed to delete user")
+	}
+
+	return &apiv1.DeleteUserResponse{}, nil
+}
+
+func userToProto(u models.User) *apiv1.UserDetails {
+	return &apiv1.UserDetails{
+		Id:             u.ID,
+		Email:          u.Email,
+		Name:           u.Name,
+		Phone:          u.Phone,
+		Address:        u.Address,
+		Activated:      u.Activated,
+		Disabled:       u.Disabled(),
+		DisabledAtUnix: unixOrZero(u.DisabledAt),
+		CreatedAtUnix:  unixOrZero(u.CreatedAt),
+	}
+}
+
+func userAdminError(err error, msg string) error {
+	switch {
+	case errors.Is(err, user.ErrInvalidInput):
+		return status.Error(codes.InvalidArgument, "invalid request")
+	case errors.Is(err, user.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, user.ErrPermissionNotFound):
+		return status.Error(codes.NotFound, "permission not found")
+	case errors.Is(err, user.ErrSelfAction):
+		return status.Error(codes.FailedPrecondition, "can't be applied to own account")
+	case errors.Is(err, user.ErrPrivilegedUser):
+		return status.Error(codes.PermissionDenied, "user has permissions the caller doesn't have")
+	default:
+		return status.Error(codes.Internal, msg)
+	}
+}
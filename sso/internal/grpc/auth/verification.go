@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apiv1 "sso/api/gen/go/sso"
+)
+
+type verificationServer struct {
+	apiv1.UnimplementedVerificationServer
+	verification Verification
+}
+
+type Verification interface {
+	ResendVerification(ctx context.Context, email string, appID int32) (nextSendAt time.Time, err error)
+}
+
+func (s *verificationServer) ResendVerification(
+	ctx context.Context,
+	in *apiv1.ResendVerificationRequest,
+) (*apiv1.ResendVerificationResponse, error) {
+	if in.GetEmail() == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	nextSendAt, err := s.verification.ResendVerification(ctx, in.GetEmail(), in.GetAppId())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to resend verification email")
+	}
+	setRetryAfter(ctx, nextSendAt)
+
+	return &apiv1.ResendVerificationResponse{
+		Message:           "If this email is registered and not verified yet, a verification link was sent",
+		RetryAfterSeconds: retryAfterSeconds(nextSendAt),
+	}, nil
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/audit"
+	"sso/internal/services/webhook"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apiv1 "sso/api/gen/go/sso"
+)
+
+type webhooksServer struct {
+	apiv1.UnimplementedWebhooksServer
+	webhooks Webhooks
+}
+
+type Webhooks interface {
+	Create(ctx context.Context, actorID int64, appID int32, url string, eventTypes []string) (models.Webhook, error)
+	List(ctx context.Context, appID int32) ([]models.Webhook, error)
+	Delete(ctx context.Context, actorID int64, webhookID int64) error
+	Deliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error)
+	Replay(ctx context.Context, actorID int64, deliveryID int64) (models.WebhookDelivery, error)
+}
+
+func (s *webhooksServer) CreateWebhook(
+	ctx context.Context,
+	in *apiv1.CreateWebhookRequest,
+) (*apiv1.CreateWebhookResponse, error) {
+	if in.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+	if in.GetUrl() == "" {
+		return nil, status.Error(codes.InvalidArgument, "url is required")
+	}
+
+	wh, err := s.webhooks.Create(ctx, audit.ActorID(ctx), in.GetAppId(), in.GetUrl(), in.GetEventTypes())
+	if err != nil {
+		return nil, webhookError(err, "failed to create webhook")
+	}
+
+	return &apiv1.CreateWebhookResponse{Webhook: webhookToProto(wh)}, nil
+}
+
+func (s *webhooksServer) ListWebhooks(
+	ctx context.Context,
+	in *apiv1.ListWebhooksRequest,
+) (*apiv1.ListWebhooksResponse, error) {
+	webhooks, err := s.webhooks.List(ctx, in.GetAppId())
+	if err != nil {
+		return nil, webhookError(err, "failed to list webhooks")
+	}
+
+	resp := &apiv1.ListWebhooksResponse{Webhooks: make([]*apiv1.Webhook, len(webhooks))}
+	for i, wh := range webhooks {
+		resp.Webhooks[i] = webhookToProto(wh)
+	}
+
+	return resp, nil
+}
+
+func (s *webhooksServer) DeleteWebhook(
+	ctx context.Context,
+	in *apiv1.DeleteWebhookRequest,
+) (*apiv1.DeleteWebhookResponse, error) {
+	if in.GetWebhookId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "webhook_id is required")
+	}
+
+	if err := s.webhooks.Delete(ctx, audit.ActorID(ctx), in.GetWebhookId()); err != nil {
+		return nil, webhookError(err, "failed to delete webhook")
+	}
+
+	return &apiv1.DeleteWebhookResponse{}, nil
+}
+
+func (s *webhooksServer) ListWebhookDeliveries(
+	ctx context.Context,
+	in *apiv1.ListWebhookDeliveriesRequest,
+) (*apiv1.ListWebhookDeliveriesResponse, error) {
+	if in.GetWebhookId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "webhook_id is required")
+	}
+
+	deliveries, err := s.webhooks.Deliveries(ctx, models.WebhookDeliveryFilter{
+		WebhookID: in.GetWebhookId(),
+		Status:    in.GetStatus(),
+		Limit:     in.GetLimit(),
+		Offset:    in.GetOffset(),
+	})
+	if err != nil {
+		return nil, webhookError(err, "failed to list webhook deliveries")
+	}
+
+	resp := &apiv1.ListWebhookDeliveriesResponse{Deliveries: make([]*apiv1.WebhookDelivery, len(deliveries))}
+	for i, delivery := range deliveries {
+		resp.Deliveries[i] = webhookDeliveryToProto(delivery)
+	}
+
+	return resp, nil
+}
+
+func (s *webhooksServer) ReplayWebhookDelivery(
+	ctx context.Context,
+	in *apiv1.ReplayWebhookDeliveryRequest,
+) (*apiv1.ReplayWebhookDeliveryResponse, error) {
+	if in.GetDeliveryId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "delivery_id is required")
+	}
+
+	delivery, err := s.webhooks.Replay(ctx, audit.ActorID(ctx), in.GetDeliveryId())
+	if err != nil {
+		return nil, webhookError(err, "failed to replay webhook delivery")
+	}
+
+	return &apiv1.ReplayWebhookDeliveryResponse{Delivery: webhookDeliveryToProto(delivery)}, nil
+}
+
+func webhookToProto(wh models.Webhook) *apiv1.Webhook {
+	return &apiv1.Webhook{
+		Id:            wh.ID,
+		AppId:         wh.AppID,
+		Url:           wh.URL,
+		EventTypes:    wh.EventTypes,
+		CreatedBy:     wh.CreatedBy,
+		CreatedAtUnix: unixOrZero(wh.CreatedAt),
+	}
+}
+
+func webhookDeliveryToProto(d models.WebhookDelivery) *apiv1.WebhookDelivery {
+	return &apiv1.WebhookDelivery{
+		Id:                d.ID,
+		WebhookId:         d.WebhookID,
+		EventId:           d.EventID,
+		EventType:         d.EventType,
+		Status:            d.Status,
+		Attempts:          d.Attempts,
+		NextAttemptAtUnix: unixOrZero(d.NextAttemptAt),
+		LastStatusCode:    d.LastStatusCode,
+		LastError:         d.LastError,
+		CreatedAtUnix:     unixOrZero(d.CreatedAt),
+		DeliveredAtUnix:   unixOrZero(d.DeliveredAt),
+	}
+}
+
+func webhookError(err error, msg string) error {
+	switch {
+	case errors.Is(err, webhook.ErrInvalidInput):
+		return status.Error(codes.InvalidArgument, "invalid webhook")
+	case errors.Is(err, webhook.ErrAppNotFound):
+		return status.Error(codes.NotFound, "app not found")
+	case errors.Is(err, webhook.ErrWebhookNotFound):
+		return status.Error(codes.NotFound, "webhook not found")
+	case errors.Is(err, webhook.ErrDeliveryNotFound):
+		return status.Error(codes.NotFound, "failed delivery not found")
+	default:
+		return status.Error(codes.Internal, msg)
+	}
+}
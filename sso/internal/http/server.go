@@ -13,6 +13,12 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"net/http"
+	apiv1 "sso/api/gen/go/sso"
+	"sso/internal/lib/audit"
+	"sso/internal/lib/metrics"
+	"sso/pkg/requestid"
+	"sso/pkg/tracing"
+	"strings"
 	"time"
 )
 
@@ -37,9 +43,18 @@ func (s *Server) Start() error {
 	//defer cancel()
 
 	// gRPC-Gateway mux for API endpoints
-	gwMux := runtime.NewServeMux()
-
-	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	gwMux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(headerMatcher),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaderMatcher),
+		runtime.WithMiddlewares(metrics.GatewayMiddleware, tracing.GatewayMiddleware),
+		runtime.WithMetadata(audit.GatewayMetadata),
+	)
+
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		// the loopback calls continue the trace of the HTTP request
+		tracing.DialOption(),
+	}
 
 	err := ssov1.RegisterAuthHandlerFromEndpoint(context.Background(), gwMux, s.grpcAddr, opts)
 	if err != nil {
@@ -51,6 +66,51 @@ func (s *Server) Start() error {
 		return fmt.Errorf("failed to register permission handler: %w", err)
 	}
 
+	err = apiv1.RegisterPermissionAdminHandlerFromEndpoint(context.Background(), gwMux, s.grpcAddr, opts)
+	if err != nil {
+		return fmt.Errorf("failed to register permission admin handler: %w", err)
+	}
+
+	err = apiv1.RegisterApiKeysHandlerFromEndpoint(context.Background(), gwMux, s.grpcAddr, opts)
+	if err != nil {
+		return fmt.Errorf("failed to register api keys handler: %w", err)
+	}
+
+	err = apiv1.RegisterImpersonationHandlerFromEndpoint(context.Background(), gwMux, s.grpcAddr, opts)
+	if err != nil {
+		return fmt.Errorf("failed to register impersonation handler: %w", err)
+	}
+
+	err = apiv1.RegisterAuditHandlerFromEndpoint(context.Background(), gwMux, s.grpcAddr, opts)
+	if err != nil {
+		return fmt.Errorf("failed to register audit handler: %w", err)
+	}
+
+	err = apiv1.RegisterUserAdminHandlerFromEndpoint(context.Background(), gwMux, s.grpcAddr, opts)
+	if err != nil {
+		return fmt.Errorf("failed to register user admin handler: %w", err)
+	}
+
+	err = apiv1.RegisterAccountHandlerFromEndpoint(context.Background(), gwMux, s.grpcAddr, opts)
+	if err != nil {
+		return fmt.Errorf("failed to register account handler: %w", err)
+	}
+
+	err = apiv1.RegisterWebhooksHandlerFromEndpoint(context.Background(), gwMux, s.grpcAddr, opts)
+	if err != nil {
+		return fmt.Errorf("failed to register webhooks handler: %w", err)
+	}
+
+	err = apiv1.RegisterEmailQueueHandlerFromEndpoint(context.Background(), gwMux, s.grpcAddr, opts)
+	if err != nil {
+		return fmt.Errorf("failed to register email queue handler: %w", err)
+	}
+
+	err = apiv1.RegisterVerificationHandlerFromEndpoint(context.Background(), gwMux, s.grpcAddr, opts)
+	if err != nil {
+		return fmt.Errorf("failed to register verification handler: %w", err)
+	}
+
 	// Main mux for swagger UI and API endpoints
 	mainMux := http.NewServeMux()
 
@@ -59,6 +119,9 @@ func (s *Server) Start() error {
 
 	// API endpoints
 	mainMux.Handle("/v1/", gwMux)
+
+	// Prometheus scrape endpoint
+	mainMux.Handle("/metrics", metrics.Handler())
 	//mainMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 	//	if strings.HasPrefix(r.URL.Path, "/swagger") {
 	//		http.NotFound(w, r)
@@ -67,7 +130,8 @@ func (s *Server) Start() error {
 	//	gwMux.ServeHTTP(w, r)
 	//})
 
-	handler := corsMiddleware(mainMux)
+	// the data export download link carries its token in the path
+	handler := tracing.Handler(corsMiddleware(mainMux), "/v1/account/exports/download/")
 
 	s.httpServer = &http.Server{
 		Addr:         fmt.Sprintf("localhost:%d", s.port),
@@ -99,12 +163,41 @@ func (s *Server) Stop(ctx context.Context) error {
 	return s.httpServer.Shutdown(ctx)
 }
 
+// headerMatcher forwards the requested token audience and api key to gRPC metadata
+func headerMatcher(key string) (string, bool) {
+	if strings.EqualFold(key, "X-Audience") {
+		return "x-audience", true
+	}
+	if strings.EqualFold(key, "X-Api-Key") {
+		return "x-api-key", true
+	}
+	if strings.EqualFold(key, "X-Request-Id") {
+		return requestid.MetadataKey, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// outgoingHeaderMatcher passes Content-Disposition of the downloads, Retry-After of the limited requests
+// and X-Request-Id as is, other metadata keeps the default Grpc-Metadata- prefix
+func outgoingHeaderMatcher(key string) (string, bool) {
+	if strings.EqualFold(key, "content-disposition") {
+		return "Content-Disposition", true
+	}
+	if strings.EqualFold(key, "retry-after") {
+		return "Retry-After", true
+	}
+	if strings.EqualFold(key, requestid.MetadataKey) {
+		return "X-Request-Id", true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-		w.Header().Set("Access-Control-Expose-Headers", "Content-Length")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Audience, X-Api-Key, X-Request-Id")
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Disposition, Retry-After, X-Request-Id")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		if r.Method == "OPTIONS" {
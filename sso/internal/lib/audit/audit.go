@@ -0,0 +1,179 @@
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+	"sso/internal/domain/models"
+	"sso/pkg/logger/sl"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// Event types
+const (
+	TypeRegister               = "auth.register"
+	TypeLogin                  = "auth.login"
+	TypeLogout                 = "auth.logout"
+	TypeVerificationSent       = "auth.verification_sent"
+	TypeEmailVerified          = "auth.email_verified"
+	TypePasswordResetRequested = "auth.password_reset_requested"
+	TypePasswordReset          = "auth.password_reset"
+	TypePermissionGrant        = "permission.grant"
+	TypePermissionRevoke       = "permission.revoke"
+	TypeRoleAssign             = "role.assign"
+	TypeRoleRevoke             = "role.revoke"
+	TypeImpersonate            = "user.impersonate"
+	TypeUserDisable            = "user.disable"
+	TypeUserEnable             = "user.enable"
+	TypeUserPasswordReset      = "user.force_password_reset"
+	TypeUserDelete             = "user.delete"
+	TypeAccountDeletionRequest = "account.deletion_scheduled"
+	TypeAccountDeletionCancel  = "account.deletion_canceled"
+	TypeAccountDeleted         = "account.deleted"
+	TypeDataExportRequest      = "account.data_export_requested"
+	TypeDataExportDownload     = "account.data_export_downloaded"
+	TypeWebhookCreate          = "webhook.create"
+	TypeWebhookDelete          = "webhook.delete"
+	TypeWebhookReplay          = "webhook.replay"
+	TypeEmailRequeue           = "email.requeue"
+)
+
+// Outcomes of the audited actions
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Sink stores audit events
+type Sink interface {
+	SaveAuditEvent(ctx context.Context, event models.AuditEvent) error
+}
+
+// Recorder records events of the services. Audit must never break the audited action,
+// so storage failures are only logged, the services depend on Record and RecordFailure only
+type Recorder struct {
+	log  *slog.Logger
+	sink Sink
+}
+
+func New(log *slog.Logger, sink Sink) *Recorder {
+	return &Recorder{
+		log:  log,
+		sink: sink,
+	}
+}
+
+// Record stores the event with the metadata of the incoming request
+func (r *Recorder) Record(ctx context.Context, event models.AuditEvent) {
+	const op = "audit.Record"
+
+	if err := r.sink.SaveAuditEvent(ctx, WithRequest(ctx, event)); err != nil {
+		r.log.ErrorContext(ctx, "failed to record audit event",
+			slog.String("op", op),
+			slog.String("type", event.Type),
+			sl.Err(err),
+		)
+	}
+}
+
+// RecordFailure records the failed attempt with the reason
+func (r *Recorder) RecordFailure(ctx context.Context, event models.AuditEvent, err error) {
+	event.Outcome = OutcomeFailure
+	if event.Payload == nil {
+		event.Payload = make(map[string]any)
+	}
+	event.Payload["error"] = err.Error()
+
+	r.Record(ctx, event)
+}
+
+// ActorID returns the authenticated caller set by the permission interceptor, 0 for public methods
+func ActorID(ctx context.Context) int64 {
+	actorID, _ := ctx.Value("user_id").(int64)
+	return actorID
+}
+
+// HashEmail identifies the attempts made with an email without storing it,
+// attempts of one address are found by the hash of the address
+func HashEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
+}
+
+// GatewayMetadataKey carries the secret of the gateway running in the process,
+// the client address and user agent sent by the gateway are trusted only with it
+const GatewayMetadataKey = "x-gateway-secret"
+
+var gatewaySecret = newGatewaySecret()
+
+// GatewayMetadata marks the calls of the gateway, see runtime.WithMetadata
+func GatewayMetadata(context.Context, *http.Request) metadata.MD {
+	return metadata.Pairs(GatewayMetadataKey, gatewaySecret)
+}
+
+func newGatewaySecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func fromGateway(md metadata.MD) bool {
+	for _, secret := range md.Get(GatewayMetadataKey) {
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(gatewaySecret)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// WithRequest fills the client address and user agent of the incoming gRPC request,
+// requests coming through the gateway carry them in x-forwarded-for and grpcgateway-user-agent,
+// the headers are ignored on direct gRPC calls
+func WithRequest(ctx context.Context, event models.AuditEvent) models.AuditEvent {
+	if event.Outcome == "" {
+		event.Outcome = OutcomeSuccess
+	}
+
+	if actorID, ok := ctx.Value("actor_id").(int64); ok {
+		if event.Payload == nil {
+			event.Payload = make(map[string]any)
+		}
+		event.Payload["impersonator_id"] = actorID
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	gateway := fromGateway(md)
+
+	if event.IP == "" {
+		if forwarded := md.Get("x-forwarded-for"); gateway && len(forwarded) > 0 {
+			// the gateway appends the address of its peer last, the ones before are sent by the client
+			addresses := strings.Split(forwarded[len(forwarded)-1], ",")
+			event.IP = strings.TrimSpace(addresses[len(addresses)-1])
+		} else if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			event.IP = p.Addr.String()
+			if host, _, err := net.SplitHostPort(event.IP); err == nil {
+				event.IP = host
+			}
+		}
+	}
+
+	if event.UserAgent == "" {
+		if ua := md.Get("grpcgateway-user-agent"); gateway && len(ua) > 0 {
+			event.UserAgent = ua[0]
+		} else if ua := md.Get("user-agent"); len(ua) > 0 {
+			event.UserAgent = ua[0]
+		}
+	}
+
+	return event
+}
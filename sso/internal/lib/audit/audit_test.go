@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"sso/internal/domain/models"
+)
+
+type memorySink struct {
+	events []models.AuditEvent
+}
+
+func (s *memorySink) SaveAuditEvent(_ context.Context, event models.AuditEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func requestContext(pairs ...string) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 50000}})
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(pairs...))
+}
+
+func TestWithRequest_DirectCallIgnoresForwardedHeaders(t *testing.T) {
+	ctx := requestContext(
+		"x-forwarded-for", "203.0.113.7",
+		"grpcgateway-user-agent", "forged",
+		"user-agent", "grpc-go/1.72",
+	)
+
+	event := WithRequest(ctx, models.AuditEvent{Type: TypeLogin})
+
+	assert.Equal(t, "10.0.0.5", event.IP)
+	assert.Equal(t, "grpc-go/1.72", event.UserAgent)
+	assert.Equal(t, OutcomeSuccess, event.Outcome)
+}
+
+func TestWithRequest_Gateway(t *testing.T) {
+	ctx := requestContext(
+		GatewayMetadataKey, gatewaySecret,
+		// the client sent its own X-Forwarded-For, the gateway appended the address it saw
+		"x-forwarded-for", "203.0.113.7, 198.51.100.2",
+		"grpcgateway-user-agent", "curl/8.5",
+	)
+
+	event := WithRequest(ctx, models.AuditEvent{Type: TypeLogin})
+
+	assert.Equal(t, "198.51.100.2", event.IP)
+	assert.Equal(t, "curl/8.5", event.UserAgent)
+}
+
+func TestWithRequest_WrongGatewaySecret(t *testing.T) {
+	ctx := requestContext(
+		GatewayMetadataKey, "guess",
+		"x-forwarded-for", "203.0.113.7",
+	)
+
+	event := WithRequest(ctx, models.AuditEvent{Type: TypeLogin})
+
+	assert.Equal(t, "10.0.0.5", event.IP)
+}
+
+func TestRecordFailure(t *testing.T) {
+	sink := &memorySink{}
+	recorder := New(slog.New(slog.NewTextHandler(io.Discard, nil)), sink)
+
+	recorder.RecordFailure(requestContext(), models.AuditEvent{
+		Type:    TypeLogin,
+		Payload: map[string]any{"email_hash": HashEmail("User@Example.com")},
+	}, errors.New("invalid credentials"))
+
+	require.Len(t, sink.events, 1)
+	assert.Equal(t, OutcomeFailure, sink.events[0].Outcome)
+	assert.Equal(t, "invalid credentials", sink.events[0].Payload["error"])
+	assert.Equal(t, HashEmail(" user@example.com"), sink.events[0].Payload["email_hash"])
+	assert.NotContains(t, sink.events[0].Payload["email_hash"], "example.com")
+}
@@ -0,0 +1,13 @@
+// Package backoff spaces the retries of the failed deliveries
+package backoff
+
+import "time"
+
+// Delay doubles minDelay with every failed attempt, up to maxDelay
+func Delay(attempts int32, minDelay, maxDelay time.Duration) time.Duration {
+	delay := minDelay
+	for i := int32(0); i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
@@ -0,0 +1,26 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelay(t *testing.T) {
+	tests := []struct {
+		attempts int32
+		want     time.Duration
+	}{
+		{attempts: 0, want: time.Second},
+		{attempts: 1, want: 2 * time.Second},
+		{attempts: 3, want: 8 * time.Second},
+		{attempts: 6, want: time.Minute},
+		{attempts: 100, want: time.Minute},
+		{attempts: -1, want: time.Second},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Delay(tt.attempts, time.Second, time.Minute), "attempts %d", tt.attempts)
+	}
+}
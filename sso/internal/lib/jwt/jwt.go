@@ -1,6 +1,8 @@
 package jwt
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
@@ -16,6 +18,7 @@ var (
 	ErrNotAccessToken  = errors.New("token is not access token")
 	ErrTokenMalformed  = errors.New("token is malformed")
 	ErrInvalidIssuer   = errors.New("token issuer is invalid")
+	ErrInvalidAudience = errors.New("token audience is invalid")
 )
 
 type TokenClaims struct {
@@ -25,19 +28,88 @@ type TokenClaims struct {
 	Type        string   `json:"type"` // access or refresh
 	ExpiresAt   int64    `json:"exp"`
 	AppID       int32    `json:"app_id"`
-	Permissions []string `json:"permissions,omitempty"`
+	Permissions []string `json:"permissions,omitzero"` // nil without embed_permissions, an empty list is kept
 	Issuer      string   `json:"iss"`
 	IssuedAt    int64    `json:"iat"`
+	Actor       *Actor   `json:"act,omitempty"` // set when the token is used on behalf of the user
 	jwt.RegisteredClaims
+
+	// Extra static claims configured for the app, merged into the payload on signing
+	Extra map[string]any `json:"-"`
+}
+
+// Actor is the party acting on behalf of the token subject (RFC 8693 "act" claim)
+type Actor struct {
+	Subject string `json:"sub"`
+	UserID  int64  `json:"user_id"`
 }
 
-// NewToken creates new access JWT token for user and app
-func NewToken(user models.User, app models.App, permissions []models.Permission, duration time.Duration) (string, error) {
+// reservedClaims can't be overwritten by the app extra claims
+var reservedClaims = map[string]struct{}{
+	"user_id": {}, "email": {}, "name": {}, "type": {}, "app_id": {}, "permissions": {},
+	"iss": {}, "sub": {}, "aud": {}, "exp": {}, "nbf": {}, "iat": {}, "jti": {}, "act": {},
+}
+
+// MarshalJSON merges the extra claims into the token payload
+func (c TokenClaims) MarshalJSON() ([]byte, error) {
+	type plain TokenClaims
+
+	raw, err := json.Marshal(plain(c))
+	if err != nil || len(c.Extra) == 0 {
+		return raw, err
+	}
+
+	merged := make(map[string]any)
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&merged); err != nil {
+		return nil, err
+	}
+
+	for key, value := range c.Extra {
+		if _, reserved := reservedClaims[key]; reserved {
+			continue
+		}
+		merged[key] = value
+	}
+
+	return json.Marshal(merged)
+}
+
+// NewToken creates new access JWT token for user and app intended for the given audience
+// app settings decide whether permissions are embedded and the extra claims
+func NewToken(user models.User, app models.App, permissions []models.Permission, audience []string, duration time.Duration) (string, error) {
+	return signedString(accessClaims(user, app, permissions, audience, duration), app.Secret)
+}
+
+// NewImpersonationToken creates access token of the user carrying the actor in the "act" claim,
+// such tokens have no refresh token and expire with the duration
+func NewImpersonationToken(
+	user models.User,
+	actor models.User,
+	app models.App,
+	permissions []models.Permission,
+	audience []string,
+	duration time.Duration,
+) (string, error) {
+	claims := accessClaims(user, app, permissions, audience, duration)
+	claims.Actor = &Actor{Subject: fmt.Sprintf("user-%d", actor.ID), UserID: actor.ID}
+	claims.RegisteredClaims.ID = fmt.Sprintf("act-%d-%s", actor.ID, claims.RegisteredClaims.ID)
+
+	return signedString(claims, app.Secret)
+}
+
+func accessClaims(user models.User, app models.App, permissions []models.Permission, audience []string, duration time.Duration) TokenClaims {
 	now := time.Now()
 
-	permissionCodes := make([]string, len(permissions))
-	for i, permission := range permissions {
-		permissionCodes[i] = permission.Code
+	// the claim is left out only for the apps without embed_permissions, a user without
+	// permissions gets an empty list so the services don't ask sso for them
+	var permissionCodes []string
+	if app.EmbedPermissions {
+		permissionCodes = make([]string, len(permissions))
+		for i, permission := range permissions {
+			permissionCodes[i] = permission.Code
+		}
 	}
 
 	claims := TokenClaims{
@@ -56,13 +128,19 @@ func NewToken(user models.User, app models.App, permissions []models.Permission,
 			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    fmt.Sprintf("sso-app-%d", app.ID),
 			Subject:   fmt.Sprintf("user-%d", user.ID),
+			Audience:  audience,
 			ID:        fmt.Sprintf("%d-%d-%d", user.ID, app.ID, now.Unix()),
 		},
+		Extra: app.ExtraClaims,
 	}
 
+	return claims
+}
+
+func signedString(claims TokenClaims, secret string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	tokenString, err := token.SignedString([]byte(app.Secret))
+	tokenString, err := token.SignedString([]byte(secret))
 	if err != nil {
 		return "", err
 	}
@@ -70,7 +148,8 @@ func NewToken(user models.User, app models.App, permissions []models.Permission,
 	return tokenString, nil
 }
 
-func NewRefreshToken(user models.User, app models.App, duration time.Duration) (string, error) {
+// NewRefreshToken creates new refresh JWT token, audience is kept for the refreshed access tokens
+func NewRefreshToken(user models.User, app models.App, audience []string, duration time.Duration) (string, error) {
 	now := time.Now()
 
 	claims := TokenClaims{
@@ -88,6 +167,7 @@ func NewRefreshToken(user models.User, app models.App, duration time.Duration) (
 			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    fmt.Sprintf("sso-app-%d", app.ID),
 			Subject:   fmt.Sprintf("user-%d", user.ID),
+			Audience:  audience,
 			ID:        fmt.Sprintf("refresh-%d-%d-%d", user.ID, app.ID, now.Unix()),
 		},
 	}
@@ -180,6 +260,38 @@ func DecodeWithoutValidation(tokenString string) (*TokenClaims, error) {
 	return claims, nil
 }
 
+// ValidateTokenForAudience validates access token and checks that it was issued for the audience
+func ValidateTokenForAudience(tokenString string, secret string, audience string) (*TokenClaims, error) {
+	claims, err := ValidateToken(tokenString, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.HasAudience(audience) {
+		return nil, ErrInvalidAudience
+	}
+
+	return claims, nil
+}
+
+// HasAudience checks whether the token was issued for the audience
+func (c *TokenClaims) HasAudience(audience string) bool {
+	for _, aud := range c.Audience {
+		if aud == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// ActorID returns the user acting on behalf of the subject, 0 if the token is not impersonated
+func (c *TokenClaims) ActorID() int64 {
+	if c.Actor == nil {
+		return 0
+	}
+	return c.Actor.UserID
+}
+
 // HasPermission checks for permission in token
 func (c *TokenClaims) HasPermission(permissionCode string) bool {
 	for _, permission := range c.Permissions {
@@ -0,0 +1,61 @@
+package locale
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Locales of the emails, Default is used when the user's one is unknown
+const (
+	Default = "en"
+	Kazakh  = "kk"
+	Russian = "ru"
+)
+
+var supported = []string{Default, Kazakh, Russian}
+
+// Supported returns the locales the templates are written in
+func Supported() []string {
+	return slices.Clone(supported)
+}
+
+// Normalize returns the supported locale of the language tag, e.g. "ru-RU" is "ru", empty if not supported
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if slices.Contains(supported, tag) {
+		return tag
+	}
+	return ""
+}
+
+// Match picks the supported locale with the highest weight from an Accept-Language header,
+// empty if none of them is supported
+func Match(acceptLanguage string) string {
+	var (
+		best   string
+		weight = 0.0
+	)
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		if l := Normalize(tag); l != "" && q > weight {
+			best, weight = l, q
+		}
+	}
+
+	return best
+}
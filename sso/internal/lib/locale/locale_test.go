@@ -0,0 +1,50 @@
+package locale
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"en":    Default,
+		"ru-RU": Russian,
+		"kk_KZ": Kazakh,
+		" KK ":  Kazakh,
+		"RU":    Russian,
+		"de":    "",
+		"":      "",
+		"en-GB": Default,
+	}
+
+	for tag, want := range tests {
+		assert.Equal(t, want, Normalize(tag), "tag %q", tag)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		want           string
+	}{
+		{name: "single", acceptLanguage: "ru", want: Russian},
+		{name: "region", acceptLanguage: "kk-KZ", want: Kazakh},
+		{name: "first of equal weights", acceptLanguage: "ru-RU, kk", want: Russian},
+		{name: "highest weight", acceptLanguage: "en;q=0.5, kk;q=0.9, ru;q=0.7", want: Kazakh},
+		{name: "unsupported preferred", acceptLanguage: "de-DE, fr;q=0.9, ru;q=0.3", want: Russian},
+		{name: "spaces around weight", acceptLanguage: "en ; q=0.2, ru ; q=0.8", want: Russian},
+		{name: "invalid weight skipped", acceptLanguage: "ru;q=high, en;q=0.1", want: Default},
+		{name: "zero weight refused", acceptLanguage: "ru;q=0", want: ""},
+		{name: "wildcard", acceptLanguage: "*", want: ""},
+		{name: "nothing supported", acceptLanguage: "de, fr", want: ""},
+		{name: "empty", acceptLanguage: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Match(tt.acceptLanguage))
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+// DKIMConfig is the signing domain, e.g. "example.com", the selector of the public key published
+// at <selector>._domainkey.<domain> and the PEM file of the private key, RSA or Ed25519
+type DKIMConfig struct {
+	Domain   string
+	Selector string
+	KeyFile  string
+}
+
+// dkimHeaders are the signed header fields, every message has them
+var dkimHeaders = []string{
+	"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type",
+}
+
+// DKIM signs the messages (RFC 6376) with relaxed canonicalization
+type DKIM struct {
+	domain   string
+	selector string
+	key      crypto.Signer
+}
+
+func NewDKIM(cfg DKIMConfig) (*DKIM, error) {
+	const op = "mailer.NewDKIM"
+
+	if cfg.Selector == "" {
+		return nil, fmt.Errorf("%s: selector is required", op)
+	}
+
+	data, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &DKIM{domain: cfg.Domain, selector: cfg.Selector, key: key}, nil
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block in the key file")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// Sign returns the message with the DKIM-Signature header prepended, the message must use CRLF
+func (d *DKIM) Sign(raw []byte) ([]byte, error) {
+	const op = "mailer.DKIM.Sign"
+
+	var signed bytes.Buffer
+	err := dkim.Sign(&signed, bytes.NewReader(raw), &dkim.SignOptions{
+		Domain:                 d.domain,
+		Selector:               d.selector,
+		Signer:                 d.key,
+		Hash:                   crypto.SHA256,
+		HeaderCanonicalization: dkim.CanonicalizationRelaxed,
+		BodyCanonicalization:   dkim.CanonicalizationRelaxed,
+		HeaderKeys:             dkimHeaders,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return signed.Bytes(), nil
+}
+
+// encode returns the MIME message signed with dkim if it is not nil
+func encode(msg Message, dkim *DKIM) ([]byte, error) {
+	raw := msg.Bytes()
+	if dkim == nil {
+		return raw, nil
+	}
+	return dkim.Sign(raw)
+}
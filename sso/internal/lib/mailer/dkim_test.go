@@ -0,0 +1,112 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-msgauth/dkim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDKIM writes the key to a PEM file, loads it and returns the DNS record of its public key
+func newTestDKIM(t *testing.T, key crypto.Signer) (*DKIM, string) {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	keyFile := filepath.Join(t.TempDir(), "dkim.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	signer, err := NewDKIM(DKIMConfig{Domain: "example.com", Selector: "sso", KeyFile: keyFile})
+	require.NoError(t, err)
+
+	var record string
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		require.NoError(t, err)
+		record = "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der)
+	case ed25519.PublicKey:
+		record = "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(pub)
+	}
+
+	return signer, record
+}
+
+func testMessage() Message {
+	return Message{
+		ID:      "1@example.com",
+		Date:    time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC),
+		From:    Address{Email: "no-reply@example.com", Name: "Сервис"},
+		To:      Address{Email: "user@example.com", Name: "User"},
+		Subject: "Подтвердите email",
+		HTML:    "<p>Hello,   user </p>\n\n",
+		Text:    "Hello,\tuser \n",
+	}
+}
+
+// verify checks the signatures of the message with the public key record of sso._domainkey.example.com
+func verify(t *testing.T, raw []byte, record string) []*dkim.Verification {
+	t.Helper()
+
+	verifications, err := dkim.VerifyWithOptions(bytes.NewReader(raw), &dkim.VerifyOptions{
+		LookupTXT: func(domain string) ([]string, error) {
+			assert.Equal(t, "sso._domainkey.example.com", domain)
+			return []string{record}, nil
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, verifications, 1)
+
+	return verifications
+}
+
+func TestDKIM_SignVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	keys := map[string]crypto.Signer{
+		"rsa":     rsaKey,
+		"ed25519": edKey,
+	}
+
+	for name, key := range keys {
+		t.Run(name, func(t *testing.T) {
+			signer, record := newTestDKIM(t, key)
+
+			raw, err := encode(testMessage(), signer)
+			require.NoError(t, err)
+
+			verification := verify(t, raw, record)[0]
+			require.NoError(t, verification.Err)
+			assert.Equal(t, "example.com", verification.Domain)
+			assert.Subset(t, verification.HeaderKeys, []string{"From", "To", "Subject", "Date", "Message-ID"})
+
+			// a changed body breaks the signature
+			tampered := bytes.Replace(raw, []byte("Hello"), []byte("Hallo"), 1)
+			assert.Error(t, verify(t, tampered, record)[0].Err)
+		})
+	}
+}
+
+func TestEncode_WithoutDKIM(t *testing.T) {
+	raw, err := encode(testMessage(), nil)
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(raw), "DKIM-Signature")
+	assert.Equal(t, testMessage().Bytes()[:40], raw[:40])
+}
@@ -0,0 +1,66 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// File writes the messages to a maildir for local development,
+// mail clients open it directly and every message is also a valid .eml file
+type File struct {
+	dir      string
+	hostname string
+	dkim     *DKIM
+	counter  atomic.Int64
+}
+
+// NewFile creates the maildir, nil dkim writes the messages unsigned
+func NewFile(dir string, dkim *DKIM) (*File, error) {
+	const op = "mailer.NewFile"
+
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+
+	return &File{
+		dir:      dir,
+		hostname: strings.NewReplacer("/", "_", ":", "_").Replace(hostname),
+		dkim:     dkim,
+	}, nil
+}
+
+// Send writes the message to tmp and moves it to new, so readers never see a partial message
+func (f *File) Send(ctx context.Context, msg Message) error {
+	const op = "mailer.File.Send"
+
+	name := fmt.Sprintf("%d.M%dP%dQ%d.%s.eml",
+		time.Now().Unix(), time.Now().Nanosecond()/1000, os.Getpid(), f.counter.Add(1), f.hostname)
+
+	raw, err := encode(msg, f.dkim)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tmpPath := filepath.Join(f.dir, "tmp", name)
+	if err := os.WriteFile(tmpPath, raw, 0o644); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(f.dir, "new", name)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
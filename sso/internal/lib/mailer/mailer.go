@@ -0,0 +1,141 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sso/internal/domain/models"
+	"sso/pkg/tracing"
+	"strings"
+	"time"
+)
+
+// ErrUnalignedFrom is returned for a sender address outside the DKIM domain,
+// DMARC rejects the messages signed for another domain than the From one
+var ErrUnalignedFrom = errors.New("from address is outside the DKIM domain")
+
+// Mailer builds the emails of sso from the templates and hands them to the sender
+type Mailer struct {
+	sender     EmailSender
+	templates  *Templates
+	from       Address
+	dkimDomain string
+}
+
+// New creates a Mailer sending from the address, e.g. "no-reply@yourdomain.com" and "SSO Service",
+// with a non-empty dkimDomain the emails are only sent from that domain and its subdomains
+func New(sender EmailSender, templates *Templates, from, fromName, dkimDomain string) *Mailer {
+	return &Mailer{
+		sender:     sender,
+		templates:  templates,
+		from:       Address{Email: from, Name: fromName},
+		dkimDomain: strings.ToLower(dkimDomain),
+	}
+}
+
+// sendEmail renders the template in the locale of the recipient with the branding of the app and sends it,
+// zero app is the sso itself
+func (m *Mailer) sendEmail(ctx context.Context, toEmail, toName, locale string, app models.App, template string, data TemplateData) error {
+	data.AppName = app.Name
+	data.LogoURL = app.Email.LogoURL
+	data.Color = app.Email.Color
+	if data.Color == "" {
+		data.Color = DefaultColor
+	}
+
+	subject, html, text, err := m.templates.Render(locale, template, data)
+	if err != nil {
+		return fmt.Errorf("failed to render template: %w", err)
+	}
+
+	from := m.from
+	if app.Email.From != "" {
+		from = Address{Email: app.Email.From}
+	}
+	if app.Email.FromName != "" {
+		from.Name = app.Email.FromName
+	}
+	if !m.aligned(from.Email) {
+		return fmt.Errorf("%w: %s is not in %s", ErrUnalignedFrom, from.Email, m.dkimDomain)
+	}
+
+	// the ID and date are kept while the email waits in the queue
+	return m.sender.Send(ctx, Message{
+		ID:      NewMessageID(from.Email),
+		Date:    time.Now(),
+		From:    from,
+		To:      Address{Email: toEmail, Name: toName},
+		Subject: subject,
+		HTML:    html,
+		Text:    text,
+		Trace:   tracing.Inject(ctx),
+	})
+}
+
+// SendVerificationEmail builds and sends a verification email, the link leads to the app's page if it has one.
+func (m *Mailer) SendVerificationEmail(ctx context.Context, toEmail, toName, locale string, app models.App, verificationToken, baseURL string) error {
+	verificationURL := fmt.Sprintf("%s/v1/auth/verification/confirm/%s", baseURL, verificationToken)
+	if app.Email.VerificationURL != "" {
+		verificationURL = tokenURL(app.Email.VerificationURL, verificationToken)
+	}
+
+	return m.sendEmail(ctx, toEmail, toName, locale, app, TemplateVerification, TemplateData{
+		Name: toName,
+		URL:  verificationURL,
+	})
+}
+
+// SendResetPasswordEmail builds and sends a password-reset email, the link leads to the app's page if it has one.
+func (m *Mailer) SendResetPasswordEmail(ctx context.Context, toEmail, toName, locale string, app models.App, resetToken, baseURL string) error {
+	resetURL := fmt.Sprintf("%s/v1/auth/reset-password?token=%s", baseURL, resetToken)
+	if app.Email.ResetPasswordURL != "" {
+		resetURL = tokenURL(app.Email.ResetPasswordURL, resetToken)
+	}
+
+	return m.sendEmail(ctx, toEmail, toName, locale, app, TemplateResetPassword, TemplateData{
+		Name: toName,
+		URL:  resetURL,
+	})
+}
+
+// SendAccountDeletionScheduledEmail tells the user when the account will be deleted and how to cancel it.
+func (m *Mailer) SendAccountDeletionScheduledEmail(ctx context.Context, toEmail, toName, locale string, deleteAfter time.Time) error {
+	return m.sendEmail(ctx, toEmail, toName, locale, models.App{}, TemplateAccountDeletionScheduled, TemplateData{
+		Name: toName,
+		Date: deleteAfter.UTC().Format(time.RFC1123),
+	})
+}
+
+// SendAccountDeletedEmail builds and sends the receipt of the account deletion.
+func (m *Mailer) SendAccountDeletedEmail(ctx context.Context, toEmail, toName, locale string, deletedAt time.Time) error {
+	return m.sendEmail(ctx, toEmail, toName, locale, models.App{}, TemplateAccountDeleted, TemplateData{
+		Name: toName,
+		Date: deletedAt.UTC().Format(time.RFC1123),
+	})
+}
+
+// SendDataExportReadyEmail sends the link to download the archive of the personal data.
+func (m *Mailer) SendDataExportReadyEmail(ctx context.Context, toEmail, toName, locale, downloadURL string, expiresAt time.Time) error {
+	return m.sendEmail(ctx, toEmail, toName, locale, models.App{}, TemplateDataExportReady, TemplateData{
+		Name: toName,
+		URL:  downloadURL,
+		Date: expiresAt.UTC().Format(time.RFC1123),
+	})
+}
+
+// aligned reports whether the DKIM signature is aligned with the address for DMARC,
+// the relaxed alignment accepts the subdomains of the signing domain
+func (m *Mailer) aligned(address string) bool {
+	if m.dkimDomain == "" {
+		return true
+	}
+
+	_, domain, ok := strings.Cut(strings.ToLower(address), "@")
+	return ok && (domain == m.dkimDomain || strings.HasSuffix(domain, "."+m.dkimDomain))
+}
+
+// tokenURL puts the token into the URL template of the app
+func tokenURL(template, token string) string {
+	return strings.ReplaceAll(template, "{token}", url.QueryEscape(token))
+}
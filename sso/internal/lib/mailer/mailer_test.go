@@ -0,0 +1,119 @@
+package mailer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sso/internal/domain/models"
+	"sso/internal/lib/locale"
+)
+
+const baseURL = "https://sso.example.com"
+
+func newMailer(t *testing.T, dkimDomain string) (*Mailer, *Memory) {
+	t.Helper()
+
+	templates, err := LoadTemplates("")
+	require.NoError(t, err)
+
+	sender := NewMemory()
+	return New(sender, templates, "no-reply@example.com", "SSO Service", dkimDomain), sender
+}
+
+func TestSendVerificationEmail_Default(t *testing.T) {
+	m, sender := newMailer(t, "")
+
+	err := m.SendVerificationEmail(context.Background(), "user@example.com", "User", locale.Default, models.App{}, "token-123", baseURL)
+	require.NoError(t, err)
+
+	msg, ok := sender.Last("user@example.com")
+	require.True(t, ok)
+	assert.Equal(t, Address{Email: "no-reply@example.com", Name: "SSO Service"}, msg.From)
+	assert.Equal(t, Address{Email: "user@example.com", Name: "User"}, msg.To)
+	assert.Contains(t, msg.Text, baseURL+"/v1/auth/verification/confirm/token-123")
+	assert.Contains(t, msg.HTML, DefaultColor)
+	assert.NotEmpty(t, msg.ID)
+}
+
+func TestSendVerificationEmail_AppBranding(t *testing.T) {
+	m, sender := newMailer(t, "")
+
+	app := models.App{
+		ID:   3,
+		Name: "Toys",
+		Email: models.AppEmail{
+			From:            "hello@toys.example.com",
+			FromName:        "Toys",
+			LogoURL:         "https://toys.example.com/logo.png",
+			Color:           "#ff6600",
+			VerificationURL: "https://toys.example.com/verify?token={token}",
+		},
+	}
+
+	err := m.SendVerificationEmail(context.Background(), "user@example.com", "User", locale.Default, app, "a+b/c", baseURL)
+	require.NoError(t, err)
+
+	msg, ok := sender.Last("user@example.com")
+	require.True(t, ok)
+	assert.Equal(t, Address{Email: "hello@toys.example.com", Name: "Toys"}, msg.From)
+	assert.Contains(t, msg.HTML, "https://toys.example.com/logo.png")
+	assert.Contains(t, msg.HTML, "#ff6600")
+	assert.NotContains(t, msg.HTML, DefaultColor)
+	assert.Contains(t, msg.Text, "https://toys.example.com/verify?token=a%2Bb%2Fc", "the token is escaped in the link of the app")
+}
+
+func TestSendResetPasswordEmail_AppFromWithoutName(t *testing.T) {
+	m, sender := newMailer(t, "")
+
+	app := models.App{
+		Name: "Toys",
+		Email: models.AppEmail{
+			From:             "hello@toys.example.com",
+			ResetPasswordURL: "https://toys.example.com/reset/{token}",
+		},
+	}
+
+	err := m.SendResetPasswordEmail(context.Background(), "user@example.com", "User", locale.Default, app, "token-123", baseURL)
+	require.NoError(t, err)
+
+	msg, ok := sender.Last("user@example.com")
+	require.True(t, ok)
+	assert.Equal(t, Address{Email: "hello@toys.example.com"}, msg.From, "the name of sso isn't put on the address of the app")
+	assert.Contains(t, msg.Text, "https://toys.example.com/reset/token-123")
+}
+
+func TestSendEmail_DKIMAlignment(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		wantErr bool
+	}{
+		{name: "default from", from: ""},
+		{name: "same domain", from: "hello@example.com"},
+		{name: "subdomain", from: "hello@toys.example.com"},
+		{name: "case insensitive", from: "Hello@Toys.Example.COM"},
+		{name: "other domain", from: "hello@toys.com", wantErr: true},
+		{name: "suffix of another domain", from: "hello@notexample.com", wantErr: true},
+		{name: "no domain", from: "hello", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, sender := newMailer(t, "Example.com")
+
+			app := models.App{Name: "Toys", Email: models.AppEmail{From: tt.from}}
+			err := m.SendVerificationEmail(context.Background(), "user@example.com", "User", locale.Default, app, "token-123", baseURL)
+
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrUnalignedFrom)
+				assert.Empty(t, sender.Messages())
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, sender.Messages(), 1)
+		})
+	}
+}
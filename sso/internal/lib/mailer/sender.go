@@ -0,0 +1,196 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Kinds of the sender in config
+const (
+	KindSMTP     = "smtp"
+	KindMailtrap = "mailtrap"
+	KindFile     = "file"
+	KindMemory   = "memory"
+)
+
+// EmailSender delivers a built message, an error means the message was not accepted
+type EmailSender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Address is a mailbox with an optional display name
+type Address struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+func (a Address) String() string {
+	if a.Name == "" {
+		return a.Email
+	}
+	return fmt.Sprintf("%s <%s>", mime.QEncoding.Encode("UTF-8", a.Name), a.Email)
+}
+
+// Message is a single email, it is stored as JSON while it waits in the queue
+type Message struct {
+	ID      string    `json:"id,omitempty"` // Message-ID without the angle brackets, kept on retries
+	Date    time.Time `json:"date"`
+	From    Address   `json:"from"`
+	To      Address   `json:"to"`
+	Subject string    `json:"subject"`
+	HTML    string    `json:"html"`
+	Text    string    `json:"text,omitempty"` // plain-text alternative of the HTML
+
+	// Trace is the trace context of the request that sent the email, the queue continues it
+	Trace map[string]string `json:"trace,omitempty"`
+}
+
+// PermanentError is a rejection of the message that retrying won't fix, e.g. an unknown mailbox
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent reports whether the message must not be sent again
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// Bytes returns the message in the MIME format, multipart/alternative if it has a text part,
+// the lines end with CRLF so the message can be signed with DKIM as is
+func (m Message) Bytes() []byte {
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	id := m.ID
+	if id == "" {
+		id = NewMessageID(m.From.Email)
+	}
+
+	var buf bytes.Buffer
+	writeHeader(&buf, "Date", date.Format(time.RFC1123Z))
+	writeHeader(&buf, "Message-ID", "<"+id+">")
+	writeHeader(&buf, "From", m.From.String())
+	writeHeader(&buf, "To", m.To.String())
+	writeHeader(&buf, "Subject", encodeHeader(m.Subject))
+	writeHeader(&buf, "MIME-Version", "1.0")
+
+	if m.Text == "" {
+		writeHeader(&buf, "Content-Type", `text/html; charset="UTF-8"`)
+		writeHeader(&buf, "Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		writeQuotedPrintable(&buf, m.HTML)
+		return buf.Bytes()
+	}
+
+	// the last part is the preferred one
+	parts := multipart.NewWriter(&buf)
+	writeHeader(&buf, "Content-Type", `multipart/alternative; boundary="`+parts.Boundary()+`"`)
+	buf.WriteString("\r\n")
+	writePart(parts, "text/plain", m.Text)
+	writePart(parts, "text/html", m.HTML)
+	_ = parts.Close()
+
+	return buf.Bytes()
+}
+
+// NewMessageID returns a unique Message-ID in the domain of the sender address
+func NewMessageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 && i < len(from)-1 {
+		domain = from[i+1:]
+	}
+
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return fmt.Sprintf("%d.%s@%s", time.Now().UnixNano(), hex.EncodeToString(b), domain)
+}
+
+func writeHeader(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name + ": " + value + "\r\n")
+}
+
+// encodeHeader encodes non-ASCII text as RFC 2047 encoded-words, folding between the words
+func encodeHeader(s string) string {
+	return strings.ReplaceAll(mime.QEncoding.Encode("UTF-8", s), "?= =?", "?=\r\n =?")
+}
+
+// writePart writes a quoted-printable part, writes to a bytes.Buffer don't fail
+func writePart(parts *multipart.Writer, contentType, body string) {
+	part, _ := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType + `; charset="UTF-8"`},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	writeQuotedPrintable(part, body)
+}
+
+// writeQuotedPrintable encodes the body, line breaks become CRLF
+func writeQuotedPrintable(w io.Writer, body string) {
+	qp := quotedprintable.NewWriter(w)
+	_, _ = qp.Write([]byte(body))
+	_ = qp.Close()
+}
+
+// Config selects and configures the sender
+type Config struct {
+	Kind          string
+	SMTP          SMTPConfig
+	MailtrapURL   string // Mailtrap sending API endpoint
+	MailtrapToken string
+	Dir           string // maildir the file sender writes to
+	Timeout       time.Duration
+	DKIM          DKIMConfig // the messages are not signed if the domain is empty
+}
+
+// NewSender creates the sender of the kind
+func NewSender(cfg Config) (EmailSender, error) {
+	const op = "mailer.NewSender"
+
+	var dkim *DKIM
+	if cfg.DKIM.Domain != "" {
+		var err error
+		if dkim, err = NewDKIM(cfg.DKIM); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	switch cfg.Kind {
+	case KindSMTP, "":
+		cfg.SMTP.Timeout = cfg.Timeout
+		return NewSMTP(cfg.SMTP, dkim), nil
+	case KindMailtrap:
+		// Mailtrap signs the messages with the keys of the domain verified there
+		return NewMailtrap(cfg.MailtrapURL, cfg.MailtrapToken, cfg.Timeout), nil
+	case KindFile:
+		sender, err := NewFile(cfg.Dir, dkim)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return sender, nil
+	case KindMemory:
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("%s: unknown email sender %q", op, cfg.Kind)
+	}
+}
@@ -0,0 +1,153 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sso/pkg/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TLS modes of the SMTP sender
+const (
+	TLSAuto     = "auto"     // STARTTLS when the server offers it
+	TLSStartTLS = "starttls" // STARTTLS is required
+	TLSImplicit = "tls"      // TLS from the first byte, usually port 465
+	TLSNone     = "none"
+)
+
+// SMTPConfig is the SMTP server, e.g. "smtp.mailtrap.io" and "587"
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	TLS      string
+	Timeout  time.Duration
+}
+
+// SMTP sends the messages through an SMTP server
+type SMTP struct {
+	cfg  SMTPConfig
+	dkim *DKIM
+}
+
+// NewSMTP creates the sender, nil dkim sends the messages unsigned
+func NewSMTP(cfg SMTPConfig, dkim *DKIM) *SMTP {
+	if cfg.TLS == "" {
+		cfg.TLS = TLSAuto
+	}
+	return &SMTP{cfg: cfg, dkim: dkim}
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	const op = "mailer.SMTP.Send"
+
+	ctx, span := tracing.Tracer().Start(ctx, "smtp send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.ServerAddress(s.cfg.Host)),
+	)
+	defer span.End()
+
+	if err := s.send(ctx, msg); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		// 5xx replies are permanent, 4xx ones are temporary
+		var reply *textproto.Error
+		if errors.As(err, &reply) && reply.Code >= 500 {
+			err = &PermanentError{Err: err}
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *SMTP) send(ctx context.Context, msg Message) error {
+	raw, err := encode(msg, s.dkim)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.cfg.Host, s.cfg.Port)
+	tlsConfig := &tls.Config{ServerName: s.cfg.Host}
+
+	if s.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+
+	var conn net.Conn
+	if s.cfg.TLS == TLSImplicit {
+		dialer := &tls.Dialer{Config: tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	// the whole conversation is bounded by the context
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.cfg.TLS == TLSAuto || s.cfg.TLS == TLSStartTLS {
+		ok, _ := c.Extension("STARTTLS")
+		if ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		} else if s.cfg.TLS == TLSStartTLS {
+			return errors.New("server does not support STARTTLS")
+		}
+	}
+
+	// the configured credentials are never skipped, sending unauthenticated would hide a downgraded connection
+	if s.cfg.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("server does not offer AUTH, the credentials can't be used")
+		}
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(msg.From.Email); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.To.Email); err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
@@ -0,0 +1,221 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"sso/internal/lib/locale"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Names of the templates
+const (
+	TemplateVerification             = "verification"
+	TemplateResetPassword            = "reset_password"
+	TemplateAccountDeletionScheduled = "account_deletion_scheduled"
+	TemplateAccountDeleted           = "account_deleted"
+	TemplateDataExportReady          = "data_export_ready"
+)
+
+// layoutFile wraps the "content" of every HTML template
+const layoutFile = "layout.html"
+
+// embedded are the default templates: templates/<locale>/<name>.html and <name>.txt,
+// the text one also defines the "subject"
+//
+//go:embed templates
+var embedded embed.FS
+
+// DefaultColor is the color of the buttons when the app has none
+const DefaultColor = "#007bff"
+
+// TemplateData is passed to every template, the fields a template doesn't need are empty
+type TemplateData struct {
+	Name string // name of the recipient
+	URL  string // link to follow
+	Date string
+
+	// branding of the app the email is sent for
+	AppName string
+	LogoURL string // no logo if empty
+	Color   string
+}
+
+// Templates are the parsed templates of every locale
+type Templates struct {
+	html map[string]*htmltemplate.Template // by "<locale>/<name>"
+	text map[string]*texttemplate.Template
+}
+
+// LoadTemplates parses the embedded templates, a file with the same path in dir replaces the embedded one,
+// so the designers may change the emails without rebuilding
+func LoadTemplates(dir string) (*Templates, error) {
+	const op = "mailer.LoadTemplates"
+
+	defaults, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	fsys := defaults
+	if dir != "" {
+		fsys = overlayFS{top: os.DirFS(dir), bottom: defaults}
+	}
+
+	layout, err := fs.ReadFile(fsys, layoutFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	t := &Templates{
+		html: make(map[string]*htmltemplate.Template),
+		text: make(map[string]*texttemplate.Template),
+	}
+
+	files, err := fs.Glob(fsys, "*/*.txt")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, file := range files {
+		key := strings.TrimSuffix(file, ".txt")
+
+		text, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		textTmpl, err := texttemplate.New(key).Parse(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if textTmpl.Lookup("subject") == nil {
+			return nil, fmt.Errorf("%s: %s does not define the subject", op, file)
+		}
+
+		content, err := fs.ReadFile(fsys, key+".html")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		htmlTmpl, err := htmltemplate.New(key).Parse(string(layout))
+		if err == nil {
+			_, err = htmlTmpl.Parse(string(content))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, key, err)
+		}
+
+		t.text[key] = textTmpl
+		t.html[key] = htmlTmpl
+	}
+
+	for _, name := range []string{
+		TemplateVerification,
+		TemplateResetPassword,
+		TemplateAccountDeletionScheduled,
+		TemplateAccountDeleted,
+		TemplateDataExportReady,
+	} {
+		if _, ok := t.text[path.Join(locale.Default, name)]; !ok {
+			return nil, fmt.Errorf("%s: template %q has no %q version", op, name, locale.Default)
+		}
+	}
+
+	return t, nil
+}
+
+// Names returns the names of the templates
+func (t *Templates) Names() []string {
+	var names []string
+	for key := range t.text {
+		if name := path.Base(key); !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Render renders the subject, HTML and text of the template in the locale, the default locale is used
+// if the template is not translated to it
+func (t *Templates) Render(loc, name string, data TemplateData) (subject, html, text string, err error) {
+	const op = "mailer.Templates.Render"
+
+	key := path.Join(loc, name)
+	if _, ok := t.text[key]; !ok {
+		key = path.Join(locale.Default, name)
+	}
+
+	textTmpl, ok := t.text[key]
+	if !ok {
+		return "", "", "", fmt.Errorf("%s: unknown template %q", op, name)
+	}
+
+	var buf bytes.Buffer
+	if err := textTmpl.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	subject = strings.Join(strings.Fields(buf.String()), " ")
+
+	buf.Reset()
+	if err := textTmpl.Execute(&buf, data); err != nil {
+		return "", "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	text = strings.TrimSpace(buf.String()) + "\n"
+
+	buf.Reset()
+	if err := t.html[key].ExecuteTemplate(&buf, "layout", data); err != nil {
+		return "", "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	html = buf.String()
+
+	return subject, html, text, nil
+}
+
+// overlayFS reads the files of top, falling back to bottom
+type overlayFS struct {
+	top    fs.FS
+	bottom fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.top.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.bottom.Open(name)
+	}
+	return f, err
+}
+
+// ReadDir merges the entries of both, so Glob finds the templates added in top
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(o.bottom, name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	top, topErr := fs.ReadDir(o.top, name)
+	if topErr != nil && !errors.Is(topErr, fs.ErrNotExist) {
+		return nil, topErr
+	}
+	if err != nil && topErr != nil {
+		return nil, err
+	}
+
+	for _, entry := range top {
+		i := slices.IndexFunc(entries, func(e fs.DirEntry) bool { return e.Name() == entry.Name() })
+		if i >= 0 {
+			entries[i] = entry
+		} else {
+			entries = append(entries, entry)
+		}
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+
+	return entries, nil
+}
@@ -0,0 +1,205 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sso/internal/lib/locale"
+)
+
+func testData() TemplateData {
+	return TemplateData{
+		Name:    "Aigerim",
+		URL:     "https://sso.example.com/v1/auth/verification/confirm/token-123",
+		Date:    "Mon, 02 Jan 2026 15:04:05 UTC",
+		AppName: "Shop",
+		Color:   DefaultColor,
+	}
+}
+
+func TestLoadTemplates_Embedded(t *testing.T) {
+	templates, err := LoadTemplates("")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		TemplateAccountDeleted,
+		TemplateAccountDeletionScheduled,
+		TemplateDataExportReady,
+		TemplateResetPassword,
+		TemplateVerification,
+	}, templates.Names())
+}
+
+func TestRender_EveryLocale(t *testing.T) {
+	templates, err := LoadTemplates("")
+	require.NoError(t, err)
+
+	data := testData()
+
+	for _, name := range templates.Names() {
+		subjects := make(map[string]bool)
+
+		for _, loc := range locale.Supported() {
+			t.Run(loc+"/"+name, func(t *testing.T) {
+				subject, html, text, err := templates.Render(loc, name, data)
+				require.NoError(t, err)
+
+				assert.NotEmpty(t, subject)
+				assert.NotContains(t, subject, "\n")
+				assert.Contains(t, text, data.Name)
+				assert.Contains(t, html, data.Name)
+				assert.True(t, strings.HasPrefix(html, "<!DOCTYPE html>"), "the layout wraps the content")
+				assert.True(t, strings.HasSuffix(text, "\n"))
+
+				subjects[subject] = true
+			})
+		}
+
+		assert.Len(t, subjects, len(locale.Supported()), "%s is translated to every locale", name)
+	}
+}
+
+func TestRender_LinkTemplates(t *testing.T) {
+	templates, err := LoadTemplates("")
+	require.NoError(t, err)
+
+	data := testData()
+
+	for _, name := range []string{TemplateVerification, TemplateResetPassword, TemplateDataExportReady} {
+		_, html, text, err := templates.Render(locale.Default, name, data)
+		require.NoError(t, err)
+
+		assert.Contains(t, text, data.URL, name)
+		assert.Contains(t, html, data.URL, name)
+	}
+}
+
+func TestRender_FallsBackToDefaultLocale(t *testing.T) {
+	templates, err := LoadTemplates("")
+	require.NoError(t, err)
+
+	want, _, _, err := templates.Render(locale.Default, TemplateVerification, testData())
+	require.NoError(t, err)
+
+	for _, loc := range []string{"de", ""} {
+		subject, _, _, err := templates.Render(loc, TemplateVerification, testData())
+		require.NoError(t, err)
+		assert.Equal(t, want, subject)
+	}
+}
+
+func TestRender_UnknownTemplate(t *testing.T) {
+	templates, err := LoadTemplates("")
+	require.NoError(t, err)
+
+	_, _, _, err = templates.Render(locale.Default, "welcome", testData())
+	require.Error(t, err)
+}
+
+func TestRender_EscapesHTML(t *testing.T) {
+	templates, err := LoadTemplates("")
+	require.NoError(t, err)
+
+	data := testData()
+	data.Name = `<script>alert(1)</script>`
+	data.URL = `javascript:alert(1)`
+
+	_, html, _, err := templates.Render(locale.Default, TemplateVerification, data)
+	require.NoError(t, err)
+
+	assert.NotContains(t, html, "<script>")
+	assert.NotContains(t, html, `href="javascript:`)
+}
+
+func TestRender_Branding(t *testing.T) {
+	templates, err := LoadTemplates("")
+	require.NoError(t, err)
+
+	data := testData()
+	data.LogoURL = "https://cdn.example.com/shop.png"
+	data.Color = "#ff6600"
+
+	_, html, _, err := templates.Render(locale.Default, TemplateVerification, data)
+	require.NoError(t, err)
+
+	assert.Contains(t, html, `src="https://cdn.example.com/shop.png"`)
+	assert.Contains(t, html, `alt="Shop"`)
+	assert.Contains(t, html, "#ff6600")
+
+	data.LogoURL = ""
+	_, html, _, err = templates.Render(locale.Default, TemplateVerification, data)
+	require.NoError(t, err)
+	assert.NotContains(t, html, "<img")
+}
+
+func writeTemplate(t *testing.T, dir, file, content string) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte(content), 0o644))
+}
+
+func TestLoadTemplates_Override(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "en/verification.txt", `{{define "subject"}}Confirm your Shop account{{end}}Hi {{.Name}}, {{.URL}}`)
+	writeTemplate(t, dir, "en/welcome.txt", `{{define "subject"}}Welcome{{end}}Welcome, {{.Name}}`)
+	writeTemplate(t, dir, "en/welcome.html", `{{define "content"}}<p>Welcome, {{.Name}}</p>{{end}}`)
+
+	templates, err := LoadTemplates(dir)
+	require.NoError(t, err)
+
+	assert.Contains(t, templates.Names(), "welcome")
+
+	// the text file is replaced, the HTML one is still the embedded one
+	subject, html, text, err := templates.Render(locale.Default, TemplateVerification, testData())
+	require.NoError(t, err)
+	assert.Equal(t, "Confirm your Shop account", subject)
+	assert.Equal(t, "Hi Aigerim, "+testData().URL+"\n", text)
+	assert.Contains(t, html, "Verify Email")
+
+	// the other locales keep the embedded templates
+	subject, _, _, err = templates.Render(locale.Russian, TemplateVerification, testData())
+	require.NoError(t, err)
+	assert.NotEqual(t, "Confirm your Shop account", subject)
+
+	subject, _, _, err = templates.Render(locale.Default, "welcome", testData())
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome", subject)
+}
+
+func TestLoadTemplates_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{
+			name:  "no subject",
+			files: map[string]string{"en/verification.txt": "Hello {{.Name}}"},
+		},
+		{
+			name:  "syntax error",
+			files: map[string]string{"en/verification.html": `{{define "content"}}{{.Name}{{end}}`},
+		},
+		{
+			name:  "no html",
+			files: map[string]string{"en/welcome.txt": `{{define "subject"}}Welcome{{end}}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for file, content := range tt.files {
+				writeTemplate(t, dir, file, content)
+			}
+
+			_, err := LoadTemplates(dir)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package privilege
+
+import "sso/internal/domain/models"
+
+// roleMarkers are the role codes that are also granted as permissions,
+// they only name the role, the permissions bundled with the role are compared instead
+var roleMarkers = map[string]bool{"user": true, "staff": true, "admin": true}
+
+// Exceeding returns the permissions of the target the actor doesn't have,
+// staff acting on an account must not gain access through it, so it must be empty
+func Exceeding(actor []models.Permission, target []models.Permission) []string {
+	held := make(map[string]bool, len(actor))
+	for _, permission := range actor {
+		held[permission.Code] = true
+	}
+
+	var exceeding []string
+	for _, permission := range target {
+		if !roleMarkers[permission.Code] && !held[permission.Code] {
+			exceeding = append(exceeding, permission.Code)
+		}
+	}
+	return exceeding
+}
+
+// Lacking returns the codes the actor doesn't hold, the role markers included,
+// granting a permission or a role must not give more than the actor has, admin holds every code
+func Lacking(actor []models.Permission, codes []string) []string {
+	held := make(map[string]bool, len(actor))
+	for _, permission := range actor {
+		held[permission.Code] = true
+	}
+	if held["admin"] {
+		return nil
+	}
+
+	var lacking []string
+	for _, code := range codes {
+		if !held[code] {
+			lacking = append(lacking, code)
+		}
+	}
+	return lacking
+}
@@ -0,0 +1,62 @@
+package privilege
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"sso/internal/domain/models"
+)
+
+func permissions(codes ...string) []models.Permission {
+	result := make([]models.Permission, len(codes))
+	for i, code := range codes {
+		result[i] = models.Permission{Code: code}
+	}
+	return result
+}
+
+func TestExceeding(t *testing.T) {
+	staff := permissions("staff", "profile:read:own", "profile:write:own", "profile:read:any", "users:read", "permissions:read")
+
+	tests := []struct {
+		name   string
+		target []models.Permission
+		want   []string
+	}{
+		{"regular user", permissions("user", "profile:read:own", "profile:write:own"), nil},
+		{"manager", permissions("user", "webhooks:manage"), []string{"webhooks:manage"}},
+		{"admin", permissions("admin", "users:manage", "users:read"), []string{"users:manage"}},
+		{"no permissions", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Exceeding(staff, tt.target))
+		})
+	}
+}
+
+func TestLacking(t *testing.T) {
+	manager := permissions("user", "permissions:manage", "profile:read:any", "users:read")
+
+	tests := []struct {
+		name  string
+		actor []models.Permission
+		codes []string
+		want  []string
+	}{
+		{"held", manager, []string{"profile:read:any", "users:read"}, nil},
+		{"not held", manager, []string{"users:manage"}, []string{"users:manage"}},
+		{"staff role", manager, []string{"staff", "profile:read:any", "users:read"}, []string{"staff"}},
+		{"admin role", manager, []string{"admin", "users:manage"}, []string{"admin", "users:manage"}},
+		{"admin", permissions("admin"), []string{"staff", "api_keys:validate"}, nil},
+		{"nothing", manager, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Lacking(tt.actor, tt.codes))
+		})
+	}
+}
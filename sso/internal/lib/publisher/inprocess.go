@@ -0,0 +1,63 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/api/events"
+	"sync"
+)
+
+// Handler handles the event, an error makes the relay retry the event
+type Handler func(ctx context.Context, event events.Event) error
+
+// ErrNoSubscribers is returned for an event nobody subscribed to, the relay keeps it for the retry
+var ErrNoSubscribers = errors.New("no subscribers for event")
+
+// InProcess delivers the events to the handlers subscribed in the same process,
+// events without subscribers fail so they aren't marked published
+type InProcess struct {
+	log      *slog.Logger
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewInProcess(log *slog.Logger) *InProcess {
+	return &InProcess{
+		log:      log,
+		handlers: make(map[string][]Handler),
+	}
+}
+
+// Subscribe registers the handler for the event type
+func (p *InProcess) Subscribe(eventType string, handler Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.handlers[eventType] = append(p.handlers[eventType], handler)
+}
+
+func (p *InProcess) Publish(ctx context.Context, event events.Event) error {
+	const op = "publisher.InProcess.Publish"
+
+	p.mu.RLock()
+	handlers := p.handlers[event.Type]
+	p.mu.RUnlock()
+
+	if len(handlers) == 0 {
+		return fmt.Errorf("%s: %s: %w", op, event.Type, ErrNoSubscribers)
+	}
+
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *InProcess) Close() error {
+	return nil
+}
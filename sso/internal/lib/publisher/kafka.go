@@ -0,0 +1,95 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sso/api/events"
+	"time"
+)
+
+// kafkaContentType is the embedded JSON format of the Kafka REST Proxy v2 API
+const kafkaContentType = "application/vnd.kafka.json.v2+json"
+
+// Kafka publishes the events to the topic through the Kafka REST Proxy,
+// the user ID is the record key, so events of a user stay in one partition and keep their order
+type Kafka struct {
+	client *http.Client
+	url    string
+	topic  string
+}
+
+func NewKafka(restURL string, topic string, timeout time.Duration) *Kafka {
+	return &Kafka{
+		client: &http.Client{Timeout: timeout},
+		url:    restURL,
+		topic:  topic,
+	}
+}
+
+type kafkaRecord struct {
+	Key   string       `json:"key"`
+	Value events.Event `json:"value"`
+}
+
+type kafkaRequest struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+// kafkaResponse reports the result of every record, the status is 200 even if a record failed
+type kafkaResponse struct {
+	Offsets []struct {
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+func (p *Kafka) Publish(ctx context.Context, event events.Event) error {
+	const op = "publisher.Kafka.Publish"
+
+	body, err := json.Marshal(kafkaRequest{Records: []kafkaRecord{{Key: event.Key(), Value: event}}})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	endpoint := fmt.Sprintf("%s/topics/%s", p.url, url.PathEscape(p.topic))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: unexpected status %d: %s", op, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	var result kafkaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, offset := range result.Offsets {
+		if offset.ErrorCode != nil {
+			return fmt.Errorf("%s: record rejected with code %d: %s", op, *offset.ErrorCode, offset.Error)
+		}
+	}
+
+	return nil
+}
+
+func (p *Kafka) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,60 @@
+package publisher
+
+import (
+	"context"
+	"sso/api/events"
+	"sync"
+)
+
+// Memory keeps the published events, it stands in for a broker in tests
+type Memory struct {
+	mu     sync.Mutex
+	events []events.Event
+	err    error
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Publish(ctx context.Context, event events.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return m.err
+	}
+
+	m.events = append(m.events, event)
+	return nil
+}
+
+// Events returns the published events in order
+func (m *Memory) Events() []events.Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	published := make([]events.Event, len(m.events))
+	copy(published, m.events)
+	return published
+}
+
+// FailWith makes the next publishes fail with the error, nil restores delivery
+func (m *Memory) FailWith(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.err = err
+}
+
+// Reset drops the published events
+func (m *Memory) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.events = nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,37 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"sso/api/events"
+)
+
+// Multi publishes every event to all the publishers, the event is retried if any of them fails,
+// so the others may get it more than once
+type Multi struct {
+	publishers []Publisher
+}
+
+func NewMulti(publishers ...Publisher) *Multi {
+	return &Multi{publishers: publishers}
+}
+
+func (p *Multi) Publish(ctx context.Context, event events.Event) error {
+	var errs []error
+	for _, publisher := range p.publishers {
+		if err := publisher.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Multi) Close() error {
+	var errs []error
+	for _, publisher := range p.publishers {
+		if err := publisher.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,71 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sso/api/events"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATS publishes the events to "<prefix>.<event type>" subjects,
+// the event ID is sent as Nats-Msg-Id so JetStream streams drop the redelivered events
+type NATS struct {
+	conn    *nats.Conn
+	prefix  string
+	timeout time.Duration
+}
+
+func NewNATS(url string, prefix string, timeout time.Duration) (*NATS, error) {
+	const op = "publisher.NewNATS"
+
+	conn, err := nats.Connect(url, nats.Name("sso-outbox"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &NATS{
+		conn:    conn,
+		prefix:  prefix,
+		timeout: timeout,
+	}, nil
+}
+
+func (p *NATS) Publish(ctx context.Context, event events.Event) error {
+	const op = "publisher.NATS.Publish"
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	subject := event.Type
+	if p.prefix != "" {
+		subject = p.prefix + "." + event.Type
+	}
+
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	msg.Header.Set(nats.MsgIdHdr, strconv.FormatInt(event.ID, 10))
+
+	if err := p.conn.PublishMsg(msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the event is marked as published only after the server got it
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	if err := p.conn.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (p *NATS) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,55 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/api/events"
+	"time"
+)
+
+// Kinds of the publisher in config
+const (
+	KindInProcess = "inprocess"
+	KindMemory    = "memory"
+	KindNATS      = "nats"
+	KindKafka     = "kafka"
+)
+
+// Publisher delivers the events to the broker, an error means the event must be retried
+type Publisher interface {
+	Publish(ctx context.Context, event events.Event) error
+	Close() error
+}
+
+// Config selects and configures the publisher
+type Config struct {
+	Kind          string
+	NATSURL       string
+	SubjectPrefix string // NATS subject is "<prefix>.<event type>"
+	KafkaRESTURL  string
+	KafkaTopic    string
+	Timeout       time.Duration
+}
+
+// New creates the publisher of the kind
+func New(log *slog.Logger, cfg Config) (Publisher, error) {
+	const op = "publisher.New"
+
+	switch cfg.Kind {
+	case KindInProcess:
+		return NewInProcess(log), nil
+	case KindMemory:
+		return NewMemory(), nil
+	case KindNATS, "":
+		p, err := NewNATS(cfg.NATSURL, cfg.SubjectPrefix, cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return p, nil
+	case KindKafka:
+		return NewKafka(cfg.KafkaRESTURL, cfg.KafkaTopic, cfg.Timeout), nil
+	default:
+		return nil, fmt.Errorf("%s: unknown publisher %q", op, cfg.Kind)
+	}
+}
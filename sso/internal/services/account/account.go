@@ -0,0 +1,274 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/api/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/audit"
+	"sso/internal/lib/jwt"
+	"sso/internal/storage"
+	"sso/pkg/logger/sl"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrUserNotFound       = errors.New("user not found")
+)
+
+const (
+	// purgeBatchSize is the number of accounts deleted in one purge run
+	purgeBatchSize = 100
+	// profileTokenTTL is the lifetime of the token used to delete the profile of the user
+	profileTokenTTL = time.Minute
+)
+
+type AccountRepository interface {
+	UserByID(ctx context.Context, userID int64) (models.User, error)
+	ScheduleUserDeletion(ctx context.Context, userID int64, deleteAfter time.Time) error
+	UsersDueForDeletion(ctx context.Context, now time.Time, limit int32) ([]models.User, error)
+	DeleteScheduledUser(ctx context.Context, userID int64, now time.Time) error
+}
+
+type AppProvider interface {
+	App(ctx context.Context, appID int32) (models.App, error)
+}
+
+// ProfileDeleter removes the profile of the user in the profile service
+type ProfileDeleter interface {
+	DeleteUserProfile(ctx context.Context, token string, userID int64) error
+}
+
+// Notifier emails the user about the deletion
+type Notifier interface {
+	SendAccountDeletionScheduledEmail(ctx context.Context, toEmail, toName, locale string, deleteAfter time.Time) error
+	SendAccountDeletedEmail(ctx context.Context, toEmail, toName, locale string, deletedAt time.Time) error
+}
+
+// AuditSink is the audit.Recorder
+type AuditSink interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+type Account struct {
+	log             *slog.Logger
+	users           AccountRepository
+	apps            AppProvider
+	profiles        ProfileDeleter
+	notifier        Notifier
+	audit           AuditSink
+	gracePeriod     time.Duration
+	profileAppID    int32
+	profileAudience string
+}
+
+func New(
+	log *slog.Logger,
+	users AccountRepository,
+	apps AppProvider,
+	profiles ProfileDeleter,
+	notifier Notifier,
+	audit AuditSink,
+	gracePeriod time.Duration,
+	profileAppID int32,
+	profileAudience string,
+) *Account {
+	return &Account{
+		log:             log,
+		users:           users,
+		apps:            apps,
+		profiles:        profiles,
+		notifier:        notifier,
+		audit:           audit,
+		gracePeriod:     gracePeriod,
+		profileAppID:    profileAppID,
+		profileAudience: profileAudience,
+	}
+}
+
+// DeleteAccount schedules deletion of the account after the grace period, the password is asked again,
+// all sessions are ended and logging in before the date cancels the deletion
+func (a *Account) DeleteAccount(ctx context.Context, userID int64, password string) (time.Time, error) {
+	const op = "Account.DeleteAccount"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", userID),
+	)
+
+	log.InfoContext(ctx, "scheduling account deletion")
+
+	user, err := a.users.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return time.Time{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		log.ErrorContext(ctx, "failed to get user", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{Type: audit.TypeAccountDeletionRequest, ActorID: userID, TargetUserID: userID}
+
+	if err := bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)); err != nil {
+		log.InfoContext(ctx, "invalid credentials")
+		event.Outcome = audit.OutcomeFailure
+		event.Payload = map[string]any{"error": ErrInvalidCredentials.Error()}
+		a.audit.Record(ctx, event)
+		return time.Time{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	// repeated requests keep the first date
+	if user.DeletionScheduled() {
+		log.InfoContext(ctx, "account deletion already scheduled")
+		return user.DeleteAfter, nil
+	}
+
+	deleteAfter := time.Now().Add(a.gracePeriod)
+
+	if err := a.users.ScheduleUserDeletion(ctx, userID, deleteAfter); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return time.Time{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		log.ErrorContext(ctx, "failed to schedule account deletion", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.Payload = map[string]any{"delete_after": deleteAfter.Unix()}
+	a.audit.Record(ctx, event)
+
+	if err := a.notifier.SendAccountDeletionScheduledEmail(ctx, user.Email, user.Name, user.Locale, deleteAfter); err != nil {
+		// the deletion is scheduled anyway, the user was told the date in the response
+		log.ErrorContext(ctx, "failed to send deletion notice", sl.Err(err))
+	}
+
+	log.InfoContext(ctx, "account deletion scheduled", slog.Time("deleteAfter", deleteAfter))
+	return deleteAfter, nil
+}
+
+// Run purges the due accounts every interval until the context is canceled
+func (a *Account) Run(ctx context.Context, interval time.Duration) {
+	const op = "Account.Run"
+
+	log := a.log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := a.Purge(ctx); err != nil {
+			log.ErrorContext(ctx, "failed to purge accounts", sl.Err(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge deletes the accounts whose grace period is over, returns the number of deleted accounts,
+// an account that failed to delete is retried on the next run
+func (a *Account) Purge(ctx context.Context) (int, error) {
+	const op = "Account.Purge"
+
+	log := a.log.With(slog.String("op", op))
+
+	users, err := a.users.UsersDueForDeletion(ctx, time.Now(), purgeBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted := 0
+	for _, user := range users {
+		ok, err := a.purgeUser(ctx, user)
+		if err != nil {
+			log.ErrorContext(ctx, "failed to delete account", slog.Int64("userID", user.ID), sl.Err(err))
+			continue
+		}
+		if ok {
+			deleted++
+		}
+	}
+
+	if deleted > 0 {
+		log.InfoContext(ctx, "accounts deleted", slog.Int("count", deleted))
+	}
+
+	return deleted, nil
+}
+
+// purgeUser deletes the account if its deletion is still due, returns false if it was canceled
+// in the meantime. The user.deleted event is written with the deletion and the profile is
+// deleted when the event is relayed, so nothing is removed for a canceled deletion
+func (a *Account) purgeUser(ctx context.Context, user models.User) (bool, error) {
+	const op = "Account.purgeUser"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", user.ID),
+	)
+
+	deletedAt := time.Now()
+
+	if err := a.users.DeleteScheduledUser(ctx, user.ID, deletedAt); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.InfoContext(ctx, "account deletion was canceled")
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.audit.Record(ctx, models.AuditEvent{
+		Type:         audit.TypeAccountDeleted,
+		ActorID:      user.ID,
+		TargetUserID: user.ID,
+		Payload:      map[string]any{"requested_delete_after": user.DeleteAfter.Unix()},
+	})
+
+	if err := a.notifier.SendAccountDeletedEmail(ctx, user.Email, user.Name, user.Locale, deletedAt); err != nil {
+		log.ErrorContext(ctx, "failed to send deletion receipt", sl.Err(err))
+	}
+
+	log.InfoContext(ctx, "account deleted")
+	return true, nil
+}
+
+// Publish deletes the profile of the deleted user, the outbox relay calls it with every event
+// after the deletion is committed and retries the event until the profile is gone
+func (a *Account) Publish(ctx context.Context, event events.Event) error {
+	const op = "Account.Publish"
+
+	if event.Type != events.TypeUserDeleted {
+		return nil
+	}
+
+	app, err := a.apps.App(ctx, a.profileAppID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// profile deletes the profile only for its owner, so it is asked on behalf of the user,
+	// the empty permissions are embedded since sso no longer knows the permissions of the user
+	app.EmbedPermissions = true
+	token, err := jwt.NewToken(models.User{ID: event.UserID}, app, nil, []string{a.profileAudience}, profileTokenTTL)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.profiles.DeleteUserProfile(ctx, token, event.UserID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Close implements the publisher of the relay, the profile connection is closed by the app
+func (a *Account) Close() error {
+	return nil
+}
@@ -0,0 +1,273 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"sso/pkg/logger/sl"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidInput     = errors.New("invalid api key input")
+	ErrInvalidKey       = errors.New("api key is invalid")
+	ErrKeyNotFound      = errors.New("api key not found")
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+const (
+	// keyPrefix marks the keys issued by sso, so leaked keys are easy to find
+	keyPrefix = "osk_"
+	// shownPrefixLen is the number of key characters kept to recognize the key
+	shownPrefixLen = len(keyPrefix) + 8
+	keyBytes       = 32
+
+	// managePermission allows to manage app keys and keys of other users
+	managePermission = "api_keys:manage"
+)
+
+type KeyRepository interface {
+	SaveAPIKey(ctx context.Context, key models.APIKey, keyHash string) (models.APIKey, error)
+	APIKeys(ctx context.Context, userID int64, appID int32) ([]models.APIKey, error)
+	APIKeyByID(ctx context.Context, id int64) (models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) (bool, error)
+	UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error)
+}
+
+type PermProvider interface {
+	GetUserPermissionsAsModels(ctx context.Context, userID int64, appID int32) ([]models.Permission, error)
+	HasUserPermission(ctx context.Context, userID int64, appID int32, permission string) (bool, error)
+}
+
+type APIKeys struct {
+	log          *slog.Logger
+	keys         KeyRepository
+	permProvider PermProvider
+}
+
+func New(log *slog.Logger, keys KeyRepository, permProvider PermProvider) *APIKeys {
+	return &APIKeys{
+		log:          log,
+		keys:         keys,
+		permProvider: permProvider,
+	}
+}
+
+// Create issues a new key for the user, permissions must be a subset of the user permissions
+// returns the raw key, it is not stored and can't be shown again
+func (a *APIKeys) Create(
+	ctx context.Context,
+	userID int64,
+	name string,
+	permissions []string,
+	appID int32,
+	expiresAt time.Time,
+) (models.APIKey, string, error) {
+	const op = "APIKeys.Create"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", userID),
+		slog.Int("appID", int(appID)),
+		slog.Any("name", sl.Sensitive(name)),
+	)
+
+	log.InfoContext(ctx, "creating api key")
+
+	if strings.TrimSpace(name) == "" || (!expiresAt.IsZero() && !expiresAt.After(time.Now())) {
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrInvalidInput)
+	}
+
+	if appID != 0 {
+		if err := a.requireManage(ctx, userID); err != nil {
+			log.WarnContext(ctx, "user is not allowed to create app keys")
+			return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	owned, err := a.permProvider.GetUserPermissionsAsModels(ctx, userID, appID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to get user permissions", sl.Err(err))
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	ownedSet := make(map[string]bool, len(owned))
+	for _, permission := range owned {
+		ownedSet[permission.Code] = true
+	}
+
+	for _, permission := range permissions {
+		if !ownedSet[permission] {
+			log.WarnContext(ctx, "requested permission is not owned by user", slog.String("permission", permission))
+			return models.APIKey{}, "", fmt.Errorf("%s: %w: %s", op, ErrPermissionDenied, permission)
+		}
+	}
+
+	raw, err := generateKey()
+	if err != nil {
+		log.ErrorContext(ctx, "failed to generate api key", sl.Err(err))
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	key, err := a.keys.SaveAPIKey(ctx, models.APIKey{
+		UserID:      userID,
+		AppID:       appID,
+		Name:        name,
+		Prefix:      raw[:shownPrefixLen],
+		Permissions: permissions,
+		ExpiresAt:   expiresAt,
+	}, hashKey(raw))
+	if err != nil {
+		log.ErrorContext(ctx, "failed to save api key", sl.Err(err))
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.InfoContext(ctx, "api key created", slog.Int64("keyID", key.ID))
+	return key, raw, nil
+}
+
+// List returns own keys of the user, or keys of the app for the managers
+func (a *APIKeys) List(ctx context.Context, userID int64, appID int32) ([]models.APIKey, error) {
+	const op = "APIKeys.List"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", userID),
+		slog.Int("appID", int(appID)),
+	)
+
+	if appID != 0 {
+		if err := a.requireManage(ctx, userID); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	keys, err := a.keys.APIKeys(ctx, userID, appID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to list api keys", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// Revoke revokes the key of the user, managers can revoke any key
+// revoking an already revoked key is not an error, changed is false then
+func (a *APIKeys) Revoke(ctx context.Context, userID int64, keyID int64) (bool, error) {
+	const op = "APIKeys.Revoke"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", userID),
+		slog.Int64("keyID", keyID),
+	)
+
+	log.InfoContext(ctx, "revoking api key")
+
+	key, err := a.keys.APIKeyByID(ctx, keyID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			return false, fmt.Errorf("%s: %w", op, ErrKeyNotFound)
+		}
+		log.ErrorContext(ctx, "failed to get api key", sl.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if key.UserID != userID || key.AppID != 0 {
+		if err := a.requireManage(ctx, userID); err != nil {
+			log.WarnContext(ctx, "user is not allowed to revoke the key")
+			// hide keys of other users
+			return false, fmt.Errorf("%s: %w", op, ErrKeyNotFound)
+		}
+	}
+
+	changed, err := a.keys.RevokeAPIKey(ctx, keyID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to revoke api key", sl.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.InfoContext(ctx, "api key revoked", slog.Bool("changed", changed))
+	return changed, nil
+}
+
+// Validate returns the active key and records its usage, the key keeps only the permissions
+// the owner still has, so revoking a grant of the user limits the keys too
+func (a *APIKeys) Validate(ctx context.Context, raw string) (models.APIKey, error) {
+	const op = "APIKeys.Validate"
+
+	log := a.log.With(slog.String("op", op))
+
+	if !strings.HasPrefix(raw, keyPrefix) {
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, ErrInvalidKey)
+	}
+
+	key, err := a.keys.UseAPIKey(ctx, hashKey(raw))
+	if err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			log.WarnContext(ctx, "unknown, revoked or expired api key", slog.String("prefix", raw[:min(len(raw), shownPrefixLen)]))
+			return models.APIKey{}, fmt.Errorf("%s: %w", op, ErrInvalidKey)
+		}
+		log.ErrorContext(ctx, "failed to validate api key", sl.Err(err))
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	owned, err := a.permProvider.GetUserPermissionsAsModels(ctx, key.UserID, key.AppID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to get owner permissions", sl.Err(err))
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key.Permissions = intersect(key.Permissions, owned)
+
+	return key, nil
+}
+
+// intersect keeps the key permissions that are still granted to the owner
+func intersect(permissions []string, owned []models.Permission) []string {
+	ownedSet := make(map[string]bool, len(owned))
+	for _, permission := range owned {
+		ownedSet[permission.Code] = true
+	}
+
+	kept := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		if ownedSet[permission] {
+			kept = append(kept, permission)
+		}
+	}
+	return kept
+}
+
+func (a *APIKeys) requireManage(ctx context.Context, userID int64) error {
+	allowed, err := a.permProvider.HasUserPermission(ctx, userID, 0, managePermission)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+func generateKey() (string, error) {
+	b := make([]byte, keyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashKey hashes the key for storage, keys are random so a plain SHA-256 is enough
+func hashKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/pkg/logger/sl"
+)
+
+var ErrInvalidInput = errors.New("invalid audit filter")
+
+const (
+	defaultEventsLimit = 50
+	maxEventsLimit     = 500
+)
+
+type EventRepository interface {
+	AuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error)
+}
+
+type Audit struct {
+	log    *slog.Logger
+	events EventRepository
+}
+
+func New(log *slog.Logger, events EventRepository) *Audit {
+	return &Audit{
+		log:    log,
+		events: events,
+	}
+}
+
+// Events returns the events matching the filter, newest first
+func (a *Audit) Events(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	const op = "Audit.Events"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", filter.UserID),
+		slog.Any("types", filter.Types),
+	)
+
+	if filter.Limit < 0 || filter.Offset < 0 || (!filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To)) {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidInput)
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = defaultEventsLimit
+	}
+	filter.Limit = min(filter.Limit, maxEventsLimit)
+
+	events, err := a.events.AuditEvents(ctx, filter)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to list audit events", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
@@ -8,21 +8,42 @@ import (
 	"fmt"
 	"golang.org/x/crypto/bcrypt"
 	"log/slog"
+	"slices"
 	_ "sso/internal/config"
 	"sso/internal/domain/models"
+	"sso/internal/lib/audit"
 	"sso/internal/lib/jwt"
-	"sso/internal/lib/logger/sl"
-	"sso/internal/lib/mailer"
+	"sso/internal/lib/metrics"
 	"sso/internal/services"
 	"sso/internal/storage"
 	"sso/internal/validator"
+	"sso/pkg/logger/sl"
 	"time"
 )
 
 var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidAudience    = errors.New("invalid audience")
+	ErrUserDisabled       = errors.New("user is disabled")
+	ErrDeletionScheduled  = errors.New("account is scheduled for deletion")
+	// ErrVerificationCooldown means the verification email was sent too recently or too often today
+	ErrVerificationCooldown = errors.New("verification email was sent recently")
 )
 
+// verificationWindow is the period VerificationConfig.DailyLimit applies to
+const verificationWindow = 24 * time.Hour
+
+// VerificationConfig limits the verification emails
+type VerificationConfig struct {
+	TokenTTL    time.Duration // lifetime of the link
+	MinValidity time.Duration // a token expiring sooner is replaced instead of sent again
+	Cooldown    time.Duration // between two emails to a user
+	DailyLimit  int32         // emails to a user in 24 hours, zero is unlimited
+}
+
+// defaultRoleID is the "user" role assigned to every registered user
+const defaultRoleID = 1
+
 type UserSaver interface {
 	SaveUserWithPermission(
 		ctx context.Context,
@@ -30,19 +51,22 @@ type UserSaver interface {
 		phone string,
 		address string,
 		email string,
+		locale string,
 		passwordHash []byte,
-		permissionID int64,
+		roleID int64,
 	) (uid int64, resName string, resEmail string, activated bool, err error)
 	//AddUserPermission(ctx context.Context, userID int64, permissionID int64) error
-	SaveVerificationToken(ctx context.Context, token string, userID int64, expiresAt time.Time) error
 	VerifyEmail(ctx context.Context, token string) (userID int64, err error)
-	GetVerificationTokenByUserID(ctx context.Context, userID int64) (string, time.Time, error)
+	GetVerificationTokenByUserID(ctx context.Context, userID int64) (models.VerificationToken, error)
+	RecordVerificationSend(ctx context.Context, userID int64, token models.VerificationToken, prevSentAt time.Time) error
 
 	SaveResetToken(ctx context.Context, token string, userID int64, expiresAt time.Time) error
 	ValidateResetToken(ctx context.Context, token string) (userID int64, err error)
 	//GetUserByResetToken(ctx context.Context, token string) (int64, error)
 	UpdateUserPassword(ctx context.Context, userID int64, passwordHash []byte) error
 	DeleteResetToken(ctx context.Context, token string) error
+
+	CancelUserDeletion(ctx context.Context, userID int64) (bool, error)
 }
 
 type RefreshSaver interface {
@@ -58,11 +82,24 @@ type UserProvider interface {
 
 type AppProvider interface {
 	App(ctx context.Context, appID int32) (models.App, error)
+	AppByAudience(ctx context.Context, audience string) (models.App, error)
 	GetAppSecret(ctx context.Context, appID int32) (string, error)
 }
 
 type PermProvider interface {
-	GetUserPermissionsAsModels(ctx context.Context, userID int64) ([]models.Permission, error)
+	GetUserPermissionsAsModels(ctx context.Context, userID int64, appID int32) ([]models.Permission, error)
+}
+
+// AuditSink is the audit.Recorder
+type AuditSink interface {
+	Record(ctx context.Context, event models.AuditEvent)
+	RecordFailure(ctx context.Context, event models.AuditEvent, err error)
+}
+
+// Mailer emails the verification and password reset links
+type Mailer interface {
+	SendVerificationEmail(ctx context.Context, toEmail, toName, locale string, app models.App, verificationToken, baseURL string) error
+	SendResetPasswordEmail(ctx context.Context, toEmail, toName, locale string, app models.App, resetToken, baseURL string) error
 }
 
 type Auth struct {
@@ -72,10 +109,13 @@ type Auth struct {
 	usrProvider  UserProvider
 	appProvider  AppProvider
 	permProvider PermProvider
-	emailClient  *mailer.Mailer
+	audit        AuditSink
+	emailClient  Mailer
 	baseURL      string
+	verification VerificationConfig
 	tokenTTL     time.Duration
 	refreshTTL   time.Duration
+	audience     string
 }
 
 func New(
@@ -85,10 +125,13 @@ func New(
 	userProvider UserProvider,
 	appProvider AppProvider,
 	permProvider PermProvider,
-	emailClient *mailer.Mailer,
+	audit AuditSink,
+	emailClient Mailer,
 	baseURL string,
+	verification VerificationConfig,
 	tokenTTL time.Duration,
 	refreshTTL time.Duration,
+	audience string,
 ) *Auth {
 	return &Auth{
 		log:          log,
@@ -97,68 +140,78 @@ func New(
 		usrProvider:  userProvider,
 		appProvider:  appProvider,
 		permProvider: permProvider,
+		audit:        audit,
 		emailClient:  emailClient,
 		baseURL:      baseURL,
+		verification: verification,
 		tokenTTL:     tokenTTL,
 		refreshTTL:   refreshTTL,
+		audience:     audience,
 	}
 }
 
-// RegisterNewUser registers a new user and returns ID, returns error if email already exists
+// RegisterNewUser registers a new user and returns ID, returns error if email already exists,
+// locale of the emails may be empty
 func (a *Auth) RegisterNewUser(ctx context.Context,
 	name string,
 	phone string,
 	address string,
 	email string,
 	password string,
+	locale string,
 ) (int64, string, string, bool, error) {
 	// op - name of the current package and function; convenient to put in logs and errors to find problems faster
 	const op = "Auth.RegisterNewUser"
 
 	log := a.log.With(
 		slog.String("op", op),
-		slog.String("name", name),
+		slog.Any("name", sl.Sensitive(name)),
 		slog.String("phone", phone),
 		slog.String("address", address),
 		slog.String("email", email),
 	)
 
-	log.Info("registering user")
+	log.InfoContext(ctx, "registering user")
 
 	v := validator.New()
 	validator.ValidateUserEmail(v, email)
 	if !v.Valid() {
-		log.Error("invalid user email", slog.Any("errors", v.Errors))
+		log.ErrorContext(ctx, "invalid user email", slog.Any("errors", v.Errors))
 		return 0, "", "", false, fmt.Errorf("%s: validation error: %v", op, services.ErrInvalidEmail)
 	}
 	validator.ValidateUserPassword(v, password)
 	if !v.Valid() {
-		log.Error("invalid user password", slog.Any("errors", v.Errors))
+		log.ErrorContext(ctx, "invalid user password", slog.Any("errors", v.Errors))
 		return 0, "", "", false, fmt.Errorf("%s: validation error: %v", op, services.ErrInvalidPassword)
 	}
 
 	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	if err != nil {
-		log.Error("failed to generate password hash", sl.Err(err))
+		log.ErrorContext(ctx, "failed to generate password hash", sl.Err(err))
 
 		return 0, "", "", false, fmt.Errorf("%s: %w", op, err)
 	}
 
 	// saving user in DB
-	id, name, email, activated, err := a.usrSaver.SaveUserWithPermission(ctx, name, phone, address, email, passwordHash, 1)
+	id, name, email, activated, err := a.usrSaver.SaveUserWithPermission(ctx, name, phone, address, email, locale, passwordHash, defaultRoleID)
 	if err != nil {
-		log.Error("failed to save user with permission", sl.Err(err))
+		log.ErrorContext(ctx, "failed to save user with permission", sl.Err(err))
+		a.audit.RecordFailure(ctx, models.AuditEvent{Type: audit.TypeRegister, Payload: map[string]any{"email_hash": audit.HashEmail(email)}}, err)
 
 		return 0, "", "", false, fmt.Errorf("%s: %w", op, err)
 	}
 
 	//if err := a.usrSaver.AddUserPermission(ctx, id, 1); err != nil {
-	//	log.Error("failed to assign user permission", sl.Err(err))
+	//	log.ErrorContext(ctx, "failed to assign user permission", sl.Err(err))
 	//
 	//	return 0, "", "", false, fmt.Errorf("%s: %w", op, err)
 	//}
 
-	log.Info("user registered successfully", slog.Int64("userID", id))
+	a.audit.Record(ctx, models.AuditEvent{Type: audit.TypeRegister, ActorID: id, TargetUserID: id})
+
+	metrics.Registrations.Inc()
+
+	log.InfoContext(ctx, "user registered successfully", slog.Int64("userID", id))
 
 	return id, name, email, activated, nil
 }
@@ -171,7 +224,9 @@ func (a *Auth) generateVerificationToken() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
-func (a *Auth) SendVerificationEmail(ctx context.Context, userId int64, appID int32) (bool, string, int64, error) {
+// SendVerificationEmail emails the verification link to the user, the still valid token is sent again.
+// It returns when the next email may be sent, also with ErrVerificationCooldown
+func (a *Auth) SendVerificationEmail(ctx context.Context, userId int64, appID int32) (bool, string, int64, time.Time, error) {
 	const op = "Auth.SendVerificationEmail"
 
 	log := a.log.With(
@@ -180,45 +235,155 @@ func (a *Auth) SendVerificationEmail(ctx context.Context, userId int64, appID in
 		slog.Int("appID", int(appID)),
 	)
 
-	log.Info("sending verification email")
+	log.InfoContext(ctx, "sending verification email")
 
 	user, err := a.usrProvider.UserByID(ctx, userId)
 	if err != nil {
-		log.Error("failed to get user", sl.Err(err))
-		return false, "User not found", 0, fmt.Errorf("%s: %w", op, err)
+		log.ErrorContext(ctx, "failed to get user", sl.Err(err))
+		return false, "User not found", 0, time.Time{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	if user.Activated {
-		log.Info("user already activated")
-		return false, "User already activated", 0, nil
+		log.InfoContext(ctx, "user already activated")
+		return false, "User already activated", 0, time.Time{}, nil
 	}
 
-	token, err := a.generateVerificationToken()
+	expiresAt, nextSendAt, err := a.sendVerification(ctx, log, user, appID)
 	if err != nil {
-		log.Error("failed to generate verification token", sl.Err(err))
-		return false, "Failed to generate verification token", 0, fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, ErrVerificationCooldown) {
+			log.WarnContext(ctx, "verification email was sent recently", slog.Time("nextSendAt", nextSendAt))
+			return false, "Verification email was sent recently", 0, nextSendAt, fmt.Errorf("%s: %w", op, err)
+		}
+		return false, "Failed to send verification email", 0, time.Time{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	expiresAt := time.Now().Add(24 * time.Hour)
-	if err := a.usrSaver.SaveVerificationToken(ctx, token, userId, expiresAt); err != nil {
-		log.Error("failed to save verification token", sl.Err(err))
-		return false, "Failed to save verification token", 0, fmt.Errorf("%s: %w", op, err)
+	log.InfoContext(ctx, "verification email queued")
+
+	return true, "Verification email sent successfully", expiresAt.Unix(), nextSendAt, nil
+}
+
+// ResendVerification emails the verification link to the user with the email, for users who can't log in yet.
+// The answer is the same whether the user exists or not: it returns when the next email may be requested
+func (a *Auth) ResendVerification(ctx context.Context, email string, appID int32) (time.Time, error) {
+	const op = "Auth.ResendVerification"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+		slog.Int("appID", int(appID)),
+	)
+
+	log.InfoContext(ctx, "resending verification email")
+
+	user, err := a.usrProvider.UserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.WarnContext(ctx, "user not found for verification")
+			a.audit.RecordFailure(ctx, models.AuditEvent{Type: audit.TypeVerificationSent, AppID: appID, Payload: map[string]any{"email_hash": audit.HashEmail(email)}}, err)
+			return time.Now().Add(a.verification.Cooldown), nil
+		}
+		log.ErrorContext(ctx, "failed to get user", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("attempting to send email",
+	if user.Activated {
+		log.InfoContext(ctx, "user already activated")
+		return time.Now().Add(a.verification.Cooldown), nil
+	}
+
+	_, nextSendAt, err := a.sendVerification(ctx, log, user, appID)
+	if err != nil {
+		if errors.Is(err, ErrVerificationCooldown) {
+			log.WarnContext(ctx, "verification email was sent recently", slog.Time("nextSendAt", nextSendAt))
+			return nextSendAt, nil
+		}
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.InfoContext(ctx, "verification email queued")
+
+	return nextSendAt, nil
+}
+
+// sendVerification emails the valid token of the user or a new one within the limits,
+// returns the expiry of the token and when the next email may be sent
+func (a *Auth) sendVerification(ctx context.Context, log *slog.Logger, user models.User, appID int32) (time.Time, time.Time, error) {
+	now := time.Now()
+
+	current, err := a.usrSaver.GetVerificationTokenByUserID(ctx, user.ID)
+	if err != nil && !errors.Is(err, storage.ErrTokenNotFound) {
+		log.ErrorContext(ctx, "failed to get verification token", sl.Err(err))
+		return time.Time{}, time.Time{}, err
+	}
+
+	if next := a.nextVerificationAt(current); next.After(now) {
+		return time.Time{}, next, ErrVerificationCooldown
+	}
+
+	sent := current
+	if sent.WindowStartedAt.IsZero() || now.Sub(sent.WindowStartedAt) >= verificationWindow {
+		sent.WindowStartedAt = now
+		sent.SentCount = 0
+	}
+	sent.SentCount++
+	sent.LastSentAt = now
+
+	// the link of the previous email keeps working unless it is about to expire
+	if sent.Token == "" || sent.ExpiresAt.Before(now.Add(a.verification.MinValidity)) {
+		token, err := a.generateVerificationToken()
+		if err != nil {
+			log.ErrorContext(ctx, "failed to generate verification token", sl.Err(err))
+			return time.Time{}, time.Time{}, err
+		}
+		sent.Token = token
+		sent.ExpiresAt = now.Add(a.verification.TokenTTL)
+	}
+
+	if err := a.usrSaver.RecordVerificationSend(ctx, user.ID, sent, current.LastSentAt); err != nil {
+		if errors.Is(err, storage.ErrConcurrentUpdate) {
+			// another request has just sent it
+			return time.Time{}, now.Add(a.verification.Cooldown), ErrVerificationCooldown
+		}
+		log.ErrorContext(ctx, "failed to save verification token", sl.Err(err))
+		return time.Time{}, time.Time{}, err
+	}
+
+	app := a.emailApp(ctx, log, appID)
+
+	log.InfoContext(ctx, "attempting to send email",
 		slog.String("toEmail", user.Email),
-		slog.String("toName", user.Name),
+		slog.Any("toName", sl.Sensitive(user.Name)),
 		slog.String("baseURL", a.baseURL),
+		slog.Int("sentToday", int(sent.SentCount)),
 	)
 
-	if err := a.emailClient.SendVerificationEmail(ctx, user.Email, user.Name, token, a.baseURL); err != nil {
-		log.Error("failed to send verification email", sl.Err(err))
-		return false, "Failed to send verification email", 0, fmt.Errorf("%s: %w", op, err)
+	event := models.AuditEvent{Type: audit.TypeVerificationSent, ActorID: audit.ActorID(ctx), TargetUserID: user.ID, AppID: appID}
+
+	if err := a.emailClient.SendVerificationEmail(ctx, user.Email, user.Name, user.Locale, app, sent.Token, a.baseURL); err != nil {
+		log.ErrorContext(ctx, "failed to send verification email", sl.Err(err))
+		a.audit.RecordFailure(ctx, event, err)
+		return time.Time{}, time.Time{}, err
 	}
 
-	log.Info("verification email sent successfully")
+	a.audit.Record(ctx, event)
 
-	return true, "Verification email sent successfully", expiresAt.Unix(), nil
+	return sent.ExpiresAt, a.nextVerificationAt(sent), nil
+}
+
+// nextVerificationAt returns when the next verification email may be sent after the recorded ones
+func (a *Auth) nextVerificationAt(token models.VerificationToken) time.Time {
+	if token.LastSentAt.IsZero() {
+		return time.Time{}
+	}
+
+	next := token.LastSentAt.Add(a.verification.Cooldown)
+	if a.verification.DailyLimit > 0 && token.SentCount >= a.verification.DailyLimit {
+		if windowEnd := token.WindowStartedAt.Add(verificationWindow); windowEnd.After(next) {
+			next = windowEnd
+		}
+	}
+
+	return next
 }
 
 func (a *Auth) EmailVerify(ctx context.Context, token string) (bool, string, bool, error) {
@@ -228,7 +393,7 @@ func (a *Auth) EmailVerify(ctx context.Context, token string) (bool, string, boo
 		slog.String("op", op),
 	)
 
-	log.Info("processing email verification")
+	log.InfoContext(ctx, "processing email verification")
 
 	if token == "" {
 		return false, "Verification token is required", false, fmt.Errorf("%s: empty token", op)
@@ -237,23 +402,29 @@ func (a *Auth) EmailVerify(ctx context.Context, token string) (bool, string, boo
 	userID, err := a.usrSaver.VerifyEmail(ctx, token)
 	if err != nil {
 		if errors.Is(err, storage.ErrTokenNotFound) {
-			log.Warn("invalid or expired verification token")
+			log.WarnContext(ctx, "invalid or expired verification token")
+			a.audit.RecordFailure(ctx, models.AuditEvent{Type: audit.TypeEmailVerified}, err)
 			return false, "Invalid or expired verification token", false, fmt.Errorf("%s: %w", op, err)
 		}
-		log.Error("failed to verify email", sl.Err(err))
+		log.ErrorContext(ctx, "failed to verify email", sl.Err(err))
 		return false, "Failed to verify email", false, fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("email verified successfully", slog.Int64("userID", userID))
+	a.audit.Record(ctx, models.AuditEvent{Type: audit.TypeEmailVerified, ActorID: userID, TargetUserID: userID})
+
+	log.InfoContext(ctx, "email verified successfully", slog.Int64("userID", userID))
 
 	return true, "Email verified successfully", true, nil
 }
 
+// Login issues tokens for the app, audience lists the resource services requested by the client,
+// when empty the app default audience is used
 func (a *Auth) Login(
 	ctx context.Context,
 	email string,
 	password string,
 	appID int32,
+	audience []string,
 ) (string, string, int64, error) {
 	const op = "Auth.Login"
 
@@ -263,55 +434,91 @@ func (a *Auth) Login(
 		slog.Int("appID", int(appID)),
 	)
 
-	log.Info("attempting to login user")
+	log.InfoContext(ctx, "attempting to login user")
 
 	user, err := a.usrProvider.UserByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
-			a.log.Warn("user not found", sl.Err(err))
+			a.log.WarnContext(ctx, "user not found", sl.Err(err))
+			a.audit.RecordFailure(ctx, models.AuditEvent{Type: audit.TypeLogin, AppID: appID, Payload: map[string]any{"email_hash": audit.HashEmail(email)}}, err)
+			metrics.Logins.WithLabelValues(metrics.ResultFailure).Inc()
 
 			return "", "", 0, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
 		}
 
-		a.log.Error("failed to get user", sl.Err(err))
+		a.log.ErrorContext(ctx, "failed to get user", sl.Err(err))
 
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
 	if err := bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)); err != nil {
-		a.log.Info("invalid credentials", sl.Err(err))
+		a.log.InfoContext(ctx, "invalid credentials", sl.Err(err))
+		a.audit.RecordFailure(ctx, models.AuditEvent{Type: audit.TypeLogin, TargetUserID: user.ID, AppID: appID}, ErrInvalidCredentials)
+		metrics.Logins.WithLabelValues(metrics.ResultFailure).Inc()
 
 		return "", "", 0, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
 	}
 
+	// checked after the password, so the state of the account is not revealed to strangers
+	if user.Disabled() {
+		log.WarnContext(ctx, "disabled user tried to login")
+		a.audit.RecordFailure(ctx, models.AuditEvent{Type: audit.TypeLogin, TargetUserID: user.ID, AppID: appID}, ErrUserDisabled)
+		metrics.Logins.WithLabelValues(metrics.ResultFailure).Inc()
+
+		return "", "", 0, fmt.Errorf("%s: %w", op, ErrUserDisabled)
+	}
+
+	// logging in during the grace period cancels the requested account deletion
+	if user.DeletionScheduled() {
+		canceled, err := a.usrSaver.CancelUserDeletion(ctx, user.ID)
+		if err != nil {
+			log.ErrorContext(ctx, "failed to cancel account deletion", sl.Err(err))
+			return "", "", 0, fmt.Errorf("%s: %w", op, err)
+		}
+		if canceled {
+			log.InfoContext(ctx, "account deletion canceled by login")
+			a.audit.Record(ctx, models.AuditEvent{Type: audit.TypeAccountDeletionCancel, ActorID: user.ID, TargetUserID: user.ID, AppID: appID})
+		}
+	}
+
 	// info about app
 	app, err := a.appProvider.App(ctx, appID)
 	if err != nil {
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	permissions, err := a.permProvider.GetUserPermissionsAsModels(ctx, user.ID)
+	permissions, err := a.permProvider.GetUserPermissionsAsModels(ctx, user.ID, app.ID)
+	if err != nil {
+		a.log.ErrorContext(ctx, "failed to get user permissions", sl.Err(err))
+		return "", "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	audience, err = a.tokenAudience(ctx, app, audience)
 	if err != nil {
-		a.log.Error("failed to get user permissions", sl.Err(err))
+		log.WarnContext(ctx, "failed to resolve token audience", sl.Err(err))
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	token, err := jwt.NewToken(user, app, permissions, a.tokenTTL)
+	// app may override the global token lifetimes
+	tokenTTL := app.AccessTokenTTL(a.tokenTTL)
+	refreshTTL := app.RefreshTokenTTL(a.refreshTTL)
+
+	token, err := jwt.NewToken(user, app, permissions, audience, tokenTTL)
 	if err != nil {
-		a.log.Error("failed to generate access token", sl.Err(err))
+		a.log.ErrorContext(ctx, "failed to generate access token", sl.Err(err))
 
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	refresh, err := jwt.NewRefreshToken(user, app, a.refreshTTL)
+	refresh, err := jwt.NewRefreshToken(user, app, audience, refreshTTL)
 	if err != nil {
-		a.log.Error("failed to generate refresh token", sl.Err(err))
+		a.log.ErrorContext(ctx, "failed to generate refresh token", sl.Err(err))
 
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
-	expiresAt := time.Now().Add(a.tokenTTL).Unix()
+	expiresAt := time.Now().Add(tokenTTL).Unix()
 
-	err = a.refreshSaver.SaveRefresh(ctx, refresh, user.ID, app.ID, time.Now().Add(a.refreshTTL))
+	err = a.refreshSaver.SaveRefresh(ctx, refresh, user.ID, app.ID, time.Now().Add(refreshTTL))
 	if err != nil {
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
@@ -321,19 +528,33 @@ func (a *Auth) Login(
 		return "", "", 0, fmt.Errorf("%s: invalid refresh token", op)
 	}
 
-	log.Info("user logged in successfully")
+	a.audit.Record(ctx, models.AuditEvent{Type: audit.TypeLogin, ActorID: user.ID, TargetUserID: user.ID, AppID: app.ID})
+
+	metrics.Logins.WithLabelValues(metrics.ResultSuccess).Inc()
+
+	log.InfoContext(ctx, "user logged in successfully")
 
 	return token, refresh, expiresAt, nil
 }
 
 func (a *Auth) Logout(ctx context.Context, refresh string) (bool, error) {
 	const op = "Auth.Logout"
+
+	// the token is already checked by the caller, here it only identifies the user for audit
+	event := models.AuditEvent{Type: audit.TypeLogout}
+	if claims, err := jwt.DecodeWithoutValidation(refresh); err == nil {
+		event.ActorID, event.TargetUserID, event.AppID = claims.UserID, claims.UserID, claims.AppID
+	}
+
 	err := a.refreshSaver.DeleteRefresh(ctx, refresh)
 	if err != nil {
-		a.log.Error("failed to delete refresh token", slog.String("op", op), sl.Err(err))
+		a.log.ErrorContext(ctx, "failed to delete refresh token", slog.String("op", op), sl.Err(err))
+		a.audit.RecordFailure(ctx, event, err)
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
+	a.audit.Record(ctx, event)
+
 	return true, nil
 }
 
@@ -342,7 +563,7 @@ func (a *Auth) GetUserInfo(ctx context.Context, token string) (int64, string, st
 
 	claims, err := jwt.DecodeWithoutValidation(token)
 	if err != nil {
-		a.log.Error("failed to decode token", slog.String("op", op), sl.Err(err))
+		a.log.ErrorContext(ctx, "failed to decode token", slog.String("op", op), sl.Err(err))
 		return 0, "", "", "", "", false, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -350,13 +571,13 @@ func (a *Auth) GetUserInfo(ctx context.Context, token string) (int64, string, st
 
 	app, err := a.appProvider.App(ctx, appID)
 	if err != nil {
-		a.log.Error("failed to get app", slog.String("op", op), sl.Err(err))
+		a.log.ErrorContext(ctx, "failed to get app", slog.String("op", op), sl.Err(err))
 		return 0, "", "", "", "", false, fmt.Errorf("%s: %w", op, err)
 	}
 
-	validClaims, err := jwt.ValidateToken(token, app.Secret)
+	validClaims, err := jwt.ValidateTokenForAudience(token, app.Secret, a.audience)
 	if err != nil {
-		a.log.Error("invalid token", slog.String("op", op), sl.Err(err))
+		a.log.ErrorContext(ctx, "invalid token", slog.String("op", op), sl.Err(err))
 		return 0, "", "", "", "", false, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -364,19 +585,20 @@ func (a *Auth) GetUserInfo(ctx context.Context, token string) (int64, string, st
 
 	user, err := a.usrProvider.UserByID(ctx, userID)
 	if err != nil {
-		a.log.Error("failed to get user", slog.String("op", op), sl.Err(err))
+		a.log.ErrorContext(ctx, "failed to get user", slog.String("op", op), sl.Err(err))
 		return 0, "", "", "", "", false, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return user.ID, user.Email, user.Name, user.Phone, user.Address, user.Activated, nil
 }
 
-func (a *Auth) RefreshToken(ctx context.Context, refresh string) (string, string, int64, error) {
+// RefreshToken rotates the tokens, audience overrides the one granted at login when not empty
+func (a *Auth) RefreshToken(ctx context.Context, refresh string, audience []string) (string, string, int64, error) {
 	const op = "Auth.RefreshTokens"
 
 	claims, err := jwt.DecodeWithoutValidation(refresh)
 	if err != nil {
-		a.log.Error("failed to decode token", slog.String("op", op), sl.Err(err))
+		a.log.ErrorContext(ctx, "failed to decode token", slog.String("op", op), sl.Err(err))
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -384,13 +606,14 @@ func (a *Auth) RefreshToken(ctx context.Context, refresh string) (string, string
 
 	app, err := a.appProvider.App(ctx, appID)
 	if err != nil {
-		a.log.Error("failed to get app", slog.String("op", op), sl.Err(err))
+		a.log.ErrorContext(ctx, "failed to get app", slog.String("op", op), sl.Err(err))
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
 	validClaims, err := jwt.ValidateRefreshToken(refresh, app.Secret)
 	if err != nil {
-		a.log.Error("invalid refresh token", slog.String("op", op), sl.Err(err))
+		a.log.ErrorContext(ctx, "invalid refresh token", slog.String("op", op), sl.Err(err))
+		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
 	userID := validClaims.UserID
@@ -400,37 +623,93 @@ func (a *Auth) RefreshToken(ctx context.Context, refresh string) (string, string
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	permissions, err := a.permProvider.GetUserPermissionsAsModels(ctx, user.ID)
+	if user.Disabled() {
+		a.log.WarnContext(ctx, "disabled user tried to refresh tokens", slog.String("op", op), slog.Int64("userID", user.ID))
+		return "", "", 0, fmt.Errorf("%s: %w", op, ErrUserDisabled)
+	}
+
+	// the deletion is canceled only by logging in with the password
+	if user.DeletionScheduled() {
+		a.log.WarnContext(ctx, "user scheduled for deletion tried to refresh tokens", slog.String("op", op), slog.Int64("userID", user.ID))
+		return "", "", 0, fmt.Errorf("%s: %w", op, ErrDeletionScheduled)
+	}
+
+	permissions, err := a.permProvider.GetUserPermissionsAsModels(ctx, user.ID, app.ID)
 	if err != nil {
-		a.log.Error("failed to get user permissions", sl.Err(err))
+		a.log.ErrorContext(ctx, "failed to get user permissions", sl.Err(err))
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	newToken, err := jwt.NewToken(user, app, permissions, a.tokenTTL)
+	if len(audience) == 0 {
+		audience = validClaims.Audience
+	}
+
+	audience, err = a.tokenAudience(ctx, app, audience)
 	if err != nil {
+		a.log.WarnContext(ctx, "failed to resolve token audience", slog.String("op", op), sl.Err(err))
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	newRefresh, err := jwt.NewRefreshToken(user, app, a.refreshTTL)
+	tokenTTL := app.AccessTokenTTL(a.tokenTTL)
+	refreshTTL := app.RefreshTokenTTL(a.refreshTTL)
+
+	newToken, err := jwt.NewToken(user, app, permissions, audience, tokenTTL)
 	if err != nil {
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	expiresAt := time.Now().Add(a.tokenTTL).Unix()
+	newRefresh, err := jwt.NewRefreshToken(user, app, audience, refreshTTL)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	expiresAt := time.Now().Add(tokenTTL).Unix()
 
 	err = a.refreshSaver.DeleteRefresh(ctx, refresh)
 	if err != nil {
-		a.log.Warn("failed to delete refresh token", slog.String("op", op), sl.Err(err))
+		a.log.WarnContext(ctx, "failed to delete refresh token", slog.String("op", op), sl.Err(err))
 	}
 
-	err = a.refreshSaver.SaveRefresh(ctx, newRefresh, user.ID, app.ID, time.Now().Add(a.refreshTTL))
+	err = a.refreshSaver.SaveRefresh(ctx, newRefresh, user.ID, app.ID, time.Now().Add(refreshTTL))
 	if err != nil {
 		return "", "", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
+	metrics.TokensRefreshed.Inc()
+
 	return newToken, newRefresh, expiresAt, nil
 }
 
+// tokenAudience resolves the audience of issued tokens: requested resource services
+// (must be registered apps) or the app default, sso itself is always included
+func (a *Auth) tokenAudience(ctx context.Context, app models.App, requested []string) ([]string, error) {
+	audience := []string{app.DefaultAudience()}
+
+	if len(requested) > 0 {
+		audience = make([]string, 0, len(requested)+1)
+		for _, aud := range requested {
+			if aud == a.audience {
+				continue
+			}
+
+			if _, err := a.appProvider.AppByAudience(ctx, aud); err != nil {
+				if errors.Is(err, storage.ErrAppNotFound) {
+					return nil, fmt.Errorf("%w: %s", ErrInvalidAudience, aud)
+				}
+				return nil, err
+			}
+
+			audience = append(audience, aud)
+		}
+	}
+
+	if a.audience != "" && !slices.Contains(audience, a.audience) {
+		audience = append(audience, a.audience)
+	}
+
+	return audience, nil
+}
+
 func (a *Auth) GetAppSecret(ctx context.Context, appID int32) (string, error) {
 	const op = "Auth.GetAppSecret"
 	app, err := a.appProvider.App(ctx, appID)
@@ -457,49 +736,88 @@ func (a *Auth) ForgotPassword(ctx context.Context, email string, appID int32) (b
 		slog.Int("appID", int(appID)),
 	)
 
-	log.Info("processing forgot password request")
+	log.InfoContext(ctx, "processing forgot password request")
 
 	// Проверяем существование пользователя
 	user, err := a.usrProvider.UserByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
-			log.Warn("user not found for password reset")
+			log.WarnContext(ctx, "user not found for password reset")
+			a.audit.RecordFailure(ctx, models.AuditEvent{Type: audit.TypePasswordResetRequested, AppID: appID, Payload: map[string]any{"email_hash": audit.HashEmail(email)}}, err)
 			// Возвращаем успех для безопасности (не раскрываем существование email)
 			return true, "If this email is registered, you will receive a reset link", 0, nil
 		}
-		log.Error("failed to get user", sl.Err(err))
+		log.ErrorContext(ctx, "failed to get user", sl.Err(err))
 		return false, "Failed to process request", 0, fmt.Errorf("%s: %w", op, err)
 	}
 
+	expiresAt, err := a.SendPasswordReset(ctx, user, appID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to send password reset", sl.Err(err))
+		return false, "Failed to send reset email", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.audit.Record(ctx, models.AuditEvent{Type: audit.TypePasswordResetRequested, TargetUserID: user.ID, AppID: appID})
+
+	log.InfoContext(ctx, "password reset email queued")
+
+	return true, "Password reset email sent successfully", expiresAt.Unix(), nil
+}
+
+// SendPasswordReset issues a reset token of the user and emails the reset link branded for the app,
+// zero appID sends the sso one
+func (a *Auth) SendPasswordReset(ctx context.Context, user models.User, appID int32) (time.Time, error) {
+	const op = "Auth.SendPasswordReset"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", user.ID),
+	)
+
 	// Генерируем токен сброса
 	resetToken, err := a.generateResetToken()
 	if err != nil {
-		log.Error("failed to generate reset token", sl.Err(err))
-		return false, "Failed to generate reset token", 0, fmt.Errorf("%s: %w", op, err)
+		log.ErrorContext(ctx, "failed to generate reset token", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	// Токен действует 1 час
 	expiresAt := time.Now().Add(1 * time.Hour)
 	if err := a.usrSaver.SaveResetToken(ctx, resetToken, user.ID, expiresAt); err != nil {
-		log.Error("failed to save reset token", sl.Err(err))
-		return false, "Failed to save reset token", 0, fmt.Errorf("%s: %w", op, err)
+		log.ErrorContext(ctx, "failed to save reset token", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("attempting to send password reset email",
+	log.InfoContext(ctx, "attempting to send password reset email",
 		slog.String("toEmail", user.Email),
-		slog.String("toName", user.Name),
+		slog.Any("toName", sl.Sensitive(user.Name)),
 		slog.String("baseURL", a.baseURL),
 	)
 
 	// Отправляем email
-	if err := a.emailClient.SendResetPasswordEmail(ctx, user.Email, user.Name, resetToken, a.baseURL); err != nil {
-		log.Error("failed to send password reset email", sl.Err(err))
-		return false, "Failed to send reset email", 0, fmt.Errorf("%s: %w", op, err)
+	app := a.emailApp(ctx, log, appID)
+	if err := a.emailClient.SendResetPasswordEmail(ctx, user.Email, user.Name, user.Locale, app, resetToken, a.baseURL); err != nil {
+		log.ErrorContext(ctx, "failed to send password reset email", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("password reset email sent successfully")
+	return expiresAt, nil
+}
 
-	return true, "Password reset email sent successfully", expiresAt.Unix(), nil
+// emailApp returns the app the email is branded for, an unknown app gets the sso defaults
+// instead of failing the email
+func (a *Auth) emailApp(ctx context.Context, log *slog.Logger, appID int32) models.App {
+	if appID == 0 {
+		return models.App{}
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		log.WarnContext(ctx, "failed to get app of the email, sending the default one", sl.Err(err))
+		return models.App{}
+	}
+
+	return app
 }
 
 func (a *Auth) ResetPassword(ctx context.Context, token string, newPassword string) (bool, string, error) {
@@ -509,7 +827,7 @@ func (a *Auth) ResetPassword(ctx context.Context, token string, newPassword stri
 		slog.String("op", op),
 	)
 
-	log.Info("processing password reset request")
+	log.InfoContext(ctx, "processing password reset request")
 
 	if token == "" {
 		return false, "Reset token is required", fmt.Errorf("%s: empty token", op)
@@ -523,7 +841,7 @@ func (a *Auth) ResetPassword(ctx context.Context, token string, newPassword stri
 	v := validator.New()
 	validator.ValidateUserPassword(v, newPassword)
 	if !v.Valid() {
-		log.Error("invalid new password", slog.Any("errors", v.Errors))
+		log.ErrorContext(ctx, "invalid new password", slog.Any("errors", v.Errors))
 		return false, "Invalid password format", fmt.Errorf("%s: validation error", op)
 	}
 
@@ -531,33 +849,36 @@ func (a *Auth) ResetPassword(ctx context.Context, token string, newPassword stri
 	userID, err := a.usrSaver.ValidateResetToken(ctx, token)
 	if err != nil {
 		if errors.Is(err, storage.ErrTokenNotFound) {
-			log.Warn("invalid or expired reset token")
+			log.WarnContext(ctx, "invalid or expired reset token")
+			a.audit.RecordFailure(ctx, models.AuditEvent{Type: audit.TypePasswordReset}, err)
 			return false, "Invalid or expired reset token", fmt.Errorf("%s: %w", op, err)
 		}
-		log.Error("failed to get user by reset token", sl.Err(err))
+		log.ErrorContext(ctx, "failed to get user by reset token", sl.Err(err))
 		return false, "Failed to process reset request", fmt.Errorf("%s: %w", op, err)
 	}
 
 	// Хешируем новый пароль
 	passwordHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), 14)
 	if err != nil {
-		log.Error("failed to generate password hash", sl.Err(err))
+		log.ErrorContext(ctx, "failed to generate password hash", sl.Err(err))
 		return false, "Failed to process new password", fmt.Errorf("%s: %w", op, err)
 	}
 
 	// Обновляем пароль пользователя
 	if err := a.usrSaver.UpdateUserPassword(ctx, userID, passwordHash); err != nil {
-		log.Error("failed to update user password", sl.Err(err))
+		log.ErrorContext(ctx, "failed to update user password", sl.Err(err))
 		return false, "Failed to update password", fmt.Errorf("%s: %w", op, err)
 	}
 
 	// Удаляем использованный токен
 	if err := a.usrSaver.DeleteResetToken(ctx, token); err != nil {
-		log.Warn("failed to delete reset token", sl.Err(err))
+		log.WarnContext(ctx, "failed to delete reset token", sl.Err(err))
 		// Не возвращаем ошибку, т.к. пароль уже обновлен
 	}
 
-	log.Info("password reset successfully", slog.Int64("userID", userID))
+	a.audit.Record(ctx, models.AuditEvent{Type: audit.TypePasswordReset, ActorID: userID, TargetUserID: userID})
+
+	log.InfoContext(ctx, "password reset successfully", slog.Int64("userID", userID))
 
 	return true, "Password reset successfully", nil
 }
@@ -0,0 +1,273 @@
+package emailqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/audit"
+	"sso/internal/lib/backoff"
+	"sso/internal/lib/mailer"
+	"sso/internal/lib/metrics"
+	"sso/pkg/logger/sl"
+	"sso/pkg/tracing"
+	"sync"
+	"time"
+)
+
+var ErrInvalidInput = errors.New("invalid email queue input")
+
+const (
+	// minRetryDelay and maxRetryDelay bound the exponential backoff of the failed emails
+	minRetryDelay = 30 * time.Second
+	maxRetryDelay = time.Hour
+
+	// lease keeps the claimed emails from the other instances while they are sent
+	lease = 5 * time.Minute
+)
+
+type Repository interface {
+	EnqueueEmail(ctx context.Context, toEmail string, subject string, message []byte) (int64, error)
+	ClaimEmails(ctx context.Context, limit int32, lease time.Duration) ([]models.QueuedEmail, error)
+	MarkEmailSent(ctx context.Context, id int64) error
+	MarkEmailFailed(ctx context.Context, id int64, reason string, nextAttempt time.Time) error
+	EmailQueueStats(ctx context.Context) (models.EmailQueueStats, error)
+	DeleteFinishedEmails(ctx context.Context, sentBefore, deadBefore time.Time) (int64, error)
+	RequeueDeadEmails(ctx context.Context, ids []int64) (int64, error)
+}
+
+// AuditSink is the audit.Recorder
+type AuditSink interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+// Queue is the email sender used by the requests: Send stores the message in the email_outbox
+// and returns, the workers started by Run send it through the real sender with retries
+type Queue struct {
+	log           *slog.Logger
+	repo          Repository
+	sender        mailer.EmailSender
+	audit         AuditSink
+	workers       int
+	maxAttempts   int32
+	retention     time.Duration
+	deadRetention time.Duration
+	wake          chan struct{}
+}
+
+// New creates the queue, sent emails are deleted after retention and dead ones after deadRetention
+func New(
+	log *slog.Logger,
+	repo Repository,
+	sender mailer.EmailSender,
+	audit AuditSink,
+	workers int,
+	maxAttempts int32,
+	retention time.Duration,
+	deadRetention time.Duration,
+) *Queue {
+	return &Queue{
+		log:           log,
+		repo:          repo,
+		sender:        sender,
+		audit:         audit,
+		workers:       max(workers, 1),
+		maxAttempts:   maxAttempts,
+		retention:     retention,
+		deadRetention: deadRetention,
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// Send enqueues the message
+func (q *Queue) Send(ctx context.Context, msg mailer.Message) error {
+	const op = "Queue.Send"
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := q.repo.EnqueueEmail(ctx, msg.To.Email, msg.Subject, payload)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	q.log.DebugContext(ctx, "email queued", slog.String("op", op), slog.Int64("emailID", id))
+
+	q.notify()
+
+	return nil
+}
+
+// Requeue sends the dead emails again with fresh attempts, all of them for empty ids,
+// returns the number of requeued emails
+func (q *Queue) Requeue(ctx context.Context, actorID int64, ids []int64) (int64, error) {
+	const op = "Queue.Requeue"
+
+	log := q.log.With(
+		slog.String("op", op),
+		slog.Int64("actorID", actorID),
+	)
+
+	for _, id := range ids {
+		if id <= 0 {
+			return 0, fmt.Errorf("%s: %w", op, ErrInvalidInput)
+		}
+	}
+
+	requeued, err := q.repo.RequeueDeadEmails(ctx, ids)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to requeue dead emails", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	q.audit.Record(ctx, models.AuditEvent{
+		Type:    audit.TypeEmailRequeue,
+		ActorID: actorID,
+		Payload: map[string]any{
+			"email_ids": ids,
+			"requeued":  requeued,
+		},
+	})
+
+	if requeued > 0 {
+		q.notify()
+	}
+
+	log.InfoContext(ctx, "dead emails requeued", slog.Int64("count", requeued))
+	return requeued, nil
+}
+
+// notify wakes up the workers
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Stats returns the depth of the queue
+func (q *Queue) Stats(ctx context.Context) (models.EmailQueueStats, error) {
+	const op = "Queue.Stats"
+
+	stats, err := q.repo.EmailQueueStats(ctx)
+	if err != nil {
+		q.log.ErrorContext(ctx, "failed to get email queue stats", slog.String("op", op), sl.Err(err))
+		return models.EmailQueueStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+// Run sends the due emails every interval until the context is canceled
+func (q *Queue) Run(ctx context.Context, interval time.Duration) {
+	const op = "Queue.Run"
+
+	log := q.log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// a full batch means there is more waiting
+		for ctx.Err() == nil {
+			n, err := q.Process(ctx)
+			if err != nil {
+				log.ErrorContext(ctx, "failed to process email queue", sl.Err(err))
+				break
+			}
+			if n < q.workers {
+				break
+			}
+		}
+
+		now := time.Now()
+		if deleted, err := q.repo.DeleteFinishedEmails(ctx, now.Add(-q.retention), now.Add(-q.deadRetention)); err != nil {
+			log.ErrorContext(ctx, "failed to delete finished emails", sl.Err(err))
+		} else if deleted > 0 {
+			log.DebugContext(ctx, "finished emails deleted", slog.Int64("count", deleted))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// Process sends one batch of the due emails, one per worker, returns the number of handled emails
+func (q *Queue) Process(ctx context.Context) (int, error) {
+	const op = "Queue.Process"
+
+	emails, err := q.repo.ClaimEmails(ctx, int32(q.workers), lease)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var wg sync.WaitGroup
+	for _, email := range emails {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.send(ctx, email)
+		}()
+	}
+	wg.Wait()
+
+	return len(emails), nil
+}
+
+// send sends the email and records the result
+func (q *Queue) send(ctx context.Context, email models.QueuedEmail) {
+	const op = "Queue.send"
+
+	log := q.log.With(
+		slog.String("op", op),
+		slog.Int64("emailID", email.ID),
+		slog.String("subject", email.Subject),
+	)
+
+	var msg mailer.Message
+	err := json.Unmarshal(email.Message, &msg)
+	if err == nil {
+		// the SMTP span joins the trace of the request that sent the email
+		err = q.sender.Send(tracing.Extract(ctx, msg.Trace), msg)
+	}
+
+	if err == nil {
+		metrics.EmailsSent.Inc()
+		if err := q.repo.MarkEmailSent(ctx, email.ID); err != nil {
+			log.ErrorContext(ctx, "failed to mark email sent", sl.Err(err))
+		}
+		return
+	}
+
+	attempts := email.Attempts + 1
+
+	// zero next attempt dead-letters the email
+	var nextAttempt time.Time
+	if attempts < q.maxAttempts && !mailer.IsPermanent(err) {
+		nextAttempt = time.Now().Add(backoff.Delay(email.Attempts, minRetryDelay, maxRetryDelay))
+	}
+
+	if nextAttempt.IsZero() {
+		metrics.EmailsFailed.WithLabelValues(metrics.OutcomeDead).Inc()
+		log.ErrorContext(ctx, "email dead-lettered", slog.Int("attempts", int(attempts)), sl.Err(err))
+	} else {
+		metrics.EmailsFailed.WithLabelValues(metrics.OutcomeRetry).Inc()
+		log.WarnContext(ctx, "failed to send email",
+			slog.Int("attempts", int(attempts)),
+			slog.Time("nextAttempt", nextAttempt),
+			sl.Err(err),
+		)
+	}
+
+	if err := q.repo.MarkEmailFailed(ctx, email.ID, err.Error(), nextAttempt); err != nil {
+		log.ErrorContext(ctx, "failed to mark email failed", sl.Err(err))
+	}
+}
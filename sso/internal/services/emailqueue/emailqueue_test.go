@@ -0,0 +1,187 @@
+package emailqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sso/internal/domain/models"
+	"sso/internal/lib/audit"
+	"sso/internal/lib/mailer"
+)
+
+const maxAttempts = 3
+
+type memoryRepo struct {
+	mu       sync.Mutex
+	emails   []models.QueuedEmail
+	sent     []int64
+	failed   map[int64]time.Time
+	requeued [][]int64
+}
+
+func (r *memoryRepo) EnqueueEmail(_ context.Context, toEmail string, subject string, message []byte) (int64, error) {
+	id := int64(len(r.emails) + 1)
+	r.emails = append(r.emails, models.QueuedEmail{ID: id, ToEmail: toEmail, Subject: subject, Message: message})
+	return id, nil
+}
+
+func (r *memoryRepo) ClaimEmails(_ context.Context, limit int32, _ time.Duration) ([]models.QueuedEmail, error) {
+	n := min(int(limit), len(r.emails))
+	claimed := r.emails[:n]
+	r.emails = r.emails[n:]
+	return claimed, nil
+}
+
+func (r *memoryRepo) MarkEmailSent(_ context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sent = append(r.sent, id)
+	return nil
+}
+
+func (r *memoryRepo) MarkEmailFailed(_ context.Context, id int64, _ string, nextAttempt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failed == nil {
+		r.failed = make(map[int64]time.Time)
+	}
+	r.failed[id] = nextAttempt
+	return nil
+}
+
+func (r *memoryRepo) EmailQueueStats(context.Context) (models.EmailQueueStats, error) {
+	return models.EmailQueueStats{}, nil
+}
+
+func (r *memoryRepo) DeleteFinishedEmails(context.Context, time.Time, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (r *memoryRepo) RequeueDeadEmails(_ context.Context, ids []int64) (int64, error) {
+	r.requeued = append(r.requeued, ids)
+	return 2, nil
+}
+
+type failingSender struct {
+	err error
+}
+
+func (s failingSender) Send(context.Context, mailer.Message) error {
+	return s.err
+}
+
+type memoryAudit struct {
+	events []models.AuditEvent
+}
+
+func (a *memoryAudit) Record(_ context.Context, event models.AuditEvent) {
+	a.events = append(a.events, event)
+}
+
+func newQueue(repo Repository, sender mailer.EmailSender, auditSink AuditSink) *Queue {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return New(log, repo, sender, auditSink, 2, maxAttempts, time.Hour, 24*time.Hour)
+}
+
+func queuedEmail(t *testing.T, id int64, attempts int32) models.QueuedEmail {
+	t.Helper()
+
+	msg, err := json.Marshal(mailer.Message{
+		To:      mailer.Address{Email: "user@example.com"},
+		Subject: "Verify your email",
+		HTML:    "<p>token</p>",
+	})
+	require.NoError(t, err)
+
+	return models.QueuedEmail{ID: id, ToEmail: "user@example.com", Message: msg, Attempts: attempts}
+}
+
+func TestSendThenProcess(t *testing.T) {
+	repo := &memoryRepo{}
+	sender := mailer.NewMemory()
+	queue := newQueue(repo, sender, &memoryAudit{})
+
+	err := queue.Send(context.Background(), mailer.Message{
+		To:      mailer.Address{Email: "user@example.com"},
+		Subject: "Verify your email",
+		HTML:    "<p>token</p>",
+	})
+	require.NoError(t, err)
+	assert.Empty(t, sender.Messages(), "the request only enqueues the email")
+
+	n, err := queue.Process(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	msg, ok := sender.Last("user@example.com")
+	require.True(t, ok)
+	assert.Equal(t, "Verify your email", msg.Subject)
+	assert.Equal(t, []int64{1}, repo.sent)
+	assert.Empty(t, repo.failed)
+}
+
+func TestProcess_RetryWithBackoff(t *testing.T) {
+	repo := &memoryRepo{emails: []models.QueuedEmail{queuedEmail(t, 1, 1)}}
+	queue := newQueue(repo, failingSender{err: errors.New("connection refused")}, &memoryAudit{})
+
+	before := time.Now()
+	_, err := queue.Process(context.Background())
+	require.NoError(t, err)
+
+	assert.Empty(t, repo.sent)
+	require.Contains(t, repo.failed, int64(1))
+	assert.WithinDuration(t, before.Add(2*minRetryDelay), repo.failed[1], time.Second)
+}
+
+func TestProcess_DeadLetters(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		attempts int32
+	}{
+		{name: "permanent", err: &mailer.PermanentError{Err: errors.New("550 no such user")}},
+		{name: "out of attempts", err: errors.New("connection refused"), attempts: maxAttempts - 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &memoryRepo{emails: []models.QueuedEmail{queuedEmail(t, 1, tt.attempts)}}
+
+			_, err := newQueue(repo, failingSender{err: tt.err}, &memoryAudit{}).Process(context.Background())
+			require.NoError(t, err)
+
+			require.Contains(t, repo.failed, int64(1))
+			assert.True(t, repo.failed[1].IsZero(), "zero next attempt dead-letters the email")
+		})
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	repo := &memoryRepo{}
+	auditSink := &memoryAudit{}
+	queue := newQueue(repo, mailer.NewMemory(), auditSink)
+
+	_, err := queue.Requeue(context.Background(), 9, []int64{1, 0})
+	require.ErrorIs(t, err, ErrInvalidInput)
+	assert.Empty(t, repo.requeued)
+
+	requeued, err := queue.Requeue(context.Background(), 9, []int64{4, 5})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), requeued)
+	assert.Equal(t, [][]int64{{4, 5}}, repo.requeued)
+
+	require.Len(t, auditSink.events, 1)
+	assert.Equal(t, audit.TypeEmailRequeue, auditSink.events[0].Type)
+	assert.Equal(t, int64(9), auditSink.events[0].ActorID)
+}
@@ -0,0 +1,383 @@
+package export
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/audit"
+	"sso/internal/lib/jwt"
+	"sso/internal/storage"
+	"sso/pkg/logger/sl"
+	"time"
+
+	profilev1 "github.com/m4rk1sov/protos/gen/go/profile"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var (
+	ErrExportNotFound = errors.New("data export not found")
+	ErrUserNotFound   = errors.New("user not found")
+)
+
+const (
+	// staleAfter is the time after which a running export is considered abandoned and built again
+	staleAfter = 15 * time.Minute
+	// auditPageSize is the number of audit events read at once
+	auditPageSize = 500
+	// profileTokenTTL is the lifetime of the token used to read the profile of the user
+	profileTokenTTL = time.Minute
+)
+
+// Repository stores the exports and provides the data of the user
+type Repository interface {
+	CreateDataExport(ctx context.Context, userID int64) (models.DataExport, error)
+	DataExport(ctx context.Context, id int64, userID int64) (models.DataExport, error)
+	ConsumeDataExport(ctx context.Context, tokenHash string) (models.DataExport, error)
+	ClaimDataExport(ctx context.Context, staleAfter time.Duration) (models.DataExport, error)
+	CompleteDataExport(ctx context.Context, id int64, archive []byte, tokenHash string, expiresAt time.Time) error
+	FailDataExport(ctx context.Context, id int64, reason string) error
+	DeleteExpiredDataExports(ctx context.Context) (int64, error)
+
+	UserByID(ctx context.Context, userID int64) (models.User, error)
+	UserSessions(ctx context.Context, userID int64) ([]models.Session, error)
+	AuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error)
+}
+
+type AppProvider interface {
+	App(ctx context.Context, appID int32) (models.App, error)
+}
+
+type PermProvider interface {
+	GetUserPermissionsAsModels(ctx context.Context, userID int64, appID int32) ([]models.Permission, error)
+}
+
+// ProfileProvider reads the profile of the user from the profile service
+type ProfileProvider interface {
+	UserProfile(ctx context.Context, token string, userID int64) (*profilev1.UserProfile, error)
+}
+
+// Notifier emails the download link to the user
+type Notifier interface {
+	SendDataExportReadyEmail(ctx context.Context, toEmail, toName, locale, downloadURL string, expiresAt time.Time) error
+}
+
+// AuditSink is the audit.Recorder
+type AuditSink interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+// Archive is the JSON document given to the user, secrets like password hashes and tokens are never included
+type Archive struct {
+	GeneratedAt time.Time           `json:"generated_at"`
+	User        models.User         `json:"user"`
+	Permissions []models.Permission `json:"permissions"`
+	Sessions    []models.Session    `json:"sessions"`
+	AuditEvents []models.AuditEvent `json:"audit_events"`
+	Profile     json.RawMessage     `json:"profile"` // null if the user has no profile
+}
+
+type Export struct {
+	log             *slog.Logger
+	repo            Repository
+	apps            AppProvider
+	permProvider    PermProvider
+	profiles        ProfileProvider
+	notifier        Notifier
+	audit           AuditSink
+	baseURL         string
+	ttl             time.Duration
+	profileAppID    int32
+	profileAudience string
+	wake            chan struct{}
+}
+
+func New(
+	log *slog.Logger,
+	repo Repository,
+	apps AppProvider,
+	permProvider PermProvider,
+	profiles ProfileProvider,
+	notifier Notifier,
+	audit AuditSink,
+	baseURL string,
+	ttl time.Duration,
+	profileAppID int32,
+	profileAudience string,
+) *Export {
+	return &Export{
+		log:             log,
+		repo:            repo,
+		apps:            apps,
+		permProvider:    permProvider,
+		profiles:        profiles,
+		notifier:        notifier,
+		audit:           audit,
+		baseURL:         baseURL,
+		ttl:             ttl,
+		profileAppID:    profileAppID,
+		profileAudience: profileAudience,
+		wake:            make(chan struct{}, 1),
+	}
+}
+
+// RequestExport queues the export of the personal data of the user, the link is emailed when it is ready,
+// an export already in progress is returned instead of a new one
+func (e *Export) RequestExport(ctx context.Context, userID int64) (models.DataExport, error) {
+	const op = "Export.RequestExport"
+
+	log := e.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", userID),
+	)
+
+	log.InfoContext(ctx, "requesting data export")
+
+	export, err := e.repo.CreateDataExport(ctx, userID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to create data export", sl.Err(err))
+		return models.DataExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	e.audit.Record(ctx, models.AuditEvent{
+		Type:         audit.TypeDataExportRequest,
+		ActorID:      userID,
+		TargetUserID: userID,
+		Payload:      map[string]any{"export_id": export.ID},
+	})
+
+	// the worker is woken up so the user does not wait for the next tick
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+
+	log.InfoContext(ctx, "data export queued", slog.Int64("exportID", export.ID))
+	return export, nil
+}
+
+// DataExport returns the state of the export of the user
+func (e *Export) DataExport(ctx context.Context, userID int64, exportID int64) (models.DataExport, error) {
+	const op = "Export.DataExport"
+
+	export, err := e.repo.DataExport(ctx, exportID, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrDataExportNotFound) {
+			return models.DataExport{}, fmt.Errorf("%s: %w", op, ErrExportNotFound)
+		}
+		e.log.ErrorContext(ctx, "failed to get data export", slog.String("op", op), sl.Err(err))
+		return models.DataExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return export, nil
+}
+
+// Download returns the archive by the token of the emailed link, the link works once,
+// the token travels in the URL and may end up in the logs of proxies and browsers
+func (e *Export) Download(ctx context.Context, token string) (models.DataExport, error) {
+	const op = "Export.Download"
+
+	export, err := e.repo.ConsumeDataExport(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrDataExportNotFound) {
+			return models.DataExport{}, fmt.Errorf("%s: %w", op, ErrExportNotFound)
+		}
+		e.log.ErrorContext(ctx, "failed to get data export", slog.String("op", op), sl.Err(err))
+		return models.DataExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	e.audit.Record(ctx, models.AuditEvent{
+		Type:         audit.TypeDataExportDownload,
+		TargetUserID: export.UserID,
+		Payload:      map[string]any{"export_id": export.ID},
+	})
+
+	return export, nil
+}
+
+// Run builds the queued exports and deletes the expired ones, it checks the queue every interval
+// and right after a new request, until the context is canceled
+func (e *Export) Run(ctx context.Context, interval time.Duration) {
+	const op = "Export.Run"
+
+	log := e.log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		e.processQueue(ctx)
+
+		if deleted, err := e.repo.DeleteExpiredDataExports(ctx); err != nil {
+			log.ErrorContext(ctx, "failed to delete expired data exports", sl.Err(err))
+		} else if deleted > 0 {
+			log.InfoContext(ctx, "expired data exports deleted", slog.Int64("count", deleted))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-e.wake:
+		}
+	}
+}
+
+// processQueue builds the exports until the queue is empty
+func (e *Export) processQueue(ctx context.Context) {
+	const op = "Export.processQueue"
+
+	log := e.log.With(slog.String("op", op))
+
+	for ctx.Err() == nil {
+		export, err := e.repo.ClaimDataExport(ctx, staleAfter)
+		if err != nil {
+			if !errors.Is(err, storage.ErrDataExportNotFound) {
+				log.ErrorContext(ctx, "failed to claim data export", sl.Err(err))
+			}
+			return
+		}
+
+		if err := e.process(ctx, export); err != nil {
+			log.ErrorContext(ctx, "failed to build data export", slog.Int64("exportID", export.ID), sl.Err(err))
+
+			if err := e.repo.FailDataExport(ctx, export.ID, err.Error()); err != nil {
+				log.ErrorContext(ctx, "failed to mark data export as failed", sl.Err(err))
+			}
+		}
+	}
+}
+
+// process builds the archive, stores it and emails the download link
+func (e *Export) process(ctx context.Context, export models.DataExport) error {
+	const op = "Export.process"
+
+	log := e.log.With(
+		slog.String("op", op),
+		slog.Int64("exportID", export.ID),
+		slog.Int64("userID", export.UserID),
+	)
+
+	user, err := e.repo.UserByID(ctx, export.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	archive, err := e.build(ctx, user)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	expiresAt := time.Now().Add(e.ttl)
+
+	if err := e.repo.CompleteDataExport(ctx, export.ID, data, hashToken(token), expiresAt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	downloadURL := fmt.Sprintf("%s/v1/account/exports/download/%s", e.baseURL, token)
+
+	if err := e.notifier.SendDataExportReadyEmail(ctx, user.Email, user.Name, user.Locale, downloadURL, expiresAt); err != nil {
+		// the token is only known here, so the export can't be downloaded without the email
+		log.ErrorContext(ctx, "failed to send data export email", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.InfoContext(ctx, "data export ready", slog.Int("bytes", len(data)))
+	return nil
+}
+
+// build gathers the data of the user from sso and the profile service
+func (e *Export) build(ctx context.Context, user models.User) (Archive, error) {
+	const op = "Export.build"
+
+	archive := Archive{
+		GeneratedAt: time.Now().UTC(),
+		User:        user,
+	}
+
+	permissions, err := e.permProvider.GetUserPermissionsAsModels(ctx, user.ID, 0)
+	if err != nil {
+		return Archive{}, fmt.Errorf("%s: %w", op, err)
+	}
+	archive.Permissions = permissions
+
+	sessions, err := e.repo.UserSessions(ctx, user.ID)
+	if err != nil {
+		return Archive{}, fmt.Errorf("%s: %w", op, err)
+	}
+	archive.Sessions = sessions
+
+	for offset := int32(0); ; offset += auditPageSize {
+		events, err := e.repo.AuditEvents(ctx, models.AuditFilter{UserID: user.ID, Limit: auditPageSize, Offset: offset})
+		if err != nil {
+			return Archive{}, fmt.Errorf("%s: %w", op, err)
+		}
+		archive.AuditEvents = append(archive.AuditEvents, events...)
+
+		if len(events) < auditPageSize {
+			break
+		}
+	}
+
+	profile, err := e.profile(ctx, user)
+	if err != nil {
+		return Archive{}, fmt.Errorf("%s: %w", op, err)
+	}
+	archive.Profile = profile
+
+	return archive, nil
+}
+
+// profile reads the profile on behalf of the user, profile shows it only to its owner
+func (e *Export) profile(ctx context.Context, user models.User) (json.RawMessage, error) {
+	app, err := e.apps.App(ctx, e.profileAppID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.NewToken(user, app, nil, []string{e.profileAudience}, profileTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := e.profiles.UserProfile(ctx, token, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		return json.RawMessage("null"), nil
+	}
+
+	return protojson.MarshalOptions{UseProtoNames: true}.Marshal(profile)
+}
+
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken hashes the download token for storage, tokens are random so a plain SHA-256 is enough
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
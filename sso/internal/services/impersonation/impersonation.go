@@ -0,0 +1,177 @@
+package impersonation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/audit"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/privilege"
+	"sso/internal/storage"
+	"sso/pkg/logger/sl"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidInput   = errors.New("invalid impersonation input")
+	ErrUserNotFound   = errors.New("user not found")
+	ErrPrivilegedUser = errors.New("privileged users can't be impersonated")
+)
+
+// privilegedPermissions protect their holders from impersonation, otherwise staff could
+// gain admin access through the token, users with permissions the actor lacks are refused too
+var privilegedPermissions = []string{"admin", "staff"}
+
+type UserProvider interface {
+	UserByID(ctx context.Context, userID int64) (models.User, error)
+}
+
+type AppProvider interface {
+	App(ctx context.Context, appID int32) (models.App, error)
+}
+
+type PermProvider interface {
+	GetUserPermissionsAsModels(ctx context.Context, userID int64, appID int32) ([]models.Permission, error)
+}
+
+// AuditLogger stores audit events, unlike the other services impersonation fails if the event is not stored
+type AuditLogger interface {
+	SaveAuditEvent(ctx context.Context, event models.AuditEvent) error
+}
+
+type Impersonation struct {
+	log          *slog.Logger
+	usrProvider  UserProvider
+	appProvider  AppProvider
+	permProvider PermProvider
+	audit        AuditLogger
+	tokenTTL     time.Duration
+	audience     string
+}
+
+func New(
+	log *slog.Logger,
+	userProvider UserProvider,
+	appProvider AppProvider,
+	permProvider PermProvider,
+	audit AuditLogger,
+	tokenTTL time.Duration,
+	audience string,
+) *Impersonation {
+	return &Impersonation{
+		log:          log,
+		usrProvider:  userProvider,
+		appProvider:  appProvider,
+		permProvider: permProvider,
+		audit:        audit,
+		tokenTTL:     tokenTTL,
+		audience:     audience,
+	}
+}
+
+// Impersonate issues access token of the user for the actor, the token is never issued without an audit entry
+func (i *Impersonation) Impersonate(
+	ctx context.Context,
+	actorID int64,
+	userID int64,
+	appID int32,
+	reason string,
+) (string, int64, error) {
+	const op = "Impersonation.Impersonate"
+
+	log := i.log.With(
+		slog.String("op", op),
+		slog.Int64("actorID", actorID),
+		slog.Int64("userID", userID),
+		slog.Int("appID", int(appID)),
+	)
+
+	log.InfoContext(ctx, "impersonating user")
+
+	if userID <= 0 || userID == actorID || strings.TrimSpace(reason) == "" {
+		return "", 0, fmt.Errorf("%s: %w", op, ErrInvalidInput)
+	}
+
+	actor, err := i.usrProvider.UserByID(ctx, actorID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to get actor", sl.Err(err))
+		return "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := i.usrProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return "", 0, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		log.ErrorContext(ctx, "failed to get user", sl.Err(err))
+		return "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := i.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return "", 0, fmt.Errorf("%s: %w", op, ErrInvalidInput)
+		}
+		log.ErrorContext(ctx, "failed to get app", sl.Err(err))
+		return "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	permissions, err := i.permProvider.GetUserPermissionsAsModels(ctx, user.ID, app.ID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to get user permissions", sl.Err(err))
+		return "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, permission := range permissions {
+		if slices.Contains(privilegedPermissions, permission.Code) {
+			log.WarnContext(ctx, "refused to impersonate privileged user", slog.String("permission", permission.Code))
+			return "", 0, fmt.Errorf("%s: %w", op, ErrPrivilegedUser)
+		}
+	}
+
+	actorPermissions, err := i.permProvider.GetUserPermissionsAsModels(ctx, actor.ID, app.ID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to get actor permissions", sl.Err(err))
+		return "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if exceeding := privilege.Exceeding(actorPermissions, permissions); len(exceeding) > 0 {
+		log.WarnContext(ctx, "refused to impersonate user with permissions the actor doesn't have", slog.Any("permissions", exceeding))
+		return "", 0, fmt.Errorf("%s: %w", op, ErrPrivilegedUser)
+	}
+
+	audience := []string{app.DefaultAudience()}
+	if i.audience != "" && !slices.Contains(audience, i.audience) {
+		audience = append(audience, i.audience)
+	}
+
+	token, err := jwt.NewImpersonationToken(user, actor, app, permissions, audience, i.tokenTTL)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to generate impersonation token", sl.Err(err))
+		return "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+	expiresAt := time.Now().Add(i.tokenTTL).Unix()
+
+	err = i.audit.SaveAuditEvent(ctx, audit.WithRequest(ctx, models.AuditEvent{
+		Type:         audit.TypeImpersonate,
+		ActorID:      actorID,
+		TargetUserID: userID,
+		AppID:        app.ID,
+		Payload: map[string]any{
+			"reason":     reason,
+			"expires_at": expiresAt,
+		},
+	}))
+	if err != nil {
+		log.ErrorContext(ctx, "failed to write audit log", sl.Err(err))
+		return "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.WarnContext(ctx, "impersonation token issued", slog.Int64("expiresAt", expiresAt))
+
+	return token, expiresAt, nil
+}
@@ -0,0 +1,140 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/api/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/backoff"
+	"sso/pkg/logger/sl"
+	"time"
+)
+
+const (
+	// minRetryDelay and maxRetryDelay bound the exponential backoff of the failed events
+	minRetryDelay = time.Second
+	maxRetryDelay = 10 * time.Minute
+)
+
+type Repository interface {
+	PendingOutboxEvents(ctx context.Context, limit int32) ([]models.OutboxEvent, error)
+	MarkOutboxEventPublished(ctx context.Context, id int64) error
+	MarkOutboxEventFailed(ctx context.Context, id int64, reason string, nextAttempt time.Time) error
+	DeletePublishedOutboxEvents(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Publisher delivers the events to the broker
+type Publisher interface {
+	Publish(ctx context.Context, event events.Event) error
+}
+
+// Relay delivers the events written to the outbox by the storage, delivery is at least once:
+// an event may be published again if marking it fails or several instances run the relay
+type Relay struct {
+	log       *slog.Logger
+	repo      Repository
+	publisher Publisher
+	batchSize int32
+	retention time.Duration
+}
+
+func New(log *slog.Logger, repo Repository, publisher Publisher, batchSize int32, retention time.Duration) *Relay {
+	return &Relay{
+		log:       log,
+		repo:      repo,
+		publisher: publisher,
+		batchSize: batchSize,
+		retention: retention,
+	}
+}
+
+// Run relays the events every interval until the context is canceled
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	const op = "Relay.Run"
+
+	log := r.log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// a full batch means there is more waiting
+		for ctx.Err() == nil {
+			n, err := r.Relay(ctx)
+			if err != nil {
+				log.ErrorContext(ctx, "failed to relay events", sl.Err(err))
+				break
+			}
+			if n < int(r.batchSize) {
+				break
+			}
+		}
+
+		if deleted, err := r.repo.DeletePublishedOutboxEvents(ctx, time.Now().Add(-r.retention)); err != nil {
+			log.ErrorContext(ctx, "failed to delete published events", sl.Err(err))
+		} else if deleted > 0 {
+			log.DebugContext(ctx, "published events deleted", slog.Int64("count", deleted))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Relay publishes one batch of the pending events, returns the number of handled events,
+// after a failure the later events of the same user wait for the retry to keep their order
+func (r *Relay) Relay(ctx context.Context) (int, error) {
+	const op = "Relay.Relay"
+
+	log := r.log.With(slog.String("op", op))
+
+	pending, err := r.repo.PendingOutboxEvents(ctx, r.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	failedUsers := make(map[int64]bool)
+
+	for _, e := range pending {
+		if failedUsers[e.UserID] {
+			continue
+		}
+
+		event := events.Event{
+			ID:         e.ID,
+			Type:       e.Type,
+			UserID:     e.UserID,
+			Payload:    e.Payload,
+			OccurredAt: e.CreatedAt,
+			AppIDs:     e.AppIDs,
+		}
+
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			failedUsers[e.UserID] = true
+
+			nextAttempt := time.Now().Add(backoff.Delay(e.Attempts, minRetryDelay, maxRetryDelay))
+			log.WarnContext(ctx, "failed to publish event",
+				slog.Int64("eventID", e.ID),
+				slog.String("type", e.Type),
+				slog.Int("attempts", int(e.Attempts)+1),
+				slog.Time("nextAttempt", nextAttempt),
+				sl.Err(err),
+			)
+
+			if err := r.repo.MarkOutboxEventFailed(ctx, e.ID, err.Error(), nextAttempt); err != nil {
+				return 0, fmt.Errorf("%s: %w", op, err)
+			}
+			continue
+		}
+
+		if err := r.repo.MarkOutboxEventPublished(ctx, e.ID); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return len(pending), nil
+}
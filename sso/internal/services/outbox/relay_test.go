@@ -0,0 +1,112 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sso/api/events"
+	"sso/internal/domain/models"
+	"sso/internal/lib/publisher"
+)
+
+type memoryRepo struct {
+	pending   []models.OutboxEvent
+	published []int64
+	failed    map[int64]time.Time
+}
+
+func (r *memoryRepo) PendingOutboxEvents(_ context.Context, limit int32) ([]models.OutboxEvent, error) {
+	return r.pending[:min(int(limit), len(r.pending))], nil
+}
+
+func (r *memoryRepo) MarkOutboxEventPublished(_ context.Context, id int64) error {
+	r.published = append(r.published, id)
+	return nil
+}
+
+func (r *memoryRepo) MarkOutboxEventFailed(_ context.Context, id int64, _ string, nextAttempt time.Time) error {
+	if r.failed == nil {
+		r.failed = make(map[int64]time.Time)
+	}
+	r.failed[id] = nextAttempt
+	return nil
+}
+
+func (r *memoryRepo) DeletePublishedOutboxEvents(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func newRelay(repo Repository, pub Publisher) *Relay {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), repo, pub, 10, time.Hour)
+}
+
+func TestRelay_PublishesAndMarks(t *testing.T) {
+	repo := &memoryRepo{pending: []models.OutboxEvent{
+		{ID: 1, Type: events.TypeUserRegistered, UserID: 7, Payload: []byte(`{}`)},
+		{ID: 2, Type: events.TypeUserVerified, UserID: 7, Payload: []byte(`{}`)},
+	}}
+	pub := publisher.NewMemory()
+
+	n, err := newRelay(repo, pub).Relay(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	published := pub.Events()
+	require.Len(t, published, 2)
+	assert.Equal(t, int64(1), published[0].ID)
+	assert.Equal(t, events.TypeUserRegistered, published[0].Type)
+	assert.Equal(t, int64(2), published[1].ID)
+	assert.Equal(t, []int64{1, 2}, repo.published)
+	assert.Empty(t, repo.failed)
+}
+
+func TestRelay_FailureKeepsUserOrder(t *testing.T) {
+	repo := &memoryRepo{pending: []models.OutboxEvent{
+		{ID: 1, Type: events.TypeUserRegistered, UserID: 7, Attempts: 2},
+		{ID: 2, Type: events.TypeUserVerified, UserID: 7},
+	}}
+	pub := publisher.NewMemory()
+	pub.FailWith(errors.New("broker is down"))
+
+	before := time.Now()
+	_, err := newRelay(repo, pub).Relay(context.Background())
+	require.NoError(t, err)
+
+	assert.Empty(t, pub.Events())
+	assert.Empty(t, repo.published)
+	// the second event of the user waits for the first one
+	require.Len(t, repo.failed, 1)
+	assert.WithinDuration(t, before.Add(4*time.Second), repo.failed[1], time.Second)
+}
+
+func TestRelay_InProcessWithoutSubscribers(t *testing.T) {
+	repo := &memoryRepo{pending: []models.OutboxEvent{
+		{ID: 1, Type: events.TypeUserRegistered, UserID: 7},
+	}}
+	pub := publisher.NewInProcess(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	_, err := newRelay(repo, pub).Relay(context.Background())
+	require.NoError(t, err)
+
+	assert.Empty(t, repo.published)
+	assert.Contains(t, repo.failed, int64(1))
+
+	var handled []int64
+	pub.Subscribe(events.TypeUserRegistered, func(_ context.Context, event events.Event) error {
+		handled = append(handled, event.ID)
+		return nil
+	})
+
+	_, err = newRelay(repo, pub).Relay(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []int64{1}, handled)
+	assert.Equal(t, []int64{1}, repo.published)
+}
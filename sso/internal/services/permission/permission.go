@@ -6,28 +6,55 @@ import (
 	"fmt"
 	"log/slog"
 	"sso/internal/domain/models"
-	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/audit"
+	"sso/internal/lib/privilege"
 	"sso/internal/services/user"
 	"sso/internal/storage"
+	"sso/pkg/logger/sl"
 )
 
 var (
 	ErrInvalidInput       = errors.New("input is not found (permission)")
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrPermissionDenied   = errors.New("permission denied")
+	ErrRoleNotFound       = errors.New("role not found")
+	ErrPermissionNotFound = errors.New("permission not found")
 )
 
+const defaultUsersLimit = 50
+
+// PermissionRepository resolves the effective permissions, granted directly or through roles
+// appID scopes role assignments, 0 means only roles assigned for every app
 type PermissionRepository interface {
 	GetUserPermissions(ctx context.Context, userID int64) ([]string, error)
-	UserPermissions(ctx context.Context, userID int64) ([]models.Permission, error)
-	HasUserPermission(ctx context.Context, userID int64, permission string) (bool, error)
+	UserPermissions(ctx context.Context, userID int64, appID int32) ([]models.Permission, error)
+	HasUserPermission(ctx context.Context, userID int64, appID int32, permission string) (bool, error)
 	AddUserPermission(ctx context.Context, userID int64, permissionID int64) error
+	Permissions(ctx context.Context) ([]models.Permission, error)
+	PermissionByCode(ctx context.Context, code string) (models.Permission, error)
+	GrantUserPermission(ctx context.Context, userID int64, permission models.Permission, event models.AuditEvent) (bool, error)
+	RevokeUserPermission(ctx context.Context, userID int64, permission models.Permission, event models.AuditEvent) (bool, error)
+	UsersWithPermission(ctx context.Context, permissionID int64, limit int32, offset int32) ([]models.User, error)
+}
+
+type RoleRepository interface {
+	Roles(ctx context.Context) ([]models.Role, error)
+	RoleByCode(ctx context.Context, code string) (models.Role, error)
+	AssignUserRole(ctx context.Context, userID int64, roleID int64, appID int32, event models.AuditEvent) (bool, error)
+	RemoveUserRole(ctx context.Context, userID int64, roleID int64, appID int32, event models.AuditEvent) (bool, error)
+	UserRoles(ctx context.Context, userID int64) ([]models.UserRole, error)
+}
+
+// AuditSink is the audit.Recorder
+type AuditSink interface {
+	Record(ctx context.Context, event models.AuditEvent)
+	RecordFailure(ctx context.Context, event models.AuditEvent, err error)
 }
 
 type PermProvider interface {
 	GetUserPermissions(ctx context.Context, userID int64) ([]string, error)
-	GetUserPermissionsAsModels(ctx context.Context, userID int64) ([]models.Permission, error)
-	HasUserPermission(ctx context.Context, userID int64, permission string) (bool, error)
+	GetUserPermissionsAsModels(ctx context.Context, userID int64, appID int32) ([]models.Permission, error)
+	HasUserPermission(ctx context.Context, userID int64, appID int32, permission string) (bool, error)
 	ValidateUserAccess(ctx context.Context, userID int64, requiredPermissions []string) error
 	GrantPermission(ctx context.Context, userID int64, permissionID int64) error
 }
@@ -35,15 +62,19 @@ type PermProvider interface {
 type Permission struct {
 	log      *slog.Logger
 	permRepo PermissionRepository
+	roleRepo RoleRepository
 	userRepo user.UserRepository
+	audit    AuditSink
 	//permProvider PermProvider
 }
 
-func New(log *slog.Logger, permissionRepo PermissionRepository, userRepo user.UserRepository) *Permission {
+func New(log *slog.Logger, permissionRepo PermissionRepository, roleRepo RoleRepository, userRepo user.UserRepository, audit AuditSink) *Permission {
 	return &Permission{
 		log:      log,
 		permRepo: permissionRepo,
+		roleRepo: roleRepo,
 		userRepo: userRepo,
+		audit:    audit,
 	}
 }
 
@@ -55,7 +86,7 @@ func (p *Permission) GetUserPermissions(ctx context.Context, userID int64) ([]st
 		slog.Int64("userID", userID),
 	)
 
-	log.Info("retrieving user permissions")
+	log.InfoContext(ctx, "retrieving user permissions")
 
 	if err := p.validateUserExists(ctx, userID); err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -63,46 +94,49 @@ func (p *Permission) GetUserPermissions(ctx context.Context, userID int64) ([]st
 
 	perms, err := p.permRepo.GetUserPermissions(ctx, userID)
 	if err != nil {
-		log.Error("failed to get user permissions", sl.Err(err))
+		log.ErrorContext(ctx, "failed to get user permissions", sl.Err(err))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("retrieved user permissions", slog.Int("count", len(perms)))
+	log.InfoContext(ctx, "retrieved user permissions", slog.Int("count", len(perms)))
 	return perms, nil
 }
 
-func (p *Permission) GetUserPermissionsAsModels(ctx context.Context, userID int64) ([]models.Permission, error) {
+// GetUserPermissionsAsModels returns the flattened effective permission set of user for the app
+func (p *Permission) GetUserPermissionsAsModels(ctx context.Context, userID int64, appID int32) ([]models.Permission, error) {
 	const op = "Permission.GetUserPermissionsAsModels"
 
 	log := p.log.With(
 		slog.String("op", op),
 		slog.Int64("userID", userID),
+		slog.Int("appID", int(appID)),
 	)
 
-	log.Info("retrieving user permissions as models")
+	log.InfoContext(ctx, "retrieving user permissions as models")
 
 	// Валидация пользователя
 	if err := p.validateUserExists(ctx, userID); err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	perms, err := p.permRepo.UserPermissions(ctx, userID)
+	perms, err := p.permRepo.UserPermissions(ctx, userID, appID)
 	if err != nil {
-		log.Error("failed to get user permissions", sl.Err(err))
+		log.ErrorContext(ctx, "failed to get user permissions", sl.Err(err))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("retrieved user permissions as models", slog.Int("count", len(perms)))
+	log.InfoContext(ctx, "retrieved user permissions as models", slog.Int("count", len(perms)))
 	return perms, nil
 }
 
-// HasUserPermission checks if user has permission
+// HasUserPermission checks if user has permission directly or through the roles assigned for the app
 // returns true if user has permission
 // returns false if user does not have permission
 // returns error if permission does not exist
 func (p *Permission) HasUserPermission(
 	ctx context.Context,
 	userID int64,
+	appID int32,
 	permission string,
 ) (bool, error) {
 	const op = "Permission.HasUserPermission"
@@ -113,7 +147,7 @@ func (p *Permission) HasUserPermission(
 		slog.String("permission", permission),
 	)
 
-	log.Info("checking if user has this permission")
+	log.InfoContext(ctx, "checking if user has this permission")
 
 	if permission == "" {
 		return false, fmt.Errorf("%s: %w", op, ErrInvalidInput)
@@ -123,17 +157,17 @@ func (p *Permission) HasUserPermission(
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
-	allowed, err := p.permRepo.HasUserPermission(ctx, userID, permission)
+	allowed, err := p.permRepo.HasUserPermission(ctx, userID, appID, permission)
 	if err != nil {
 		if errors.Is(err, storage.ErrPermissionNotFound) {
-			log.Warn("permission not found", sl.Err(err))
+			log.WarnContext(ctx, "permission not found", sl.Err(err))
 			return false, fmt.Errorf("%s: %w", op, ErrInvalidInput)
 		}
-		log.Error("failed to check user permission", sl.Err(err))
+		log.ErrorContext(ctx, "failed to check user permission", sl.Err(err))
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("checked the permission for user", slog.Bool("allowed", allowed))
+	log.InfoContext(ctx, "checked the permission for user", slog.Bool("allowed", allowed))
 	return allowed, nil
 }
 
@@ -155,7 +189,7 @@ func (p *Permission) ValidateUserAccess(ctx context.Context, userID int64, requi
 		slog.Any("requiredPermissions", requiredPermissions),
 	)
 
-	log.Info("validating user access")
+	log.InfoContext(ctx, "validating user access")
 
 	userPerms, err := p.GetUserPermissions(ctx, userID)
 	if err != nil {
@@ -177,11 +211,11 @@ func (p *Permission) ValidateUserAccess(ctx context.Context, userID int64, requi
 	}
 
 	if len(missingPermissions) > 0 {
-		log.Warn("user lacks required permissions", slog.Any("missing", missingPermissions))
+		log.WarnContext(ctx, "user lacks required permissions", slog.Any("missing", missingPermissions))
 		return fmt.Errorf("%s: missing permissions: %v", op, missingPermissions)
 	}
 
-	log.Info("user access validated")
+	log.InfoContext(ctx, "user access validated")
 	return nil
 }
 
@@ -195,7 +229,7 @@ func (p *Permission) GrantPermission(ctx context.Context, userID int64, permissi
 		slog.Int64("permissionID", permissionID),
 	)
 
-	log.Info("granting permission to user")
+	log.InfoContext(ctx, "granting permission to user")
 
 	if err := p.validateUserExists(ctx, userID); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
@@ -203,14 +237,354 @@ func (p *Permission) GrantPermission(ctx context.Context, userID int64, permissi
 
 	err := p.permRepo.AddUserPermission(ctx, userID, permissionID)
 	if err != nil {
-		log.Error("failed to grant permission", sl.Err(err))
+		log.ErrorContext(ctx, "failed to grant permission", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.InfoContext(ctx, "permission granted to user")
+	return nil
+}
+
+// GrantUserPermission grants permission by code to user on behalf of the actor
+// granting a permission the user already has is not an error, changed is false then,
+// the actor can't grant permissions to themselves or permissions they don't have
+func (p *Permission) GrantUserPermission(ctx context.Context, actorID int64, userID int64, code string) (bool, error) {
+	const op = "Permission.GrantUserPermission"
+
+	log := p.log.With(
+		slog.String("op", op),
+		slog.Int64("actorID", actorID),
+		slog.Int64("userID", userID),
+		slog.String("permission", code),
+	)
+
+	log.InfoContext(ctx, "granting permission to user")
+
+	event := models.AuditEvent{
+		Type:         audit.TypePermissionGrant,
+		ActorID:      actorID,
+		TargetUserID: userID,
+		Payload:      map[string]any{"permission": code},
+	}
+
+	permission, err := p.permissionForUser(ctx, userID, code)
+	if err != nil {
+		p.audit.RecordFailure(ctx, event, err)
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if actorID == userID {
+		log.WarnContext(ctx, "refused to grant permission to the actor")
+		p.audit.RecordFailure(ctx, event, ErrPermissionDenied)
+		return false, fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
+
+	if err := p.checkPrivilege(ctx, actorID, []string{code}); err != nil {
+		p.audit.RecordFailure(ctx, event, err)
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// successful grant is recorded together with the change
+	changed, err := p.permRepo.GrantUserPermission(ctx, userID, permission, audit.WithRequest(ctx, event))
+	if err != nil {
+		log.ErrorContext(ctx, "failed to grant permission", sl.Err(err))
+		p.audit.RecordFailure(ctx, event, err)
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.InfoContext(ctx, "permission granted to user", slog.Bool("changed", changed))
+	return changed, nil
+}
+
+// RevokeUserPermission revokes direct permission grant from user on behalf of the actor
+// permissions given through roles are kept, revoking a missing grant is not an error,
+// the actor can't revoke permissions they don't have
+func (p *Permission) RevokeUserPermission(ctx context.Context, actorID int64, userID int64, code string) (bool, error) {
+	const op = "Permission.RevokeUserPermission"
+
+	log := p.log.With(
+		slog.String("op", op),
+		slog.Int64("actorID", actorID),
+		slog.Int64("userID", userID),
+		slog.String("permission", code),
+	)
+
+	log.InfoContext(ctx, "revoking permission from user")
+
+	event := models.AuditEvent{
+		Type:         audit.TypePermissionRevoke,
+		ActorID:      actorID,
+		TargetUserID: userID,
+		Payload:      map[string]any{"permission": code},
+	}
+
+	permission, err := p.permissionForUser(ctx, userID, code)
+	if err != nil {
+		p.audit.RecordFailure(ctx, event, err)
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := p.checkPrivilege(ctx, actorID, []string{code}); err != nil {
+		p.audit.RecordFailure(ctx, event, err)
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	changed, err := p.permRepo.RevokeUserPermission(ctx, userID, permission, audit.WithRequest(ctx, event))
+	if err != nil {
+		log.ErrorContext(ctx, "failed to revoke permission", sl.Err(err))
+		p.audit.RecordFailure(ctx, event, err)
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.InfoContext(ctx, "permission revoked from user", slog.Bool("changed", changed))
+	return changed, nil
+}
+
+// ListPermissions returns all known permissions
+func (p *Permission) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	const op = "Permission.ListPermissions"
+
+	log := p.log.With(slog.String("op", op))
+
+	permissions, err := p.permRepo.Permissions(ctx)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to list permissions", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return permissions, nil
+}
+
+// ListUsersWithPermission returns users having the permission directly or through roles
+func (p *Permission) ListUsersWithPermission(ctx context.Context, code string, limit int32, offset int32) ([]models.User, error) {
+	const op = "Permission.ListUsersWithPermission"
+
+	log := p.log.With(
+		slog.String("op", op),
+		slog.String("permission", code),
+	)
+
+	if code == "" || offset < 0 {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidInput)
+	}
+
+	if limit <= 0 || limit > defaultUsersLimit {
+		limit = defaultUsersLimit
+	}
+
+	permission, err := p.permissionByCode(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	users, err := p.permRepo.UsersWithPermission(ctx, permission.ID, limit, offset)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to list users with permission", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+func (p *Permission) permissionForUser(ctx context.Context, userID int64, code string) (models.Permission, error) {
+	if code == "" {
+		return models.Permission{}, ErrInvalidInput
+	}
+
+	if err := p.validateUserExists(ctx, userID); err != nil {
+		return models.Permission{}, err
+	}
+
+	return p.permissionByCode(ctx, code)
+}
+
+func (p *Permission) permissionByCode(ctx context.Context, code string) (models.Permission, error) {
+	permission, err := p.permRepo.PermissionByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, storage.ErrPermissionNotFound) {
+			return models.Permission{}, ErrPermissionNotFound
+		}
+		return models.Permission{}, err
+	}
+
+	return permission, nil
+}
+
+// ListRoles returns all roles with their bundled permissions
+func (p *Permission) ListRoles(ctx context.Context) ([]models.Role, error) {
+	const op = "Permission.ListRoles"
+
+	log := p.log.With(slog.String("op", op))
+
+	roles, err := p.roleRepo.Roles(ctx)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to list roles", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// GetUserRoles returns role assignments of user
+func (p *Permission) GetUserRoles(ctx context.Context, userID int64) ([]models.UserRole, error) {
+	const op = "Permission.GetUserRoles"
+
+	log := p.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", userID),
+	)
+
+	if err := p.validateUserExists(ctx, userID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles, err := p.roleRepo.UserRoles(ctx, userID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to get user roles", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// AssignRole assigns role to user, appID 0 assigns it for every app
+// the actor can't assign roles to themselves or roles with permissions they don't have
+func (p *Permission) AssignRole(ctx context.Context, userID int64, roleCode string, appID int32) error {
+	const op = "Permission.AssignRole"
+
+	actorID := audit.ActorID(ctx)
+
+	log := p.log.With(
+		slog.String("op", op),
+		slog.Int64("actorID", actorID),
+		slog.Int64("userID", userID),
+		slog.String("role", roleCode),
+		slog.Int("appID", int(appID)),
+	)
+
+	log.InfoContext(ctx, "assigning role to user")
+
+	event := models.AuditEvent{
+		Type:         audit.TypeRoleAssign,
+		ActorID:      actorID,
+		TargetUserID: userID,
+		AppID:        appID,
+		Payload:      map[string]any{"role": roleCode},
+	}
+
+	role, err := p.roleByCode(ctx, userID, roleCode)
+	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("permission granted to user")
+	if actorID == userID {
+		log.WarnContext(ctx, "refused to assign role to the actor")
+		p.audit.RecordFailure(ctx, event, ErrPermissionDenied)
+		return fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
+
+	if err := p.checkPrivilege(ctx, actorID, role.Permissions); err != nil {
+		p.audit.RecordFailure(ctx, event, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// successful assignment is recorded together with the change
+	changed, err := p.roleRepo.AssignUserRole(ctx, userID, role.ID, appID, audit.WithRequest(ctx, event))
+	if err != nil {
+		log.ErrorContext(ctx, "failed to assign role", sl.Err(err))
+		p.audit.RecordFailure(ctx, event, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.InfoContext(ctx, "role assigned to user", slog.Bool("changed", changed))
+	return nil
+}
+
+// RevokeRole removes role assignment with the same app scope from user
+// the actor can't revoke roles with permissions they don't have
+func (p *Permission) RevokeRole(ctx context.Context, userID int64, roleCode string, appID int32) error {
+	const op = "Permission.RevokeRole"
+
+	actorID := audit.ActorID(ctx)
+
+	log := p.log.With(
+		slog.String("op", op),
+		slog.Int64("actorID", actorID),
+		slog.Int64("userID", userID),
+		slog.String("role", roleCode),
+		slog.Int("appID", int(appID)),
+	)
+
+	log.InfoContext(ctx, "revoking role from user")
+
+	event := models.AuditEvent{
+		Type:         audit.TypeRoleRevoke,
+		ActorID:      actorID,
+		TargetUserID: userID,
+		AppID:        appID,
+		Payload:      map[string]any{"role": roleCode},
+	}
+
+	role, err := p.roleByCode(ctx, userID, roleCode)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := p.checkPrivilege(ctx, actorID, role.Permissions); err != nil {
+		p.audit.RecordFailure(ctx, event, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	changed, err := p.roleRepo.RemoveUserRole(ctx, userID, role.ID, appID, audit.WithRequest(ctx, event))
+	if err != nil {
+		log.ErrorContext(ctx, "failed to revoke role", sl.Err(err))
+		p.audit.RecordFailure(ctx, event, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.InfoContext(ctx, "role revoked from user", slog.Bool("changed", changed))
+	return nil
+}
+
+// checkPrivilege refuses changes of the permissions the actor doesn't hold,
+// otherwise a permission manager could make anyone an admin
+func (p *Permission) checkPrivilege(ctx context.Context, actorID int64, codes []string) error {
+	actorPermissions, err := p.permRepo.UserPermissions(ctx, actorID, 0)
+	if err != nil {
+		return err
+	}
+
+	if lacking := privilege.Lacking(actorPermissions, codes); len(lacking) > 0 {
+		p.log.WarnContext(ctx, "refused to change permissions the actor doesn't have",
+			slog.Int64("actorID", actorID),
+			slog.Any("permissions", lacking),
+		)
+		return ErrPermissionDenied
+	}
+
 	return nil
 }
 
+func (p *Permission) roleByCode(ctx context.Context, userID int64, roleCode string) (models.Role, error) {
+	if roleCode == "" {
+		return models.Role{}, ErrInvalidInput
+	}
+
+	if err := p.validateUserExists(ctx, userID); err != nil {
+		return models.Role{}, err
+	}
+
+	role, err := p.roleRepo.RoleByCode(ctx, roleCode)
+	if err != nil {
+		if errors.Is(err, storage.ErrRoleNotFound) {
+			return models.Role{}, ErrRoleNotFound
+		}
+		return models.Role{}, err
+	}
+
+	return role, nil
+}
+
 func (p *Permission) validateUserExists(ctx context.Context, userID int64) error {
 	_, err := p.userRepo.UserByID(ctx, userID)
 	if err != nil {
@@ -0,0 +1,273 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/audit"
+	"sso/internal/lib/privilege"
+	"sso/internal/storage"
+	"sso/pkg/logger/sl"
+	"time"
+)
+
+const defaultUsersLimit = 50
+
+// AdminRepository manages the accounts on behalf of admins
+type AdminRepository interface {
+	Users(ctx context.Context, filter models.UserFilter) ([]models.User, error)
+	SetUserDisabled(ctx context.Context, userID int64, disabled bool) (bool, error)
+	LockUserPassword(ctx context.Context, userID int64, unusableHash []byte) error
+	DeleteUser(ctx context.Context, userID int64) error
+	PermissionByCode(ctx context.Context, code string) (models.Permission, error)
+}
+
+type PermProvider interface {
+	GetUserPermissionsAsModels(ctx context.Context, userID int64, appID int32) ([]models.Permission, error)
+}
+
+// PasswordResetter emails the password reset link to the user
+type PasswordResetter interface {
+	SendPasswordReset(ctx context.Context, user models.User, appID int32) (time.Time, error)
+}
+
+// AuditSink is the audit.Recorder
+type AuditSink interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+// ListUsers returns the users matching the filter, permission is the code of the permission the users must have
+func (u *User) ListUsers(ctx context.Context, filter models.UserFilter, permission string) ([]models.User, error) {
+	const op = "User.ListUsers"
+
+	log := u.log.With(
+		slog.String("op", op),
+		slog.String("emailPrefix", filter.EmailPrefix),
+		slog.String("permission", permission),
+	)
+
+	if filter.Offset < 0 {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidInput)
+	}
+
+	if filter.Limit <= 0 || filter.Limit > defaultUsersLimit {
+		filter.Limit = defaultUsersLimit
+	}
+
+	if permission != "" {
+		p, err := u.adminRepo.PermissionByCode(ctx, permission)
+		if err != nil {
+			if errors.Is(err, storage.ErrPermissionNotFound) {
+				return nil, fmt.Errorf("%s: %w", op, ErrPermissionNotFound)
+			}
+			log.ErrorContext(ctx, "failed to get permission", sl.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		filter.PermissionID = p.ID
+	}
+
+	users, err := u.adminRepo.Users(ctx, filter)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to list users", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+// UserDetails returns the user with the permissions granted for every app
+func (u *User) UserDetails(ctx context.Context, userID int64) (models.User, []models.Permission, error) {
+	const op = "User.UserDetails"
+
+	user, err := u.GetUserByID(ctx, userID)
+	if err != nil {
+		return models.User{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	permissions, err := u.permProvider.GetUserPermissionsAsModels(ctx, userID, 0)
+	if err != nil {
+		u.log.ErrorContext(ctx, "failed to get user permissions", slog.String("op", op), sl.Err(err))
+		return models.User{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, permissions, nil
+}
+
+// SetDisabled disables or enables the account, disabled users can't login or refresh tokens
+// returns false if the account already was in the state
+func (u *User) SetDisabled(ctx context.Context, actorID int64, userID int64, disabled bool, reason string) (bool, error) {
+	const op = "User.SetDisabled"
+
+	log := u.log.With(
+		slog.String("op", op),
+		slog.Int64("actorID", actorID),
+		slog.Int64("userID", userID),
+		slog.Bool("disabled", disabled),
+	)
+
+	log.InfoContext(ctx, "changing user status")
+
+	if userID == actorID {
+		return false, fmt.Errorf("%s: %w", op, ErrSelfAction)
+	}
+
+	if err := u.checkPrivilege(ctx, actorID, userID); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{
+		Type:         audit.TypeUserEnable,
+		ActorID:      actorID,
+		TargetUserID: userID,
+		Payload:      map[string]any{"reason": reason},
+	}
+	if disabled {
+		event.Type = audit.TypeUserDisable
+	}
+
+	changed, err := u.adminRepo.SetUserDisabled(ctx, userID, disabled)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return false, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		log.ErrorContext(ctx, "failed to change user status", sl.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if changed {
+		u.audit.Record(ctx, event)
+	}
+
+	log.InfoContext(ctx, "user status changed", slog.Bool("changed", changed))
+	return changed, nil
+}
+
+// ForcePasswordReset locks the current password, ends all sessions and emails the reset link,
+// the user can login again only after setting a new password
+func (u *User) ForcePasswordReset(ctx context.Context, actorID int64, userID int64) (time.Time, error) {
+	const op = "User.ForcePasswordReset"
+
+	log := u.log.With(
+		slog.String("op", op),
+		slog.Int64("actorID", actorID),
+		slog.Int64("userID", userID),
+	)
+
+	log.InfoContext(ctx, "forcing password reset")
+
+	user, err := u.GetUserByID(ctx, userID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.checkPrivilege(ctx, actorID, userID); err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	hash, err := unusablePasswordHash()
+	if err != nil {
+		log.ErrorContext(ctx, "failed to generate password hash", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.adminRepo.LockUserPassword(ctx, userID, hash); err != nil {
+		log.ErrorContext(ctx, "failed to lock user password", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	u.audit.Record(ctx, models.AuditEvent{Type: audit.TypeUserPasswordReset, ActorID: actorID, TargetUserID: userID})
+
+	// zero app: the email is branded as the sso itself
+	expiresAt, err := u.resetter.SendPasswordReset(ctx, user, 0)
+	if err != nil {
+		// the password stays locked, the user can still request the link with ForgotPassword
+		log.ErrorContext(ctx, "failed to send password reset", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.InfoContext(ctx, "password reset forced")
+	return expiresAt, nil
+}
+
+// DeleteUser removes the account with all its grants, keys and sessions
+func (u *User) DeleteUser(ctx context.Context, actorID int64, userID int64) error {
+	const op = "User.DeleteUser"
+
+	log := u.log.With(
+		slog.String("op", op),
+		slog.Int64("actorID", actorID),
+		slog.Int64("userID", userID),
+	)
+
+	log.InfoContext(ctx, "deleting user")
+
+	if userID == actorID {
+		return fmt.Errorf("%s: %w", op, ErrSelfAction)
+	}
+
+	user, err := u.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.checkPrivilege(ctx, actorID, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.adminRepo.DeleteUser(ctx, userID); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		log.ErrorContext(ctx, "failed to delete user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// audit events are kept, the hash of the email identifies the removed account there
+	u.audit.Record(ctx, models.AuditEvent{
+		Type:         audit.TypeUserDelete,
+		ActorID:      actorID,
+		TargetUserID: userID,
+		Payload:      map[string]any{"email_hash": audit.HashEmail(user.Email)},
+	})
+
+	log.InfoContext(ctx, "user deleted")
+	return nil
+}
+
+// checkPrivilege refuses actions on the users holding permissions the actor doesn't have,
+// otherwise a user manager could lock out or remove the admins
+func (u *User) checkPrivilege(ctx context.Context, actorID int64, userID int64) error {
+	actorPermissions, err := u.permProvider.GetUserPermissionsAsModels(ctx, actorID, 0)
+	if err != nil {
+		return err
+	}
+
+	userPermissions, err := u.permProvider.GetUserPermissionsAsModels(ctx, userID, 0)
+	if err != nil {
+		return err
+	}
+
+	if exceeding := privilege.Exceeding(actorPermissions, userPermissions); len(exceeding) > 0 {
+		u.log.WarnContext(ctx, "refused to act on user with permissions the actor doesn't have",
+			slog.Int64("actorID", actorID),
+			slog.Int64("userID", userID),
+			slog.Any("permissions", exceeding),
+		)
+		return ErrPrivilegedUser
+	}
+
+	return nil
+}
+
+// unusablePasswordHash is not a valid bcrypt hash, so no password matches it
+func unusablePasswordHash() ([]byte, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return []byte("!locked-" + hex.EncodeToString(b)), nil
+}
@@ -6,12 +6,16 @@ import (
 	"fmt"
 	"log/slog"
 	"sso/internal/domain/models"
-	"sso/internal/lib/logger/sl"
 	"sso/internal/storage"
+	"sso/pkg/logger/sl"
 )
 
 var (
-	ErrUserNotFound = errors.New("user not found")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidInput       = errors.New("invalid user input")
+	ErrPermissionNotFound = errors.New("permission not found")
+	ErrSelfAction         = errors.New("admins can't do this to their own account")
+	ErrPrivilegedUser     = errors.New("user has permissions the admin doesn't have")
 )
 
 type UserRepository interface {
@@ -23,8 +27,9 @@ type UserRepository interface {
 		phone string,
 		address string,
 		email string,
+		locale string,
 		passwordHash []byte,
-		permissionID int64,
+		roleID int64,
 	) (uid int64, resName string, resEmail string, activated bool, err error)
 }
 
@@ -35,14 +40,29 @@ type UserProvider interface {
 }
 
 type User struct {
-	log      *slog.Logger
-	userRepo UserRepository
+	log          *slog.Logger
+	userRepo     UserRepository
+	adminRepo    AdminRepository
+	permProvider PermProvider
+	resetter     PasswordResetter
+	audit        AuditSink
 }
 
-func New(log *slog.Logger, userRepo UserRepository) *User {
+func New(
+	log *slog.Logger,
+	userRepo UserRepository,
+	adminRepo AdminRepository,
+	permProvider PermProvider,
+	resetter PasswordResetter,
+	audit AuditSink,
+) *User {
 	return &User{
-		log:      log,
-		userRepo: userRepo,
+		log:          log,
+		userRepo:     userRepo,
+		adminRepo:    adminRepo,
+		permProvider: permProvider,
+		resetter:     resetter,
+		audit:        audit,
 	}
 }
 
@@ -54,19 +74,19 @@ func (u *User) GetUserByID(ctx context.Context, userID int64) (models.User, erro
 		slog.Int64("userID", userID),
 	)
 
-	log.Info("getting user by ID")
+	log.InfoContext(ctx, "getting user by ID")
 
 	user, err := u.userRepo.UserByID(ctx, userID)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
-			log.Warn("user not found")
+			log.WarnContext(ctx, "user not found")
 			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
 		}
-		log.Error("failed to get user", sl.Err(err))
+		log.ErrorContext(ctx, "failed to get user", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("user retrieved successfully")
+	log.InfoContext(ctx, "user retrieved successfully")
 	return user, nil
 }
 
@@ -78,18 +98,18 @@ func (u *User) GetUserByEmail(ctx context.Context, email string) (models.User, e
 		slog.String("email", email),
 	)
 
-	log.Info("getting user by email")
+	log.InfoContext(ctx, "getting user by email")
 
 	user, err := u.userRepo.UserByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
-			log.Warn("user not found")
+			log.WarnContext(ctx, "user not found")
 			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
 		}
-		log.Error("failed to get user", sl.Err(err))
+		log.ErrorContext(ctx, "failed to get user", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("user retrieved successfully")
+	log.InfoContext(ctx, "user retrieved successfully")
 	return user, nil
 }
@@ -0,0 +1,462 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sso/api/events"
+	"sso/api/webhooks"
+	"sso/internal/domain/models"
+	"sso/internal/lib/audit"
+	"sso/internal/lib/backoff"
+	"sso/internal/storage"
+	"sso/pkg/logger/sl"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidInput     = errors.New("invalid webhook input")
+	ErrAppNotFound      = errors.New("app not found")
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("failed webhook delivery not found")
+)
+
+const (
+	// minRetryDelay and maxRetryDelay bound the exponential backoff of the failed deliveries
+	minRetryDelay = 10 * time.Second
+	maxRetryDelay = time.Hour
+
+	// responseSnippet is the part of the error response kept in the delivery log
+	responseSnippet = 512
+
+	defaultDeliveriesLimit = 50
+)
+
+// EventTypes are the events apps can subscribe to
+var EventTypes = []string{
+	events.TypeUserRegistered,
+	events.TypeUserVerified,
+	events.TypeUserUpdated,
+	events.TypeUserDeleted,
+}
+
+type Repository interface {
+	SaveWebhook(ctx context.Context, webhook models.Webhook) (models.Webhook, error)
+	Webhooks(ctx context.Context, appID int32) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	EnqueueWebhookDeliveries(ctx context.Context, eventID int64, eventType string, appIDs []int32, payload []byte) (int64, error)
+	ClaimWebhookDeliveries(ctx context.Context, limit int32, lease time.Duration) ([]models.WebhookDelivery, error)
+	MarkWebhookDelivered(ctx context.Context, id int64, statusCode int32) error
+	MarkWebhookDeliveryFailed(ctx context.Context, id int64, statusCode int32, reason string, nextAttempt time.Time) error
+	WebhookDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error)
+	ReplayWebhookDelivery(ctx context.Context, id int64) (models.WebhookDelivery, error)
+	DeleteDeliveredWebhookDeliveries(ctx context.Context, before time.Time) (int64, error)
+}
+
+// AppProvider returns the secret the payloads of the app are signed with
+type AppProvider interface {
+	GetAppSecret(ctx context.Context, appID int32) (string, error)
+}
+
+// AuditSink is the audit.Recorder
+type AuditSink interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+// Webhooks delivers the user events to the urls registered by the apps,
+// it is a publisher of the outbox relay: publishing only enqueues the deliveries, Run sends them
+type Webhooks struct {
+	log         *slog.Logger
+	repo        Repository
+	apps        AppProvider
+	audit       AuditSink
+	client      *http.Client
+	timeout     time.Duration
+	maxAttempts int32
+	batchSize   int32
+	retention   time.Duration
+	wake        chan struct{}
+}
+
+func New(
+	log *slog.Logger,
+	repo Repository,
+	apps AppProvider,
+	audit AuditSink,
+	timeout time.Duration,
+	maxAttempts int32,
+	batchSize int32,
+	retention time.Duration,
+) *Webhooks {
+	return &Webhooks{
+		log:         log,
+		repo:        repo,
+		apps:        apps,
+		audit:       audit,
+		client:      newClient(timeout),
+		timeout:     timeout,
+		maxAttempts: maxAttempts,
+		batchSize:   batchSize,
+		retention:   retention,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Create subscribes the app to the event types, all the user events if none are given,
+// the url must be https on a public host
+func (w *Webhooks) Create(ctx context.Context, actorID int64, appID int32, rawURL string, eventTypes []string) (models.Webhook, error) {
+	const op = "Webhooks.Create"
+
+	log := w.log.With(
+		slog.String("op", op),
+		slog.Int64("actorID", actorID),
+		slog.Int("appID", int(appID)),
+	)
+
+	u, ok := validURL(rawURL)
+	if !ok {
+		return models.Webhook{}, fmt.Errorf("%s: %w", op, ErrInvalidInput)
+	}
+
+	if len(eventTypes) == 0 {
+		eventTypes = EventTypes
+	}
+	for _, eventType := range eventTypes {
+		if !slices.Contains(EventTypes, eventType) {
+			return models.Webhook{}, fmt.Errorf("%s: %w", op, ErrInvalidInput)
+		}
+	}
+	eventTypes = slices.Compact(slices.Sorted(slices.Values(eventTypes)))
+
+	webhook, err := w.repo.SaveWebhook(ctx, models.Webhook{
+		AppID:      appID,
+		URL:        u.String(),
+		EventTypes: eventTypes,
+		CreatedBy:  actorID,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return models.Webhook{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+		log.ErrorContext(ctx, "failed to save webhook", sl.Err(err))
+		return models.Webhook{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	w.audit.Record(ctx, models.AuditEvent{
+		Type:    audit.TypeWebhookCreate,
+		ActorID: actorID,
+		AppID:   appID,
+		Payload: map[string]any{
+			"webhook_id":  webhook.ID,
+			"url":         webhook.URL,
+			"event_types": webhook.EventTypes,
+		},
+	})
+
+	log.InfoContext(ctx, "webhook created", slog.Int64("webhookID", webhook.ID))
+	return webhook, nil
+}
+
+// List returns the webhooks of the app, all of them for zero appID
+func (w *Webhooks) List(ctx context.Context, appID int32) ([]models.Webhook, error) {
+	const op = "Webhooks.List"
+
+	webhooks, err := w.repo.Webhooks(ctx, appID)
+	if err != nil {
+		w.log.ErrorContext(ctx, "failed to list webhooks", slog.String("op", op), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return webhooks, nil
+}
+
+// Delete removes the webhook, its pending deliveries are dropped
+func (w *Webhooks) Delete(ctx context.Context, actorID int64, webhookID int64) error {
+	const op = "Webhooks.Delete"
+
+	log := w.log.With(
+		slog.String("op", op),
+		slog.Int64("actorID", actorID),
+		slog.Int64("webhookID", webhookID),
+	)
+
+	if err := w.repo.DeleteWebhook(ctx, webhookID); err != nil {
+		if errors.Is(err, storage.ErrWebhookNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrWebhookNotFound)
+		}
+		log.ErrorContext(ctx, "failed to delete webhook", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	w.audit.Record(ctx, models.AuditEvent{
+		Type:    audit.TypeWebhookDelete,
+		ActorID: actorID,
+		Payload: map[string]any{"webhook_id": webhookID},
+	})
+
+	log.InfoContext(ctx, "webhook deleted")
+	return nil
+}
+
+// Deliveries returns the delivery log of the webhook, newest first
+func (w *Webhooks) Deliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error) {
+	const op = "Webhooks.Deliveries"
+
+	if filter.WebhookID <= 0 || filter.Limit < 0 || filter.Offset < 0 {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidInput)
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultDeliveriesLimit
+	}
+
+	deliveries, err := w.repo.WebhookDeliveries(ctx, filter)
+	if err != nil {
+		w.log.ErrorContext(ctx, "failed to list webhook deliveries", slog.String("op", op), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// Replay queues the failed delivery again
+func (w *Webhooks) Replay(ctx context.Context, actorID int64, deliveryID int64) (models.WebhookDelivery, error) {
+	const op = "Webhooks.Replay"
+
+	log := w.log.With(
+		slog.String("op", op),
+		slog.Int64("actorID", actorID),
+		slog.Int64("deliveryID", deliveryID),
+	)
+
+	delivery, err := w.repo.ReplayWebhookDelivery(ctx, deliveryID)
+	if err != nil {
+		if errors.Is(err, storage.ErrDeliveryNotFound) {
+			return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, ErrDeliveryNotFound)
+		}
+		log.ErrorContext(ctx, "failed to replay webhook delivery", sl.Err(err))
+		return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	w.audit.Record(ctx, models.AuditEvent{
+		Type:    audit.TypeWebhookReplay,
+		ActorID: actorID,
+		AppID:   delivery.AppID,
+		Payload: map[string]any{
+			"webhook_id":  delivery.WebhookID,
+			"delivery_id": delivery.ID,
+			"event_id":    delivery.EventID,
+		},
+	})
+
+	w.notify()
+
+	log.InfoContext(ctx, "webhook delivery replayed")
+	return delivery, nil
+}
+
+// Publish enqueues the deliveries of the event to the subscribed webhooks of the user apps
+func (w *Webhooks) Publish(ctx context.Context, event events.Event) error {
+	const op = "Webhooks.Publish"
+
+	if len(event.AppIDs) == 0 {
+		return nil
+	}
+
+	payload, err := webhookPayload(event)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	enqueued, err := w.repo.EnqueueWebhookDeliveries(ctx, event.ID, event.Type, event.AppIDs, payload)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if enqueued > 0 {
+		w.notify()
+	}
+
+	return nil
+}
+
+// webhookPayload is the event without the personal data of the user,
+// the apps read the user through the API with their own permissions
+func webhookPayload(event events.Event) ([]byte, error) {
+	var user any = map[string]int64{"user_id": event.UserID}
+
+	if event.Type != events.TypeUserDeleted {
+		full, err := event.User()
+		if err != nil {
+			return nil, err
+		}
+		user = events.UserPayload{
+			UserID:    event.UserID,
+			Activated: full.Activated,
+			Disabled:  full.Disabled,
+		}
+	}
+
+	userPayload, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+	event.Payload = userPayload
+
+	return json.Marshal(event)
+}
+
+func (w *Webhooks) Close() error {
+	return nil
+}
+
+// notify wakes up the delivery worker
+func (w *Webhooks) notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends the due deliveries every interval until the context is canceled
+func (w *Webhooks) Run(ctx context.Context, interval time.Duration) {
+	const op = "Webhooks.Run"
+
+	log := w.log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// a full batch means there is more waiting
+		for ctx.Err() == nil {
+			n, err := w.Deliver(ctx)
+			if err != nil {
+				log.ErrorContext(ctx, "failed to deliver webhooks", sl.Err(err))
+				break
+			}
+			if n < int(w.batchSize) {
+				break
+			}
+		}
+
+		if deleted, err := w.repo.DeleteDeliveredWebhookDeliveries(ctx, time.Now().Add(-w.retention)); err != nil {
+			log.ErrorContext(ctx, "failed to delete delivered webhook deliveries", sl.Err(err))
+		} else if deleted > 0 {
+			log.DebugContext(ctx, "delivered webhook deliveries deleted", slog.Int64("count", deleted))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// Deliver sends one batch of the due deliveries concurrently, returns the number of sent deliveries
+func (w *Webhooks) Deliver(ctx context.Context) (int, error) {
+	const op = "Webhooks.Deliver"
+
+	// the claim outlives the request, so another instance doesn't send the delivery meanwhile
+	deliveries, err := w.repo.ClaimWebhookDeliveries(ctx, w.batchSize, w.timeout+30*time.Second)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+// deliver sends the delivery and records the result
+func (w *Webhooks) deliver(ctx context.Context, delivery models.WebhookDelivery) {
+	const op = "Webhooks.deliver"
+
+	log := w.log.With(
+		slog.String("op", op),
+		slog.Int64("deliveryID", delivery.ID),
+		slog.Int64("webhookID", delivery.WebhookID),
+		slog.Int64("eventID", delivery.EventID),
+	)
+
+	statusCode, err := w.send(ctx, delivery)
+	if err == nil {
+		if err := w.repo.MarkWebhookDelivered(ctx, delivery.ID, statusCode); err != nil {
+			log.ErrorContext(ctx, "failed to mark webhook delivered", sl.Err(err))
+		}
+		return
+	}
+
+	attempts := delivery.Attempts + 1
+
+	// zero next attempt marks the delivery as failed, it waits for a replay
+	var nextAttempt time.Time
+	if attempts < w.maxAttempts {
+		nextAttempt = time.Now().Add(backoff.Delay(delivery.Attempts, minRetryDelay, maxRetryDelay))
+	}
+
+	log.WarnContext(ctx, "failed to deliver webhook",
+		slog.Int("attempts", int(attempts)),
+		slog.Int("statusCode", int(statusCode)),
+		slog.Time("nextAttempt", nextAttempt),
+		sl.Err(err),
+	)
+
+	if err := w.repo.MarkWebhookDeliveryFailed(ctx, delivery.ID, statusCode, err.Error(), nextAttempt); err != nil {
+		log.ErrorContext(ctx, "failed to mark webhook delivery failed", sl.Err(err))
+	}
+}
+
+// send posts the signed payload, any 2xx response is a success
+func (w *Webhooks) send(ctx context.Context, delivery models.WebhookDelivery) (int32, error) {
+	secret, err := w.apps.GetAppSecret(ctx, delivery.AppID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get app secret: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sso-webhooks")
+	req.Header.Set(webhooks.HeaderDeliveryID, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(webhooks.HeaderEventType, delivery.EventType)
+	req.Header.Set(webhooks.HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhooks.HeaderSignature, webhooks.Sign(secret, timestamp, delivery.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, responseSnippet))
+		return int32(resp.StatusCode), fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	// drain the body so the connection is reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, responseSnippet))
+
+	return int32(resp.StatusCode), nil
+}
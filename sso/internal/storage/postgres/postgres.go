@@ -6,8 +6,11 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"log/slog"
+	"sso/api/events"
 	"sso/internal/domain/models"
 	"sso/internal/storage"
+	"sso/pkg/tracing"
+	"strings"
 	"time"
 
 	//_ "database/sql"
@@ -48,19 +51,11 @@ func Config(dsn string) *pgxpool.Config {
 	dbConfig.MaxConnIdleTime = defaultMaxConnIdleTime
 	dbConfig.HealthCheckPeriod = defaultHealthCheckPeriod
 	dbConfig.ConnConfig.ConnectTimeout = defaultConnectTimeout
+	dbConfig.ConnConfig.Tracer = tracing.NewQueryTracer()
 
-	dbConfig.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
-		slog.Info("Before acquiring the connection pool to the database")
-		return true
-	}
-
-	dbConfig.AfterRelease = func(conn *pgx.Conn) bool {
-		slog.Info("After releasing the connection pool to the database")
-		return true
-	}
-
+	// acquires and releases are counted by the pool metrics instead of logged
 	dbConfig.BeforeClose = func(conn *pgx.Conn) {
-		slog.Info("Closed the connection pool to the database")
+		slog.Debug("Closed the connection pool to the database")
 	}
 
 	return dbConfig
@@ -87,7 +82,13 @@ func (s *Storage) Close() {
 	s.db.Close()
 }
 
-func (s *Storage) SaveUserWithPermission(ctx context.Context, name string, phone string, address string, email string, passwordHash []byte, permissionID int64) (int64, string, string, bool, error) {
+// Stat returns the stats of the connection pool for the metrics
+func (s *Storage) Stat() *pgxpool.Stat {
+	return s.db.Stat()
+}
+
+// SaveUserWithPermission saves user and assigns the default role for every app
+func (s *Storage) SaveUserWithPermission(ctx context.Context, name string, phone string, address string, email string, locale string, passwordHash []byte, roleID int64) (int64, string, string, bool, error) {
 	const op = "storage.postgres.SaveUserWithPermission"
 
 	tx, err := s.db.Begin(ctx)
@@ -102,15 +103,15 @@ func (s *Storage) SaveUserWithPermission(ctx context.Context, name string, phone
 	}(tx, ctx)
 
 	userQuery := `
-		INSERT INTO users(email, password_hash, name, phone, address)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO users(email, password_hash, name, phone, address, locale)
+		VALUES ($1, $2, $3, $4, $5, $6)
         RETURNING id, name, email, activated`
 
 	var id int64
 	var resName, resEmail string
 	var activated bool
 
-	err = tx.QueryRow(ctx, userQuery, email, passwordHash, name, phone, address).Scan(&id, &resName, &resEmail, &activated)
+	err = tx.QueryRow(ctx, userQuery, email, passwordHash, name, phone, address, locale).Scan(&id, &resName, &resEmail, &activated)
 
 	if err != nil {
 		var postgresErr *pgconn.PgError
@@ -120,13 +121,17 @@ func (s *Storage) SaveUserWithPermission(ctx context.Context, name string, phone
 		return 0, "", "", false, fmt.Errorf("%s: %w", op, err)
 	}
 
-	permQuery := `
-		INSERT INTO users_permissions(user_id, permission_id)
+	roleQuery := `
+		INSERT INTO users_roles(user_id, role_id)
 		VALUES ($1, $2) ON CONFLICT DO NOTHING`
 
-	_, err = tx.Exec(ctx, permQuery, id, permissionID)
+	_, err = tx.Exec(ctx, roleQuery, id, roleID)
 	if err != nil {
-		return 0, "", "", false, fmt.Errorf("%s: failed to add permission: %w", op, err)
+		return 0, "", "", false, fmt.Errorf("%s: failed to assign role: %w", op, err)
+	}
+
+	if err := insertUserEvent(ctx, tx, events.TypeUserRegistered, id); err != nil {
+		return 0, "", "", false, fmt.Errorf("%s: failed to write event: %w", op, err)
 	}
 
 	if err = tx.Commit(ctx); err != nil {
@@ -136,16 +141,42 @@ func (s *Storage) SaveUserWithPermission(ctx context.Context, name string, phone
 	return id, resName, resEmail, activated, nil
 }
 
-func (s *Storage) UserByEmail(ctx context.Context, email string) (models.User, error) {
-	const op = "storage.postgres.UserByEmail"
-
-	query := `SELECT id, email, password_hash, name, phone, address, activated FROM users WHERE email = $1`
+// userColumns are read by scanUser
+const userColumns = `users.id, users.email, users.password_hash, users.name, users.phone, users.address,
+	users.activated, users.disabled_at, users.created_at, users.delete_after, users.locale`
 
+func scanUser(row pgx.Row) (models.User, error) {
 	var user models.User
-	err := s.db.QueryRow(ctx, query, email).Scan(
+	var disabledAt, createdAt, deleteAfter *time.Time
+
+	err := row.Scan(
 		&user.ID, &user.Email, &user.PasswordHash,
 		&user.Name, &user.Phone, &user.Address, &user.Activated,
+		&disabledAt, &createdAt, &deleteAfter, &user.Locale,
 	)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if disabledAt != nil {
+		user.DisabledAt = *disabledAt
+	}
+	if createdAt != nil {
+		user.CreatedAt = *createdAt
+	}
+	if deleteAfter != nil {
+		user.DeleteAfter = *deleteAfter
+	}
+
+	return user, nil
+}
+
+func (s *Storage) UserByEmail(ctx context.Context, email string) (models.User, error) {
+	const op = "storage.postgres.UserByEmail"
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1`
+
+	user, err := scanUser(s.db.QueryRow(ctx, query, email))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -159,13 +190,9 @@ func (s *Storage) UserByEmail(ctx context.Context, email string) (models.User, e
 func (s *Storage) UserByID(ctx context.Context, userID int64) (models.User, error) {
 	const op = "storage.postgres.UserByID"
 
-	query := `SELECT id, email, password_hash, name, phone, address, activated FROM users WHERE id = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
 
-	var user models.User
-	err := s.db.QueryRow(ctx, query, userID).Scan(
-		&user.ID, &user.Email, &user.PasswordHash,
-		&user.Name, &user.Phone, &user.Address, &user.Activated,
-	)
+	user, err := scanUser(s.db.QueryRow(ctx, query, userID))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -176,13 +203,271 @@ func (s *Storage) UserByID(ctx context.Context, userID int64) (models.User, erro
 	return user, nil
 }
 
+// Users returns the users matching the filter ordered by id,
+// the permission filter matches direct grants and grants through any role
+func (s *Storage) Users(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	const op = "storage.postgres.Users"
+
+	query := `
+	SELECT ` + userColumns + ` FROM users
+	WHERE ($1 = '' OR lower(users.email::text) LIKE lower($1) || '%')
+		AND ($2::boolean IS NULL OR users.activated = $2)
+		AND ($3::bigint = 0 OR EXISTS (
+			SELECT 1 FROM users_permissions
+			WHERE users_permissions.user_id = users.id AND users_permissions.permission_id = $3
+		) OR EXISTS (
+			SELECT 1 FROM users_roles
+			INNER JOIN roles_permissions ON roles_permissions.role_id = users_roles.role_id
+			WHERE users_roles.user_id = users.id AND roles_permissions.permission_id = $3
+		))
+	ORDER BY users.id
+	LIMIT $4 OFFSET $5`
+
+	// LIKE wildcards in the prefix are matched literally
+	prefix := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(filter.EmailPrefix)
+
+	rows, err := s.db.Query(ctx, query, prefix, filter.Activated, filter.PermissionID, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+// SetUserDisabled disables or enables the account, disabling also ends all sessions of the user
+// returns false if the account already was in the state
+func (s *Storage) SetUserDisabled(ctx context.Context, userID int64, disabled bool) (bool, error) {
+	const op = "storage.postgres.SetUserDisabled"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(tx pgx.Tx, ctx context.Context) {
+		err := tx.Rollback(ctx)
+		if err != nil {
+			return
+		}
+	}(tx, ctx)
+
+	var exists bool
+	err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	query := `UPDATE users SET disabled_at = now() WHERE id = $1 AND disabled_at IS NULL`
+	if !disabled {
+		query = `UPDATE users SET disabled_at = NULL WHERE id = $1 AND disabled_at IS NOT NULL`
+	}
+
+	tag, err := tx.Exec(ctx, query, userID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() > 0 {
+		if err := insertUserEvent(ctx, tx, events.TypeUserUpdated, userID); err != nil {
+			return false, fmt.Errorf("%s: failed to write event: %w", op, err)
+		}
+	}
+
+	if disabled {
+		if _, err := tx.Exec(ctx, `DELETE FROM refresh_tokens WHERE user_id = $1`, userID); err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// LockUserPassword replaces the password so it can only be set again through reset, all sessions are ended
+func (s *Storage) LockUserPassword(ctx context.Context, userID int64, unusableHash []byte) error {
+	const op = "storage.postgres.LockUserPassword"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(tx pgx.Tx, ctx context.Context) {
+		err := tx.Rollback(ctx)
+		if err != nil {
+			return
+		}
+	}(tx, ctx)
+
+	tag, err := tx.Exec(ctx, `UPDATE users SET password_hash = $2 WHERE id = $1`, userID, unusableHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM refresh_tokens WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteUser removes the user with the sessions, keys and grants are removed by cascade
+func (s *Storage) DeleteUser(ctx context.Context, userID int64) error {
+	const op = "storage.postgres.DeleteUser"
+
+	// refresh tokens have no foreign key to users
+	query := `
+	WITH sessions AS (
+		DELETE FROM refresh_tokens WHERE user_id = $1
+	), deleted AS (
+		DELETE FROM users WHERE id = $1 RETURNING id
+	), event AS (` + userDeletedEvent + `)
+	SELECT count(*) FROM deleted`
+
+	var deleted int64
+	if err := s.db.QueryRow(ctx, query, userID).Scan(&deleted); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// ScheduleUserDeletion marks the account for deletion after deleteAfter and ends all sessions of the user
+func (s *Storage) ScheduleUserDeletion(ctx context.Context, userID int64, deleteAfter time.Time) error {
+	const op = "storage.postgres.ScheduleUserDeletion"
+
+	// refresh tokens have no foreign key to users
+	query := `
+	WITH sessions AS (DELETE FROM refresh_tokens WHERE user_id = $1)
+	UPDATE users SET delete_after = $2 WHERE id = $1`
+
+	tag, err := s.db.Exec(ctx, query, userID, deleteAfter)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// CancelUserDeletion clears the scheduled deletion, returns false if the account was not scheduled
+func (s *Storage) CancelUserDeletion(ctx context.Context, userID int64) (bool, error) {
+	const op = "storage.postgres.CancelUserDeletion"
+
+	query := `UPDATE users SET delete_after = NULL WHERE id = $1 AND delete_after IS NOT NULL`
+
+	tag, err := s.db.Exec(ctx, query, userID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// UsersDueForDeletion returns the accounts whose grace period ended before now, oldest first
+func (s *Storage) UsersDueForDeletion(ctx context.Context, now time.Time, limit int32) ([]models.User, error) {
+	const op = "storage.postgres.UsersDueForDeletion"
+
+	query := `
+	SELECT ` + userColumns + ` FROM users
+	WHERE users.delete_after IS NOT NULL AND users.delete_after <= $1
+	ORDER BY users.delete_after
+	LIMIT $2`
+
+	rows, err := s.db.Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+// DeleteScheduledUser removes the account only if its deletion is still due,
+// so a login racing with the purge keeps the account, returns ErrUserNotFound otherwise
+func (s *Storage) DeleteScheduledUser(ctx context.Context, userID int64, now time.Time) error {
+	const op = "storage.postgres.DeleteScheduledUser"
+
+	query := `
+	WITH deleted AS (
+		DELETE FROM users WHERE id = $1 AND delete_after IS NOT NULL AND delete_after <= $2 RETURNING id
+	), sessions AS (
+		DELETE FROM refresh_tokens WHERE user_id IN (SELECT id FROM deleted)
+	), event AS (` + userDeletedEvent + `)
+	SELECT count(*) FROM deleted`
+
+	var deleted int64
+	if err := s.db.QueryRow(ctx, query, userID, now).Scan(&deleted); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
 func (s *Storage) App(ctx context.Context, id int32) (models.App, error) {
 	const op = "storage.postgres.App"
 
-	query := `SELECT id, name, secret FROM apps WHERE id = $1`
+	query := `
+	SELECT id, name, secret, access_ttl_seconds, refresh_ttl_seconds, embed_permissions, extra_claims, audience,
+		COALESCE(email_from, ''), COALESCE(email_from_name, ''), COALESCE(email_logo_url, ''), COALESCE(email_color, ''),
+		COALESCE(verification_url, ''), COALESCE(reset_password_url, '')
+	FROM apps WHERE id = $1`
 
 	var app models.App
-	err := s.db.QueryRow(ctx, query, id).Scan(&app.ID, &app.Name, &app.Secret)
+	var accessTTL, refreshTTL *int32
+	var audience *string
+	err := s.db.QueryRow(ctx, query, id).Scan(
+		&app.ID, &app.Name, &app.Secret,
+		&accessTTL, &refreshTTL, &app.EmbedPermissions, &app.ExtraClaims, &audience,
+		&app.Email.From, &app.Email.FromName, &app.Email.LogoURL, &app.Email.Color,
+		&app.Email.VerificationURL, &app.Email.ResetPasswordURL,
+	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
@@ -191,9 +476,37 @@ func (s *Storage) App(ctx context.Context, id int32) (models.App, error) {
 		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if accessTTL != nil {
+		app.AccessTTL = time.Duration(*accessTTL) * time.Second
+	}
+	if refreshTTL != nil {
+		app.RefreshTTL = time.Duration(*refreshTTL) * time.Second
+	}
+	if audience != nil {
+		app.Audience = *audience
+	}
+
 	return app, nil
 }
 
+// AppByAudience finds the app tokens can be issued for by its audience (name when not set)
+func (s *Storage) AppByAudience(ctx context.Context, audience string) (models.App, error) {
+	const op = "storage.postgres.AppByAudience"
+
+	query := `SELECT id FROM apps WHERE COALESCE(audience, name) = $1`
+
+	var appID int32
+	err := s.db.QueryRow(ctx, query, audience).Scan(&appID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.App(ctx, appID)
+}
+
 func (s *Storage) GetAppSecret(ctx context.Context, appID int32) (string, error) {
 	const op = "storage.postgres.GetAppSecret"
 
@@ -263,55 +576,41 @@ func (s *Storage) ExistsRefresh(ctx context.Context, token string) (bool, error)
 	return true, nil
 }
 
+// effectivePermissionsQuery selects permissions granted directly and through roles,
+// roles scoped to another app are skipped ($1 - user id, $2 - app id, 0 for global only)
+const effectivePermissionsQuery = `
+	SELECT permissions.id, permissions.code FROM permissions
+	INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
+	WHERE users_permissions.user_id = $1
+	UNION
+	SELECT permissions.id, permissions.code FROM permissions
+	INNER JOIN roles_permissions ON roles_permissions.permission_id = permissions.id
+	INNER JOIN users_roles ON users_roles.role_id = roles_permissions.role_id
+	WHERE users_roles.user_id = $1 AND (users_roles.app_id IS NULL OR users_roles.app_id = $2)`
+
 func (s *Storage) GetUserPermissions(ctx context.Context, id int64) ([]string, error) {
 	const op = "storage.postgres.GetUserPermissions"
 
-	query := `
-	SELECT permissions.code FROM permissions 
-    INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id 
-    INNER JOIN users ON users_permissions.user_id = users.id 
-    WHERE users.id = $1`
-
-	rows, err := s.db.Query(ctx, query, id)
-
+	permissions, err := s.UserPermissions(ctx, id, 0)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var permissions []string
-
-	for rows.Next() {
-		var permission string
-
-		err := rows.Scan(&permission)
-		if err != nil {
-			if errors.Is(err, pgx.ErrNoRows) {
-				return nil, fmt.Errorf("%s: %w", op, storage.ErrPermissionNotFound)
-			}
-
-			return nil, fmt.Errorf("%s: %w", op, err)
-		}
-
-		permissions = append(permissions, permission)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	codes := make([]string, len(permissions))
+	for i, permission := range permissions {
+		codes[i] = permission.Code
 	}
 
-	return permissions, nil
+	return codes, nil
 }
 
-func (s *Storage) UserPermissions(ctx context.Context, userID int64) ([]models.Permission, error) {
+// UserPermissions returns the flattened effective permission set of the user for the app
+func (s *Storage) UserPermissions(ctx context.Context, userID int64, appID int32) ([]models.Permission, error) {
 	const op = "storage.postgres.UserPermissions"
 
-	query := `
-	SELECT permissions.id, permissions.code FROM permissions
-    INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
-    WHERE users_permissions.user_id = $1`
+	query := effectivePermissionsQuery + ` ORDER BY code`
 
-	rows, err := s.db.Query(ctx, query, userID)
+	rows, err := s.db.Query(ctx, query, userID, appID)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -335,19 +634,14 @@ func (s *Storage) UserPermissions(ctx context.Context, userID int64) ([]models.P
 	return permissions, nil
 }
 
-func (s *Storage) HasUserPermission(ctx context.Context, id int64, permission string) (bool, error) {
+func (s *Storage) HasUserPermission(ctx context.Context, id int64, appID int32, permission string) (bool, error) {
 	const op = "storage.postgres.HasUserPermission"
 
-	query := `
-	SELECT EXISTS ( 
-	SELECT 1 FROM users_permissions 
-    JOIN permissions ON users_permissions.permission_id = permissions.id
-	WHERE users_permissions.user_id = $1 AND permissions.code = $2 
-	)`
+	query := `SELECT EXISTS (SELECT 1 FROM (` + effectivePermissionsQuery + `) AS effective WHERE effective.code = $3)`
 
 	var allowed bool
 
-	err := s.db.QueryRow(ctx, query, id, permission).Scan(&allowed)
+	err := s.db.QueryRow(ctx, query, id, appID, permission).Scan(&allowed)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return false, fmt.Errorf("%s: %w", op, storage.ErrPermissionNotFound)
@@ -374,63 +668,1286 @@ func (s *Storage) AddUserPermission(ctx context.Context, userID int64, permissio
 	return err
 }
 
-func (s *Storage) GetUserPermissionsAsModels(ctx context.Context, userID int64) ([]models.Permission, error) {
-	return s.UserPermissions(ctx, userID)
+func (s *Storage) GetUserPermissionsAsModels(ctx context.Context, userID int64, appID int32) ([]models.Permission, error) {
+	return s.UserPermissions(ctx, userID, appID)
 }
 
-func (s *Storage) SaveVerificationToken(ctx context.Context, token string, userID int64, expiresAt time.Time) error {
-	const op = "storage.postgres.SaveVerificationToken"
+// Roles returns all roles with the codes of bundled permissions
+func (s *Storage) Roles(ctx context.Context) ([]models.Role, error) {
+	const op = "storage.postgres.Roles"
 
 	query := `
-		INSERT INTO email_verification_tokens (token, user_id, expires_at)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (user_id) DO UPDATE SET
-			token = EXCLUDED.token,
-			expires_at = EXCLUDED.expires_at,
-			created_at = now()`
-
-	_, err := s.db.Exec(ctx, query, token, userID, expiresAt)
+	SELECT roles.id, roles.code, roles.description,
+	       COALESCE(array_agg(permissions.code ORDER BY permissions.code) FILTER (WHERE permissions.code IS NOT NULL), '{}')
+	FROM roles
+	LEFT JOIN roles_permissions ON roles_permissions.role_id = roles.id
+	LEFT JOIN permissions ON permissions.id = roles_permissions.permission_id
+	GROUP BY roles.id
+	ORDER BY roles.id`
+
+	rows, err := s.db.Query(ctx, query)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer rows.Close()
 
-	return nil
-}
+	var roles []models.Role
 
-func (s *Storage) VerifyEmail(ctx context.Context, token string) (int64, error) {
-	const op = "storage.postgres.VerifyEmail"
+	for rows.Next() {
+		var role models.Role
+		if err := rows.Scan(&role.ID, &role.Code, &role.Description, &role.Permissions); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		roles = append(roles, role)
+	}
 
-	// Начинаем транзакцию
-	tx, err := s.db.Begin(ctx)
-	if err != nil {
-		return 0, fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	defer func(tx pgx.Tx, ctx context.Context) {
-		err := tx.Rollback(ctx)
-		if err != nil {
-			return
-		}
-	}(tx, ctx)
 
-	// 1. Получаем пользователя по токену и проверяем срок действия
-	var userID int64
-	checkQuery := `
-		SELECT user_id FROM email_verification_tokens
-		WHERE token = $1 AND expires_at > now()`
+	return roles, nil
+}
 
-	err = tx.QueryRow(ctx, checkQuery, token).Scan(&userID)
+func (s *Storage) RoleByCode(ctx context.Context, code string) (models.Role, error) {
+	const op = "storage.postgres.RoleByCode"
+
+	query := `SELECT id, code, description FROM roles WHERE code = $1`
+
+	var role models.Role
+	err := s.db.QueryRow(ctx, query, code).Scan(&role.ID, &role.Code, &role.Description)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return 0, fmt.Errorf("%s: %w", op, storage.ErrTokenNotFound)
+			return models.Role{}, fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
 		}
-		return 0, fmt.Errorf("%s: failed to check token: %w", op, err)
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	updateQuery := `UPDATE users SET activated = true WHERE id = $1`
-	_, err = tx.Exec(ctx, updateQuery, userID)
-	if err != nil {
-		return 0, fmt.Errorf("%s: failed to activate user: %w", op, err)
-	}
+	return role, nil
+}
+
+// AssignUserRole assigns the role to user and records the event in the same transaction,
+// zero appID assigns it for every app, returns false if the user already had the assignment
+func (s *Storage) AssignUserRole(ctx context.Context, userID int64, roleID int64, appID int32, event models.AuditEvent) (bool, error) {
+	const op = "storage.postgres.AssignUserRole"
+
+	query := `
+		INSERT INTO users_roles (user_id, role_id, app_id)
+		VALUES ($1, $2, NULLIF($3, 0)) ON CONFLICT DO NOTHING`
+
+	changed, err := s.execWithAudit(ctx, event, query, userID, roleID, appID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return changed, nil
+}
+
+// RemoveUserRole removes the role assignment with the same app scope and records the event in the same transaction,
+// returns false if the user did not have the assignment
+func (s *Storage) RemoveUserRole(ctx context.Context, userID int64, roleID int64, appID int32, event models.AuditEvent) (bool, error) {
+	const op = "storage.postgres.RemoveUserRole"
+
+	query := `DELETE FROM users_roles WHERE user_id = $1 AND role_id = $2 AND COALESCE(app_id, 0) = $3`
+
+	changed, err := s.execWithAudit(ctx, event, query, userID, roleID, appID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return changed, nil
+}
+
+func (s *Storage) UserRoles(ctx context.Context, userID int64) ([]models.UserRole, error) {
+	const op = "storage.postgres.UserRoles"
+
+	query := `
+	SELECT users_roles.user_id, roles.id, roles.code, COALESCE(users_roles.app_id, 0)
+	FROM users_roles
+	INNER JOIN roles ON roles.id = users_roles.role_id
+	WHERE users_roles.user_id = $1
+	ORDER BY roles.id`
+
+	rows, err := s.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var userRoles []models.UserRole
+
+	for rows.Next() {
+		var userRole models.UserRole
+		if err := rows.Scan(&userRole.UserID, &userRole.RoleID, &userRole.RoleCode, &userRole.AppID); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		userRoles = append(userRoles, userRole)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userRoles, nil
+}
+
+func (s *Storage) Permissions(ctx context.Context) ([]models.Permission, error) {
+	const op = "storage.postgres.Permissions"
+
+	query := `SELECT id, code, description FROM permissions ORDER BY code`
+
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var permissions []models.Permission
+
+	for rows.Next() {
+		var permission models.Permission
+		if err := rows.Scan(&permission.ID, &permission.Code, &permission.Description); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		permissions = append(permissions, permission)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return permissions, nil
+}
+
+func (s *Storage) PermissionByCode(ctx context.Context, code string) (models.Permission, error) {
+	const op = "storage.postgres.PermissionByCode"
+
+	query := `SELECT id, code, description FROM permissions WHERE code = $1`
+
+	var permission models.Permission
+	err := s.db.QueryRow(ctx, query, code).Scan(&permission.ID, &permission.Code, &permission.Description)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Permission{}, fmt.Errorf("%s: %w", op, storage.ErrPermissionNotFound)
+		}
+		return models.Permission{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return permission, nil
+}
+
+// GrantUserPermission grants permission to user directly and records the event in the same transaction
+// returns false if the user already had the permission, nothing is recorded then
+func (s *Storage) GrantUserPermission(ctx context.Context, userID int64, permission models.Permission, event models.AuditEvent) (bool, error) {
+	const op = "storage.postgres.GrantUserPermission"
+
+	query := `
+		INSERT INTO users_permissions(user_id, permission_id)
+		VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	changed, err := s.execWithAudit(ctx, event, query, userID, permission.ID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return changed, nil
+}
+
+// RevokeUserPermission removes direct permission grant of user and records the event in the same transaction
+// returns false if the user did not have the permission
+func (s *Storage) RevokeUserPermission(ctx context.Context, userID int64, permission models.Permission, event models.AuditEvent) (bool, error) {
+	const op = "storage.postgres.RevokeUserPermission"
+
+	query := `DELETE FROM users_permissions WHERE user_id = $1 AND permission_id = $2`
+
+	changed, err := s.execWithAudit(ctx, event, query, userID, permission.ID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return changed, nil
+}
+
+// SaveAuditEvent records the security event
+func (s *Storage) SaveAuditEvent(ctx context.Context, event models.AuditEvent) error {
+	const op = "storage.postgres.SaveAuditEvent"
+
+	if err := insertAuditEvent(ctx, s.db, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AuditEvents returns the events matching the filter, newest first
+func (s *Storage) AuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	const op = "storage.postgres.AuditEvents"
+
+	query := `
+	SELECT id, type, COALESCE(actor_id, 0), COALESCE(target_user_id, 0), COALESCE(app_id, 0),
+		ip, user_agent, outcome, payload, created_at
+	FROM audit_events
+	WHERE ($1::bigint = 0 OR actor_id = $1 OR target_user_id = $1)
+		AND (cardinality($2::text[]) = 0 OR type = ANY($2))
+		AND ($3::timestamptz IS NULL OR created_at >= $3)
+		AND ($4::timestamptz IS NULL OR created_at < $4)
+	ORDER BY created_at DESC, id DESC
+	LIMIT $5 OFFSET $6`
+
+	types := filter.Types
+	if types == nil {
+		types = []string{}
+	}
+
+	rows, err := s.db.Query(ctx, query, filter.UserID, types, nullTime(filter.From), nullTime(filter.To), filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		var createdAt *time.Time
+
+		err := rows.Scan(&event.ID, &event.Type, &event.ActorID, &event.TargetUserID, &event.AppID,
+			&event.IP, &event.UserAgent, &event.Outcome, &event.Payload, &createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if createdAt != nil {
+			event.CreatedAt = *createdAt
+		}
+
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// execWithAudit runs the statement and records the event in the same transaction if any row was changed
+func (s *Storage) execWithAudit(ctx context.Context, event models.AuditEvent, query string, args ...any) (bool, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func(tx pgx.Tx, ctx context.Context) {
+		err := tx.Rollback(ctx)
+		if err != nil {
+			return
+		}
+	}(tx, ctx)
+
+	tag, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if err := insertAuditEvent(ctx, tx, event); err != nil {
+		return false, fmt.Errorf("failed to write audit event: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// execer is the pool or a transaction
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// insertAuditEvent writes the event with the pool or inside a transaction
+func insertAuditEvent(ctx context.Context, db execer, event models.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (type, actor_id, target_user_id, app_id, ip, user_agent, outcome, payload)
+		VALUES ($1, NULLIF($2::bigint, 0), NULLIF($3::bigint, 0), NULLIF($4::int, 0), $5, $6, $7, $8)`
+
+	payload := event.Payload
+	if payload == nil {
+		payload = map[string]any{}
+	}
+
+	_, err := db.Exec(ctx, query, event.Type, event.ActorID, event.TargetUserID, event.AppID,
+		event.IP, event.UserAgent, event.Outcome, payload)
+	return err
+}
+
+// UsersWithPermission returns users having the permission directly or through any of their roles
+func (s *Storage) UsersWithPermission(ctx context.Context, permissionID int64, limit int32, offset int32) ([]models.User, error) {
+	const op = "storage.postgres.UsersWithPermission"
+
+	query := `
+	SELECT users.id, users.email, users.name FROM users
+	WHERE EXISTS (
+		SELECT 1 FROM users_permissions
+		WHERE users_permissions.user_id = users.id AND users_permissions.permission_id = $1
+	) OR EXISTS (
+		SELECT 1 FROM users_roles
+		INNER JOIN roles_permissions ON roles_permissions.role_id = users_roles.role_id
+		WHERE users_roles.user_id = users.id AND roles_permissions.permission_id = $1
+	)
+	ORDER BY users.id
+	LIMIT $2 OFFSET $3`
+
+	rows, err := s.db.Query(ctx, query, permissionID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Email, &user.Name); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+const apiKeyColumns = `id, user_id, COALESCE(app_id, 0), name, prefix, permissions, expires_at, last_used_at, revoked_at, created_at`
+
+func scanAPIKey(row pgx.Row) (models.APIKey, error) {
+	var key models.APIKey
+	var expiresAt, lastUsedAt, revokedAt, createdAt *time.Time
+
+	err := row.Scan(&key.ID, &key.UserID, &key.AppID, &key.Name, &key.Prefix, &key.Permissions, &expiresAt, &lastUsedAt, &revokedAt, &createdAt)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	if expiresAt != nil {
+		key.ExpiresAt = *expiresAt
+	}
+	if lastUsedAt != nil {
+		key.LastUsedAt = *lastUsedAt
+	}
+	if revokedAt != nil {
+		key.RevokedAt = *revokedAt
+	}
+	if createdAt != nil {
+		key.CreatedAt = *createdAt
+	}
+
+	return key, nil
+}
+
+// UserSessions returns the refresh tokens of the user without the tokens
+func (s *Storage) UserSessions(ctx context.Context, userID int64) ([]models.Session, error) {
+	const op = "storage.postgres.UserSessions"
+
+	query := `
+	SELECT id, app_id, COALESCE(created_at, expires_at), expires_at
+	FROM refresh_tokens WHERE user_id = $1
+	ORDER BY id`
+
+	rows, err := s.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		var session models.Session
+		if err := rows.Scan(&session.ID, &session.AppID, &session.CreatedAt, &session.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+// dataExportColumns are read by scanDataExport, the archive is read separately
+const dataExportColumns = `id, user_id, status, error, created_at, ready_at, expires_at`
+
+func scanDataExport(row pgx.Row) (models.DataExport, error) {
+	var export models.DataExport
+	var readyAt, expiresAt *time.Time
+
+	err := row.Scan(&export.ID, &export.UserID, &export.Status, &export.Error, &export.CreatedAt, &readyAt, &expiresAt)
+	if err != nil {
+		return models.DataExport{}, err
+	}
+
+	if readyAt != nil {
+		export.ReadyAt = *readyAt
+	}
+	if expiresAt != nil {
+		export.ExpiresAt = *expiresAt
+	}
+
+	return export, nil
+}
+
+// CreateDataExport queues an export of the user, the export already in progress is returned instead of a new one
+func (s *Storage) CreateDataExport(ctx context.Context, userID int64) (models.DataExport, error) {
+	const op = "storage.postgres.CreateDataExport"
+
+	query := `
+	INSERT INTO data_exports (user_id) VALUES ($1)
+	ON CONFLICT (user_id) WHERE status IN ('pending', 'running') DO NOTHING
+	RETURNING ` + dataExportColumns
+
+	export, err := scanDataExport(s.db.QueryRow(ctx, query, userID))
+	if err == nil {
+		return export, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return models.DataExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = `SELECT ` + dataExportColumns + ` FROM data_exports WHERE user_id = $1 AND status IN ('pending', 'running')`
+
+	export, err = scanDataExport(s.db.QueryRow(ctx, query, userID))
+	if err != nil {
+		return models.DataExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return export, nil
+}
+
+// DataExport returns the export of the user without the archive
+func (s *Storage) DataExport(ctx context.Context, id int64, userID int64) (models.DataExport, error) {
+	const op = "storage.postgres.DataExport"
+
+	query := `SELECT ` + dataExportColumns + ` FROM data_exports WHERE id = $1 AND user_id = $2`
+
+	export, err := scanDataExport(s.db.QueryRow(ctx, query, id, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.DataExport{}, fmt.Errorf("%s: %w", op, storage.ErrDataExportNotFound)
+		}
+		return models.DataExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return export, nil
+}
+
+// ConsumeDataExport returns the ready and not expired export with the archive, the token and
+// the archive are cleared in the same statement, so the link works once
+func (s *Storage) ConsumeDataExport(ctx context.Context, tokenHash string) (models.DataExport, error) {
+	const op = "storage.postgres.ConsumeDataExport"
+
+	query := `
+	WITH taken AS (
+		SELECT id AS taken_id, archive AS taken_archive FROM data_exports
+		WHERE token_hash = $1 AND status = 'ready' AND expires_at > now()
+		FOR UPDATE
+	)
+	UPDATE data_exports SET token_hash = NULL, archive = NULL
+	FROM taken WHERE id = taken_id
+	RETURNING ` + dataExportColumns + `, taken_archive`
+
+	var export models.DataExport
+	var readyAt, expiresAt *time.Time
+
+	err := s.db.QueryRow(ctx, query, tokenHash).Scan(
+		&export.ID, &export.UserID, &export.Status, &export.Error, &export.CreatedAt, &readyAt, &expiresAt,
+		&export.Archive,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.DataExport{}, fmt.Errorf("%s: %w", op, storage.ErrDataExportNotFound)
+		}
+		return models.DataExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if readyAt != nil {
+		export.ReadyAt = *readyAt
+	}
+	if expiresAt != nil {
+		export.ExpiresAt = *expiresAt
+	}
+
+	return export, nil
+}
+
+// ClaimDataExport marks the oldest pending export as running and returns it,
+// exports left running longer than staleAfter (e.g. by a crash) are claimed again
+func (s *Storage) ClaimDataExport(ctx context.Context, staleAfter time.Duration) (models.DataExport, error) {
+	const op = "storage.postgres.ClaimDataExport"
+
+	query := `
+	UPDATE data_exports SET status = 'running', started_at = now()
+	WHERE id = (
+		SELECT id FROM data_exports
+		WHERE status = 'pending' OR (status = 'running' AND started_at < now() - $1 * interval '1 second')
+		ORDER BY created_at
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + dataExportColumns
+
+	export, err := scanDataExport(s.db.QueryRow(ctx, query, staleAfter.Seconds()))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.DataExport{}, fmt.Errorf("%s: %w", op, storage.ErrDataExportNotFound)
+		}
+		return models.DataExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return export, nil
+}
+
+// CompleteDataExport stores the archive, it can be downloaded once with the token until expiresAt
+func (s *Storage) CompleteDataExport(ctx context.Context, id int64, archive []byte, tokenHash string, expiresAt time.Time) error {
+	const op = "storage.postgres.CompleteDataExport"
+
+	query := `
+	UPDATE data_exports
+	SET status = 'ready', archive = $2, token_hash = $3, ready_at = now(), expires_at = $4
+	WHERE id = $1`
+
+	tag, err := s.db.Exec(ctx, query, id, archive, tokenHash, expiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrDataExportNotFound)
+	}
+
+	return nil
+}
+
+// FailDataExport marks the export as failed, the user may request a new one
+func (s *Storage) FailDataExport(ctx context.Context, id int64, reason string) error {
+	const op = "storage.postgres.FailDataExport"
+
+	tag, err := s.db.Exec(ctx, `UPDATE data_exports SET status = 'failed', error = $2 WHERE id = $1`, id, reason)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrDataExportNotFound)
+	}
+
+	return nil
+}
+
+// DeleteExpiredDataExports removes the archives that can't be downloaded anymore
+func (s *Storage) DeleteExpiredDataExports(ctx context.Context) (int64, error) {
+	const op = "storage.postgres.DeleteExpiredDataExports"
+
+	tag, err := s.db.Exec(ctx, `DELETE FROM data_exports WHERE expires_at < now()`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// userAppIDs selects the apps of the user: the ones with a role granted on them or signed in to,
+// the webhooks of an app only get the events of its users
+func userAppIDs(userID string) string {
+	return `ARRAY(
+		SELECT app_id FROM users_roles WHERE user_id = ` + userID + ` AND app_id IS NOT NULL
+		UNION
+		SELECT app_id FROM refresh_tokens WHERE user_id = ` + userID + `
+	)`
+}
+
+// userDeletedEvent is the outbox insert of the "deleted" CTE, the event carries only the id,
+// the CTEs share the snapshot taken before the deletion, so the apps of the user are still visible
+var userDeletedEvent = `
+		INSERT INTO outbox_events (type, aggregate_id, payload, app_ids)
+		SELECT '` + events.TypeUserDeleted + `', id, jsonb_build_object('user_id', id), ` + userAppIDs("deleted.id") + `
+		FROM deleted`
+
+// insertUserEvent writes the event into the outbox inside the transaction of the change,
+// the payload is the state of the user row after the change, see events.UserPayload
+func insertUserEvent(ctx context.Context, db execer, eventType string, userID int64) error {
+	query := `
+	INSERT INTO outbox_events (type, aggregate_id, payload, app_ids)
+	SELECT $1, users.id, jsonb_build_object(
+		'user_id', users.id,
+		'email', users.email::text,
+		'name', COALESCE(users.name, ''),
+		'phone', COALESCE(users.phone, ''),
+		'address', COALESCE(users.address, ''),
+		'activated', users.activated,
+		'disabled', users.disabled_at IS NOT NULL
+	), ` + userAppIDs("users.id") + `
+	FROM users WHERE users.id = $2`
+
+	_, err := db.Exec(ctx, query, eventType, userID)
+	return err
+}
+
+// PendingOutboxEvents returns the events due for publishing in order,
+// events of a user waiting for retry hold back the later events of the same user
+func (s *Storage) PendingOutboxEvents(ctx context.Context, limit int32) ([]models.OutboxEvent, error) {
+	const op = "storage.postgres.PendingOutboxEvents"
+
+	query := `
+	SELECT e.id, e.type, e.aggregate_id, e.payload, e.app_ids, e.created_at, e.attempts
+	FROM outbox_events e
+	WHERE e.published_at IS NULL AND e.next_attempt_at <= now()
+		AND NOT EXISTS (
+			SELECT 1 FROM outbox_events p
+			WHERE p.aggregate_id = e.aggregate_id AND p.published_at IS NULL
+				AND p.id < e.id AND p.next_attempt_at > now()
+		)
+	ORDER BY e.id
+	LIMIT $1`
+
+	rows, err := s.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var pending []models.OutboxEvent
+	for rows.Next() {
+		var event models.OutboxEvent
+		if err := rows.Scan(&event.ID, &event.Type, &event.UserID, &event.Payload, &event.AppIDs, &event.CreatedAt, &event.Attempts); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		pending = append(pending, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pending, nil
+}
+
+// MarkOutboxEventPublished marks the event as delivered
+func (s *Storage) MarkOutboxEventPublished(ctx context.Context, id int64) error {
+	const op = "storage.postgres.MarkOutboxEventPublished"
+
+	query := `UPDATE outbox_events SET published_at = now(), attempts = attempts + 1, last_error = '' WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// MarkOutboxEventFailed records the failed attempt, the event is retried at nextAttempt
+func (s *Storage) MarkOutboxEventFailed(ctx context.Context, id int64, reason string, nextAttempt time.Time) error {
+	const op = "storage.postgres.MarkOutboxEventFailed"
+
+	query := `UPDATE outbox_events SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, id, reason, nextAttempt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeletePublishedOutboxEvents removes the events published before the time
+func (s *Storage) DeletePublishedOutboxEvents(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.postgres.DeletePublishedOutboxEvents"
+
+	tag, err := s.db.Exec(ctx, `DELETE FROM outbox_events WHERE published_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// SaveWebhook stores the subscription of the app
+func (s *Storage) SaveWebhook(ctx context.Context, webhook models.Webhook) (models.Webhook, error) {
+	const op = "storage.postgres.SaveWebhook"
+
+	query := `
+	INSERT INTO webhooks (app_id, url, event_types, created_by)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at`
+
+	err := s.db.QueryRow(ctx, query, webhook.AppID, webhook.URL, webhook.EventTypes, webhook.CreatedBy).
+		Scan(&webhook.ID, &webhook.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign_key_violation
+			return models.Webhook{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+		return models.Webhook{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return webhook, nil
+}
+
+const webhookColumns = `id, app_id, url, event_types, created_by, created_at`
+
+func scanWebhook(row pgx.Row) (models.Webhook, error) {
+	var webhook models.Webhook
+	err := row.Scan(&webhook.ID, &webhook.AppID, &webhook.URL, &webhook.EventTypes, &webhook.CreatedBy, &webhook.CreatedAt)
+	return webhook, err
+}
+
+// Webhook returns the subscription by id
+func (s *Storage) Webhook(ctx context.Context, id int64) (models.Webhook, error) {
+	const op = "storage.postgres.Webhook"
+
+	webhook, err := scanWebhook(s.db.QueryRow(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Webhook{}, fmt.Errorf("%s: %w", op, storage.ErrWebhookNotFound)
+		}
+		return models.Webhook{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return webhook, nil
+}
+
+// Webhooks returns the subscriptions of the app, all of them for zero appID
+func (s *Storage) Webhooks(ctx context.Context, appID int32) ([]models.Webhook, error) {
+	const op = "storage.postgres.Webhooks"
+
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE $1 = 0 OR app_id = $1 ORDER BY id`
+
+	rows, err := s.db.Query(ctx, query, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook removes the subscription with its delivery log
+func (s *Storage) DeleteWebhook(ctx context.Context, id int64) error {
+	const op = "storage.postgres.DeleteWebhook"
+
+	tag, err := s.db.Exec(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrWebhookNotFound)
+	}
+
+	return nil
+}
+
+// EnqueueWebhookDeliveries creates a delivery of the event for every webhook of the apps subscribed to its type,
+// an event enqueued again doesn't create duplicates
+func (s *Storage) EnqueueWebhookDeliveries(ctx context.Context, eventID int64, eventType string, appIDs []int32, payload []byte) (int64, error) {
+	const op = "storage.postgres.EnqueueWebhookDeliveries"
+
+	query := `
+	INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+	SELECT id, $1, $2, $4 FROM webhooks WHERE $2 = ANY(event_types) AND app_id = ANY($3)
+	ON CONFLICT (webhook_id, event_id) DO NOTHING`
+
+	tag, err := s.db.Exec(ctx, query, eventID, eventType, appIDs, payload)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+const webhookDeliveryColumns = `d.id, d.webhook_id, w.app_id, w.url, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+	d.next_attempt_at, d.last_status_code, d.last_error, d.created_at, d.delivered_at`
+
+func scanWebhookDelivery(row pgx.Row) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	var deliveredAt *time.Time
+
+	err := row.Scan(
+		&delivery.ID, &delivery.WebhookID, &delivery.AppID, &delivery.URL, &delivery.EventID, &delivery.EventType,
+		&delivery.Payload, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastStatusCode,
+		&delivery.LastError, &delivery.CreatedAt, &deliveredAt,
+	)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	if deliveredAt != nil {
+		delivery.DeliveredAt = *deliveredAt
+	}
+
+	return delivery, nil
+}
+
+func scanWebhookDeliveries(rows pgx.Rows) ([]models.WebhookDelivery, error) {
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// ClaimWebhookDeliveries returns the due pending deliveries and postpones them by lease,
+// so other instances don't send them while they are in flight
+func (s *Storage) ClaimWebhookDeliveries(ctx context.Context, limit int32, lease time.Duration) ([]models.WebhookDelivery, error) {
+	const op = "storage.postgres.ClaimWebhookDeliveries"
+
+	query := `
+	WITH claimed AS (
+		UPDATE webhook_deliveries SET next_attempt_at = now() + $2 * interval '1 second'
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *
+	)
+	SELECT ` + webhookDeliveryColumns + `
+	FROM claimed d JOIN webhooks w ON w.id = d.webhook_id
+	ORDER BY d.id`
+
+	rows, err := s.db.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := scanWebhookDeliveries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// MarkWebhookDelivered records the successful attempt
+func (s *Storage) MarkWebhookDelivered(ctx context.Context, id int64, statusCode int32) error {
+	const op = "storage.postgres.MarkWebhookDelivered"
+
+	query := `
+	UPDATE webhook_deliveries
+	SET status = 'delivered', attempts = attempts + 1, last_status_code = $2, last_error = '', delivered_at = now()
+	WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, id, statusCode); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// MarkWebhookDeliveryFailed records the failed attempt, the delivery is retried at nextAttempt
+// or becomes failed for zero nextAttempt
+func (s *Storage) MarkWebhookDeliveryFailed(ctx context.Context, id int64, statusCode int32, reason string, nextAttempt time.Time) error {
+	const op = "storage.postgres.MarkWebhookDeliveryFailed"
+
+	query := `
+	UPDATE webhook_deliveries
+	SET status = CASE WHEN $4::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+		attempts = attempts + 1, last_status_code = $2, last_error = $3,
+		next_attempt_at = COALESCE($4, next_attempt_at)
+	WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, id, statusCode, reason, nullTime(nextAttempt)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// WebhookDeliveries returns the delivery log of the webhook, newest first
+func (s *Storage) WebhookDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error) {
+	const op = "storage.postgres.WebhookDeliveries"
+
+	query := `
+	SELECT ` + webhookDeliveryColumns + `
+	FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+	WHERE d.webhook_id = $1 AND ($2 = '' OR d.status = $2)
+	ORDER BY d.id DESC
+	LIMIT $3 OFFSET $4`
+
+	rows, err := s.db.Query(ctx, query, filter.WebhookID, filter.Status, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := scanWebhookDeliveries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// ReplayWebhookDelivery queues the failed delivery again with a fresh attempts budget
+func (s *Storage) ReplayWebhookDelivery(ctx context.Context, id int64) (models.WebhookDelivery, error) {
+	const op = "storage.postgres.ReplayWebhookDelivery"
+
+	query := `
+	WITH replayed AS (
+		UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = now()
+		WHERE id = $1 AND status = 'failed'
+		RETURNING *
+	)
+	SELECT ` + webhookDeliveryColumns + `
+	FROM replayed d JOIN webhooks w ON w.id = d.webhook_id`
+
+	delivery, err := scanWebhookDelivery(s.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, storage.ErrDeliveryNotFound)
+		}
+		return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return delivery, nil
+}
+
+// DeleteDeliveredWebhookDeliveries trims the delivery log, failed deliveries are kept for replay
+func (s *Storage) DeleteDeliveredWebhookDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.postgres.DeleteDeliveredWebhookDeliveries"
+
+	tag, err := s.db.Exec(ctx, `DELETE FROM webhook_deliveries WHERE status = 'delivered' AND delivered_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// EnqueueEmail queues the built message for sending
+func (s *Storage) EnqueueEmail(ctx context.Context, toEmail string, subject string, message []byte) (int64, error) {
+	const op = "storage.postgres.EnqueueEmail"
+
+	var id int64
+	err := s.db.QueryRow(ctx, `
+	INSERT INTO email_outbox (to_email, subject, message) VALUES ($1, $2, $3)
+	RETURNING id`, toEmail, subject, message).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// ClaimEmails returns the due pending emails and postpones them by lease,
+// so other instances don't send them while they are in flight
+func (s *Storage) ClaimEmails(ctx context.Context, limit int32, lease time.Duration) ([]models.QueuedEmail, error) {
+	const op = "storage.postgres.ClaimEmails"
+
+	query := `
+	UPDATE email_outbox SET next_attempt_at = now() + $2 * interval '1 second'
+	WHERE id IN (
+		SELECT id FROM email_outbox
+		WHERE status = 'pending' AND next_attempt_at <= now()
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, to_email, subject, message, attempts, created_at`
+
+	rows, err := s.db.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var emails []models.QueuedEmail
+	for rows.Next() {
+		var email models.QueuedEmail
+		if err := rows.Scan(&email.ID, &email.ToEmail, &email.Subject, &email.Message, &email.Attempts, &email.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		emails = append(emails, email)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return emails, nil
+}
+
+// MarkEmailSent records the successful attempt and drops the body, it holds live tokens
+func (s *Storage) MarkEmailSent(ctx context.Context, id int64) error {
+	const op = "storage.postgres.MarkEmailSent"
+
+	query := `
+	UPDATE email_outbox
+	SET status = 'sent', message = '{}', attempts = attempts + 1, last_error = '', sent_at = now()
+	WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// MarkEmailFailed records the failed attempt, the email is retried at nextAttempt
+// or becomes dead for zero nextAttempt
+func (s *Storage) MarkEmailFailed(ctx context.Context, id int64, reason string, nextAttempt time.Time) error {
+	const op = "storage.postgres.MarkEmailFailed"
+
+	query := `
+	UPDATE email_outbox
+	SET status = CASE WHEN $3::timestamptz IS NULL THEN 'dead' ELSE 'pending' END,
+		dead_at = CASE WHEN $3::timestamptz IS NULL THEN now() END,
+		attempts = attempts + 1, last_error = $2,
+		next_attempt_at = COALESCE($3, next_attempt_at)
+	WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, id, reason, nullTime(nextAttempt)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// EmailQueueStats returns the depth of the email queue
+func (s *Storage) EmailQueueStats(ctx context.Context) (models.EmailQueueStats, error) {
+	const op = "storage.postgres.EmailQueueStats"
+
+	query := `
+	SELECT
+		count(*) FILTER (WHERE status = 'pending'),
+		count(*) FILTER (WHERE status = 'pending' AND next_attempt_at <= now()),
+		count(*) FILTER (WHERE status = 'dead'),
+		min(created_at) FILTER (WHERE status = 'pending')
+	FROM email_outbox
+	WHERE status IN ('pending', 'dead')`
+
+	var stats models.EmailQueueStats
+	var oldest *time.Time
+
+	if err := s.db.QueryRow(ctx, query).Scan(&stats.Pending, &stats.Due, &stats.Dead, &oldest); err != nil {
+		return models.EmailQueueStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if oldest != nil {
+		stats.OldestPending = *oldest
+	}
+
+	return stats, nil
+}
+
+// DeleteFinishedEmails trims the emails sent before sentBefore and the ones dead before deadBefore
+func (s *Storage) DeleteFinishedEmails(ctx context.Context, sentBefore, deadBefore time.Time) (int64, error) {
+	const op = "storage.postgres.DeleteFinishedEmails"
+
+	query := `
+	DELETE FROM email_outbox
+	WHERE (status = 'sent' AND sent_at < $1) OR (status = 'dead' AND dead_at < $2)`
+
+	tag, err := s.db.Exec(ctx, query, sentBefore, deadBefore)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// RequeueDeadEmails makes the dead emails pending again with fresh attempts, all of them for empty ids,
+// returns the number of requeued emails
+func (s *Storage) RequeueDeadEmails(ctx context.Context, ids []int64) (int64, error) {
+	const op = "storage.postgres.RequeueDeadEmails"
+
+	query := `
+	UPDATE email_outbox
+	SET status = 'pending', attempts = 0, next_attempt_at = now(), dead_at = NULL
+	WHERE status = 'dead' AND (cardinality($1::bigint[]) = 0 OR id = ANY($1))`
+
+	if ids == nil {
+		ids = []int64{}
+	}
+
+	tag, err := s.db.Exec(ctx, query, ids)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// SaveAPIKey saves the key by its hash, the raw key is never stored
+func (s *Storage) SaveAPIKey(ctx context.Context, key models.APIKey, keyHash string) (models.APIKey, error) {
+	const op = "storage.postgres.SaveAPIKey"
+
+	query := `
+		INSERT INTO api_keys (user_id, app_id, name, prefix, key_hash, permissions, expires_at)
+		VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6, $7)
+		RETURNING ` + apiKeyColumns
+
+	saved, err := scanAPIKey(s.db.QueryRow(ctx, query, key.UserID, key.AppID, key.Name, key.Prefix, keyHash, key.Permissions, nullTime(key.ExpiresAt)))
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return saved, nil
+}
+
+// APIKeys returns personal keys of the user, or all keys of the app if appID is set
+func (s *Storage) APIKeys(ctx context.Context, userID int64, appID int32) ([]models.APIKey, error) {
+	const op = "storage.postgres.APIKeys"
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = $1 AND app_id IS NULL ORDER BY id`
+	args := []any{userID}
+	if appID != 0 {
+		query = `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE app_id = $1 ORDER BY id`
+		args = []any{appID}
+	}
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+func (s *Storage) APIKeyByID(ctx context.Context, id int64) (models.APIKey, error) {
+	const op = "storage.postgres.APIKeyByID"
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+
+	key, err := scanAPIKey(s.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.APIKey{}, fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+		}
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// RevokeAPIKey returns false if the key was already revoked
+func (s *Storage) RevokeAPIKey(ctx context.Context, id int64) (bool, error) {
+	const op = "storage.postgres.RevokeAPIKey"
+
+	query := `UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`
+
+	tag, err := s.db.Exec(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// UseAPIKey finds the active key by hash and marks it as used
+func (s *Storage) UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error) {
+	const op = "storage.postgres.UseAPIKey"
+
+	// keys of disabled users and users pending deletion stop working with the account
+	query := `
+		UPDATE api_keys SET last_used_at = now()
+		WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > now())
+		  AND user_id IN (SELECT id FROM users WHERE disabled_at IS NULL AND delete_after IS NULL)
+		RETURNING ` + apiKeyColumns
+
+	key, err := scanAPIKey(s.db.QueryRow(ctx, query, keyHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.APIKey{}, fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+		}
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+func (s *Storage) VerifyEmail(ctx context.Context, token string) (int64, error) {
+	const op = "storage.postgres.VerifyEmail"
+
+	// Начинаем транзакцию
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer func(tx pgx.Tx, ctx context.Context) {
+		err := tx.Rollback(ctx)
+		if err != nil {
+			return
+		}
+	}(tx, ctx)
+
+	// 1. Получаем пользователя по токену и проверяем срок действия
+	var userID int64
+	checkQuery := `
+		SELECT user_id FROM email_verification_tokens
+		WHERE token = $1 AND expires_at > now()`
+
+	err = tx.QueryRow(ctx, checkQuery, token).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrTokenNotFound)
+		}
+		return 0, fmt.Errorf("%s: failed to check token: %w", op, err)
+	}
+
+	updateQuery := `UPDATE users SET activated = true WHERE id = $1`
+	_, err = tx.Exec(ctx, updateQuery, userID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to activate user: %w", op, err)
+	}
+
+	if err := insertUserEvent(ctx, tx, events.TypeUserVerified, userID); err != nil {
+		return 0, fmt.Errorf("%s: failed to write event: %w", op, err)
+	}
 
 	deleteQuery := `DELETE FROM email_verification_tokens WHERE token = $1`
 	_, err = tx.Exec(ctx, deleteQuery, token)
@@ -446,22 +1963,65 @@ func (s *Storage) VerifyEmail(ctx context.Context, token string) (int64, error)
 	return userID, nil
 }
 
-func (s *Storage) GetVerificationTokenByUserID(ctx context.Context, userID int64) (string, time.Time, error) {
+// GetVerificationTokenByUserID returns the pending verification of the user with its send counters
+func (s *Storage) GetVerificationTokenByUserID(ctx context.Context, userID int64) (models.VerificationToken, error) {
 	const op = "storage.postgres.GetVerificationTokenByUserID"
 
-	query := `SELECT token, expires_at FROM email_verification_tokens WHERE user_id = $1`
+	query := `
+	SELECT token, expires_at, last_sent_at, sent_count, window_started_at
+	FROM email_verification_tokens WHERE user_id = $1`
 
-	var token string
-	var expiresAt time.Time
-	err := s.db.QueryRow(ctx, query, userID).Scan(&token, &expiresAt)
+	var token models.VerificationToken
+	var lastSentAt, windowStartedAt *time.Time
+	err := s.db.QueryRow(ctx, query, userID).Scan(
+		&token.Token, &token.ExpiresAt, &lastSentAt, &token.SentCount, &windowStartedAt,
+	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return "", time.Time{}, fmt.Errorf("%s: %w", op, storage.ErrTokenNotFound)
+			return models.VerificationToken{}, fmt.Errorf("%s: %w", op, storage.ErrTokenNotFound)
 		}
-		return "", time.Time{}, fmt.Errorf("%s: %w", op, err)
+		return models.VerificationToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if lastSentAt != nil {
+		token.LastSentAt = *lastSentAt
+	}
+	if windowStartedAt != nil {
+		token.WindowStartedAt = *windowStartedAt
+	}
+
+	return token, nil
+}
+
+// RecordVerificationSend saves the token and counters of a sent verification email,
+// it fails with ErrConcurrentUpdate if another email was recorded after prevSentAt was read
+func (s *Storage) RecordVerificationSend(ctx context.Context, userID int64, token models.VerificationToken, prevSentAt time.Time) error {
+	const op = "storage.postgres.RecordVerificationSend"
+
+	query := `
+	INSERT INTO email_verification_tokens (token, user_id, expires_at, last_sent_at, sent_count, window_started_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (user_id) DO UPDATE SET
+		token = EXCLUDED.token,
+		expires_at = EXCLUDED.expires_at,
+		created_at = CASE WHEN email_verification_tokens.token = EXCLUDED.token
+			THEN email_verification_tokens.created_at ELSE now() END,
+		last_sent_at = EXCLUDED.last_sent_at,
+		sent_count = EXCLUDED.sent_count,
+		window_started_at = EXCLUDED.window_started_at
+	WHERE email_verification_tokens.last_sent_at IS NOT DISTINCT FROM $7`
+
+	tag, err := s.db.Exec(ctx, query,
+		token.Token, userID, token.ExpiresAt, token.LastSentAt, token.SentCount, token.WindowStartedAt, nullTime(prevSentAt),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrConcurrentUpdate)
 	}
 
-	return token, expiresAt, nil
+	return nil
 }
 
 // SaveResetToken сохраняет токен для сброса пароля
@@ -10,4 +10,10 @@ var (
 	ErrTokenNotFound        = errors.New("token not found")
 	ErrRefreshTokenNotFound = errors.New("refresh token not found")
 	ErrPermissionNotFound   = errors.New("permission not found")
+	ErrRoleNotFound         = errors.New("role not found")
+	ErrAPIKeyNotFound       = errors.New("api key not found")
+	ErrDataExportNotFound   = errors.New("data export not found")
+	ErrWebhookNotFound      = errors.New("webhook not found")
+	ErrDeliveryNotFound     = errors.New("webhook delivery not found")
+	ErrConcurrentUpdate     = errors.New("row was changed concurrently")
 )
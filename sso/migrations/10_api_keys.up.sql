@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- app keys are created by admins on behalf of the app
+    app_id INT REFERENCES apps(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT UNIQUE NOT NULL,
+    permissions TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_app_id ON api_keys (app_id);
+
+INSERT INTO permissions (code, description)
+VALUES ('api_keys:manage', 'Manage API keys of apps and other users')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO roles_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id FROM roles
+JOIN permissions ON permissions.code = 'api_keys:manage'
+WHERE roles.code = 'admin'
+ON CONFLICT DO NOTHING;
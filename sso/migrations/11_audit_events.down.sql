@@ -0,0 +1,17 @@
+DELETE FROM permissions WHERE code = 'audit:read';
+
+DROP INDEX IF EXISTS idx_audit_events_type_created_at;
+DROP INDEX IF EXISTS idx_audit_events_actor_id;
+ALTER INDEX IF EXISTS idx_audit_events_created_at RENAME TO idx_audit_log_created_at;
+ALTER INDEX IF EXISTS idx_audit_events_target_user_id RENAME TO idx_audit_log_target_user_id;
+
+ALTER TABLE audit_events
+    DROP COLUMN IF EXISTS outcome,
+    DROP COLUMN IF EXISTS user_agent,
+    DROP COLUMN IF EXISTS ip,
+    DROP COLUMN IF EXISTS app_id;
+
+ALTER TABLE audit_events RENAME COLUMN payload TO details;
+ALTER TABLE audit_events RENAME COLUMN type TO action;
+ALTER SEQUENCE IF EXISTS audit_events_id_seq RENAME TO audit_log_id_seq;
+ALTER TABLE audit_events RENAME TO audit_log;
@@ -0,0 +1,26 @@
+-- audit_log of permission changes becomes the log of every security event
+ALTER TABLE audit_log RENAME TO audit_events;
+ALTER SEQUENCE IF EXISTS audit_log_id_seq RENAME TO audit_events_id_seq;
+ALTER TABLE audit_events RENAME COLUMN action TO type;
+ALTER TABLE audit_events RENAME COLUMN details TO payload;
+
+ALTER TABLE audit_events
+    ADD COLUMN IF NOT EXISTS app_id INT,
+    ADD COLUMN IF NOT EXISTS ip TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS user_agent TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS outcome TEXT NOT NULL DEFAULT 'success';
+
+ALTER INDEX IF EXISTS idx_audit_log_target_user_id RENAME TO idx_audit_events_target_user_id;
+ALTER INDEX IF EXISTS idx_audit_log_created_at RENAME TO idx_audit_events_created_at;
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_type_created_at ON audit_events (type, created_at);
+
+INSERT INTO permissions (code, description)
+VALUES ('audit:read', 'Read security audit events')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO roles_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id FROM roles
+JOIN permissions ON permissions.code = 'audit:read'
+WHERE roles.code = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_users_email_prefix;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS disabled_at;
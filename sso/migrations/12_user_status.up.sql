@@ -0,0 +1,6 @@
+-- disabled users can't log in or refresh their tokens
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_users_email_prefix ON users ((lower(email::text)) text_pattern_ops);
@@ -0,0 +1,6 @@
+-- accounts scheduled for deletion are erased by the purge worker after delete_after,
+-- logging in before that cancels the deletion
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS delete_after TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_users_delete_after ON users (delete_after) WHERE delete_after IS NOT NULL;
@@ -0,0 +1,17 @@
+-- personal data archives built in the background, downloaded by the link emailed to the user
+CREATE TABLE IF NOT EXISTS data_exports (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending', -- pending, running, ready, failed
+    token_hash TEXT UNIQUE, -- sha256 of the download token, set when the archive is ready
+    archive BYTEA,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    started_at TIMESTAMP WITH TIME ZONE,
+    ready_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE
+);
+
+-- one export in progress per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_exports_user_active ON data_exports (user_id) WHERE status IN ('pending', 'running');
+CREATE INDEX IF NOT EXISTS idx_data_exports_status ON data_exports (status, created_at);
@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE code = 'email_queue:read';
+
+DROP TABLE IF EXISTS email_outbox;
@@ -0,0 +1,26 @@
+-- emails are queued by the requests and sent by the workers
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    to_email TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    message JSONB NOT NULL, -- the built message, the template is not rendered again on retries
+    status TEXT NOT NULL DEFAULT 'pending', -- pending, sent, dead
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    sent_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_outbox_pending ON email_outbox (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_email_outbox_sent_at ON email_outbox (sent_at) WHERE status = 'sent';
+
+INSERT INTO permissions (code, description)
+VALUES ('email_queue:read', 'Read the state of the email queue')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO roles_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id FROM roles
+JOIN permissions ON permissions.code = 'email_queue:read'
+WHERE roles.code = 'admin'
+ON CONFLICT DO NOTHING;
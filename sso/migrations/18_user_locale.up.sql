@@ -0,0 +1,2 @@
+-- locale of the emails sent to the user, empty is the default one
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale TEXT NOT NULL DEFAULT '';
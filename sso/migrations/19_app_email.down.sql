@@ -0,0 +1,7 @@
+ALTER TABLE apps
+    DROP COLUMN IF EXISTS email_from,
+    DROP COLUMN IF EXISTS email_from_name,
+    DROP COLUMN IF EXISTS email_logo_url,
+    DROP COLUMN IF EXISTS email_color,
+    DROP COLUMN IF EXISTS verification_url,
+    DROP COLUMN IF EXISTS reset_password_url;
@@ -0,0 +1,9 @@
+-- branding of the emails sent for the app, NULL falls back to the config
+ALTER TABLE apps
+    ADD COLUMN IF NOT EXISTS email_from TEXT,
+    ADD COLUMN IF NOT EXISTS email_from_name TEXT,
+    ADD COLUMN IF NOT EXISTS email_logo_url TEXT,
+    ADD COLUMN IF NOT EXISTS email_color TEXT,
+    -- links in the emails, {token} is replaced with the token, e.g. https://toys.example.com/verify?token={token}
+    ADD COLUMN IF NOT EXISTS verification_url TEXT,
+    ADD COLUMN IF NOT EXISTS reset_password_url TEXT;
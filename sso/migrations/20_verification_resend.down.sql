@@ -0,0 +1,4 @@
+ALTER TABLE email_verification_tokens
+    DROP COLUMN IF EXISTS last_sent_at,
+    DROP COLUMN IF EXISTS sent_count,
+    DROP COLUMN IF EXISTS window_started_at;
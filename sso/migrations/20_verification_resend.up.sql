@@ -0,0 +1,5 @@
+-- verification emails are limited per user, the counters are kept when the token is renewed
+ALTER TABLE email_verification_tokens
+    ADD COLUMN IF NOT EXISTS last_sent_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS sent_count INT NOT NULL DEFAULT 0, -- emails since window_started_at
+    ADD COLUMN IF NOT EXISTS window_started_at TIMESTAMP WITH TIME ZONE;
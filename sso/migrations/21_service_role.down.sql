@@ -0,0 +1,3 @@
+DELETE FROM roles WHERE code = 'service';
+
+DELETE FROM permissions WHERE code = 'api_keys:validate';
@@ -0,0 +1,14 @@
+-- services calling sso (profile) authenticate with an api key of a user holding the service role
+INSERT INTO permissions (code, description)
+VALUES ('api_keys:validate', 'Validate API keys of the callers')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO roles (code, description)
+VALUES ('service', 'Internal services')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO roles_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id FROM roles
+JOIN permissions ON permissions.code IN ('api_keys:validate', 'permissions:read')
+WHERE roles.code = 'service'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+-- apps the user belonged to when the event happened, webhooks only get the events of their app users
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS app_ids INT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE code = 'email_queue:manage';
+
+DROP INDEX IF EXISTS idx_email_outbox_dead_at;
+ALTER TABLE email_outbox DROP COLUMN IF EXISTS dead_at;
@@ -0,0 +1,18 @@
+-- dead emails are deleted email.dead_retention after they died
+ALTER TABLE email_outbox ADD COLUMN IF NOT EXISTS dead_at TIMESTAMP WITH TIME ZONE;
+UPDATE email_outbox SET dead_at = now() WHERE status = 'dead';
+
+CREATE INDEX IF NOT EXISTS idx_email_outbox_dead_at ON email_outbox (dead_at) WHERE status = 'dead';
+
+-- the bodies of the sent emails hold live tokens, they are not needed anymore
+UPDATE email_outbox SET message = '{}' WHERE status = 'sent';
+
+INSERT INTO permissions (code, description)
+VALUES ('email_queue:manage', 'Requeue the dead emails')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO roles_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id FROM roles
+JOIN permissions ON permissions.code = 'email_queue:manage'
+WHERE roles.code = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,6 @@
+ALTER TABLE apps
+    DROP COLUMN IF EXISTS access_ttl_seconds,
+    DROP COLUMN IF EXISTS refresh_ttl_seconds,
+    DROP COLUMN IF EXISTS embed_permissions,
+    DROP COLUMN IF EXISTS extra_claims,
+    DROP COLUMN IF EXISTS audience;
@@ -0,0 +1,6 @@
+ALTER TABLE apps
+    ADD COLUMN IF NOT EXISTS access_ttl_seconds INT,
+    ADD COLUMN IF NOT EXISTS refresh_ttl_seconds INT,
+    ADD COLUMN IF NOT EXISTS embed_permissions BOOLEAN NOT NULL DEFAULT true,
+    ADD COLUMN IF NOT EXISTS extra_claims JSONB NOT NULL DEFAULT '{}'::jsonb,
+    ADD COLUMN IF NOT EXISTS audience TEXT;
@@ -0,0 +1,13 @@
+INSERT INTO users_permissions (user_id, permission_id)
+SELECT users_roles.user_id, permissions.id FROM users_roles
+JOIN roles ON roles.id = users_roles.role_id
+JOIN permissions ON permissions.code = roles.code
+ON CONFLICT DO NOTHING;
+
+DROP TABLE IF EXISTS users_roles;
+DROP TABLE IF EXISTS roles_permissions;
+DROP TABLE IF EXISTS roles;
+
+DELETE FROM permissions WHERE code NOT IN ('user', 'admin', 'staff');
+DROP INDEX IF EXISTS idx_permissions_code;
+ALTER TABLE permissions DROP COLUMN IF EXISTS description;
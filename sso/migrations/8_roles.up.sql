@@ -0,0 +1,82 @@
+ALTER TABLE permissions ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_permissions_code ON permissions (code);
+
+CREATE TABLE IF NOT EXISTS roles (
+    id BIGSERIAL PRIMARY KEY,
+    code TEXT UNIQUE NOT NULL,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS roles_permissions (
+    role_id BIGINT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id BIGINT NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+-- app_id NULL means the role is granted for every app
+CREATE TABLE IF NOT EXISTS users_roles (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id BIGINT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    app_id INT REFERENCES apps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_roles_scope ON users_roles (user_id, role_id, COALESCE(app_id, 0));
+CREATE INDEX IF NOT EXISTS idx_users_roles_user_id ON users_roles (user_id);
+
+-- former permission codes become roles, ids are kept the same
+INSERT INTO roles (id, code, description)
+VALUES
+    (1, 'user', 'Regular customer'),
+    (2, 'admin', 'Full access to every service'),
+    (3, 'staff', 'Support staff')
+ON CONFLICT DO NOTHING;
+
+SELECT setval('roles_id_seq', (SELECT MAX(id) FROM roles));
+
+INSERT INTO permissions (code, description)
+VALUES
+    ('profile:read:own', 'Read own profile'),
+    ('profile:write:own', 'Create, update and delete own profile'),
+    ('profile:read:any', 'Read any profile'),
+    ('profile:write:any', 'Update and delete any profile'),
+    ('profile:list', 'List all profiles'),
+    ('users:read', 'Read user accounts'),
+    ('users:manage', 'Disable, enable and delete user accounts'),
+    ('permissions:read', 'Read user permissions and roles'),
+    ('permissions:manage', 'Grant and revoke permissions and roles'),
+    ('orders:refund', 'Refund orders')
+ON CONFLICT (code) DO NOTHING;
+
+-- role codes are still granted as permissions so existing checks keep working
+INSERT INTO roles_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id FROM roles
+JOIN permissions ON permissions.code IN ('user', 'profile:read:own', 'profile:write:own')
+WHERE roles.code = 'user'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO roles_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id FROM roles
+JOIN permissions ON permissions.code IN (
+    'staff', 'profile:read:own', 'profile:write:own', 'profile:read:any', 'users:read', 'permissions:read'
+)
+WHERE roles.code = 'staff'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO roles_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id FROM roles
+JOIN permissions ON permissions.code <> 'staff' AND permissions.code <> 'user'
+WHERE roles.code = 'admin'
+ON CONFLICT DO NOTHING;
+
+-- move role-like grants to role assignments
+INSERT INTO users_roles (user_id, role_id)
+SELECT users_permissions.user_id, roles.id FROM users_permissions
+JOIN permissions ON permissions.id = users_permissions.permission_id
+JOIN roles ON roles.code = permissions.code
+ON CONFLICT DO NOTHING;
+
+DELETE FROM users_permissions
+USING permissions, roles
+WHERE permissions.id = users_permissions.permission_id AND roles.code = permissions.code;
@@ -0,0 +1,39 @@
+package sl
+
+import (
+	"context"
+	"log/slog"
+	"sso/pkg/requestid"
+)
+
+// ContextHandler adds the request ID, the method and the user of the request to the records
+// logged with a context, e.g. log.InfoContext(ctx, ...)
+type ContextHandler struct {
+	slog.Handler
+}
+
+// NewContextHandler wraps the handler h
+func NewContextHandler(h slog.Handler) *ContextHandler {
+	return &ContextHandler{Handler: h}
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if r := requestid.FromContext(ctx); r != nil {
+		record.AddAttrs(
+			slog.String("request_id", r.ID),
+			slog.String("method", r.Method),
+		)
+		if userID := r.UserID(); userID != 0 {
+			record.AddAttrs(slog.Int64("user_id", userID))
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name)}
+}
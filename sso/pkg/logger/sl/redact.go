@@ -0,0 +1,115 @@
+package sl
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces the masked values
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are the attributes masked by their name, compared in lower case without underscores,
+// the values of ambiguous keys such as "name" are wrapped in Sensitive instead
+var sensitiveKeys = map[string]bool{
+	"email":        true,
+	"toemail":      true,
+	"emailprefix":  true,
+	"phone":        true,
+	"address":      true,
+	"password":     true,
+	"token":        true,
+	"accesstoken":  true,
+	"refreshtoken": true,
+	"secret":       true,
+	"apikey":       true,
+}
+
+// emailPattern finds the addresses in free text, e.g. the errors of the mail servers
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// Sensitive marks a value as personal data, e.g. slog.Any("name", sl.Sensitive(name)),
+// RedactHandler masks it, other handlers log it as is
+type Sensitive string
+
+func (s Sensitive) LogValue() slog.Value {
+	return slog.StringValue(string(s))
+}
+
+// RedactHandler masks the personal data: the attributes with sensitive names, the Sensitive values
+// and the emails in the errors
+type RedactHandler struct {
+	slog.Handler
+}
+
+// NewRedactHandler wraps the handler h, it is used outside of the local environment
+func NewRedactHandler(h slog.Handler) *RedactHandler {
+	return &RedactHandler{Handler: h}
+}
+
+func (h *RedactHandler) Handle(ctx context.Context, record slog.Record) error {
+	masked := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		masked.AddAttrs(redact(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, masked)
+}
+
+func (h *RedactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	masked := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		masked[i] = redact(a)
+	}
+	return &RedactHandler{Handler: h.Handler.WithAttrs(masked)}
+}
+
+func (h *RedactHandler) WithGroup(name string) slog.Handler {
+	return &RedactHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+func redact(a slog.Attr) slog.Attr {
+	if s, ok := a.Value.Any().(Sensitive); ok {
+		return slog.String(a.Key, mask(string(s)))
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		masked := make([]any, len(group))
+		for i, ga := range group {
+			masked[i] = redact(ga)
+		}
+		return slog.Group(a.Key, masked...)
+	}
+
+	if sensitiveKeys[strings.ReplaceAll(strings.ToLower(a.Key), "_", "")] {
+		value := a.Value.Resolve()
+		if value.Kind() == slog.KindString {
+			return slog.String(a.Key, mask(value.String()))
+		}
+		return slog.String(a.Key, redacted)
+	}
+
+	if a.Key == "error" {
+		return slog.String(a.Key, maskEmails(a.Value.Resolve().String()))
+	}
+
+	return a
+}
+
+// maskEmails masks every email in the text
+func maskEmails(text string) string {
+	return emailPattern.ReplaceAllStringFunc(text, mask)
+}
+
+// mask hides the value, emails keep the domain to tell the mail providers apart
+func mask(value string) string {
+	if value == "" {
+		return ""
+	}
+	if at := strings.LastIndexByte(value, '@'); at > 0 {
+		return value[:1] + "***" + value[at:]
+	}
+	return redacted
+}
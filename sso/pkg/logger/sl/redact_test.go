@@ -0,0 +1,130 @@
+package sl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newLogger returns a redacting logger and the JSON line it wrote last
+func newLogger(t *testing.T) (*slog.Logger, func() map[string]any) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	log := slog.New(NewRedactHandler(slog.NewJSONHandler(&buf, nil)))
+
+	return log, func() map[string]any {
+		t.Helper()
+
+		lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(lines[len(lines)-1], &entry))
+		return entry
+	}
+}
+
+func TestRedactHandler_Keys(t *testing.T) {
+	log, last := newLogger(t)
+
+	log.Info("login",
+		slog.String("email", "aigerim@example.com"),
+		slog.String("to_email", "user@example.com"),
+		slog.String("Password", "qwerty"),
+		slog.String("refresh_token", "eyJhbGciOi"),
+		slog.Int("api_key", 42),
+		slog.String("phone", ""),
+		slog.Int64("userID", 7),
+		slog.String("op", "Auth.Login"),
+	)
+
+	entry := last()
+	assert.Equal(t, "a***@example.com", entry["email"])
+	assert.Equal(t, "u***@example.com", entry["to_email"])
+	assert.Equal(t, redacted, entry["Password"])
+	assert.Equal(t, redacted, entry["refresh_token"])
+	assert.Equal(t, redacted, entry["api_key"], "the values of other kinds are replaced")
+	assert.Equal(t, "", entry["phone"])
+	assert.Equal(t, float64(7), entry["userID"])
+	assert.Equal(t, "Auth.Login", entry["op"])
+}
+
+func TestRedactHandler_Groups(t *testing.T) {
+	log, last := newLogger(t)
+
+	log.Info("registered", slog.Group("user",
+		slog.Int64("id", 7),
+		slog.String("email", "user@example.com"),
+		slog.Group("contacts", slog.String("phone", "+77010000000")),
+	))
+
+	user := last()["user"].(map[string]any)
+	assert.Equal(t, float64(7), user["id"])
+	assert.Equal(t, "u***@example.com", user["email"])
+	assert.Equal(t, redacted, user["contacts"].(map[string]any)["phone"])
+
+	log.WithGroup("request").Info("sent", slog.String("email", "user@example.com"))
+
+	request := last()["request"].(map[string]any)
+	assert.Equal(t, "u***@example.com", request["email"])
+}
+
+func TestRedactHandler_WithAttrs(t *testing.T) {
+	log, last := newLogger(t)
+
+	log = log.With(slog.String("email", "user@example.com"), slog.String("op", "Auth.RegisterNewUser"))
+	log.Info("registering user", slog.String("token", "secret"))
+
+	entry := last()
+	assert.Equal(t, "u***@example.com", entry["email"])
+	assert.Equal(t, "Auth.RegisterNewUser", entry["op"])
+	assert.Equal(t, redacted, entry["token"])
+
+	// the handlers derived from the redacting one keep redacting
+	log.WithGroup("queue").With(slog.String("address", "Street 1")).Info("queued")
+
+	assert.Equal(t, redacted, last()["queue"].(map[string]any)["address"])
+}
+
+func TestRedactHandler_Sensitive(t *testing.T) {
+	log, last := newLogger(t)
+
+	log.Info("creating api key",
+		slog.Any("name", Sensitive("Aigerim")),
+		slog.Any("contact", Sensitive("aigerim@example.com")),
+		slog.Group("user", slog.Any("name", Sensitive("Aigerim"))),
+	)
+
+	entry := last()
+	assert.Equal(t, redacted, entry["name"])
+	assert.Equal(t, "a***@example.com", entry["contact"])
+	assert.Equal(t, redacted, entry["user"].(map[string]any)["name"])
+
+	// without the redacting handler the value is logged as is
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("creating api key", slog.Any("name", Sensitive("Aigerim")))
+	assert.Contains(t, buf.String(), `"name":"Aigerim"`)
+}
+
+func TestRedactHandler_ErrorEmails(t *testing.T) {
+	log, last := newLogger(t)
+
+	err := fmt.Errorf("mailer.SMTP.Send: 550 5.1.1 <user.name+tag@mail.example.com>: mailbox unavailable, cc a@b.kz: %w",
+		errors.New("permanent"))
+
+	log.Error("failed to send email", Err(err))
+	assert.Equal(t,
+		"mailer.SMTP.Send: 550 5.1.1 <u***@mail.example.com>: mailbox unavailable, cc a***@b.kz: permanent",
+		last()["error"])
+
+	log.Error("failed to send email", slog.Any("error", err))
+	assert.NotContains(t, last()["error"], "user.name+tag")
+
+	log.Error("failed to connect", Err(errors.New("dial tcp 10.0.0.1:587: connection refused")))
+	assert.Equal(t, "dial tcp 10.0.0.1:587: connection refused", last()["error"])
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor counts and times the requests, it goes first in the chain
+// to see the codes of the recovered panics and the denied requests
+func (s *Server) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+
+		resp, err := handler(ctx, req)
+
+		service, method := splitMethod(info.FullMethod)
+		s.grpcDuration.WithLabelValues(service, method).Observe(time.Since(start).Seconds())
+		s.grpcHandled.WithLabelValues(service, method, status.Code(err).String()).Inc()
+
+		return resp, err
+	}
+}
+
+// splitMethod splits "/auth.Auth/Login" into "auth.Auth" and "Login"
+func splitMethod(fullMethod string) (string, string) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return "unknown", "unknown"
+	}
+	return service, method
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+// GatewayMiddleware counts and times the gateway requests by their route template,
+// e.g. /v1/admin/users/{user_id=*}, so the paths with IDs don't make new series
+func (s *Server) GatewayMiddleware(next runtime.HandlerFunc) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next(rec, r, pathParams)
+
+		route := "unknown"
+		if pattern, ok := runtime.HTTPPattern(r.Context()); ok {
+			route = pattern.String()
+		}
+		s.httpDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+		s.httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+	}
+}
+
+// statusRecorder remembers the status code written by the gateway
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush keeps the streamed responses working
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,48 @@
+// Package metrics holds the Prometheus metrics shared by the services: the gRPC and gateway requests
+// and the database pool, every service registers them in its own namespace and registry
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Server is the metrics of the requests handled by a service
+type Server struct {
+	grpcHandled  *prometheus.CounterVec
+	grpcDuration *prometheus.HistogramVec
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+}
+
+// NewServer creates the metrics of the requests in the namespace, e.g. "sso", and registers them
+func NewServer(namespace string, registry prometheus.Registerer) *Server {
+	s := &Server{
+		grpcHandled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "grpc_server_handled_total",
+			Help:      "Handled gRPC requests by method and status code.",
+		}, []string{"grpc_service", "grpc_method", "grpc_code"}),
+
+		grpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "grpc_server_handling_seconds",
+			Help:      "Latency of the gRPC requests by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"grpc_service", "grpc_method"}),
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Gateway requests by route and status code.",
+		}, []string{"method", "route", "code"}),
+
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of the gateway requests by route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+	}
+
+	registry.MustRegister(s.grpcHandled, s.grpcDuration, s.httpRequests, s.httpDuration)
+
+	return s
+}
@@ -0,0 +1,180 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"sso/pkg/logger/sl"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"google.golang.org/grpc"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule describes what the caller of a method needs
+type Rule struct {
+	Required    []string `yaml:"required"`     // mandatory permissions
+	OneOf       []string `yaml:"one_of"`       // one of given permissions
+	RequireAuth bool     `yaml:"require_auth"` // require authentication without specific permissions
+}
+
+// Protected reports whether the method needs an authenticated caller
+func (r Rule) Protected() bool {
+	return r.RequireAuth || len(r.Required) > 0 || len(r.OneOf) > 0
+}
+
+// Policy is the section of the policy file for one service
+type Policy struct {
+	Default Rule            `yaml:"default"`
+	Methods map[string]Rule `yaml:"methods"`
+}
+
+// Rule returns rule of the full gRPC method name, the default one if method is not listed
+func (p *Policy) Rule(method string) Rule {
+	if rule, ok := p.Methods[method]; ok {
+		return rule
+	}
+	return p.Default
+}
+
+// Validate checks that every method of the policy is registered on the gRPC server
+func (p *Policy) Validate(services map[string]grpc.ServiceInfo) error {
+	registered := make(map[string]bool)
+	for service, info := range services {
+		for _, method := range info.Methods {
+			registered["/"+service+"/"+method.Name] = true
+		}
+	}
+
+	var unknown []string
+	for method := range p.Methods {
+		if !registered[method] {
+			unknown = append(unknown, method)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown methods in policy: %s", strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+// Load reads the section of the policy file, unknown keys are rejected,
+// a misspelled key would otherwise leave the method without its permissions
+func Load(path string, section string) (*Policy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+	defer file.Close()
+
+	dec := yaml.NewDecoder(file)
+	dec.KnownFields(true)
+
+	var sections map[string]Policy
+	if err := dec.Decode(&sections); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("policy file is empty")
+		}
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	policy, ok := sections[section]
+	if !ok {
+		return nil, fmt.Errorf("policy section %q not found", section)
+	}
+
+	return &policy, nil
+}
+
+// Store keeps the current policy, reloads replace it atomically
+type Store struct {
+	path    string
+	section string
+
+	current atomic.Pointer[Policy]
+
+	mu       sync.Mutex
+	services map[string]grpc.ServiceInfo
+}
+
+// MustLoad loads the section of policy file, panics on error
+func MustLoad(path string, section string) *Store {
+	policy, err := Load(path, section)
+	if err != nil {
+		panic(err)
+	}
+
+	store := &Store{path: path, section: section}
+	store.current.Store(policy)
+
+	return store
+}
+
+// Rule returns rule of the method from the current policy
+func (s *Store) Rule(method string) Rule {
+	return s.current.Load().Rule(method)
+}
+
+// Bind validates the policy against the registered services, later reloads are validated as well
+func (s *Store) Bind(services map[string]grpc.ServiceInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.current.Load().Validate(services); err != nil {
+		return err
+	}
+
+	s.services = services
+	return nil
+}
+
+// Reload reads the policy file again, the current policy is kept if the new one is invalid
+func (s *Store) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policy, err := Load(s.path, s.section)
+	if err != nil {
+		return err
+	}
+
+	if s.services != nil {
+		if err := policy.Validate(s.services); err != nil {
+			return err
+		}
+	}
+
+	s.current.Store(policy)
+	return nil
+}
+
+// ReloadOnSIGHUP reloads the policy on every SIGHUP until the context is done
+func (s *Store) ReloadOnSIGHUP(ctx context.Context, log *slog.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := s.Reload(); err != nil {
+				log.Error("failed to reload policy, keeping the current one", slog.String("path", s.path), sl.Err(err))
+				continue
+			}
+			log.Info("policy reloaded", slog.String("path", s.path))
+		}
+	}
+}
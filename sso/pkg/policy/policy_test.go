@@ -0,0 +1,145 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+const testPolicy = `
+sso:
+  default:
+    require_auth: true
+  methods:
+    /auth.Auth/Login: {}
+    /auth.UserAdmin/ListUsers:
+      required: ["users:read"]
+profile:
+  methods:
+    /profile.Profile/GetProfile:
+      one_of: ["profile:read:own", "profile:read:any"]
+`
+
+var services = map[string]grpc.ServiceInfo{
+	"auth.Auth":      {Methods: []grpc.MethodInfo{{Name: "Login"}, {Name: "Logout"}}},
+	"auth.UserAdmin": {Methods: []grpc.MethodInfo{{Name: "ListUsers"}}},
+}
+
+// writePolicy writes the policy file and returns its path
+func writePolicy(t *testing.T, dir string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, "methods.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	policy, err := Load(writePolicy(t, t.TempDir(), testPolicy), "sso")
+	require.NoError(t, err)
+
+	assert.False(t, policy.Rule("/auth.Auth/Login").Protected())
+	assert.Equal(t, []string{"users:read"}, policy.Rule("/auth.UserAdmin/ListUsers").Required)
+	// the methods that are not listed get the default rule
+	assert.True(t, policy.Rule("/auth.Auth/Logout").RequireAuth)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		section string
+		wantErr string
+	}{
+		{
+			name:    "unknown rule key",
+			content: "sso:\n  methods:\n    /auth.UserAdmin/ListUsers:\n      requried: [\"users:read\"]\n",
+			section: "sso",
+			wantErr: "field requried not found",
+		},
+		{
+			name:    "unknown section key",
+			content: "sso:\n  defaults:\n    require_auth: true\n",
+			section: "sso",
+			wantErr: "field defaults not found",
+		},
+		{
+			name:    "missing section",
+			content: testPolicy,
+			section: "orders",
+			wantErr: `policy section "orders" not found`,
+		},
+		{
+			name:    "empty file",
+			content: "",
+			section: "sso",
+			wantErr: "policy file is empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writePolicy(t, t.TempDir(), tt.content), tt.section)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	policy, err := Load(writePolicy(t, t.TempDir(), testPolicy), "sso")
+	require.NoError(t, err)
+	require.NoError(t, policy.Validate(services))
+
+	policy.Methods["/auth.Auth/Loginn"] = Rule{}
+	policy.Methods["/auth.Auth/Delete"] = Rule{}
+
+	err = policy.Validate(services)
+	require.Error(t, err)
+	assert.EqualError(t, err, "unknown methods in policy: /auth.Auth/Delete, /auth.Auth/Loginn")
+}
+
+func TestStore_Reload(t *testing.T) {
+	dir := t.TempDir()
+	path := writePolicy(t, dir, testPolicy)
+
+	store := MustLoad(path, "sso")
+	require.NoError(t, store.Bind(services))
+
+	writePolicy(t, dir, `
+sso:
+  methods:
+    /auth.UserAdmin/ListUsers:
+      required: ["users:manage"]
+`)
+	require.NoError(t, store.Reload())
+	assert.Equal(t, []string{"users:manage"}, store.Rule("/auth.UserAdmin/ListUsers").Required)
+
+	// the failed reloads keep the previous policy
+	invalid := map[string]string{
+		"unknown key":    "sso:\n  methods:\n    /auth.UserAdmin/ListUsers:\n      requried: []\n",
+		"unknown method": "sso:\n  methods:\n    /auth.UserAdmin/DeleteUser: {}\n",
+		"no section":     "profile:\n  methods: {}\n",
+		"broken yaml":    "sso: [",
+	}
+	for name, content := range invalid {
+		writePolicy(t, dir, content)
+		require.Error(t, store.Reload(), name)
+		assert.Equal(t, []string{"users:manage"}, store.Rule("/auth.UserAdmin/ListUsers").Required, name)
+	}
+}
+
+func TestMustLoad_Panics(t *testing.T) {
+	assert.Panics(t, func() { MustLoad(filepath.Join(t.TempDir(), "missing.yaml"), "sso") })
+}
+
+func TestLoad_RepositoryPolicy(t *testing.T) {
+	for _, section := range []string{"sso", "profile"} {
+		_, err := Load("../../../policy/methods.yaml", section)
+		require.NoError(t, err, section)
+	}
+}
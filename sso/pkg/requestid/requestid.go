@@ -0,0 +1,117 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataKey carries the request ID in both directions, the gateway maps it to the X-Request-Id header
+const MetadataKey = "x-request-id"
+
+// maxLength limits the IDs accepted from the callers
+const maxLength = 128
+
+type ctxKey struct{}
+
+// Request is what the logs of a request are tagged with
+type Request struct {
+	ID     string
+	Method string
+
+	// the user is known after the authentication, deeper in the chain than the request
+	userID atomic.Int64
+}
+
+// UserID returns the authenticated user, 0 before the authentication or for public methods
+func (r *Request) UserID() int64 {
+	return r.userID.Load()
+}
+
+// NewContext returns ctx carrying the request
+func NewContext(ctx context.Context, r *Request) context.Context {
+	return context.WithValue(ctx, ctxKey{}, r)
+}
+
+// FromContext returns the request of ctx, nil outside of the requests, e.g. in the background workers
+func FromContext(ctx context.Context) *Request {
+	r, _ := ctx.Value(ctxKey{}).(*Request)
+	return r
+}
+
+// ID returns the request ID of ctx, empty outside of the requests
+func ID(ctx context.Context) string {
+	if r := FromContext(ctx); r != nil {
+		return r.ID
+	}
+	return ""
+}
+
+// SetUserID tags the rest of the request with the authenticated user
+func SetUserID(ctx context.Context, userID int64) {
+	if r := FromContext(ctx); r != nil {
+		r.userID.Store(userID)
+	}
+}
+
+// New returns a random request ID
+func New() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// UnaryServerInterceptor takes the request ID sent by the caller or generates one, stores it in the context
+// and returns it in the response header, it goes before the interceptors that log
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		id := fromIncoming(ctx)
+		if id == "" {
+			id = New()
+		}
+
+		// headers of a failed call are sent with its trailers
+		_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataKey, id))
+
+		return handler(NewContext(ctx, &Request{ID: id, Method: info.FullMethod}), req)
+	}
+}
+
+// UnaryClientInterceptor passes the request ID of ctx to the called service
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := ID(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, MetadataKey, id)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// fromIncoming returns the request ID sent by the caller, the IDs that don't fit in a log line are ignored
+func fromIncoming(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	values := md.Get(MetadataKey)
+	if len(values) == 0 {
+		return ""
+	}
+
+	id := values[0]
+	if len(id) > maxLength {
+		return ""
+	}
+	for _, c := range id {
+		// printable ASCII only
+		if c < 0x21 || c > 0x7e {
+			return ""
+		}
+	}
+	return id
+}
@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// redacted replaces the secret path segments in the spans
+const redacted = "[REDACTED]"
+
+// originalRequestKey keeps the request with the secret path for the traced handler
+type originalRequestKey struct{}
+
+// Handler traces the HTTP requests except the metrics scrapes, the path segment following
+// any of secretPrefixes, e.g. a download token, is redacted in the span
+func Handler(h http.Handler, secretPrefixes ...string) http.Handler {
+	// otelhttp renames the span after the Pattern a ServeMux sets on the request, serving a copy
+	// keeps the route name given by GatewayMiddleware
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(r.Context())
+		if original, ok := r.Context().Value(originalRequestKey{}).(*http.Request); ok {
+			r.URL, r.RequestURI = original.URL, original.RequestURI
+		}
+		h.ServeHTTP(w, r)
+	})
+
+	traced := otelhttp.NewHandler(next, "http",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return r.URL.Path != "/metrics"
+		}),
+	)
+
+	if len(secretPrefixes) == 0 {
+		return traced
+	}
+
+	// otelhttp records the path of the request it is given, so it gets a copy with the secret
+	// replaced and the handler behind it gets the original back
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, ok := redactPath(r.URL.Path, secretPrefixes)
+		if !ok {
+			traced.ServeHTTP(w, r)
+			return
+		}
+
+		masked := r.WithContext(context.WithValue(r.Context(), originalRequestKey{}, r))
+		u := *r.URL
+		u.Path, u.RawPath, u.RawQuery = path, "", ""
+		masked.URL = &u
+		masked.RequestURI = path
+
+		traced.ServeHTTP(w, masked)
+	})
+}
+
+// redactPath replaces the segment following the matching prefix, false if no prefix matches
+func redactPath(path string, prefixes []string) (string, bool) {
+	for _, prefix := range prefixes {
+		rest, ok := strings.CutPrefix(path, prefix)
+		if !ok || rest == "" {
+			continue
+		}
+
+		_, tail, hasTail := strings.Cut(rest, "/")
+		if hasTail {
+			return prefix + redacted + "/" + tail, true
+		}
+		return prefix + redacted, true
+	}
+	return path, false
+}
+
+// GatewayMiddleware names the span started by Handler after the route template
+func GatewayMiddleware(next runtime.HandlerFunc) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		if pattern, ok := runtime.HTTPPattern(r.Context()); ok {
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(r.Method + " " + pattern.String())
+			span.SetAttributes(semconv.HTTPRoute(pattern.String()))
+		}
+
+		next(w, r, pathParams)
+	}
+}
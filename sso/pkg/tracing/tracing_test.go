@@ -0,0 +1,212 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newExporter installs a provider exporting every span to memory as soon as it ends
+func newExporter(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(exporter),
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+	)
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	return exporter
+}
+
+func attributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value, len(span.Attributes))
+	for _, attr := range span.Attributes {
+		attrs[attr.Key] = attr.Value
+	}
+	return attrs
+}
+
+func TestQueryTracer(t *testing.T) {
+	exporter := newExporter(t)
+	tracer := NewQueryTracer()
+
+	ctx, parent := Tracer().Start(context.Background(), "login")
+
+	queries := []struct {
+		sql string
+		err error
+	}{
+		{sql: "  select id from users where email = $1", err: pgx.ErrNoRows},
+		{sql: "UPDATE users SET name = $1", err: errors.New("deadlock detected")},
+	}
+	for _, q := range queries {
+		qctx := tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: q.sql, Args: []any{"secret@example.com"}})
+		tracer.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{Err: q.err})
+	}
+	parent.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+
+	selectSpan, updateSpan := spans[0], spans[1]
+
+	assert.Equal(t, "SELECT", selectSpan.Name)
+	assert.Equal(t, trace.SpanKindClient, selectSpan.SpanKind)
+	assert.Equal(t, parent.SpanContext().SpanID(), selectSpan.Parent.SpanID())
+	assert.Equal(t, codes.Unset, selectSpan.Status.Code, "no rows is not a failure")
+	assert.Equal(t, "postgresql", attributes(selectSpan)["db.system.name"].AsString())
+	assert.Equal(t, "  select id from users where email = $1", attributes(selectSpan)["db.query.text"].AsString())
+
+	assert.Equal(t, "UPDATE", updateSpan.Name)
+	assert.Equal(t, codes.Error, updateSpan.Status.Code)
+	assert.Equal(t, "deadlock detected", updateSpan.Status.Description)
+
+	// the arguments are not recorded
+	for _, span := range spans {
+		for _, attr := range span.Attributes {
+			assert.NotContains(t, attr.Value.Emit(), "secret@example.com")
+		}
+	}
+}
+
+func TestOperationName(t *testing.T) {
+	assert.Equal(t, "SELECT", operationName("select 1"))
+	assert.Equal(t, "WITH", operationName("\n  with t as (select 1) select * from t"))
+	assert.Equal(t, "query", operationName("  "))
+}
+
+func TestHandler_GatewayRoute(t *testing.T) {
+	exporter := newExporter(t)
+
+	gateway := runtime.NewServeMux(runtime.WithMiddlewares(GatewayMiddleware))
+	require.NoError(t, gateway.HandlePath(http.MethodGet, "/v1/users/{user_id}", func(w http.ResponseWriter, _ *http.Request, _ map[string]string) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	mux := http.NewServeMux()
+	mux.Handle("/", gateway)
+	mux.Handle("/metrics", http.NotFoundHandler())
+	handler := Handler(mux)
+
+	// the caller's trace is continued
+	callerCtx, caller := Tracer().Start(context.Background(), "caller")
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/7", nil)
+	otel.GetTextMapPropagator().Inject(callerCtx, propagation.HeaderCarrier(req.Header))
+	caller.End()
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2, "the metrics scrapes are not traced")
+
+	server := spans[1]
+	assert.Equal(t, "GET /v1/users/{user_id=*}", server.Name)
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+	assert.Equal(t, caller.SpanContext().TraceID(), server.SpanContext.TraceID())
+	assert.Equal(t, "/v1/users/{user_id=*}", attributes(server)["http.route"].AsString())
+}
+
+func TestHandler_SecretPath(t *testing.T) {
+	exporter := newExporter(t)
+
+	const token = "s3cr3t-download-token"
+
+	var servedPath string
+	gateway := runtime.NewServeMux(runtime.WithMiddlewares(GatewayMiddleware))
+	require.NoError(t, gateway.HandlePath(http.MethodGet, "/v1/exports/download/{token}", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		servedPath = r.URL.Path
+		assert.Equal(t, token, params["token"])
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	handler := Handler(gateway, "/v1/exports/download/")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/exports/download/"+token+"?format=json", nil))
+
+	// the handler gets the token, the span doesn't
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "/v1/exports/download/"+token, servedPath)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GET /v1/exports/download/{token=*}", spans[0].Name)
+	for _, attr := range spans[0].Attributes {
+		assert.NotContains(t, attr.Value.Emit(), token, attr.Key)
+	}
+	assert.Equal(t, "/v1/exports/download/[REDACTED]", attributes(spans[0])["url.path"].AsString())
+}
+
+func TestRedactPath(t *testing.T) {
+	prefixes := []string{"/v1/exports/download/", "/v1/links/"}
+
+	tests := map[string]struct {
+		want     string
+		redacted bool
+	}{
+		"/v1/exports/download/abc":    {"/v1/exports/download/[REDACTED]", true},
+		"/v1/links/abc/open":          {"/v1/links/[REDACTED]/open", true},
+		"/v1/exports/download/":       {"/v1/exports/download/", false},
+		"/v1/exports/7":               {"/v1/exports/7", false},
+		"/v2/v1/exports/download/abc": {"/v2/v1/exports/download/abc", false},
+	}
+
+	for path, tt := range tests {
+		got, redacted := redactPath(path, prefixes)
+		assert.Equal(t, tt.want, got, path)
+		assert.Equal(t, tt.redacted, redacted, path)
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	exporter := newExporter(t)
+
+	assert.Nil(t, Inject(context.Background()), "no trace, nothing to store")
+
+	ctx, request := Tracer().Start(context.Background(), "request")
+	carrier := Inject(ctx)
+	request.End()
+	require.NotEmpty(t, carrier)
+
+	// the work done later, e.g. a queued email, joins the trace of the request
+	_, later := Tracer().Start(Extract(context.Background(), carrier), "smtp send")
+	later.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, spans[0].SpanContext.TraceID(), spans[1].SpanContext.TraceID())
+	assert.Equal(t, spans[0].SpanContext.SpanID(), spans[1].Parent.SpanID())
+}
+
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterNone}, "sso")
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup(context.Background(), Config{Exporter: "jaeger"}, "sso")
+	require.Error(t, err)
+}
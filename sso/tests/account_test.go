@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"context"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/golang-jwt/jwt/v5"
+	profilev1 "github.com/m4rk1sov/protos/gen/go/profile"
+	ssov1 "github.com/m4rk1sov/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"regexp"
+	apiv1 "sso/api/gen/go/sso"
+	"sso/tests/suite"
+	"testing"
+	"time"
+)
+
+func TestDeleteAccount_Unauthenticated(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.AccountClient.DeleteAccount(ctx, &apiv1.DeleteAccountRequest{Password: randomFakePassword()})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestDeleteAccount_ScheduleAndCancelByLogin(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+respLogin.GetAccessToken())
+
+	// the password is asked again
+	_, err = st.AccountClient.DeleteAccount(authCtx, &apiv1.DeleteAccountRequest{Password: randomFakePassword()})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	respDelete, err := st.AccountClient.DeleteAccount(authCtx, &apiv1.DeleteAccountRequest{Password: pass})
+	require.NoError(t, err)
+	assert.Greater(t, respDelete.GetDeleteAfter(), time.Now().Unix())
+
+	// sessions are ended when the deletion is scheduled
+	_, err = st.AuthClient.RefreshToken(ctx, &ssov1.RefreshTokenRequest{RefreshToken: respLogin.GetRefreshToken()})
+	require.Error(t, err)
+
+	// logging in cancels the deletion, a new request gets a new date
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	respAgain, err := st.AccountClient.DeleteAccount(authCtx, &apiv1.DeleteAccountRequest{Password: pass})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, respAgain.GetDeleteAfter(), respDelete.GetDeleteAfter())
+}
+
+func TestDeleteAccount_Purged(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user := st.NewUser(ctx)
+
+	_, err := st.AccountClient.DeleteAccount(st.Login(ctx, user, appID), &apiv1.DeleteAccountRequest{Password: user.Password})
+	require.NoError(t, err)
+
+	// the grace period is over, logging in now would cancel the deletion
+	st.Exec(ctx, `UPDATE users SET delete_after = now() - interval '1 second' WHERE id = $1`, user.ID)
+
+	// the worker runs every account_deletion.purge_interval
+	require.Eventually(t, func() bool {
+		return st.Count(ctx, `SELECT count(*) FROM users WHERE id = $1`, user.ID) == 0
+	}, 5*time.Second, 200*time.Millisecond)
+
+	// the relay deletes the profile when it publishes the event
+	assert.Equal(t, int64(1), st.Count(ctx, `SELECT count(*) FROM outbox_events WHERE type = 'user.deleted' AND aggregate_id = $1`, user.ID))
+	assert.Equal(t, int64(1), st.Count(ctx, `SELECT count(*) FROM audit_events WHERE type = 'account.deleted' AND target_user_id = $1`, user.ID))
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: user.Email, Password: user.Password, AppId: appID})
+	require.Error(t, err)
+}
+
+func TestDeleteAccount_PurgedProfile(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user := st.NewUser(ctx)
+	profileCtx := profileContext(ctx, t, st, user.ID)
+
+	respCreate, err := st.ProfileClient.CreateProfile(profileCtx, &profilev1.CreateProfileRequest{
+		UserId: user.ID,
+		Name:   gofakeit.Name(),
+		Email:  user.Email,
+	})
+	require.NoError(t, err)
+	require.Nil(t, respCreate.GetError())
+
+	_, err = st.AccountClient.DeleteAccount(st.Login(ctx, user, appID), &apiv1.DeleteAccountRequest{Password: user.Password})
+	require.NoError(t, err)
+
+	st.Exec(ctx, `UPDATE users SET delete_after = now() - interval '1 second' WHERE id = $1`, user.ID)
+
+	// the relay deletes the profile after the purge, profile.delete_profiles is on
+	require.Eventually(t, func() bool {
+		resp, err := st.ProfileClient.GetProfileByUserID(profileCtx, &profilev1.GetProfileByUserIDRequest{UserId: user.ID})
+		return err == nil && codes.Code(resp.GetError().GetCode()) == codes.NotFound
+	}, 10*time.Second, 200*time.Millisecond)
+}
+
+// profileContext authenticates the calls to profile as the user, the token of the profile app
+// embeds the empty permissions, so profile doesn't ask sso about the deleted user
+func profileContext(ctx context.Context, t *testing.T, st *suite.Suite, userID int64) context.Context {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":     userID,
+		"app_id":      st.Cfg.Profile.AppID,
+		"aud":         st.Cfg.Profile.Audience,
+		"permissions": []string{},
+		"exp":         time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte(appSecret))
+	require.NoError(t, err)
+
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func TestExportMyData_QueuedAndOwnedByCaller(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+respLogin.GetAccessToken())
+
+	respExport, err := st.AccountClient.ExportMyData(authCtx, &apiv1.ExportMyDataRequest{})
+	require.NoError(t, err)
+	require.NotZero(t, respExport.GetExportId())
+
+	respGet, err := st.AccountClient.GetDataExport(authCtx, &apiv1.GetDataExportRequest{ExportId: respExport.GetExportId()})
+	require.NoError(t, err)
+	assert.Equal(t, respExport.GetExportId(), respGet.GetExportId())
+
+	// exports of other users are not visible
+	_, err = st.AccountClient.GetDataExport(authCtx, &apiv1.GetDataExportRequest{ExportId: respExport.GetExportId() + 1})
+	require.Error(t, err)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestDownloadDataExport_UnknownToken(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.AccountClient.DownloadDataExport(ctx, &apiv1.DownloadDataExportRequest{Token: gofakeit.UUID()})
+	require.Error(t, err)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+var exportLink = regexp.MustCompile(`/v1/account/exports/download/([^\s"'<>]+)`)
+
+func TestDownloadDataExport_Once(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user := st.NewUser(ctx)
+
+	_, err := st.AccountClient.ExportMyData(st.Login(ctx, user, appID), &apiv1.ExportMyDataRequest{})
+	require.NoError(t, err)
+
+	var email suite.Email
+	require.Eventually(t, func() bool {
+		email = st.LastEmail(user.Email)
+		return exportLink.MatchString(email.Text)
+	}, 15*time.Second, 200*time.Millisecond)
+	token := emailToken(t, email, exportLink)
+
+	resp, err := st.AccountClient.DownloadDataExport(ctx, &apiv1.DownloadDataExportRequest{Token: token})
+	require.NoError(t, err)
+	assert.Contains(t, string(resp.GetData()), user.Email)
+
+	// the token travels in the URL, so the link works once
+	_, err = st.AccountClient.DownloadDataExport(ctx, &apiv1.DownloadDataExportRequest{Token: token})
+	require.Error(t, err)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"context"
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/m4rk1sov/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	apiv1 "sso/api/gen/go/sso"
+	"sso/tests/suite"
+	"testing"
+)
+
+func TestCreateApiKey_ValidateAndRevoke(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+respLogin.GetAccessToken())
+
+	respCreate, err := st.ApiKeysClient.CreateApiKey(authCtx, &apiv1.CreateApiKeyRequest{
+		Name: "ci",
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, respCreate.GetKey())
+	assert.Equal(t, respReg.GetUserId(), respCreate.GetApiKey().GetUserId())
+
+	serviceCtx := serviceContext(ctx, st)
+
+	respValidate, err := st.ApiKeysClient.ValidateApiKey(serviceCtx, &apiv1.ValidateApiKeyRequest{
+		Key: respCreate.GetKey(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, respCreate.GetApiKey().GetId(), respValidate.GetKeyId())
+	assert.Equal(t, respReg.GetUserId(), respValidate.GetUserId())
+
+	respRevoke, err := st.ApiKeysClient.RevokeApiKey(authCtx, &apiv1.RevokeApiKeyRequest{
+		Id: respCreate.GetApiKey().GetId(),
+	})
+	require.NoError(t, err)
+	assert.True(t, respRevoke.GetChanged())
+
+	_, err = st.ApiKeysClient.ValidateApiKey(serviceCtx, &apiv1.ValidateApiKeyRequest{
+		Key: respCreate.GetKey(),
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestCreateApiKey_NotOwnedPermission(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+respLogin.GetAccessToken())
+
+	// keys can't carry more than the user has
+	_, err = st.ApiKeysClient.CreateApiKey(ctx, &apiv1.CreateApiKeyRequest{
+		Name:        "ci",
+		Permissions: []string{"permissions:manage"},
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestValidateApiKey_ServiceOnly(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user := st.NewUser(ctx)
+	userCtx := st.Login(ctx, user, appID)
+
+	respCreate, err := st.ApiKeysClient.CreateApiKey(userCtx, &apiv1.CreateApiKeyRequest{Name: "ci"})
+	require.NoError(t, err)
+
+	// anonymous callers can't probe keys
+	_, err = st.ApiKeysClient.ValidateApiKey(ctx, &apiv1.ValidateApiKeyRequest{Key: respCreate.GetKey()})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// regular users don't have api_keys:validate
+	_, err = st.ApiKeysClient.ValidateApiKey(userCtx, &apiv1.ValidateApiKeyRequest{Key: respCreate.GetKey()})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestValidateApiKey_OwnerLostPermission(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user := st.NewUser(ctx)
+	st.GrantRole(ctx, user.ID, "staff")
+
+	respCreate, err := st.ApiKeysClient.CreateApiKey(st.Login(ctx, user, appID), &apiv1.CreateApiKeyRequest{
+		Name:        "ci",
+		Permissions: []string{"users:read"},
+	})
+	require.NoError(t, err)
+
+	serviceCtx := serviceContext(ctx, st)
+
+	respValidate, err := st.ApiKeysClient.ValidateApiKey(serviceCtx, &apiv1.ValidateApiKeyRequest{Key: respCreate.GetKey()})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"users:read"}, respValidate.GetPermissions())
+
+	// the key follows the current grants of the owner
+	st.RevokeRole(ctx, user.ID, "staff")
+
+	respValidate, err = st.ApiKeysClient.ValidateApiKey(serviceCtx, &apiv1.ValidateApiKeyRequest{Key: respCreate.GetKey()})
+	require.NoError(t, err)
+	assert.Empty(t, respValidate.GetPermissions())
+
+	_, err = st.UserAdminClient.ListUsers(suite.WithAPIKey(ctx, respCreate.GetKey()), &apiv1.ListUsersRequest{})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestValidateApiKey_DisabledOwner(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user := st.NewUser(ctx)
+
+	respCreate, err := st.ApiKeysClient.CreateApiKey(st.Login(ctx, user, appID), &apiv1.CreateApiKeyRequest{Name: "ci"})
+	require.NoError(t, err)
+
+	serviceCtx := serviceContext(ctx, st)
+
+	_, err = st.ApiKeysClient.ValidateApiKey(serviceCtx, &apiv1.ValidateApiKeyRequest{Key: respCreate.GetKey()})
+	require.NoError(t, err)
+
+	st.Exec(ctx, `UPDATE users SET disabled_at = now() WHERE id = $1`, user.ID)
+
+	_, err = st.ApiKeysClient.ValidateApiKey(serviceCtx, &apiv1.ValidateApiKeyRequest{Key: respCreate.GetKey()})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+// serviceContext authenticates the calls with the key of a user holding the service role, as profile does
+func serviceContext(ctx context.Context, st *suite.Suite) context.Context {
+	st.Helper()
+
+	service := st.NewUser(ctx)
+	st.GrantRole(ctx, service.ID, "service")
+
+	resp, err := st.ApiKeysClient.CreateApiKey(st.Login(ctx, service, appID), &apiv1.CreateApiKeyRequest{
+		Name:        "profile",
+		Permissions: []string{"api_keys:validate"},
+	})
+	require.NoError(st.T, err)
+
+	return suite.WithAPIKey(ctx, resp.GetKey())
+}
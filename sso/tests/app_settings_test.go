@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	ssov1 "github.com/m4rk1sov/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sso/tests/suite"
+	"testing"
+	"time"
+)
+
+const (
+	settingsAppID      = 2
+	settingsAppSecret  = "test-settings-secret"
+	settingsAccessTTL  = time.Minute
+	settingsRefreshTTL = 2 * time.Minute
+)
+
+func TestLogin_AppTokenTTL(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user := st.NewUser(ctx)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    user.Email,
+		Password: user.Password,
+		AppId:    settingsAppID,
+	})
+	require.NoError(t, err)
+	loginTime := time.Now()
+
+	const deltaSeconds = 1
+
+	access := parseClaims(t, respLogin.GetAccessToken(), settingsAppSecret)
+	assert.InDelta(t, loginTime.Add(settingsAccessTTL).Unix(), access["exp"].(float64), deltaSeconds)
+
+	refresh := parseClaims(t, respLogin.GetRefreshToken(), settingsAppSecret)
+	assert.InDelta(t, loginTime.Add(settingsRefreshTTL).Unix(), refresh["exp"].(float64), deltaSeconds)
+}
+
+func TestLogin_EmbedPermissions(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user := st.NewUser(ctx)
+	st.GrantRole(ctx, user.ID, "staff")
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    user.Email,
+		Password: user.Password,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	claims := parseClaims(t, respLogin.GetAccessToken(), appSecret)
+	assert.Contains(t, claims["permissions"], "staff")
+
+	// the app is configured to keep permissions out of the tokens
+	respLogin, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    user.Email,
+		Password: user.Password,
+		AppId:    settingsAppID,
+	})
+	require.NoError(t, err)
+
+	claims = parseClaims(t, respLogin.GetAccessToken(), settingsAppSecret)
+	assert.NotContains(t, claims, "permissions")
+}
+
+func TestLogin_EmbedPermissions_Empty(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user := st.NewUser(ctx)
+	st.RevokeRole(ctx, user.ID, "user")
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    user.Email,
+		Password: user.Password,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	// a user without permissions gets an empty claim, not a token that looks issued without them
+	claims := parseClaims(t, respLogin.GetAccessToken(), appSecret)
+	require.Contains(t, claims, "permissions")
+	assert.Empty(t, claims["permissions"])
+}
+
+func parseClaims(t *testing.T, token string, secret string) jwt.MapClaims {
+	t.Helper()
+
+	parsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithoutClaimsValidation())
+	require.NoError(t, err)
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	require.True(t, ok)
+
+	return claims
+}
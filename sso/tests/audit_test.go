@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/m4rk1sov/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	apiv1 "sso/api/gen/go/sso"
+	"sso/tests/suite"
+	"testing"
+)
+
+func TestListAuditEvents_Unauthenticated(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.AuditClient.ListAuditEvents(ctx, &apiv1.ListAuditEventsRequest{})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestListAuditEvents_RegularUserDenied(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	// users can't read even their own events
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+respLogin.GetAccessToken())
+
+	_, err = st.AuditClient.ListAuditEvents(ctx, &apiv1.ListAuditEventsRequest{
+		UserId: respReg.GetUserId(),
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
@@ -6,6 +6,7 @@ import (
 	ssov1 "github.com/m4rk1sov/protos/gen/go/sso"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
 	"sso/tests/suite"
 	"testing"
 	"time"
@@ -15,6 +16,7 @@ const (
 	emptyAppID = 0
 	appID      = 1
 	appSecret  = "test-secret"
+	appName    = "test"
 
 	passDefaultLen = 10
 )
@@ -66,11 +68,13 @@ func TestRegisterLogin_Login_HappyPath(t *testing.T) {
 	assert.Equal(t, respReg.GetUserId(), int64(claims["user_id"].(float64)))
 	assert.Equal(t, email, claims["email"].(string))
 	assert.Equal(t, appID, int(claims["app_id"].(float64)))
+	// token is issued for the app itself and for sso
+	assert.ElementsMatch(t, []any{appName, st.Cfg.JWT.Audience}, claims["aud"])
 
 	const deltaSeconds = 1
 	// checking if TTL is approximate to our expectations
 	// accuracy close to 1sec
-	assert.InDelta(t, loginTime.Add(st.Cfg.TokenTTL).Unix(), claims["exp"].(float64), deltaSeconds)
+	assert.InDelta(t, loginTime.Add(st.Cfg.JWT.TokenTTL).Unix(), claims["exp"].(float64), deltaSeconds)
 }
 
 func TestRegisterLogin_DuplicatedRegistration(t *testing.T) {
@@ -204,6 +208,30 @@ func TestLogin_FailCases(t *testing.T) {
 	}
 }
 
+func TestLogin_UnknownAudience(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	// audience must be one of the registered apps
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-audience", "unknown-service")
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "invalid audience")
+}
+
 func randomFakePassword() string {
 	return gofakeit.Password(true, true, true, true, true, passDefaultLen)
 }
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apiv1 "sso/api/gen/go/sso"
+	"sso/internal/lib/mailer"
+	"sso/tests/suite"
+)
+
+func TestRequeueDeadEmails(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	to := gofakeit.Email()
+	msg, err := json.Marshal(mailer.Message{
+		From:    mailer.Address{Email: "sso@example.com"},
+		To:      mailer.Address{Email: to},
+		Subject: "Requeued email",
+		HTML:    "<p>sent again</p>",
+	})
+	require.NoError(t, err)
+
+	st.Exec(ctx, `
+		INSERT INTO email_outbox (to_email, subject, message, status, attempts, last_error, dead_at)
+		VALUES ($1, 'Requeued email', $2, 'dead', 8, 'connection refused', now())`, to, msg)
+
+	user := st.NewUser(ctx)
+	_, err = st.EmailQueueClient.RequeueDeadEmails(st.Login(ctx, user, appID), &apiv1.RequeueDeadEmailsRequest{})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	admin := st.NewUser(ctx)
+	st.GrantRole(ctx, admin.ID, "admin")
+	adminCtx := st.Login(ctx, admin, appID)
+
+	_, err = st.EmailQueueClient.RequeueDeadEmails(adminCtx, &apiv1.RequeueDeadEmailsRequest{Ids: []int64{-1}})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	resp, err := st.EmailQueueClient.RequeueDeadEmails(adminCtx, &apiv1.RequeueDeadEmailsRequest{})
+	require.NoError(t, err)
+	assert.Positive(t, resp.GetRequeued())
+
+	assert.Equal(t, "Requeued email", st.LastEmail(to).Subject)
+
+	// the body of the sent email is dropped
+	require.Eventually(t, func() bool {
+		return st.Count(ctx, `
+			SELECT count(*) FROM email_outbox
+			WHERE to_email = $1 AND status = 'sent' AND message = '{}'`, to) == 1
+	}, 10*time.Second, 200*time.Millisecond)
+}
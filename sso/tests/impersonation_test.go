@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/m4rk1sov/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	apiv1 "sso/api/gen/go/sso"
+	"sso/tests/suite"
+	"testing"
+)
+
+func TestImpersonate_Unauthenticated(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.ImpersonationClient.Impersonate(ctx, &apiv1.ImpersonateRequest{
+		UserId: 1,
+		AppId:  appID,
+		Reason: "support ticket",
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestImpersonate_RegularUserDenied(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+respLogin.GetAccessToken())
+
+	_, err = st.ImpersonationClient.Impersonate(ctx, &apiv1.ImpersonateRequest{
+		UserId: 1,
+		AppId:  appID,
+		Reason: "support ticket",
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestImpersonate_StaffImpersonatesUser(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	staff := st.NewUser(ctx)
+	st.GrantRole(ctx, staff.ID, "staff")
+
+	user := st.NewUser(ctx)
+
+	resp, err := st.ImpersonationClient.Impersonate(st.Login(ctx, staff, appID), &apiv1.ImpersonateRequest{
+		UserId: user.ID,
+		AppId:  appID,
+		Reason: "support ticket",
+	})
+	require.NoError(t, err)
+
+	claims := parseClaims(t, resp.GetAccessToken(), appSecret)
+	assert.Equal(t, user.ID, int64(claims["user_id"].(float64)))
+
+	actor, ok := claims["act"].(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, staff.ID, int64(actor["user_id"].(float64)))
+}
+
+func TestImpersonate_TargetWithMorePermissions(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	staff := st.NewUser(ctx)
+	st.GrantRole(ctx, staff.ID, "staff")
+
+	// not a staff member or an admin, but the token would let staff manage webhooks
+	user := st.NewUser(ctx)
+	st.GrantPermission(ctx, user.ID, "webhooks:manage")
+
+	_, err := st.ImpersonationClient.Impersonate(st.Login(ctx, staff, appID), &apiv1.ImpersonateRequest{
+		UserId: user.ID,
+		AppId:  appID,
+		Reason: "support ticket",
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
@@ -0,0 +1,4 @@
+-- app with its own token lifetimes and without permissions in the tokens
+INSERT INTO apps (id, name, secret, access_ttl_seconds, refresh_ttl_seconds, embed_permissions)
+VALUES (2, 'test-settings', 'test-settings-secret', 60, 120, false)
+ON CONFLICT DO NOTHING;
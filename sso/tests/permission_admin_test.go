@@ -0,0 +1,274 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/m4rk1sov/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	apiv1 "sso/api/gen/go/sso"
+	"sso/tests/suite"
+	"testing"
+)
+
+func TestGrantPermission_Unauthenticated(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.PermissionAdminClient.GrantPermission(ctx, &apiv1.GrantPermissionRequest{
+		UserId:     1,
+		Permission: "orders:refund",
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestGrantPermission_RegularUserDenied(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	// a regular user must not be able to grant permissions even to themselves
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+respLogin.GetAccessToken())
+
+	_, err = st.PermissionAdminClient.GrantPermission(ctx, &apiv1.GrantPermissionRequest{
+		UserId:     respReg.GetUserId(),
+		Permission: "permissions:manage",
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestAssignRole_RegularUserDenied(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user := st.NewUser(ctx)
+
+	_, err := st.PermissionAdminClient.AssignRole(st.Login(ctx, user, appID), &apiv1.AssignRoleRequest{
+		UserId: user.ID,
+		Role:   "admin",
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestAssignRole_AssignAndRevoke(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	admin := st.NewUser(ctx)
+	st.GrantRole(ctx, admin.ID, "admin")
+	adminCtx := st.Login(ctx, admin, appID)
+
+	user := st.NewUser(ctx)
+
+	respRoles, err := st.PermissionAdminClient.ListRoles(adminCtx, &apiv1.ListRolesRequest{})
+	require.NoError(t, err)
+
+	codesByRole := make(map[string][]string)
+	for _, role := range respRoles.GetRoles() {
+		codesByRole[role.GetCode()] = role.GetPermissions()
+	}
+	require.Contains(t, codesByRole, "staff")
+	assert.Contains(t, codesByRole["staff"], "users:read")
+
+	_, err = st.PermissionAdminClient.AssignRole(adminCtx, &apiv1.AssignRoleRequest{
+		UserId: user.ID,
+		Role:   "staff",
+		AppId:  appID,
+	})
+	require.NoError(t, err)
+
+	respUserRoles, err := st.PermissionAdminClient.GetUserRoles(adminCtx, &apiv1.GetUserRolesRequest{UserId: user.ID})
+	require.NoError(t, err)
+	assert.Contains(t, userRoles(respUserRoles), fmt.Sprintf("staff@%d", appID))
+
+	// the assignment is revoked only with the same app scope
+	_, err = st.PermissionAdminClient.RevokeRole(adminCtx, &apiv1.RevokeRoleRequest{
+		UserId: user.ID,
+		Role:   "staff",
+		AppId:  appID,
+	})
+	require.NoError(t, err)
+
+	respUserRoles, err = st.PermissionAdminClient.GetUserRoles(adminCtx, &apiv1.GetUserRolesRequest{UserId: user.ID})
+	require.NoError(t, err)
+	assert.NotContains(t, userRoles(respUserRoles), fmt.Sprintf("staff@%d", appID))
+
+	// both changes are recorded with the actor
+	assert.Equal(t, int64(1), st.Count(ctx,
+		`SELECT count(*) FROM audit_events WHERE type = 'role.assign' AND outcome = 'success' AND actor_id = $1 AND target_user_id = $2`,
+		admin.ID, user.ID))
+	assert.Equal(t, int64(1), st.Count(ctx,
+		`SELECT count(*) FROM audit_events WHERE type = 'role.revoke' AND outcome = 'success' AND actor_id = $1 AND target_user_id = $2`,
+		admin.ID, user.ID))
+}
+
+func TestAssignRole_ExceedingPrivilegeDenied(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	// a permission manager is not an admin and doesn't hold the permissions of staff
+	manager := st.NewUser(ctx)
+	st.GrantPermission(ctx, manager.ID, "permissions:manage")
+	managerCtx := st.Login(ctx, manager, appID)
+
+	user := st.NewUser(ctx)
+	admin := st.NewUser(ctx)
+	st.GrantRole(ctx, admin.ID, "admin")
+
+	tests := []struct {
+		name   string
+		userID int64
+		role   string
+	}{
+		{"admin role", user.ID, "admin"},
+		{"role with permissions the actor lacks", user.ID, "staff"},
+		{"role to themselves", manager.ID, "user"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := st.PermissionAdminClient.AssignRole(managerCtx, &apiv1.AssignRoleRequest{
+				UserId: tt.userID,
+				Role:   tt.role,
+			})
+			require.Error(t, err)
+			assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		})
+	}
+
+	_, err := st.PermissionAdminClient.RevokeRole(managerCtx, &apiv1.RevokeRoleRequest{
+		UserId: admin.ID,
+		Role:   "admin",
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	assert.Equal(t, int64(0), st.Count(ctx,
+		`SELECT count(*) FROM audit_events WHERE type IN ('role.assign', 'role.revoke') AND outcome = 'success' AND actor_id = $1`,
+		manager.ID))
+
+	// the roles the actor holds in full can be assigned
+	_, err = st.PermissionAdminClient.AssignRole(managerCtx, &apiv1.AssignRoleRequest{
+		UserId: user.ID,
+		Role:   "user",
+	})
+	require.NoError(t, err)
+}
+
+func TestAssignRole_UnknownRole(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	admin := st.NewUser(ctx)
+	st.GrantRole(ctx, admin.ID, "admin")
+
+	_, err := st.PermissionAdminClient.AssignRole(st.Login(ctx, admin, appID), &apiv1.AssignRoleRequest{
+		UserId: admin.ID,
+		Role:   "no-such-role",
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// userRoles formats the assignments as role@app_id
+func userRoles(resp *apiv1.GetUserRolesResponse) []string {
+	roles := make([]string, len(resp.GetRoles()))
+	for i, role := range resp.GetRoles() {
+		roles[i] = fmt.Sprintf("%s@%d", role.GetRole(), role.GetAppId())
+	}
+	return roles
+}
+
+func TestGrantPermission_GrantedCallSucceeds(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	admin := st.NewUser(ctx)
+	st.GrantRole(ctx, admin.ID, "admin")
+	adminCtx := st.Login(ctx, admin, appID)
+
+	user := st.NewUser(ctx)
+	userCtx := st.Login(ctx, user, appID)
+
+	_, err := st.UserAdminClient.ListUsers(userCtx, &apiv1.ListUsersRequest{Limit: 1})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	respGrant, err := st.PermissionAdminClient.GrantPermission(adminCtx, &apiv1.GrantPermissionRequest{
+		UserId:     user.ID,
+		Permission: "users:read",
+	})
+	require.NoError(t, err)
+	assert.True(t, respGrant.GetChanged())
+
+	// grants are checked in the database, the token issued before still works
+	_, err = st.UserAdminClient.ListUsers(userCtx, &apiv1.ListUsersRequest{Limit: 1})
+	require.NoError(t, err)
+
+	respRevoke, err := st.PermissionAdminClient.RevokePermission(adminCtx, &apiv1.RevokePermissionRequest{
+		UserId:     user.ID,
+		Permission: "users:read",
+	})
+	require.NoError(t, err)
+	assert.True(t, respRevoke.GetChanged())
+
+	_, err = st.UserAdminClient.ListUsers(userCtx, &apiv1.ListUsersRequest{Limit: 1})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestGrantPermission_ExceedingPrivilegeDenied(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	manager := st.NewUser(ctx)
+	st.GrantPermission(ctx, manager.ID, "permissions:manage")
+	st.GrantPermission(ctx, manager.ID, "users:read")
+	managerCtx := st.Login(ctx, manager, appID)
+
+	user := st.NewUser(ctx)
+
+	tests := []struct {
+		name       string
+		userID     int64
+		permission string
+	}{
+		{"admin", user.ID, "admin"},
+		{"permission the actor lacks", user.ID, "users:manage"},
+		{"to themselves", manager.ID, "users:read"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := st.PermissionAdminClient.GrantPermission(managerCtx, &apiv1.GrantPermissionRequest{
+				UserId:     tt.userID,
+				Permission: tt.permission,
+			})
+			require.Error(t, err)
+			assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		})
+	}
+
+	assert.Equal(t, int64(0), st.Count(ctx, `SELECT count(*) FROM users_permissions WHERE user_id = $1`, user.ID))
+
+	// the permissions the actor holds can be granted to others
+	respGrant, err := st.PermissionAdminClient.GrantPermission(managerCtx, &apiv1.GrantPermissionRequest{
+		UserId:     user.ID,
+		Permission: "users:read",
+	})
+	require.NoError(t, err)
+	assert.True(t, respGrant.GetChanged())
+}
@@ -0,0 +1,45 @@
+package tests
+
+import (
+	ssov1 "github.com/m4rk1sov/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apiv1 "sso/api/gen/go/sso"
+	"sso/tests/suite"
+	"testing"
+)
+
+func TestPolicy_OneOfGranted(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user := st.NewUser(ctx)
+
+	// GetUserPermissions requires one of admin, staff
+	_, err := st.PermissionClient.GetUserPermissions(st.Login(ctx, user, appID), &ssov1.GetUserPermissionsRequest{UserId: user.ID})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	st.GrantRole(ctx, user.ID, "staff")
+
+	resp, err := st.PermissionClient.GetUserPermissions(st.Login(ctx, user, appID), &ssov1.GetUserPermissionsRequest{UserId: user.ID})
+	require.NoError(t, err)
+	assert.Contains(t, resp.GetPermissions(), "staff")
+}
+
+func TestPolicy_RequiredGranted(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user := st.NewUser(ctx)
+
+	// ListUsers requires users:read, bundled with the staff role
+	_, err := st.UserAdminClient.ListUsers(st.Login(ctx, user, appID), &apiv1.ListUsersRequest{Limit: 1})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	st.GrantRole(ctx, user.ID, "staff")
+
+	_, err = st.UserAdminClient.ListUsers(st.Login(ctx, user, appID), &apiv1.ListUsersRequest{Limit: 1})
+	require.NoError(t, err)
+}
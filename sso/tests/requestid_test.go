@@ -0,0 +1,44 @@
+package tests
+
+import (
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/m4rk1sov/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"sso/tests/suite"
+	"testing"
+)
+
+func TestRequestID_Echoed(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	requestID := "test-" + gofakeit.UUID()
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-request-id", requestID)
+
+	// failed calls return the request ID too
+	var header metadata.MD
+	_, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+		AppId:    appID,
+	}, grpc.Header(&header))
+	require.Error(t, err)
+	assert.Equal(t, []string{requestID}, header.Get("x-request-id"))
+}
+
+func TestRequestID_Generated(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	var header metadata.MD
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	}, grpc.Header(&header))
+	require.NoError(t, err)
+
+	ids := header.Get("x-request-id")
+	require.Len(t, ids, 1)
+	assert.Len(t, ids[0], 32)
+}
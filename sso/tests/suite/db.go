@@ -0,0 +1,78 @@
+package suite
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Exec runs the query on the database of the tested server, used to set up
+// the state that can't be reached through the API (roles, app settings)
+func (s *Suite) Exec(ctx context.Context, query string, args ...any) {
+	s.Helper()
+
+	if s.Cfg.DSN == "" {
+		s.Skip("DSN_STRING is not set")
+	}
+
+	conn, err := pgx.Connect(ctx, s.Cfg.DSN)
+	if err != nil {
+		s.Fatalf("database connection failed: %v", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, query, args...); err != nil {
+		s.Fatalf("query failed: %v", err)
+	}
+}
+
+// GrantRole assigns the role to the user for every app
+func (s *Suite) GrantRole(ctx context.Context, userID int64, role string) {
+	s.Helper()
+
+	s.Exec(ctx, `
+		INSERT INTO users_roles (user_id, role_id)
+		SELECT $1, id FROM roles WHERE code = $2
+		ON CONFLICT DO NOTHING`, userID, role)
+}
+
+// RevokeRole removes the role of the user granted for every app
+func (s *Suite) RevokeRole(ctx context.Context, userID int64, role string) {
+	s.Helper()
+
+	s.Exec(ctx, `
+		DELETE FROM users_roles
+		WHERE user_id = $1 AND app_id IS NULL AND role_id = (SELECT id FROM roles WHERE code = $2)`, userID, role)
+}
+
+// GrantPermission grants the permission to the user directly
+func (s *Suite) GrantPermission(ctx context.Context, userID int64, permission string) {
+	s.Helper()
+
+	s.Exec(ctx, `
+		INSERT INTO users_permissions (user_id, permission_id)
+		SELECT $1, id FROM permissions WHERE code = $2
+		ON CONFLICT DO NOTHING`, userID, permission)
+}
+
+// Count returns the result of the count query on the database of the tested server
+func (s *Suite) Count(ctx context.Context, query string, args ...any) int64 {
+	s.Helper()
+
+	if s.Cfg.DSN == "" {
+		s.Skip("DSN_STRING is not set")
+	}
+
+	conn, err := pgx.Connect(ctx, s.Cfg.DSN)
+	if err != nil {
+		s.Fatalf("database connection failed: %v", err)
+	}
+	defer conn.Close(context.Background())
+
+	var count int64
+	if err := conn.QueryRow(ctx, query, args...).Scan(&count); err != nil {
+		s.Fatalf("query failed: %v", err)
+	}
+
+	return count
+}
@@ -0,0 +1,167 @@
+package suite
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sso/internal/lib/mailer"
+)
+
+const (
+	mailTimeout  = 15 * time.Second
+	mailInterval = 100 * time.Millisecond
+)
+
+// Email is a message the server wrote to the maildir of the file sender
+type Email struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	sentAt  time.Time
+}
+
+// LastEmail waits for an email to the address and returns the newest one,
+// the server must run with the "file" sender of the tests config
+func (s *Suite) LastEmail(to string) Email {
+	s.Helper()
+
+	if s.Cfg.Email.Sender != mailer.KindFile {
+		s.Skipf("email.sender is %q, emails are only readable with %q", s.Cfg.Email.Sender, mailer.KindFile)
+	}
+
+	deadline := time.Now().Add(mailTimeout)
+	for {
+		emails, err := readMaildir(mailDir(s.Cfg.Email.Dir))
+		if err != nil {
+			s.Fatalf("failed to read the maildir: %v", err)
+		}
+
+		var last *Email
+		for i := range emails {
+			if strings.EqualFold(emails[i].To, to) && (last == nil || emails[i].sentAt.After(last.sentAt)) {
+				last = &emails[i]
+			}
+		}
+		if last != nil {
+			return *last
+		}
+
+		if time.Now().After(deadline) {
+			s.Fatalf("no email to %s within %s", to, mailTimeout)
+		}
+		time.Sleep(mailInterval)
+	}
+}
+
+// mailDir resolves the maildir of the config, relative paths are relative to the sso directory
+// the server runs from, the tests run from sso/tests
+func mailDir(dir string) string {
+	if v := os.Getenv("TEST_MAIL_DIR"); v != "" {
+		return v
+	}
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join("..", dir)
+}
+
+// readMaildir parses the delivered messages of the maildir
+func readMaildir(dir string) ([]Email, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, "new"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	emails := make([]Email, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		email, err := readEmail(filepath.Join(dir, "new", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		email.sentAt = info.ModTime()
+
+		emails = append(emails, email)
+	}
+
+	return emails, nil
+}
+
+func readEmail(path string) (Email, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Email{}, err
+	}
+	defer f.Close()
+
+	msg, err := mail.ReadMessage(f)
+	if err != nil {
+		return Email{}, err
+	}
+
+	var email Email
+
+	if to, err := mail.ParseAddress(msg.Header.Get("To")); err == nil {
+		email.To = to.Address
+	}
+
+	var decoder mime.WordDecoder
+	if email.Subject, err = decoder.DecodeHeader(msg.Header.Get("Subject")); err != nil {
+		return Email{}, err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return Email{}, err
+	}
+
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+		if err != nil {
+			return Email{}, err
+		}
+		email.HTML = string(body)
+		return email, nil
+	}
+
+	// the quoted-printable parts are decoded by the reader
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Email{}, err
+		}
+
+		body, err := io.ReadAll(part)
+		if err != nil {
+			return Email{}, err
+		}
+
+		switch {
+		case strings.HasPrefix(part.Header.Get("Content-Type"), "text/plain"):
+			email.Text = string(body)
+		case strings.HasPrefix(part.Header.Get("Content-Type"), "text/html"):
+			email.HTML = string(body)
+		}
+	}
+
+	return email, nil
+}
@@ -2,11 +2,13 @@ package suite
 
 import (
 	"context"
+	profilev1 "github.com/m4rk1sov/protos/gen/go/profile"
 	ssov1 "github.com/m4rk1sov/protos/gen/go/sso"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"net"
 	"os"
+	apiv1 "sso/api/gen/go/sso"
 	"sso/internal/config"
 	"strconv"
 	"testing"
@@ -16,6 +18,21 @@ type Suite struct {
 	*testing.T
 	Cfg        *config.Config
 	AuthClient ssov1.AuthClient
+
+	PermissionClient ssov1.PermissionClient
+
+	PermissionAdminClient apiv1.PermissionAdminClient
+	ApiKeysClient         apiv1.ApiKeysClient
+	ImpersonationClient   apiv1.ImpersonationClient
+	AuditClient           apiv1.AuditClient
+	UserAdminClient       apiv1.UserAdminClient
+	AccountClient         apiv1.AccountClient
+	WebhooksClient        apiv1.WebhooksClient
+	VerificationClient    apiv1.VerificationClient
+	EmailQueueClient      apiv1.EmailQueueClient
+
+	// ProfileClient calls the profile service sso deletes the profiles in
+	ProfileClient profilev1.ProfileServiceClient
 }
 
 const (
@@ -52,6 +69,12 @@ func New(t *testing.T) (context.Context, *Suite) {
 		t.Fatalf("grpc server connection failed: %v", err)
 	}
 
+	profileConn, err := grpc.NewClient(cfg.Profile.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("profile connection failed: %v", err)
+	}
+	t.Cleanup(func() { _ = profileConn.Close() })
+
 	// gRPC client for server
 	authClient := ssov1.NewAuthClient(cc)
 
@@ -59,6 +82,20 @@ func New(t *testing.T) (context.Context, *Suite) {
 		T:          t,
 		Cfg:        cfg,
 		AuthClient: authClient,
+
+		PermissionClient: ssov1.NewPermissionClient(cc),
+
+		PermissionAdminClient: apiv1.NewPermissionAdminClient(cc),
+		ApiKeysClient:         apiv1.NewApiKeysClient(cc),
+		ImpersonationClient:   apiv1.NewImpersonationClient(cc),
+		AuditClient:           apiv1.NewAuditClient(cc),
+		UserAdminClient:       apiv1.NewUserAdminClient(cc),
+		AccountClient:         apiv1.NewAccountClient(cc),
+		WebhooksClient:        apiv1.NewWebhooksClient(cc),
+		VerificationClient:    apiv1.NewVerificationClient(cc),
+		EmailQueueClient:      apiv1.NewEmailQueueClient(cc),
+
+		ProfileClient: profilev1.NewProfileServiceClient(profileConn),
 	}
 }
 
@@ -0,0 +1,53 @@
+package suite
+
+import (
+	"context"
+
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/m4rk1sov/protos/gen/go/sso"
+	"google.golang.org/grpc/metadata"
+)
+
+const passLen = 10
+
+// User is a registered test user
+type User struct {
+	ID       int64
+	Email    string
+	Password string
+}
+
+// NewUser registers a user with random credentials
+func (s *Suite) NewUser(ctx context.Context) User {
+	s.Helper()
+
+	user := User{
+		Email:    gofakeit.Email(),
+		Password: gofakeit.Password(true, true, true, true, true, passLen),
+	}
+
+	resp, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: user.Email, Password: user.Password})
+	if err != nil {
+		s.Fatalf("register failed: %v", err)
+	}
+	user.ID = resp.GetUserId()
+
+	return user
+}
+
+// Login logs the user in and returns the context carrying the access token
+func (s *Suite) Login(ctx context.Context, user User, appID int32) context.Context {
+	s.Helper()
+
+	resp, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: user.Email, Password: user.Password, AppId: appID})
+	if err != nil {
+		s.Fatalf("login failed: %v", err)
+	}
+
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+resp.GetAccessToken())
+}
+
+// WithAPIKey returns the context authenticating the calls with the api key
+func WithAPIKey(ctx context.Context, key string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "x-api-key", key)
+}
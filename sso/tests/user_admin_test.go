@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/m4rk1sov/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	apiv1 "sso/api/gen/go/sso"
+	"sso/internal/lib/audit"
+	"sso/tests/suite"
+	"testing"
+)
+
+func TestListUsers_Unauthenticated(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.UserAdminClient.ListUsers(ctx, &apiv1.ListUsersRequest{})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestDisableUser_RegularUserDenied(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+respLogin.GetAccessToken())
+
+	_, err = st.UserAdminClient.DisableUser(authCtx, &apiv1.DisableUserRequest{
+		UserId: respReg.GetUserId() + 1,
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// the account is still usable
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+}
+
+func TestDisableUser_DisableAndEnable(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	admin := st.NewUser(ctx)
+	st.GrantRole(ctx, admin.ID, "admin")
+	adminCtx := st.Login(ctx, admin, appID)
+
+	user := st.NewUser(ctx)
+
+	respKey, err := st.ApiKeysClient.CreateApiKey(st.Login(ctx, user, appID), &apiv1.CreateApiKeyRequest{Name: "ci"})
+	require.NoError(t, err)
+	keyCtx := suite.WithAPIKey(ctx, respKey.GetKey())
+
+	respDisable, err := st.UserAdminClient.DisableUser(adminCtx, &apiv1.DisableUserRequest{UserId: user.ID, Reason: "fraud"})
+	require.NoError(t, err)
+	assert.True(t, respDisable.GetChanged())
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: user.Email, Password: user.Password, AppId: appID})
+	require.Error(t, err)
+
+	// keys of the disabled user stop working too
+	_, err = st.ApiKeysClient.ListApiKeys(keyCtx, &apiv1.ListApiKeysRequest{})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	respEnable, err := st.UserAdminClient.EnableUser(adminCtx, &apiv1.EnableUserRequest{UserId: user.ID, Reason: "resolved"})
+	require.NoError(t, err)
+	assert.True(t, respEnable.GetChanged())
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: user.Email, Password: user.Password, AppId: appID})
+	require.NoError(t, err)
+	assert.NotEmpty(t, respLogin.GetAccessToken())
+
+	_, err = st.ApiKeysClient.ListApiKeys(keyCtx, &apiv1.ListApiKeysRequest{})
+	require.NoError(t, err)
+}
+
+func TestUserAdmin_PrivilegedTarget(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	// may manage users, but is not an admin
+	manager := st.NewUser(ctx)
+	st.GrantPermission(ctx, manager.ID, "users:manage")
+	managerCtx := st.Login(ctx, manager, appID)
+
+	admin := st.NewUser(ctx)
+	st.GrantRole(ctx, admin.ID, "admin")
+
+	_, err := st.UserAdminClient.DisableUser(managerCtx, &apiv1.DisableUserRequest{UserId: admin.ID, Reason: "takeover"})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = st.UserAdminClient.ForcePasswordReset(managerCtx, &apiv1.ForcePasswordResetRequest{UserId: admin.ID})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = st.UserAdminClient.DeleteUser(managerCtx, &apiv1.DeleteUserRequest{UserId: admin.ID})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// the admin can still login
+	st.Login(ctx, admin, appID)
+}
+
+func TestDeleteUser_AuditWithoutEmail(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	admin := st.NewUser(ctx)
+	st.GrantRole(ctx, admin.ID, "admin")
+
+	user := st.NewUser(ctx)
+
+	_, err := st.UserAdminClient.DeleteUser(st.Login(ctx, admin, appID), &apiv1.DeleteUserRequest{UserId: user.ID})
+	require.NoError(t, err)
+
+	// the removed account is found by the hash of its email, the email itself is not stored
+	assert.Equal(t, int64(1), st.Count(ctx,
+		`SELECT count(*) FROM audit_events WHERE type = 'user.delete' AND actor_id = $1 AND payload->>'email_hash' = $2`,
+		admin.ID, audit.HashEmail(user.Email)))
+	assert.Equal(t, int64(0), st.Count(ctx,
+		`SELECT count(*) FROM audit_events WHERE type = 'user.delete' AND strpos(lower(payload::text), lower($1)) > 0`,
+		user.Email))
+}
@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/m4rk1sov/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net/url"
+	"regexp"
+	apiv1 "sso/api/gen/go/sso"
+	"sso/tests/suite"
+	"strconv"
+	"testing"
+)
+
+func TestSendEmailVerification_Cooldown(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	var header metadata.MD
+	respSend, err := st.AuthClient.SendEmailVerification(ctx, &ssov1.SendEmailVerificationRequest{
+		UserId: respReg.GetUserId(),
+		AppId:  appID,
+	}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.True(t, respSend.GetSuccess())
+	assert.Equal(t, int(st.Cfg.Verification.Cooldown.Seconds()), retryAfter(t, header))
+
+	// the second email waits for the cooldown
+	_, err = st.AuthClient.SendEmailVerification(ctx, &ssov1.SendEmailVerificationRequest{
+		UserId: respReg.GetUserId(),
+		AppId:  appID,
+	}, grpc.Header(&header))
+	require.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Positive(t, retryAfter(t, header))
+}
+
+func TestResendVerification_ByEmail(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	resp, err := st.VerificationClient.ResendVerification(ctx, &apiv1.ResendVerificationRequest{Email: email, AppId: appID})
+	require.NoError(t, err)
+	assert.Equal(t, int64(st.Cfg.Verification.Cooldown.Seconds()), resp.GetRetryAfterSeconds())
+
+	// the cooldown is reported without an error
+	resp, err = st.VerificationClient.ResendVerification(ctx, &apiv1.ResendVerificationRequest{Email: email, AppId: appID})
+	require.NoError(t, err)
+	assert.Positive(t, resp.GetRetryAfterSeconds())
+}
+
+func TestResendVerification_UnknownEmail(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	// the answer does not tell whether the email is registered
+	resp, err := st.VerificationClient.ResendVerification(ctx, &apiv1.ResendVerificationRequest{Email: gofakeit.Email()})
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.GetMessage())
+	assert.Positive(t, resp.GetRetryAfterSeconds())
+
+	_, err = st.VerificationClient.ResendVerification(ctx, &apiv1.ResendVerificationRequest{})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+var (
+	verificationLink = regexp.MustCompile(`/v1/auth/verification/confirm/([^\s"'<>]+)`)
+	resetLink        = regexp.MustCompile(`/v1/auth/reset-password\?token=([^\s"'<>&]+)`)
+)
+
+func TestEmailVerify_LinkFromEmail(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user := st.NewUser(ctx)
+
+	_, err := st.AuthClient.SendEmailVerification(ctx, &ssov1.SendEmailVerificationRequest{
+		UserId: user.ID,
+		AppId:  appID,
+	})
+	require.NoError(t, err)
+
+	token := emailToken(t, st.LastEmail(user.Email), verificationLink)
+
+	resp, err := st.AuthClient.EmailVerify(ctx, &ssov1.EmailVerifyRequest{Token: token})
+	require.NoError(t, err)
+	assert.True(t, resp.GetActivated())
+}
+
+func TestResetPassword_LinkFromEmail(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user := st.NewUser(ctx)
+
+	_, err := st.AuthClient.ForgotPassword(ctx, &ssov1.ForgotPasswordRequest{Email: user.Email, AppId: appID})
+	require.NoError(t, err)
+
+	token := emailToken(t, st.LastEmail(user.Email), resetLink)
+	newPassword := randomFakePassword()
+
+	_, err = st.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{Token: token, NewPassword: newPassword})
+	require.NoError(t, err)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: user.Email, Password: user.Password, AppId: appID})
+	require.Error(t, err)
+
+	user.Password = newPassword
+	st.Login(ctx, user, appID)
+
+	// the token works once
+	_, err = st.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{Token: token, NewPassword: randomFakePassword()})
+	require.Error(t, err)
+}
+
+// emailToken returns the token of the link in the email
+func emailToken(t *testing.T, email suite.Email, link *regexp.Regexp) string {
+	t.Helper()
+
+	match := link.FindStringSubmatch(email.Text + email.HTML)
+	require.Len(t, match, 2, "no link in the email %q", email.Subject)
+
+	token, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+	return token
+}
+
+func retryAfter(t *testing.T, header metadata.MD) int {
+	t.Helper()
+
+	values := header.Get("retry-after")
+	require.Len(t, values, 1)
+
+	seconds, err := strconv.Atoi(values[0])
+	require.NoError(t, err)
+	return seconds
+}
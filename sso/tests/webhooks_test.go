@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/m4rk1sov/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	apiv1 "sso/api/gen/go/sso"
+	"sso/tests/suite"
+	"testing"
+)
+
+func TestCreateWebhook_Unauthenticated(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.WebhooksClient.CreateWebhook(ctx, &apiv1.CreateWebhookRequest{
+		AppId: appID,
+		Url:   "https://example.com/hooks/sso",
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestWebhooks_RegularUserDenied(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+respLogin.GetAccessToken())
+
+	_, err = st.WebhooksClient.CreateWebhook(authCtx, &apiv1.CreateWebhookRequest{
+		AppId: appID,
+		Url:   "https://example.com/hooks/sso",
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = st.WebhooksClient.ReplayWebhookDelivery(authCtx, &apiv1.ReplayWebhookDeliveryRequest{DeliveryId: 1})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}